@@ -13,8 +13,15 @@ import (
 
 	"github.com/gosiva/hardlink-ui/internal/api"
 	"github.com/gosiva/hardlink-ui/internal/config"
+	"github.com/gosiva/hardlink-ui/internal/dedup"
+	"github.com/gosiva/hardlink-ui/internal/fsutil"
+	"github.com/gosiva/hardlink-ui/internal/indexer"
+	"github.com/gosiva/hardlink-ui/internal/jobs"
+	"github.com/gosiva/hardlink-ui/internal/journal"
 	"github.com/gosiva/hardlink-ui/internal/scanner"
+	"github.com/gosiva/hardlink-ui/internal/scheduler"
 	"github.com/gosiva/hardlink-ui/internal/storage"
+	"github.com/gosiva/hardlink-ui/internal/trash"
 )
 
 func main() {
@@ -30,6 +37,9 @@ func main() {
 		log.Fatalf("Data root does not exist: %s - %v", cfg.DataRoot, err)
 	}
 
+	// The trash is skipped by every walk
+	fsutil.SetTrashDir(cfg.TrashDir)
+
 	// Initialize database
 	db, err := storage.New(cfg.DBPath)
 	if err != nil {
@@ -42,8 +52,62 @@ func main() {
 		log.Fatalf("Failed to initialize admin user: %v", err)
 	}
 
+	// Repair the conversions interrupted by a crash
+	fileJournal := journal.New(db, cfg.DataRoot)
+	if count, err := fileJournal.RecoverReplaces(); err != nil {
+		log.Printf("Failed to recover interrupted conversions: %v", err)
+	} else if count > 0 {
+		log.Printf("Recovered %d interrupted conversion(s)", count)
+	}
+
 	// Create scanner
 	scan := scanner.NewScanner(db, cfg.DataRoot)
+	if err := scan.RecoverInterruptedJobs(); err != nil {
+		log.Printf("Failed to recover interrupted scan jobs: %v", err)
+	}
+
+	// Bulk file operations run as background jobs
+	runner := jobs.NewRunner(db)
+	if err := runner.RecoverInterruptedJobs(); err != nil {
+		log.Printf("Failed to recover interrupted jobs: %v", err)
+	}
+
+	// Evaluate the conversion rules after each completed scan
+	verify, err := scanner.ParseVerifyPolicy(cfg.VerifyPolicy)
+	if err != nil {
+		log.Printf("Invalid VERIFY_POLICY, using %s: %v", scanner.VerifyQuick, err)
+		verify = scanner.VerifyQuick
+	}
+	metadata, err := dedup.ParseMetadataPolicy(cfg.MetadataPolicy)
+	if err != nil {
+		log.Printf("Invalid METADATA_POLICY, using %s: %v", dedup.MetadataWarn, err)
+		metadata = dedup.MetadataWarn
+	}
+	engine := dedup.NewEngine(db, cfg.DataRoot, verify, metadata)
+	scan.OnComplete(engine.OnScanComplete)
+
+	// Background tasks are stopped on shutdown
+	bgCtx, bgCancel := context.WithCancel(context.Background())
+	defer bgCancel()
+
+	// Repair the temporary hardlinks left without intent
+	go func() {
+		if count := fileJournal.SweepTempLinks(bgCtx); count > 0 {
+			log.Printf("Repaired %d leftover temporary hardlink(s)", count)
+		}
+	}()
+
+	// Start inode indexer
+	idx := indexer.NewIndexer(db, cfg.DataRoot, time.Duration(cfg.IndexInterval)*time.Second)
+	go idx.Run(bgCtx)
+
+	// Start scheduled scans
+	sched := scheduler.NewScheduler(db, scan)
+	go sched.Run(bgCtx)
+
+	// Purge the trash items older than the retention
+	bin := trash.New(db, cfg.DataRoot, time.Duration(cfg.TrashRetentionDays)*24*time.Hour)
+	go bin.Run(bgCtx)
 
 	// Determine web path
 	webPath := os.Getenv("WEB_PATH")
@@ -69,7 +133,7 @@ func main() {
 	}
 
 	// Initialize router
-	router, err := api.Router(db, cfg, scan, absWebPath)
+	router, err := api.Router(db, cfg, scan, idx, engine, runner, absWebPath)
 	if err != nil {
 		log.Fatalf("Failed to initialize router: %v", err)
 	}
@@ -114,6 +178,13 @@ func main() {
 	<-quit
 
 	log.Println("Shutting down server...")
+	bgCancel()
+
+	// Paused scans resume from their checkpoint after the restart
+	scan.Shutdown()
+
+	// Running jobs are cancelled and keep the report of the items done
+	runner.Shutdown()
 
 	// Graceful shutdown
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
@@ -8,6 +8,11 @@ require (
 	github.com/mattn/go-sqlite3 v1.14.22
 	github.com/pquerna/otp v1.4.0
 	golang.org/x/crypto v0.21.0
+	lukechampine.com/blake3 v1.4.1
 )
 
-require github.com/boombuler/barcode v1.0.1 // indirect
+require (
+	github.com/boombuler/barcode v1.0.1 // indirect
+	github.com/klauspost/cpuid/v2 v2.2.10 // indirect
+	golang.org/x/sys v0.30.0 // indirect
+)
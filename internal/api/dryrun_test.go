@@ -0,0 +1,193 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/gosiva/hardlink-ui/internal/config"
+	"github.com/gosiva/hardlink-ui/internal/dedup"
+	"github.com/gosiva/hardlink-ui/internal/scanner"
+	"github.com/gosiva/hardlink-ui/internal/storage"
+)
+
+// TestDryRun verifies that dry runs report the plan of a conversion or a
+// folder hardlinking without touching the filesystem
+func TestDryRun(t *testing.T) {
+	tmpDir := t.TempDir()
+	dbPath := filepath.Join(tmpDir, "test.db")
+	dataDir := filepath.Join(tmpDir, "data")
+
+	files := map[string]string{
+		"dup/a.bin":       "identical content",
+		"dup/b.bin":       "identical content",
+		"dup/c.bin":       "different content",
+		"src/one.mkv":     "one",
+		"src/sub/two.nfo": "two",
+		"dest/one.mkv":    "already there",
+	}
+	for name, content := range files {
+		path := filepath.Join(dataDir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Failed to create dir: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+	}
+
+	db, err := storage.New(dbPath)
+	if err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+	defer db.Close()
+
+	cfg := &config.Config{DataRoot: dataDir, VerifyPolicy: "full", MetadataPolicy: "warn"}
+	duplicates := NewDuplicatesHandler(db, cfg, scanner.NewScanner(db, dataDir), nil)
+	hardlinks := NewHardlinkHandler(db, cfg, nil)
+
+	post := func(handler http.HandlerFunc, body string, resp interface{}) {
+		t.Helper()
+		req := httptest.NewRequest("POST", "/", bytes.NewBufferString(body))
+		w := httptest.NewRecorder()
+		handler(w, req)
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+		}
+		if err := json.NewDecoder(w.Body).Decode(resp); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+	}
+
+	var convert struct {
+		DryRun     bool               `json:"dry_run"`
+		Created    int                `json:"created"`
+		Planned    int                `json:"planned"`
+		BytesSaved int64              `json:"bytes_saved"`
+		Files      []dedup.FileReport `json:"files"`
+	}
+	post(duplicates.ConvertDuplicates, `{"dry_run":true,"groups":[{"master":"/dup/a.bin","others":["/dup/b.bin","/dup/c.bin"]}]}`, &convert)
+
+	if !convert.DryRun || convert.Created != 0 || convert.Planned != 1 || len(convert.Files) != 2 {
+		t.Fatalf("Expected 1 planned file, got %+v", convert)
+	}
+	if convert.BytesSaved != int64(len("identical content")) {
+		t.Errorf("Expected projected bytes saved %d, got %d", len("identical content"), convert.BytesSaved)
+	}
+	if convert.Files[0].Status != dedup.StatusPlanned || convert.Files[1].Status != dedup.StatusFailed {
+		t.Errorf("Expected planned then failed files, got %+v", convert.Files)
+	}
+
+	a, _ := os.Stat(filepath.Join(dataDir, "dup/a.bin"))
+	b, _ := os.Stat(filepath.Join(dataDir, "dup/b.bin"))
+	if os.SameFile(a, b) {
+		t.Error("Expected dry run to leave the duplicate untouched")
+	}
+	if _, total, _ := db.ListOperations(10, 0); total != 0 {
+		t.Errorf("Expected no journaled operation, got %d", total)
+	}
+
+	var folder struct {
+		DryRun   bool           `json:"dry_run"`
+		Created  int            `json:"created"`
+		Planned  int            `json:"planned"`
+		Existing int            `json:"existing"`
+		Plan     []LinkPlanItem `json:"plan"`
+	}
+	post(hardlinks.CreateHardlinksFolder, `{"source":"/src","dest_root":"/dest","dry_run":true}`, &folder)
+
+	if !folder.DryRun || folder.Created != 0 || folder.Planned != 1 || folder.Existing != 1 || len(folder.Plan) != 2 {
+		t.Fatalf("Expected 1 planned and 1 existing file, got %+v", folder)
+	}
+	for _, item := range folder.Plan {
+		want := map[string]string{"/dest/one.mkv": "exists", "/dest/sub/two.nfo": "planned"}[item.Dest]
+		if item.Status != want {
+			t.Errorf("Expected %s to be %q, got %+v", item.Dest, want, item)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dataDir, "dest/sub")); !os.IsNotExist(err) {
+		t.Errorf("Expected dry run not to create directories, got %v", err)
+	}
+}
+
+// TestConvertTwice verifies that converting a group again only skips its
+// files, already linked to the master, and that the projection of a dry run
+// counts the data of an inode once
+func TestConvertTwice(t *testing.T) {
+	tmpDir := t.TempDir()
+	dataDir := filepath.Join(tmpDir, "data")
+	content := "identical content"
+
+	for _, name := range []string{"dup/a.bin", "dup/b.bin"} {
+		path := filepath.Join(dataDir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Failed to create dir: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+	}
+	// b.bin and c.bin share an inode: its data is only freed with both
+	if err := os.Link(filepath.Join(dataDir, "dup/b.bin"), filepath.Join(dataDir, "dup/c.bin")); err != nil {
+		t.Fatalf("Failed to link: %v", err)
+	}
+
+	db, err := storage.New(filepath.Join(tmpDir, "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+	defer db.Close()
+
+	cfg := &config.Config{DataRoot: dataDir, VerifyPolicy: "full", MetadataPolicy: "warn"}
+	duplicates := NewDuplicatesHandler(db, cfg, scanner.NewScanner(db, dataDir), nil)
+
+	type conversion struct {
+		Created    int      `json:"created"`
+		Planned    int      `json:"planned"`
+		Skipped    int      `json:"skipped"`
+		BytesSaved int64    `json:"bytes_saved"`
+		Errors     []string `json:"errors"`
+	}
+	convert := func(dryRun bool) conversion {
+		t.Helper()
+		body, _ := json.Marshal(map[string]interface{}{
+			"dry_run": dryRun,
+			"groups":  []dedup.Group{{Master: "/dup/a.bin", Others: []string{"/dup/b.bin", "/dup/c.bin"}}},
+		})
+		req := httptest.NewRequest("POST", "/api/duplicates/convert", bytes.NewReader(body))
+		w := httptest.NewRecorder()
+		duplicates.ConvertDuplicates(w, req)
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+		}
+		var resp conversion
+		json.NewDecoder(w.Body).Decode(&resp)
+		return resp
+	}
+
+	size := int64(len(content))
+	tests := []struct {
+		name   string
+		dryRun bool
+		want   conversion
+	}{
+		{"preview", true, conversion{Planned: 2, BytesSaved: size}},
+		{"convert", false, conversion{Created: 2, BytesSaved: size}},
+		{"preview again", true, conversion{Skipped: 2}},
+		{"convert again", false, conversion{Skipped: 2}},
+	}
+	for _, tt := range tests {
+		if got := convert(tt.dryRun); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: expected %+v, got %+v", tt.name, tt.want, got)
+		}
+	}
+
+	if _, total, _ := db.ListOperations(10, 0); total != 2 {
+		t.Errorf("Expected the 2 conversions journaled once, got %d operations", total)
+	}
+}
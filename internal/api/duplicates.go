@@ -1,19 +1,20 @@
 package api
 
 import (
-    "crypto/rand"
-    "encoding/hex"
+    "context"
     "encoding/json"
+    "errors"
     "fmt"
     "log"
     "net/http"
-    "os"
-    "path/filepath"
     "strconv"
     "strings"
     "time"
 
+    "github.com/go-chi/chi/v5"
     "github.com/gosiva/hardlink-ui/internal/config"
+    "github.com/gosiva/hardlink-ui/internal/dedup"
+    "github.com/gosiva/hardlink-ui/internal/jobs"
     "github.com/gosiva/hardlink-ui/internal/scanner"
     "github.com/gosiva/hardlink-ui/internal/storage"
 )
@@ -23,19 +24,32 @@ type DuplicatesHandler struct {
     db      *storage.DB
     cfg     *config.Config
     scanner *scanner.Scanner
+    jobs    *jobs.Runner
 }
 
-// NewDuplicatesHandler creates a new duplicates handler
-func NewDuplicatesHandler(db *storage.DB, cfg *config.Config, scan *scanner.Scanner) *DuplicatesHandler {
+// NewDuplicatesHandler creates a new duplicates handler. Without a job
+// runner, conversions can't run in the background.
+func NewDuplicatesHandler(db *storage.DB, cfg *config.Config, scan *scanner.Scanner, runner *jobs.Runner) *DuplicatesHandler {
     return &DuplicatesHandler{
         db:      db,
         cfg:     cfg,
         scanner: scan,
+        jobs:    runner,
     }
 }
 
-// StartScan starts a duplicate scan job
+// StartScan starts a duplicate scan job. Options come from the query string
+// or, for POST requests, from a JSON body with the scanner.ScanOptions fields.
 func (h *DuplicatesHandler) StartScan(w http.ResponseWriter, r *http.Request) {
+    opts := h.scanOptionsFromQuery(r)
+
+    if r.Method == http.MethodPost && r.ContentLength != 0 {
+        if err := json.NewDecoder(r.Body).Decode(&opts); err != nil {
+            JSONError(w, http.StatusBadRequest, "Invalid request")
+            return
+        }
+    }
+
     // Generate job ID
     jobID, err := generateJobID()
     if err != nil {
@@ -44,123 +58,316 @@ func (h *DuplicatesHandler) StartScan(w http.ResponseWriter, r *http.Request) {
     }
 
     // Start scan in background
-    if err := h.scanner.StartScan(jobID); err != nil {
+    if err := h.scanner.StartScanWithOptions(jobID, opts); err != nil {
+        if errors.Is(err, scanner.ErrInvalidOptions) {
+            JSONError(w, http.StatusBadRequest, err.Error())
+            return
+        }
         JSONError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to start scan: %v", err))
         return
     }
 
-    log.Printf("DUPSCAN START job=%s by %s", jobID, GetUsername(r))
+    log.Printf("DUPSCAN START job=%s force_rehash=%v workers=%d read_limit=%d low_priority=%v verify=%s roots=%v exclude=%v min_size=%d max_size=%d extensions=%v by %s",
+        jobID, opts.ForceRehash, opts.Workers, opts.ReadLimit, opts.LowPriority, opts.Verify,
+        opts.Roots, opts.Exclude, opts.MinSize, opts.MaxSize, opts.Extensions, GetUsername(r))
 
     JSONResponse(w, http.StatusOK, map[string]string{
         "job_id": jobID,
     })
 }
 
-// GetProgress streams scan progress via SSE
-func (h *DuplicatesHandler) GetProgress(w http.ResponseWriter, r *http.Request) {
-    jobID := r.URL.Query().Get("job_id")
-    if jobID == "" {
-        parts := strings.Split(r.URL.Path, "/")
-        if len(parts) > 0 {
-            lastPart := parts[len(parts)-1]
-            if lastPart != "" && lastPart != "progress" {
-                jobID = lastPart
-            }
+// defaultScanOptions returns the scan options configured for the server
+func defaultScanOptions(cfg *config.Config) scanner.ScanOptions {
+    return scanner.ScanOptions{
+        Workers:     cfg.ScanWorkers,
+        ReadLimit:   cfg.ScanReadLimit,
+        LowPriority: cfg.ScanLowPriority,
+        Verify:      scanner.VerifyPolicy(cfg.VerifyPolicy),
+        Master:      scanner.MasterStrategy(cfg.MasterStrategy),
+        PreferPaths: cfg.PreferPaths,
+    }
+}
+
+// scanOptionsFromQuery applies query parameter overrides to the default scan options:
+// force_rehash=1 ignores the hash cache, workers=N, read_limit=bytes/s, low_priority=1,
+// root and exclude (repeatable), min_size and max_size (e.g. 100M), ext=mkv,mp4, verify=policy,
+// master=strategy and prefer (repeatable) for the prefer-path strategy
+func (h *DuplicatesHandler) scanOptionsFromQuery(r *http.Request) scanner.ScanOptions {
+    opts := defaultScanOptions(h.cfg)
+    query := r.URL.Query()
+
+    opts.ForceRehash = isTruthy(query.Get("force_rehash"))
+
+    if v := query.Get("workers"); v != "" {
+        if n, err := strconv.Atoi(v); err == nil && n > 0 {
+            opts.Workers = n
+        }
+    }
+    if v := query.Get("read_limit"); v != "" {
+        if n, err := strconv.ParseInt(v, 10, 64); err == nil && n >= 0 {
+            opts.ReadLimit = n
+        }
+    }
+    if v := query.Get("low_priority"); v != "" {
+        opts.LowPriority = isTruthy(v)
+    }
+
+    if v := query.Get("verify"); v != "" {
+        opts.Verify = scanner.VerifyPolicy(v)
+    }
+
+    opts.Roots = query["root"]
+    opts.Exclude = query["exclude"]
+    if v := query.Get("min_size"); v != "" {
+        if n, err := config.ParseByteSize(v); err == nil {
+            opts.MinSize = n
+        }
+    }
+    if v := query.Get("max_size"); v != "" {
+        if n, err := config.ParseByteSize(v); err == nil {
+            opts.MaxSize = n
         }
     }
+    for _, v := range query["ext"] {
+        opts.Extensions = append(opts.Extensions, strings.Split(v, ",")...)
+    }
 
-    if jobID == "" {
-        JSONError(w, http.StatusBadRequest, "Job ID is required")
-        return
+    if v := query.Get("master"); v != "" {
+        opts.Master = scanner.MasterStrategy(v)
+    }
+    if prefer := query["prefer"]; len(prefer) > 0 {
+        opts.PreferPaths = prefer
     }
 
-    username := GetUsername(r)
-    remoteAddr := r.RemoteAddr
+    return opts
+}
 
-    progress := h.scanner.GetProgress(jobID)
-    if progress == nil {
-        JSONError(w, http.StatusNotFound, "Job not found")
+// CancelScan stops a running or paused scan job and discards its progress
+func (h *DuplicatesHandler) CancelScan(w http.ResponseWriter, r *http.Request) {
+    jobID := chi.URLParam(r, "job_id")
+
+    if err := h.scanner.CancelScan(jobID); err != nil {
+        scanControlError(w, err)
         return
     }
 
-    w.Header().Set("Content-Type", "text/event-stream; charset=utf-8")
-    w.Header().Set("Cache-Control", "no-cache, no-transform")
-    w.Header().Set("Connection", "keep-alive")
-    w.Header().Set("X-Accel-Buffering", "no")
-    w.Header().Set("Content-Encoding", "identity")
+    log.Printf("DUPSCAN CANCEL job=%s by %s", jobID, GetUsername(r))
+
+    JSONResponse(w, http.StatusOK, map[string]string{
+        "job_id": jobID,
+        "status": "cancelled",
+    })
+}
+
+// PauseScan checkpoints a running scan job so it can be resumed later
+func (h *DuplicatesHandler) PauseScan(w http.ResponseWriter, r *http.Request) {
+    jobID := chi.URLParam(r, "job_id")
 
-    origin := r.Header.Get("Origin")
-    if origin != "" && isAllowedOrigin(origin, r.Host) {
-        w.Header().Set("Access-Control-Allow-Origin", origin)
-        w.Header().Set("Access-Control-Allow-Credentials", "true")
+    if err := h.scanner.PauseScan(jobID); err != nil {
+        scanControlError(w, err)
+        return
     }
 
-    flusher, ok := w.(http.Flusher)
-    if !ok {
-        log.Printf("SSE CONNECT job=%s user=%s remote=%s flusher=NO", jobID, username, remoteAddr)
-        JSONError(w, http.StatusInternalServerError, "Streaming not supported")
+    log.Printf("DUPSCAN PAUSE job=%s by %s", jobID, GetUsername(r))
+
+    // The job stops once in-flight files are hashed, progress reports "paused"
+    JSONResponse(w, http.StatusAccepted, map[string]string{
+        "job_id": jobID,
+        "status": "pausing",
+    })
+}
+
+// ResumeScan restarts a paused scan job from its checkpoint
+func (h *DuplicatesHandler) ResumeScan(w http.ResponseWriter, r *http.Request) {
+    jobID := chi.URLParam(r, "job_id")
+
+    if err := h.scanner.ResumeScan(jobID); err != nil {
+        scanControlError(w, err)
         return
     }
 
-    log.Printf("SSE CONNECT job=%s user=%s remote=%s flusher=YES", jobID, username, remoteAddr)
+    log.Printf("DUPSCAN RESUME job=%s by %s", jobID, GetUsername(r))
 
-    fmt.Fprintf(w, ": ping\n\n")
-    flusher.Flush()
+    JSONResponse(w, http.StatusOK, map[string]string{
+        "job_id": jobID,
+        "status": "running",
+    })
+}
 
-    connectedEvent := fmt.Sprintf("event: connected\ndata: {\"job_id\": \"%s\"}\n\n", jobID)
-    fmt.Fprint(w, connectedEvent)
-    flusher.Flush()
+// ScanJobSummary is a scan job as listed in the job history
+type ScanJobSummary struct {
+    JobID            string          `json:"job_id"`
+    Status           string          `json:"status"`
+    StartedAt        int64           `json:"started_at"`
+    CompletedAt      *int64          `json:"completed_at,omitempty"`
+    DurationSeconds  int64           `json:"duration_seconds"`
+    TotalFiles       int             `json:"total_files"`
+    Processed        int             `json:"processed"`
+    GroupsFound      int             `json:"groups_found"`
+    ReclaimableBytes int64           `json:"reclaimable_bytes"`
+    ReclaimableHuman string          `json:"reclaimable_human"`
+    Error            string          `json:"error,omitempty"`
+    Options          json.RawMessage `json:"options"`
+}
 
-    ticker := time.NewTicker(500 * time.Millisecond)
-    defer ticker.Stop()
+// ListJobs returns the scan job history, most recent first.
+// Query parameters: limit (default 20, max 100) and offset.
+func (h *DuplicatesHandler) ListJobs(w http.ResponseWriter, r *http.Request) {
+    limit := 20
+    if v, err := strconv.Atoi(r.URL.Query().Get("limit")); err == nil && v > 0 {
+        limit = min(v, 100)
+    }
+    offset := 0
+    if v, err := strconv.Atoi(r.URL.Query().Get("offset")); err == nil && v > 0 {
+        offset = v
+    }
 
-    heartbeatTicker := time.NewTicker(15 * time.Second)
-    defer heartbeatTicker.Stop()
+    jobs, total, err := h.db.ListScanJobs(limit, offset)
+    if err != nil {
+        JSONError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to list jobs: %v", err))
+        return
+    }
 
-    timeout := time.After(10 * time.Minute)
+    now := time.Now().Unix()
+    items := make([]ScanJobSummary, 0, len(jobs))
+    for _, job := range jobs {
+        item := ScanJobSummary{
+            JobID:            job.JobID,
+            Status:           job.Status,
+            StartedAt:        job.StartedAt,
+            TotalFiles:       job.TotalFiles,
+            Processed:        job.Progress,
+            GroupsFound:      job.GroupsFound,
+            ReclaimableBytes: job.ReclaimableBytes,
+            ReclaimableHuman: humanSize(job.ReclaimableBytes),
+            Error:            job.Error.String,
+            Options:          json.RawMessage(job.Options),
+        }
+        end := now
+        if job.CompletedAt.Valid {
+            completedAt := job.CompletedAt.Int64
+            item.CompletedAt = &completedAt
+            end = completedAt
+        }
+        item.DurationSeconds = end - job.StartedAt
+        items = append(items, item)
+    }
 
-    lastProgressStatus := ""
-    eventCount := 0
+    JSONResponse(w, http.StatusOK, map[string]interface{}{
+        "items":  items,
+        "total":  total,
+        "limit":  limit,
+        "offset": offset,
+    })
+}
 
-    for {
-        select {
-        case <-ticker.C:
-            progress := h.scanner.GetProgress(jobID)
-            if progress == nil {
-                fmt.Fprintf(w, "event: error\ndata: {\"error\": \"Job not found\"}\n\n")
-                flusher.Flush()
-                return
-            }
+// DeleteJob deletes a finished or paused scan job with its results
+func (h *DuplicatesHandler) DeleteJob(w http.ResponseWriter, r *http.Request) {
+    jobID := chi.URLParam(r, "job_id")
 
-            data, _ := json.Marshal(progress)
-            fmt.Fprintf(w, "data: %s\n\n", data)
-            flusher.Flush()
-            eventCount++
+    if err := h.scanner.DeleteJob(jobID); err != nil {
+        scanControlError(w, err)
+        return
+    }
 
-            if progress.Status != lastProgressStatus {
-                log.Printf("SSE SENT job=%s event=progress status=%s processed=%d total=%d",
-                    jobID, progress.Status, progress.Processed, progress.TotalFiles)
-                lastProgressStatus = progress.Status
-            }
+    log.Printf("DUPJOB DELETE job=%s by %s", jobID, GetUsername(r))
 
-            if progress.Status == "completed" || progress.Status == "failed" {
-                log.Printf("SSE DISCONNECT job=%s status=%s", jobID, progress.Status)
-                return
-            }
+    JSONResponse(w, http.StatusOK, map[string]interface{}{
+        "ok":     true,
+        "job_id": jobID,
+    })
+}
 
-        case <-heartbeatTicker.C:
-            fmt.Fprintf(w, ": heartbeat\n\n")
-            flusher.Flush()
+// PruneJobsRequest selects the finished scan jobs to delete
+type PruneJobsRequest struct {
+    // OlderThanDays deletes jobs started more than this many days ago, default 30
+    OlderThanDays *int `json:"older_than_days"`
+    // Keep always keeps this many most recent jobs
+    Keep int `json:"keep"`
+}
 
-        case <-timeout:
-            fmt.Fprintf(w, "event: timeout\ndata: {\"error\": \"Timeout\"}\n\n")
-            flusher.Flush()
+// PruneJobs deletes old finished scan jobs with their results
+func (h *DuplicatesHandler) PruneJobs(w http.ResponseWriter, r *http.Request) {
+    var req PruneJobsRequest
+    if r.ContentLength != 0 {
+        if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+            JSONError(w, http.StatusBadRequest, "Invalid request")
             return
+        }
+    }
 
-        case <-r.Context().Done():
-            return
+    days := 30
+    if req.OlderThanDays != nil {
+        days = *req.OlderThanDays
+    }
+    if days < 0 || req.Keep < 0 {
+        JSONError(w, http.StatusBadRequest, "older_than_days and keep must not be negative")
+        return
+    }
+
+    before := time.Now().Add(-time.Duration(days) * 24 * time.Hour)
+    deleted, err := h.scanner.PruneJobs(before, req.Keep)
+    if err != nil {
+        JSONError(w, http.StatusInternalServerError, err.Error())
+        return
+    }
+
+    log.Printf("DUPJOB PRUNE older_than_days=%d keep=%d deleted=%d by %s", days, req.Keep, deleted, GetUsername(r))
+
+    JSONResponse(w, http.StatusOK, map[string]interface{}{
+        "ok":      true,
+        "deleted": deleted,
+    })
+}
+
+// scanControlError maps scanner job control errors to HTTP statuses
+func scanControlError(w http.ResponseWriter, err error) {
+    switch {
+    case errors.Is(err, scanner.ErrJobNotFound):
+        JSONError(w, http.StatusNotFound, "Job not found")
+    case errors.Is(err, scanner.ErrJobNotRunning), errors.Is(err, scanner.ErrJobNotPaused),
+        errors.Is(err, scanner.ErrJobRunning):
+        JSONError(w, http.StatusConflict, err.Error())
+    default:
+        JSONError(w, http.StatusInternalServerError, err.Error())
+    }
+}
+
+// GetProgress streams scan progress via SSE
+func (h *DuplicatesHandler) GetProgress(w http.ResponseWriter, r *http.Request) {
+    jobID := r.URL.Query().Get("job_id")
+    if jobID == "" {
+        parts := strings.Split(r.URL.Path, "/")
+        if len(parts) > 0 {
+            lastPart := parts[len(parts)-1]
+            if lastPart != "" && lastPart != "progress" {
+                jobID = lastPart
+            }
         }
     }
+
+    if jobID == "" {
+        JSONError(w, http.StatusBadRequest, "Job ID is required")
+        return
+    }
+
+    if h.scanner.GetProgress(jobID) == nil {
+        JSONError(w, http.StatusNotFound, "Job not found")
+        return
+    }
+
+    streamProgress(w, r, jobID, func() *progressSnapshot {
+        progress := h.scanner.GetProgress(jobID)
+        if progress == nil {
+            return nil
+        }
+        return &progressSnapshot{
+            Data:      progress,
+            Status:    progress.Status,
+            Processed: progress.Processed,
+            Total:     progress.TotalFiles,
+        }
+    })
 }
 
 func isAllowedOrigin(origin, host string) bool {
@@ -204,17 +411,62 @@ func (h *DuplicatesHandler) GetResults(w http.ResponseWriter, r *http.Request) {
         return
     }
 
+    results, err := h.scanner.GetResults(jobID)
+    if err != nil {
+        JSONError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to load results: %v", err))
+        return
+    }
+
+    // Initialize as empty slice to avoid null in JSON when no duplicates were found
+    if results == nil {
+        results = make([]scanner.DuplicateGroup, 0)
+    }
+
     JSONResponse(w, http.StatusOK, map[string]interface{}{
-        "items": progress.Results,
+        "items": results,
+    })
+}
+
+// GetLatestResults returns the results of the most recently completed scan,
+// manual or scheduled
+func (h *DuplicatesHandler) GetLatestResults(w http.ResponseWriter, r *http.Request) {
+    job, err := h.db.GetLatestCompletedScanJob()
+    if err != nil {
+        JSONError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to load latest scan: %v", err))
+        return
+    }
+    if job == nil {
+        JSONError(w, http.StatusNotFound, "No completed scan")
+        return
+    }
+
+    results, err := h.scanner.GetResults(job.JobID)
+    if err != nil {
+        JSONError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to load results: %v", err))
+        return
+    }
+    if results == nil {
+        results = make([]scanner.DuplicateGroup, 0)
+    }
+
+    JSONResponse(w, http.StatusOK, map[string]interface{}{
+        "job_id":       job.JobID,
+        "completed_at": job.CompletedAt.Int64,
+        "items":        results,
     })
 }
 
 // ConvertDuplicatesRequest represents a conversion request
 type ConvertDuplicatesRequest struct {
-    Groups []struct {
-        Master string   `json:"master"`
-        Others []string `json:"others"`
-    } `json:"groups"`
+    Groups []dedup.Group `json:"groups"`
+    // Verify overrides the configured verification policy
+    Verify string `json:"verify"`
+    // Metadata overrides the configured metadata policy
+    Metadata string `json:"metadata"`
+    // DryRun returns the plan without touching the filesystem
+    DryRun bool `json:"dry_run"`
+    // Async runs the conversion as a background job and returns its ID
+    Async bool `json:"async"`
 }
 
 // ConvertDuplicates converts duplicate files to hardlinks
@@ -225,138 +477,118 @@ func (h *DuplicatesHandler) ConvertDuplicates(w http.ResponseWriter, r *http.Req
         return
     }
 
-    // Read PUID/PGID from environment
-    uidStr := os.Getenv("PUID")
-    gidStr := os.Getenv("PGID")
-    uid, _ := strconv.Atoi(uidStr)
-    gid, _ := strconv.Atoi(gidStr)
-
-    totalCreated := 0
-    totalBytesSaved := int64(0)
-    var errors []string
+    if req.Verify == "" {
+        req.Verify = h.cfg.VerifyPolicy
+    }
+    policy, err := scanner.ParseVerifyPolicy(req.Verify)
+    if err != nil {
+        JSONError(w, http.StatusBadRequest, err.Error())
+        return
+    }
 
-    log.Printf("DUPCONVERT START groups=%d by %s", len(req.Groups), GetUsername(r))
+    if req.Metadata == "" {
+        req.Metadata = h.cfg.MetadataPolicy
+    }
+    metadata, err := dedup.ParseMetadataPolicy(req.Metadata)
+    if err != nil {
+        JSONError(w, http.StatusBadRequest, err.Error())
+        return
+    }
 
+    // A group may override its master with a strategy, and carries the
+    // policy of the scan that found it
     for _, group := range req.Groups {
-        if group.Master == "" || len(group.Others) == 0 {
-            continue
-        }
-
-        masterPath := filepath.Join(h.cfg.DataRoot, strings.TrimPrefix(group.Master, "/"))
-        masterPath = filepath.Clean(masterPath)
-
-        if !strings.HasPrefix(masterPath, h.cfg.DataRoot) {
-            errors = append(errors, fmt.Sprintf("Master path outside root: %s", group.Master))
-            continue
-        }
-
-        masterInfo, err := os.Stat(masterPath)
-        if err != nil {
-            errors = append(errors, fmt.Sprintf("Failed to stat master %s: %v", group.Master, err))
-            continue
-        }
-
-        if !masterInfo.Mode().IsRegular() {
-            errors = append(errors, fmt.Sprintf("Master is not a regular file: %s", group.Master))
-            continue
-        }
-
-        // Normalize master permissions
-        if uid > 0 && gid > 0 {
-            _ = os.Chown(masterPath, uid, gid)
-            _ = os.Chmod(masterPath, 0o644)
-        }
-
-        for _, otherRel := range group.Others {
-            otherPath := filepath.Join(h.cfg.DataRoot, strings.TrimPrefix(otherRel, "/"))
-            otherPath = filepath.Clean(otherPath)
-
-            if !strings.HasPrefix(otherPath, h.cfg.DataRoot) {
-                errors = append(errors, fmt.Sprintf("Path outside root: %s", otherRel))
-                continue
-            }
-
-            otherInfo, err := os.Stat(otherPath)
-            if err != nil {
-                if os.IsNotExist(err) {
-                    errors = append(errors, fmt.Sprintf("File not found: %s", otherRel))
-                } else {
-                    errors = append(errors, fmt.Sprintf("Failed to stat %s: %v", otherRel, err))
-                }
-                continue
+        if group.Strategy != "" {
+            if _, err := scanner.ParseMasterStrategy(string(group.Strategy)); err != nil {
+                JSONError(w, http.StatusBadRequest, err.Error())
+                return
             }
-
-            size := otherInfo.Size()
-
-            // Normalize duplicate permissions
-            if uid > 0 && gid > 0 {
-                _ = os.Chown(otherPath, uid, gid)
-                _ = os.Chmod(otherPath, 0o644)
+        }
+        if group.Verify != "" {
+            if _, err := scanner.ParseVerifyPolicy(string(group.Verify)); err != nil {
+                JSONError(w, http.StatusBadRequest, err.Error())
+                return
             }
+        }
+    }
 
-            // Verify files are identical
-            identical, err := scanner.VerifyFilesIdentical(masterPath, otherPath)
-            if err != nil {
-                errors = append(errors, fmt.Sprintf("%s: verification error: %v", otherRel, err))
-                continue
-            }
+    if req.Async && h.jobs == nil {
+        JSONError(w, http.StatusServiceUnavailable, "Background jobs are not available")
+        return
+    }
 
-            if !identical {
-                errors = append(errors, fmt.Sprintf("%s: files are not identical", otherRel))
-                continue
-            }
+    username := GetUsername(r)
+    log.Printf("DUPCONVERT START groups=%d verify=%s metadata=%s dry_run=%v async=%v by %s",
+        len(req.Groups), policy, metadata, req.DryRun, req.Async, username)
 
-            // Create a temporary hardlink path
-            tmpPath := otherPath + ".tmp-hardlink"
+    converter := dedup.NewConverter(h.db, h.cfg.DataRoot, metadata)
+    converter.Username = username
+    converter.DryRun = req.DryRun
 
-            // 1. Create temporary hardlink
-            if err := os.Link(masterPath, tmpPath); err != nil {
-                errors = append(errors, fmt.Sprintf("%s: failed to create temporary hardlink: %v", otherRel, err))
-                continue
-            }
+    if !req.Async {
+        result := converter.Convert(req.Groups, policy)
+        JSONResponse(w, http.StatusOK, conversionResponse(result, policy, metadata, req.DryRun))
+        return
+    }
 
-            // ❌ DO NOT CHOWN THE HARDLINK — impossible and useless
-            // It shares the inode with master, so permissions come from master.
+    total := 0
+    for _, group := range req.Groups {
+        if group.Master != "" {
+            total += len(group.Others)
+        }
+    }
 
-            // 2. Remove original duplicate
-            if err := os.Remove(otherPath); err != nil {
-                _ = os.Remove(tmpPath)
-                errors = append(errors, fmt.Sprintf("%s: failed to remove original file: %v", otherRel, err))
-                continue
+    jobID, err := h.jobs.Start("convert", username, req, func(ctx context.Context, t *jobs.Tracker) (interface{}, error) {
+        t.SetTotal(total)
+        result := converter.ConvertContext(ctx, req.Groups, policy, func(file dedup.FileReport) {
+            var err error
+            if file.Status == dedup.StatusFailed || (file.Status == dedup.StatusSkipped && file.Error != dedup.AlreadyLinked) {
+                err = errors.New(file.Error)
             }
+            t.Step(file.Path, err)
+        })
+        return conversionResponse(result, policy, metadata, req.DryRun), nil
+    })
+    if err != nil {
+        JSONError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to start job: %v", err))
+        return
+    }
 
-            // 3. Rename temporary hardlink to original filename
-            if err := os.Rename(tmpPath, otherPath); err != nil {
-                _ = os.Remove(tmpPath)
-                errors = append(errors, fmt.Sprintf("%s: failed to rename temporary hardlink: %v", otherRel, err))
-                continue
-            }
+    JSONResponse(w, http.StatusAccepted, map[string]interface{}{
+        "ok":     true,
+        "job_id": jobID,
+    })
+}
 
-            totalCreated++
-            totalBytesSaved += size
-        }
+// conversionResponse builds the report of a conversion
+func conversionResponse(result dedup.Result, policy scanner.VerifyPolicy, metadata dedup.MetadataPolicy, dryRun bool) map[string]interface{} {
+    if result.Files == nil {
+        result.Files = make([]dedup.FileReport, 0)
     }
 
-    log.Printf("DUPCONVERT END created=%d bytes_saved=%d errors=%d", totalCreated, totalBytesSaved, len(errors))
+    log.Printf("DUPCONVERT END created=%d planned=%d skipped=%d bytes_saved=%d errors=%d",
+        result.Created, result.Planned, result.Skipped, result.BytesSaved, len(result.Errors))
 
     response := map[string]interface{}{
         "ok":                true,
-        "created":           totalCreated,
-        "bytes_saved":       totalBytesSaved,
-        "bytes_saved_human": humanSize(totalBytesSaved),
+        "dry_run":           dryRun,
+        "created":           result.Created,
+        "planned":           result.Planned,
+        "skipped":           result.Skipped,
+        "bytes_saved":       result.BytesSaved,
+        "bytes_saved_human": humanSize(result.BytesSaved),
+        "verify":            policy,
+        "metadata":          metadata,
+        "files":             result.Files,
     }
 
-    if len(errors) > 0 {
-        response["errors"] = errors
+    if len(result.Errors) > 0 {
+        response["errors"] = result.Errors
     }
 
-    JSONResponse(w, http.StatusOK, response)
+    return response
 }
 
 func generateJobID() (string, error) {
-    bytes := make([]byte, 16)
-    if _, err := rand.Read(bytes); err != nil {
-        return "", err
-    }
-    return hex.EncodeToString(bytes), nil
+    return scanner.NewJobID()
 }
@@ -0,0 +1,103 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/gosiva/hardlink-ui/internal/config"
+	"github.com/gosiva/hardlink-ui/internal/scanner"
+	"github.com/gosiva/hardlink-ui/internal/storage"
+)
+
+// TestPauseResumeCancelScan verifies that a paused scan resumes from its
+// checkpoint, even in a fresh scanner, and that finished jobs can't be cancelled
+func TestPauseResumeCancelScan(t *testing.T) {
+	tmpDir := t.TempDir()
+	dbPath := filepath.Join(tmpDir, "test.db")
+	dataDir := filepath.Join(tmpDir, "data")
+
+	if err := os.MkdirAll(dataDir, 0755); err != nil {
+		t.Fatalf("Failed to create data dir: %v", err)
+	}
+	// Two sets of identical files with different sizes
+	for i := 0; i < 4; i++ {
+		for set, content := range []string{"first set of identical files", "second set"} {
+			name := filepath.Join(dataDir, fmt.Sprintf("set%d-%d.txt", set, i))
+			if err := os.WriteFile(name, []byte(content), 0644); err != nil {
+				t.Fatalf("Failed to write %s: %v", name, err)
+			}
+		}
+	}
+
+	db, err := storage.New(dbPath)
+	if err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+	defer db.Close()
+
+	// A low read limit keeps the job running long enough to pause it
+	scan := scanner.NewScanner(db, dataDir)
+	opts := scanner.ScanOptions{Workers: 1, ReadLimit: 100}
+	if err := scan.StartScanWithOptions("control-job", opts); err != nil {
+		t.Fatalf("Failed to start scan: %v", err)
+	}
+	if err := scan.PauseScan("control-job"); err != nil {
+		t.Fatalf("Failed to pause scan: %v", err)
+	}
+
+	progress := waitForScan(t, scan, "control-job")
+	if progress.Status != "paused" {
+		t.Fatalf("Expected paused scan, got status %s (%s)", progress.Status, progress.Error)
+	}
+
+	// Resume from the checkpoint in a new scanner, like after a restart
+	restarted := scanner.NewScanner(db, dataDir)
+	if err := restarted.ResumeScan("control-job"); err != nil {
+		t.Fatalf("Failed to resume scan: %v", err)
+	}
+
+	progress = waitForScan(t, restarted, "control-job")
+	if progress.Status != "completed" {
+		t.Fatalf("Expected completed scan, got status %s (%s)", progress.Status, progress.Error)
+	}
+	if len(progress.Results) != 2 {
+		t.Fatalf("Expected 2 duplicate groups, got %d", len(progress.Results))
+	}
+	for _, group := range progress.Results {
+		if len(group.Others) != 3 {
+			t.Errorf("Expected 3 duplicates of %s, got %v", group.Master, group.Others)
+		}
+	}
+
+	cfg := &config.Config{DataRoot: dataDir}
+	handler := NewDuplicatesHandler(db, cfg, restarted, nil)
+	r := chi.NewRouter()
+	r.Delete("/api/duplicates/scan/{job_id}", handler.CancelScan)
+
+	tests := []struct {
+		name   string
+		jobID  string
+		status int
+	}{
+		{"completed job", "control-job", http.StatusConflict},
+		{"unknown job", "missing-job", http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("DELETE", "/api/duplicates/scan/"+tt.jobID, nil)
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			if w.Code != tt.status {
+				t.Errorf("Expected status %d, got %d: %s", tt.status, w.Code, strings.TrimSpace(w.Body.String()))
+			}
+		})
+	}
+}
@@ -0,0 +1,134 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/gosiva/hardlink-ui/internal/config"
+	"github.com/gosiva/hardlink-ui/internal/scanner"
+	"github.com/gosiva/hardlink-ui/internal/storage"
+)
+
+// TestScanJobHistory verifies listing, deleting and pruning scan jobs
+func TestScanJobHistory(t *testing.T) {
+	tmpDir := t.TempDir()
+	dbPath := filepath.Join(tmpDir, "test.db")
+	dataDir := filepath.Join(tmpDir, "data")
+
+	if err := os.MkdirAll(dataDir, 0755); err != nil {
+		t.Fatalf("Failed to create data dir: %v", err)
+	}
+	content := []byte("0123456789")
+	for _, name := range []string{"a.txt", "b.txt", "c.txt"} {
+		if err := os.WriteFile(filepath.Join(dataDir, name), content, 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+	}
+
+	db, err := storage.New(dbPath)
+	if err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+	defer db.Close()
+
+	scan := scanner.NewScanner(db, dataDir)
+	for _, jobID := range []string{"old-job", "new-job"} {
+		if err := scan.StartScan(jobID); err != nil {
+			t.Fatalf("Failed to start scan: %v", err)
+		}
+		if progress := waitForScan(t, scan, jobID); progress.Status != "completed" {
+			t.Fatalf("Expected completed scan, got status %s (%s)", progress.Status, progress.Error)
+		}
+	}
+	if _, err := db.Exec(`UPDATE scan_jobs SET started_at = started_at - 90 * 86400 WHERE job_id = 'old-job'`); err != nil {
+		t.Fatalf("Failed to age job: %v", err)
+	}
+
+	cfg := &config.Config{DataRoot: dataDir}
+	handler := NewDuplicatesHandler(db, cfg, scan, nil)
+	r := chi.NewRouter()
+	r.Get("/api/duplicates/jobs", handler.ListJobs)
+	r.Post("/api/duplicates/jobs/prune", handler.PruneJobs)
+	r.Delete("/api/duplicates/jobs/{job_id}", handler.DeleteJob)
+
+	type listResponse struct {
+		Items []ScanJobSummary `json:"items"`
+		Total int              `json:"total"`
+	}
+	list := func(query string) listResponse {
+		t.Helper()
+		req := httptest.NewRequest("GET", "/api/duplicates/jobs"+query, nil)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+		}
+		var resp listResponse
+		if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+		return resp
+	}
+
+	resp := list("?limit=1")
+	if resp.Total != 2 || len(resp.Items) != 1 {
+		t.Fatalf("Expected 1 of 2 jobs, got %d of %d", len(resp.Items), resp.Total)
+	}
+	job := resp.Items[0]
+	if job.JobID != "new-job" || job.Status != "completed" || job.GroupsFound != 1 {
+		t.Errorf("Unexpected most recent job: %+v", job)
+	}
+	// Three copies: converting frees two of them
+	if job.ReclaimableBytes != 2*int64(len(content)) {
+		t.Errorf("Expected %d reclaimable bytes, got %d", 2*len(content), job.ReclaimableBytes)
+	}
+
+	resp = list("?limit=1&offset=1")
+	if len(resp.Items) != 1 || resp.Items[0].JobID != "old-job" {
+		t.Fatalf("Expected old-job on the second page, got %+v", resp.Items)
+	}
+
+	// Pruning jobs older than 30 days only removes the aged job
+	req := httptest.NewRequest("POST", "/api/duplicates/jobs/prune", bytes.NewBufferString(`{"older_than_days":30}`))
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var pruned struct {
+		Deleted int `json:"deleted"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&pruned); err != nil || pruned.Deleted != 1 {
+		t.Fatalf("Expected 1 pruned job, got %d (%v)", pruned.Deleted, err)
+	}
+	if scan.GetProgress("old-job") != nil {
+		t.Error("Expected pruned job to be gone")
+	}
+
+	req = httptest.NewRequest("DELETE", "/api/duplicates/jobs/new-job", nil)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	if resp := list(""); resp.Total != 0 {
+		t.Errorf("Expected empty history, got %+v", resp.Items)
+	}
+	if groups, err := db.GetDuplicateGroups("new-job"); err != nil || len(groups) != 0 {
+		t.Errorf("Expected results to be deleted with the job, got %v (%v)", groups, err)
+	}
+
+	req = httptest.NewRequest("DELETE", "/api/duplicates/jobs/new-job", nil)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 for deleted job, got %d", w.Code)
+	}
+}
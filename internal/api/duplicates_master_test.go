@@ -0,0 +1,155 @@
+package api
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gosiva/hardlink-ui/internal/config"
+	"github.com/gosiva/hardlink-ui/internal/fsutil"
+	"github.com/gosiva/hardlink-ui/internal/scanner"
+	"github.com/gosiva/hardlink-ui/internal/storage"
+)
+
+// TestMasterStrategies verifies the master kept by each strategy, per scan
+// and overridden per group at conversion
+func TestMasterStrategies(t *testing.T) {
+	tmpDir := t.TempDir()
+	dbPath := filepath.Join(tmpDir, "test.db")
+	dataDir := filepath.Join(tmpDir, "data")
+
+	now := time.Now()
+	files := []struct {
+		name  string
+		mtime time.Time
+	}{
+		{"downloads/x.mkv", now.Add(-72 * time.Hour)},
+		{"media/x.mkv", now.Add(-48 * time.Hour)},
+		{"media/deep/dir/x.mkv", now.Add(-24 * time.Hour)},
+	}
+	for _, f := range files {
+		path := filepath.Join(dataDir, f.name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Failed to create dir: %v", err)
+		}
+		if err := os.WriteFile(path, []byte("same movie"), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", f.name, err)
+		}
+		if err := os.Chtimes(path, f.mtime, f.mtime); err != nil {
+			t.Fatalf("Failed to set mtime: %v", err)
+		}
+	}
+	// media/x.mkv already has a second link
+	if err := os.Link(filepath.Join(dataDir, "media/x.mkv"), filepath.Join(dataDir, "media/x-link.mkv")); err != nil {
+		t.Fatalf("Failed to create hardlink: %v", err)
+	}
+
+	// The default keeps the lowest inode
+	lowest := ""
+	var lowestIno uint64
+	for _, name := range []string{"/downloads/x.mkv", "/media/deep/dir/x.mkv", "/media/x-link.mkv"} {
+		info, err := os.Stat(filepath.Join(dataDir, name))
+		if err != nil {
+			t.Fatalf("Failed to stat %s: %v", name, err)
+		}
+		_, ino, _, _ := fsutil.Identity(info)
+		if lowest == "" || ino < lowestIno {
+			lowest, lowestIno = name, ino
+		}
+	}
+
+	db, err := storage.New(dbPath)
+	if err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+	defer db.Close()
+
+	scan := scanner.NewScanner(db, dataDir)
+
+	tests := []struct {
+		strategy scanner.MasterStrategy
+		prefer   []string
+		master   string
+	}{
+		{"", nil, lowest},
+		{scanner.MasterOldest, nil, "/downloads/x.mkv"},
+		{scanner.MasterNewest, nil, "/media/deep/dir/x.mkv"},
+		{scanner.MasterMostLinks, nil, "/media/x-link.mkv"},
+		{scanner.MasterPreferPath, []string{"/media/deep", "/media"}, "/media/deep/dir/x.mkv"},
+		{scanner.MasterPreferPath, []string{"/nowhere", "/downloads"}, "/downloads/x.mkv"},
+		{scanner.MasterShortestPath, nil, "/media/x.mkv"},
+	}
+
+	for i, tt := range tests {
+		name := string(tt.strategy)
+		if name == "" {
+			name = "default"
+		}
+		t.Run(name, func(t *testing.T) {
+			jobID := "master-" + string(rune('a'+i))
+			opts := scanner.ScanOptions{Master: tt.strategy, PreferPaths: tt.prefer}
+			if err := scan.StartScanWithOptions(jobID, opts); err != nil {
+				t.Fatalf("Failed to start scan: %v", err)
+			}
+			progress := waitForScan(t, scan, jobID)
+			if progress.Status != "completed" || len(progress.Results) != 1 {
+				t.Fatalf("Expected 1 group, got status %s (%s): %+v", progress.Status, progress.Error, progress.Results)
+			}
+
+			group := progress.Results[0]
+			if group.Master != tt.master {
+				t.Errorf("Expected master %s, got %s", tt.master, group.Master)
+			}
+			// Links of the master's inode are not duplicates of it
+			for _, other := range group.Others {
+				if other == group.Master || sameFile(dataDir, other, group.Master) {
+					t.Errorf("Expected %s not to share the master inode", other)
+				}
+			}
+		})
+	}
+
+	if err := scan.StartScanWithOptions("master-invalid", scanner.ScanOptions{Master: "largest"}); err == nil {
+		t.Error("Expected unknown strategy to be rejected")
+	}
+
+	cfg := &config.Config{DataRoot: dataDir, VerifyPolicy: "quick"}
+	handler := NewDuplicatesHandler(db, cfg, scan, nil)
+
+	convert := func(body string) int {
+		req := httptest.NewRequest("POST", "/api/duplicates/convert", bytes.NewBufferString(body))
+		w := httptest.NewRecorder()
+		handler.ConvertDuplicates(w, req)
+		return w.Code
+	}
+
+	if code := convert(`{"groups":[{"master":"/downloads/x.mkv","others":["/media/x.mkv"],"master_strategy":"largest"}]}`); code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for unknown strategy, got %d", code)
+	}
+
+	// The group's strategy replaces the master sent by the client
+	body := `{"groups":[{"master":"/downloads/x.mkv","others":["/media/x.mkv","/media/deep/dir/x.mkv"],"master_strategy":"newest-mtime"}]}`
+	if code := convert(body); code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", code)
+	}
+	for _, name := range []string{"/downloads/x.mkv", "/media/x.mkv"} {
+		if !sameFile(dataDir, name, "/media/deep/dir/x.mkv") {
+			t.Errorf("Expected %s to link to the newest file", name)
+		}
+	}
+	info, err := os.Stat(filepath.Join(dataDir, "downloads/x.mkv"))
+	if err != nil || !info.ModTime().Equal(files[2].mtime) {
+		t.Errorf("Expected the newest mtime to be kept, got %v (%v)", info, err)
+	}
+}
+
+// sameFile reports whether two paths relative to root are the same inode
+func sameFile(root, a, b string) bool {
+	infoA, errA := os.Stat(filepath.Join(root, a))
+	infoB, errB := os.Stat(filepath.Join(root, b))
+	return errA == nil && errB == nil && os.SameFile(infoA, infoB)
+}
@@ -0,0 +1,158 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gosiva/hardlink-ui/internal/config"
+	"github.com/gosiva/hardlink-ui/internal/dedup"
+	"github.com/gosiva/hardlink-ui/internal/fsutil"
+	"github.com/gosiva/hardlink-ui/internal/scanner"
+	"github.com/gosiva/hardlink-ui/internal/storage"
+)
+
+// TestMetadataPolicies verifies how each metadata policy handles files whose
+// mode, mtime or extended attributes differ from the master
+func TestMetadataPolicies(t *testing.T) {
+	tmpDir := t.TempDir()
+	dbPath := filepath.Join(tmpDir, "test.db")
+	dataDir := filepath.Join(tmpDir, "data")
+
+	if err := os.MkdirAll(dataDir, 0755); err != nil {
+		t.Fatalf("Failed to create data dir: %v", err)
+	}
+
+	old := time.Now().Add(-24 * time.Hour).Truncate(time.Second)
+	write := func(name string, mode os.FileMode, mtime time.Time) {
+		t.Helper()
+		path := filepath.Join(dataDir, name)
+		if err := os.WriteFile(path, []byte("identical content"), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+		if err := os.Chmod(path, mode); err != nil {
+			t.Fatalf("Failed to chmod %s: %v", name, err)
+		}
+		if err := os.Chtimes(path, mtime, mtime); err != nil {
+			t.Fatalf("Failed to set mtime of %s: %v", name, err)
+		}
+	}
+
+	now := time.Now().Truncate(time.Second)
+	write("refuse-master.bin", 0644, now)
+	write("refuse-other.bin", 0600, now)
+	write("refuse-mtime.bin", 0644, old)
+	write("warn-master.bin", 0644, now)
+	write("warn-other.bin", 0600, now)
+	write("union-master.bin", 0640, now)
+	write("union-other.bin", 0604, old)
+
+	// Extended attributes depend on the filesystem of the temp dir
+	xattrs := fsutil.SetXattr(filepath.Join(dataDir, "union-other.bin"), "user.origin", []byte("nas")) == nil
+
+	db, err := storage.New(dbPath)
+	if err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+	defer db.Close()
+
+	cfg := &config.Config{DataRoot: dataDir, VerifyPolicy: "quick", MetadataPolicy: "warn"}
+	handler := NewDuplicatesHandler(db, cfg, scanner.NewScanner(db, dataDir), nil)
+
+	type convertResponse struct {
+		Created int                `json:"created"`
+		Skipped int                `json:"skipped"`
+		Files   []dedup.FileReport `json:"files"`
+	}
+	convert := func(t *testing.T, body string, status int) convertResponse {
+		t.Helper()
+		req := httptest.NewRequest("POST", "/api/duplicates/convert", bytes.NewBufferString(body))
+		w := httptest.NewRecorder()
+		handler.ConvertDuplicates(w, req)
+		if w.Code != status {
+			t.Fatalf("Expected status %d, got %d: %s", status, w.Code, w.Body.String())
+		}
+		var resp convertResponse
+		if status == http.StatusOK {
+			if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+		}
+		return resp
+	}
+	hasConflict := func(report dedup.FileReport, field string) bool {
+		for _, c := range report.Conflicts {
+			if c.Field == field {
+				return true
+			}
+		}
+		return false
+	}
+	stat := func(t *testing.T, name string) os.FileInfo {
+		t.Helper()
+		info, err := os.Stat(filepath.Join(dataDir, name))
+		if err != nil {
+			t.Fatalf("Failed to stat %s: %v", name, err)
+		}
+		return info
+	}
+
+	convert(t, `{"metadata":"chaos","groups":[{"master":"/warn-master.bin","others":["/warn-other.bin"]}]}`, http.StatusBadRequest)
+
+	t.Run("refuse", func(t *testing.T) {
+		resp := convert(t, `{"metadata":"refuse","groups":[{"master":"/refuse-master.bin","others":["/refuse-other.bin","/refuse-mtime.bin"]}]}`, http.StatusOK)
+		if resp.Created != 1 || resp.Skipped != 1 || len(resp.Files) != 2 {
+			t.Fatalf("Expected 1 created and 1 skipped, got %+v", resp)
+		}
+		if f := resp.Files[0]; f.Status != dedup.StatusSkipped || !hasConflict(f, "mode") {
+			t.Errorf("Expected mode conflict to skip the file, got %+v", f)
+		}
+		// A different mtime alone doesn't block the conversion
+		if f := resp.Files[1]; f.Status != dedup.StatusConverted || !hasConflict(f, "mtime") {
+			t.Errorf("Expected converted file with an mtime conflict, got %+v", f)
+		}
+		if os.SameFile(stat(t, "refuse-master.bin"), stat(t, "refuse-other.bin")) {
+			t.Error("Expected skipped file to keep its inode")
+		}
+	})
+
+	t.Run("warn", func(t *testing.T) {
+		resp := convert(t, `{"groups":[{"master":"/warn-master.bin","others":["/warn-other.bin"]}]}`, http.StatusOK)
+		if resp.Created != 1 || len(resp.Files) != 1 || !hasConflict(resp.Files[0], "mode") {
+			t.Fatalf("Expected converted file with a mode conflict, got %+v", resp)
+		}
+		if mode := stat(t, "warn-other.bin").Mode().Perm(); mode != 0644 {
+			t.Errorf("Expected master mode 0644 to be kept, got %04o", mode)
+		}
+	})
+
+	t.Run("union", func(t *testing.T) {
+		resp := convert(t, `{"metadata":"union","groups":[{"master":"/union-master.bin","others":["/union-other.bin"]}]}`, http.StatusOK)
+		if resp.Created != 1 || len(resp.Files) != 1 {
+			t.Fatalf("Expected 1 converted file, got %+v", resp)
+		}
+		info := stat(t, "union-other.bin")
+		if mode := info.Mode().Perm(); mode != 0644 {
+			t.Errorf("Expected merged mode 0644, got %04o", mode)
+		}
+		if !info.ModTime().Equal(old) {
+			t.Errorf("Expected oldest mtime %v, got %v", old, info.ModTime())
+		}
+		if !xattrs {
+			t.Log("Extended attributes not supported, skipping xattr checks")
+			return
+		}
+		attrs, err := fsutil.Xattrs(filepath.Join(dataDir, "union-master.bin"))
+		if err != nil || string(attrs["user.origin"]) != "nas" {
+			t.Errorf("Expected merged xattr user.origin, got %v (%v)", attrs, err)
+		}
+		if merged := resp.Files[0].Merged; len(merged) != 3 {
+			t.Errorf("Expected mode, mtime and xattr to be merged, got %v", merged)
+		}
+	})
+}
@@ -0,0 +1,111 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gosiva/hardlink-ui/internal/config"
+	"github.com/gosiva/hardlink-ui/internal/scanner"
+	"github.com/gosiva/hardlink-ui/internal/storage"
+)
+
+// waitForScan polls the scanner until the job leaves the running state
+func waitForScan(t *testing.T, scan *scanner.Scanner, jobID string) *scanner.ScanProgress {
+	t.Helper()
+
+	deadline := time.Now().Add(10 * time.Second)
+	for time.Now().Before(deadline) {
+		progress := scan.GetProgress(jobID)
+		if progress != nil && progress.Status != "running" {
+			return progress
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	t.Fatalf("Scan job %s did not finish in time", jobID)
+	return nil
+}
+
+// TestDuplicateResultsSurviveRestart verifies that results are read back from
+// the database by a fresh scanner, as happens after a container restart
+func TestDuplicateResultsSurviveRestart(t *testing.T) {
+	tmpDir := t.TempDir()
+	dbPath := filepath.Join(tmpDir, "test.db")
+	dataDir := filepath.Join(tmpDir, "data")
+
+	if err := os.MkdirAll(filepath.Join(dataDir, "a"), 0755); err != nil {
+		t.Fatalf("Failed to create data dir: %v", err)
+	}
+	content := []byte("same content in both files")
+	for _, name := range []string{"a/one.txt", "two.txt"} {
+		if err := os.WriteFile(filepath.Join(dataDir, name), content, 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+	}
+
+	db, err := storage.New(dbPath)
+	if err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+	defer db.Close()
+
+	scan := scanner.NewScanner(db, dataDir)
+	if err := scan.StartScan("persist-job"); err != nil {
+		t.Fatalf("Failed to start scan: %v", err)
+	}
+
+	progress := waitForScan(t, scan, "persist-job")
+	if progress.Status != "completed" {
+		t.Fatalf("Expected completed scan, got status %s (%s)", progress.Status, progress.Error)
+	}
+
+	// A new scanner has no in-memory state, like after a restart
+	restarted := scanner.NewScanner(db, dataDir)
+	cfg := &config.Config{DataRoot: dataDir}
+	handler := NewDuplicatesHandler(db, cfg, restarted, nil)
+
+	req := httptest.NewRequest("GET", "/api/duplicates/results?job_id=persist-job", nil)
+	w := httptest.NewRecorder()
+	handler.GetResults(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var resp struct {
+		Items []scanner.DuplicateGroup `json:"items"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+
+	if len(resp.Items) != 1 {
+		t.Fatalf("Expected 1 duplicate group, got %d", len(resp.Items))
+	}
+
+	group := resp.Items[0]
+	if len(group.Others) != 1 {
+		t.Fatalf("Expected 1 duplicate besides the master, got %v", group.Others)
+	}
+	paths := map[string]bool{group.Master: true, group.Others[0]: true}
+	if !paths["/a/one.txt"] || !paths["/two.txt"] {
+		t.Errorf("Expected group of /a/one.txt and /two.txt, got master=%s others=%v", group.Master, group.Others)
+	}
+	if group.Size != int64(len(content)) {
+		t.Errorf("Expected size %d, got %d", len(content), group.Size)
+	}
+
+	// A job whose results can't be loaded is still reported, with the error
+	if _, err := db.Exec(`DROP TABLE duplicate_groups`); err != nil {
+		t.Fatalf("Failed to drop results: %v", err)
+	}
+	progress = scanner.NewScanner(db, dataDir).GetProgress("persist-job")
+	if progress == nil || progress.Status != "completed" || progress.Error == "" || progress.Results != nil {
+		t.Errorf("Expected the job with a load error, got %+v", progress)
+	}
+}
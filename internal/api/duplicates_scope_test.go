@@ -0,0 +1,128 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gosiva/hardlink-ui/internal/config"
+	"github.com/gosiva/hardlink-ui/internal/scanner"
+	"github.com/gosiva/hardlink-ui/internal/storage"
+)
+
+// TestScopedScan verifies that roots, excludes and extension filters limit
+// the files considered by a scan, and that invalid options are rejected
+func TestScopedScan(t *testing.T) {
+	tmpDir := t.TempDir()
+	dbPath := filepath.Join(tmpDir, "test.db")
+	dataDir := filepath.Join(tmpDir, "data")
+
+	files := map[string]string{
+		"media/movies/a.mkv":    "movie content",
+		"media/movies/b.mkv":    "movie content",
+		"media/movies/a.nfo":    "metadata",
+		"media/movies/b.nfo":    "metadata",
+		"media/#recycle/c.mkv":  "movie content",
+		"backup/movies/d.mkv":   "movie content",
+		"media/movies/tiny.mkv": "x",
+		"media/other/tiny.mkv":  "x",
+	}
+	for name, content := range files {
+		path := filepath.Join(dataDir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Failed to create dir: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+	}
+
+	db, err := storage.New(dbPath)
+	if err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+	defer db.Close()
+
+	scan := scanner.NewScanner(db, dataDir)
+	cfg := &config.Config{DataRoot: dataDir}
+	handler := NewDuplicatesHandler(db, cfg, scan, nil)
+
+	tests := []struct {
+		name   string
+		body   string
+		status int
+		groups [][]string // expected master + others of each group, in any order
+	}{
+		{
+			name:   "scoped scan",
+			body:   `{"roots":["/media"],"exclude":["#recycle"],"extensions":["MKV"],"min_size":2}`,
+			status: http.StatusOK,
+			groups: [][]string{{"/media/movies/a.mkv", "/media/movies/b.mkv"}},
+		},
+		{
+			name:   "path exclude",
+			body:   `{"exclude":["/media/#recycle","re:^/backup/"],"extensions":[".nfo"]}`,
+			status: http.StatusOK,
+			groups: [][]string{{"/media/movies/a.nfo", "/media/movies/b.nfo"}},
+		},
+		{
+			name:   "root outside data root",
+			body:   `{"roots":["../"]}`,
+			status: http.StatusBadRequest,
+		},
+		{
+			name:   "invalid regex",
+			body:   `{"exclude":["re:(["]}`,
+			status: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", "/api/duplicates/scan", bytes.NewBufferString(tt.body))
+			w := httptest.NewRecorder()
+			handler.StartScan(w, req)
+
+			if w.Code != tt.status {
+				t.Fatalf("Expected status %d, got %d: %s", tt.status, w.Code, w.Body.String())
+			}
+			if tt.status != http.StatusOK {
+				return
+			}
+
+			var resp struct {
+				JobID string `json:"job_id"`
+			}
+			if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+
+			progress := waitForScan(t, scan, resp.JobID)
+			if progress.Status != "completed" {
+				t.Fatalf("Expected completed scan, got status %s (%s)", progress.Status, progress.Error)
+			}
+			if len(progress.Results) != len(tt.groups) {
+				t.Fatalf("Expected %d groups, got %+v", len(tt.groups), progress.Results)
+			}
+
+			for i, group := range progress.Results {
+				got := map[string]bool{group.Master: true}
+				for _, other := range group.Others {
+					got[other] = true
+				}
+				if len(got) != len(tt.groups[i]) {
+					t.Errorf("Expected group %v, got %v", tt.groups[i], got)
+				}
+				for _, path := range tt.groups[i] {
+					if !got[path] {
+						t.Errorf("Expected %s in group, got %v", path, got)
+					}
+				}
+			}
+		})
+	}
+}
@@ -37,7 +37,7 @@ func TestDuplicatesProgressSSE(t *testing.T) {
 	}
 
 	// Create handler
-	handler := NewDuplicatesHandler(db, cfg, scan)
+	handler := NewDuplicatesHandler(db, cfg, scan, nil)
 
 	// Start a scan job to test against
 	err = scan.StartScan("test-job-123")
@@ -198,7 +198,7 @@ func TestDuplicatesProgressSSE_MissingJobID(t *testing.T) {
 
 	scan := scanner.NewScanner(db, dataDir)
 	cfg := &config.Config{DataRoot: dataDir}
-	handler := NewDuplicatesHandler(db, cfg, scan)
+	handler := NewDuplicatesHandler(db, cfg, scan, nil)
 
 	// Request without job_id
 	req := httptest.NewRequest("GET", "/api/duplicates/progress", nil)
@@ -227,7 +227,7 @@ func TestDuplicatesProgressSSE_InvalidJobID(t *testing.T) {
 
 	scan := scanner.NewScanner(db, dataDir)
 	cfg := &config.Config{DataRoot: dataDir}
-	handler := NewDuplicatesHandler(db, cfg, scan)
+	handler := NewDuplicatesHandler(db, cfg, scan, nil)
 
 	// Request with non-existent job_id
 	req := httptest.NewRequest("GET", "/api/duplicates/progress?job_id=nonexistent", nil)
@@ -259,7 +259,7 @@ defer db.Close()
 
 scan := scanner.NewScanner(db, dataDir)
 cfg := &config.Config{DataRoot: dataDir}
-handler := NewDuplicatesHandler(db, cfg, scan)
+handler := NewDuplicatesHandler(db, cfg, scan, nil)
 
 // Start a scan job
 err = scan.StartScan("test-cors-job")
@@ -0,0 +1,176 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gosiva/hardlink-ui/internal/config"
+	"github.com/gosiva/hardlink-ui/internal/scanner"
+	"github.com/gosiva/hardlink-ui/internal/storage"
+)
+
+// TestVerifyPolicies verifies that each policy groups identical files, is
+// recorded in the results and is applied before conversion
+func TestVerifyPolicies(t *testing.T) {
+	tmpDir := t.TempDir()
+	dbPath := filepath.Join(tmpDir, "test.db")
+	dataDir := filepath.Join(tmpDir, "data")
+
+	if err := os.MkdirAll(dataDir, 0755); err != nil {
+		t.Fatalf("Failed to create data dir: %v", err)
+	}
+	files := map[string]string{
+		"a.bin":     "identical content",
+		"b.bin":     "identical content",
+		"other.bin": "different content",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dataDir, name), []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+	}
+
+	db, err := storage.New(dbPath)
+	if err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+	defer db.Close()
+
+	scan := scanner.NewScanner(db, dataDir)
+
+	for _, policy := range []scanner.VerifyPolicy{scanner.VerifyQuick, scanner.VerifySampled,
+		scanner.VerifySHA256, scanner.VerifyBLAKE3, scanner.VerifyFull} {
+		t.Run("scan "+string(policy), func(t *testing.T) {
+			jobID := "verify-" + string(policy)
+			if err := scan.StartScanWithOptions(jobID, scanner.ScanOptions{Verify: policy}); err != nil {
+				t.Fatalf("Failed to start scan: %v", err)
+			}
+
+			progress := waitForScan(t, scan, jobID)
+			if progress.Status != "completed" {
+				t.Fatalf("Expected completed scan, got status %s (%s)", progress.Status, progress.Error)
+			}
+			if len(progress.Results) != 1 {
+				t.Fatalf("Expected 1 duplicate group, got %+v", progress.Results)
+			}
+			if progress.Results[0].Verify != policy {
+				t.Errorf("Expected policy %s in results, got %s", policy, progress.Results[0].Verify)
+			}
+
+			// The policy is persisted with the results
+			results, err := scanner.NewScanner(db, dataDir).GetResults(jobID)
+			if err != nil || len(results) != 1 || results[0].Verify != policy {
+				t.Errorf("Expected persisted policy %s, got %+v (%v)", policy, results, err)
+			}
+		})
+	}
+
+	if err := scan.StartScanWithOptions("verify-invalid", scanner.ScanOptions{Verify: "md5"}); err == nil {
+		t.Error("Expected unknown policy to be rejected")
+	}
+
+	cfg := &config.Config{DataRoot: dataDir, VerifyPolicy: "quick"}
+	handler := NewDuplicatesHandler(db, cfg, scan, nil)
+
+	tests := []struct {
+		name    string
+		body    string
+		status  int
+		created int
+	}{
+		{"unknown policy", `{"verify":"md5","groups":[{"master":"/a.bin","others":["/b.bin"]}]}`, http.StatusBadRequest, 0},
+		{"different content", `{"verify":"full","groups":[{"master":"/a.bin","others":["/other.bin"]}]}`, http.StatusOK, 0},
+		{"identical content", `{"verify":"blake3","groups":[{"master":"/a.bin","others":["/b.bin"]}]}`, http.StatusOK, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", "/api/duplicates/convert", bytes.NewBufferString(tt.body))
+			w := httptest.NewRecorder()
+			handler.ConvertDuplicates(w, req)
+
+			if w.Code != tt.status {
+				t.Fatalf("Expected status %d, got %d: %s", tt.status, w.Code, w.Body.String())
+			}
+			if tt.status != http.StatusOK {
+				return
+			}
+
+			var resp struct {
+				Created int      `json:"created"`
+				Errors  []string `json:"errors"`
+			}
+			if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+			if resp.Created != tt.created {
+				t.Errorf("Expected %d created, got %d (errors: %v)", tt.created, resp.Created, resp.Errors)
+			}
+		})
+	}
+
+	if content, err := os.ReadFile(filepath.Join(dataDir, "other.bin")); err != nil || string(content) != "different content" {
+		t.Errorf("Expected other.bin to be untouched, got %q (%v)", content, err)
+	}
+
+	// Large files differing in the middle pass the quick policy. A group
+	// confirmed by a full scan is verified fully even when the request
+	// asks for less.
+	size := int64(scanner.SmallFileThreshold + 4*scanner.PartialHashSize)
+	for _, name := range []string{"big1.bin", "big2.bin"} {
+		file, err := os.Create(filepath.Join(dataDir, name))
+		if err != nil {
+			t.Fatalf("Failed to create %s: %v", name, err)
+		}
+		if err := file.Truncate(size); err != nil {
+			t.Fatalf("Failed to size %s: %v", name, err)
+		}
+		if name == "big2.bin" {
+			if _, err := file.WriteAt([]byte("x"), size/2); err != nil {
+				t.Fatalf("Failed to write %s: %v", name, err)
+			}
+		}
+		file.Close()
+	}
+
+	recorded := []struct {
+		name    string
+		verify  string
+		planned int
+	}{
+		{"recorded quick", "quick", 1},
+		{"recorded full", "full", 0},
+	}
+	for _, tt := range recorded {
+		t.Run(tt.name, func(t *testing.T) {
+			body := `{"verify":"quick","dry_run":true,"groups":[{"master":"/big1.bin","others":["/big2.bin"],"verify":"` + tt.verify + `"}]}`
+			req := httptest.NewRequest("POST", "/api/duplicates/convert", bytes.NewBufferString(body))
+			w := httptest.NewRecorder()
+			handler.ConvertDuplicates(w, req)
+			if w.Code != http.StatusOK {
+				t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+			}
+			var resp struct {
+				Planned int `json:"planned"`
+			}
+			if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+			if resp.Planned != tt.planned {
+				t.Errorf("Expected %d planned, got %d", tt.planned, resp.Planned)
+			}
+		})
+	}
+
+	req := httptest.NewRequest("POST", "/api/duplicates/convert", bytes.NewBufferString(`{"groups":[{"master":"/a.bin","others":["/b.bin"],"verify":"md5"}]}`))
+	w := httptest.NewRecorder()
+	handler.ConvertDuplicates(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected an unknown group policy to be rejected, got %d", w.Code)
+	}
+}
@@ -1,18 +1,24 @@
 package api
 
 import (
+	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"html/template"
 	"log"
 	"net/http"
 	"os"
+	"path"
 	"path/filepath"
 	"sort"
+	"strconv"
 	"strings"
-	"syscall"
+	"sync"
+	"time"
 
 	"github.com/gosiva/hardlink-ui/internal/config"
+	"github.com/gosiva/hardlink-ui/internal/fsutil"
+	"github.com/gosiva/hardlink-ui/internal/indexer"
 	"github.com/gosiva/hardlink-ui/internal/storage"
 )
 
@@ -20,11 +26,13 @@ import (
 type ExplorerHandler struct {
 	db        *storage.DB
 	cfg       *config.Config
+	indexer   *indexer.Indexer
 	templates *template.Template
+	listings  listCache
 }
 
 // NewExplorerHandler creates a new explorer handler
-func NewExplorerHandler(db *storage.DB, cfg *config.Config, templatesPath string) (*ExplorerHandler, error) {
+func NewExplorerHandler(db *storage.DB, cfg *config.Config, idx *indexer.Indexer, templatesPath string) (*ExplorerHandler, error) {
 	// Parse only the templates needed for the explorer
 	// This prevents conflicts with login.html and 2fa.html which also define "content" block
 	tmpl, err := template.ParseFiles(
@@ -38,6 +46,7 @@ func NewExplorerHandler(db *storage.DB, cfg *config.Config, templatesPath string
 	return &ExplorerHandler{
 		db:        db,
 		cfg:       cfg,
+		indexer:   idx,
 		templates: tmpl,
 	}, nil
 }
@@ -52,19 +61,212 @@ type FileEntry struct {
 	Name      string `json:"name"`
 	Path      string `json:"path"`
 	IsDir     bool   `json:"is_dir"`
+	Dev       uint64 `json:"dev"`
 	Inode     uint64 `json:"inode"`
 	Nlink     uint64 `json:"nlink"`
 	Size      int64  `json:"size"`
 	SizeHuman string `json:"size_human"`
+	Mtime     int64  `json:"mtime"`
+	UID       uint32 `json:"uid"`
+	GID       uint32 `json:"gid"`
 }
 
-// ListDirectory lists files in a directory
+// newFileEntry describes the file at the relative path rel
+func newFileEntry(rel string, info os.FileInfo) FileEntry {
+	dev, inode, nlink, _ := fsutil.Identity(info)
+	uid, gid, _ := fsutil.Owner(info)
+
+	sizeHuman := ""
+	if !info.IsDir() {
+		sizeHuman = humanSize(info.Size())
+	}
+
+	return FileEntry{
+		Name:      filepath.Base(rel),
+		Path:      rel,
+		IsDir:     info.IsDir(),
+		Dev:       dev,
+		Inode:     inode,
+		Nlink:     nlink,
+		Size:      info.Size(),
+		SizeHuman: sizeHuman,
+		Mtime:     info.ModTime().Unix(),
+		UID:       uid,
+		GID:       gid,
+	}
+}
+
+// Sort keys of ListDirectory
+var listSortKeys = map[string]bool{
+	"name":  true,
+	"size":  true,
+	"mtime": true,
+	"nlink": true,
+	"inode": true,
+}
+
+// listItem is a directory entry being listed. info is only read for the
+// sort keys that need it, and for the returned page.
+type listItem struct {
+	entry os.DirEntry
+	info  os.FileInfo
+	key   int64
+}
+
+// position returns the place of the item in its listing
+func (item listItem) position() listCursor {
+	return listCursor{Dir: item.entry.IsDir(), Key: item.key, Name: item.entry.Name()}
+}
+
+// listCacheSize is the number of sorted listings kept for pagination
+const listCacheSize = 16
+
+// listCacheTTL bounds the age of a cached listing: the sort keys of its
+// entries can change without touching the directory
+const listCacheTTL = time.Minute
+
+// listKey identifies a sorted directory listing
+type listKey struct {
+	path string
+	sort string
+	desc bool
+}
+
+// listing is a sorted directory listing, valid while the directory keeps its
+// modification time
+type listing struct {
+	mtime   int64
+	created time.Time
+	items   []listItem
+}
+
+// listCache keeps the sorted listings of the directories being paged
+// through, so that each page doesn't read and sort the whole directory again
+type listCache struct {
+	mu       sync.Mutex
+	listings map[listKey]*listing
+}
+
+// get returns the cached listing of key, if the directory wasn't modified
+// since
+func (c *listCache) get(key listKey, mtime int64) []listItem {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	l, ok := c.listings[key]
+	if !ok || l.mtime != mtime || time.Since(l.created) > listCacheTTL {
+		return nil
+	}
+	return l.items
+}
+
+// put caches the listing of key, evicting the oldest one when full. The
+// entries are stat'ed again when listed, so their info isn't kept.
+func (c *listCache) put(key listKey, mtime int64, items []listItem) {
+	cached := make([]listItem, len(items))
+	for i, item := range items {
+		cached[i] = listItem{entry: item.entry, key: item.key}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.listings == nil {
+		c.listings = make(map[listKey]*listing)
+	}
+	if _, ok := c.listings[key]; !ok && len(c.listings) >= listCacheSize {
+		var oldest listKey
+		var oldestTime time.Time
+		for k, l := range c.listings {
+			if oldestTime.IsZero() || l.created.Before(oldestTime) {
+				oldest, oldestTime = k, l.created
+			}
+		}
+		delete(c.listings, oldest)
+	}
+	c.listings[key] = &listing{mtime: mtime, created: time.Now(), items: cached}
+}
+
+// listCursor identifies the last entry of a page: the next page starts after
+// it, even if entries were added or removed in between
+type listCursor struct {
+	Dir  bool   `json:"d"`
+	Key  int64  `json:"k,omitempty"`
+	Name string `json:"n"`
+}
+
+// encode returns the opaque form of the cursor sent to clients
+func (c listCursor) encode() string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// decodeListCursor parses a cursor returned by ListDirectory
+func decodeListCursor(value string) (listCursor, error) {
+	var c listCursor
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err == nil {
+		err = json.Unmarshal(data, &c)
+	}
+	if err != nil {
+		return c, fmt.Errorf("invalid cursor")
+	}
+	return c, nil
+}
+
+// listBefore reports whether the entry a sorts before b: directories first,
+// then by key (name only for the name sort), then by name
+func listBefore(a, b listCursor, desc bool) bool {
+	if a.Dir != b.Dir {
+		return a.Dir
+	}
+	if a.Key != b.Key {
+		return (a.Key < b.Key) != desc
+	}
+	la, lb := strings.ToLower(a.Name), strings.ToLower(b.Name)
+	if la != lb {
+		return (la < lb) != desc
+	}
+	return a.Name != b.Name && (a.Name < b.Name) != desc
+}
+
+// ListDirectory lists files in a directory. Without parameters every entry is
+// returned, directories first, then by name. sort (name, size, mtime, nlink
+// or inode) and order (asc or desc) change the order, limit paginates with
+// the next_cursor to pass as cursor, and format=ndjson streams one entry per
+// line, the cursor and the total being sent in the X-Next-Cursor and
+// X-Total-Count headers. The sorted listing of a paginated directory is
+// cached until the directory changes.
 func (h *ExplorerHandler) ListDirectory(w http.ResponseWriter, r *http.Request) {
-	relPath := r.URL.Query().Get("path")
+	query := r.URL.Query()
+	relPath := query.Get("path")
 	if relPath == "" {
 		relPath = "/"
 	}
 
+	sortKey := query.Get("sort")
+	if sortKey == "" {
+		sortKey = "name"
+	}
+	if !listSortKeys[sortKey] {
+		JSONError(w, http.StatusBadRequest, "sort must be name, size, mtime, nlink or inode")
+		return
+	}
+	desc := query.Get("order") == "desc"
+
+	limit := 0
+	if v, err := strconv.Atoi(query.Get("limit")); err == nil && v > 0 {
+		limit = v
+	}
+
+	var cursor *listCursor
+	if v := query.Get("cursor"); v != "" {
+		c, err := decodeListCursor(v)
+		if err != nil {
+			JSONError(w, http.StatusBadRequest, "Invalid cursor")
+			return
+		}
+		cursor = &c
+	}
+
 	// Resolve path safely
 	targetPath := filepath.Join(h.cfg.DataRoot, strings.TrimPrefix(relPath, "/"))
 	targetPath = filepath.Clean(targetPath)
@@ -75,80 +277,164 @@ func (h *ExplorerHandler) ListDirectory(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	// Read directory
-	entries, err := os.ReadDir(targetPath)
+	// Pages are served from the listing sorted for the first one until the
+	// directory changes
+	dirInfo, err := os.Stat(targetPath)
 	if err != nil {
 		JSONError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to read directory: %v", err))
 		return
 	}
+	key := listKey{path: targetPath, sort: sortKey, desc: desc}
+	mtime := dirInfo.ModTime().UnixNano()
 
-	// Initialize as empty slice to avoid null in JSON for empty directories
-	result := make([]FileEntry, 0)
-	for _, entry := range entries {
-		// Skip @eaDir
-		if entry.Name() == "@eaDir" {
-			continue
+	var items []listItem
+	if limit > 0 {
+		items = h.listings.get(key, mtime)
+	}
+	if items == nil {
+		if items, err = readListing(targetPath, sortKey, desc); err != nil {
+			JSONError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to read directory: %v", err))
+			return
+		}
+		if limit > 0 {
+			h.listings.put(key, mtime, items)
 		}
+	}
 
-		fullPath := filepath.Join(targetPath, entry.Name())
-		info, err := entry.Info()
-		if err != nil {
-			log.Printf("Failed to stat %s: %v", fullPath, err)
-			continue
+	// The page starts after the cursor and ends after limit entries
+	total := len(items)
+	start := 0
+	if cursor != nil {
+		start = sort.Search(len(items), func(i int) bool {
+			return listBefore(*cursor, items[i].position(), desc)
+		})
+	}
+	page := items[start:]
+	nextCursor := ""
+	if limit > 0 && len(page) > limit {
+		page = page[:limit]
+		nextCursor = page[limit-1].position().encode()
+	}
+
+	// entryOf stats the entries of the page not stat'ed for sorting, cached
+	// ones included
+	entryOf := func(item listItem) (FileEntry, bool) {
+		info := item.info
+		if info == nil {
+			var err error
+			if info, err = item.entry.Info(); err != nil {
+				log.Printf("Failed to stat %s: %v", filepath.Join(targetPath, item.entry.Name()), err)
+				return FileEntry{}, false
+			}
 		}
+		return newFileEntry(path.Join("/", relPath, item.entry.Name()), info), true
+	}
 
-		// Get inode and nlink
-		var inode, nlink uint64
-		if stat, ok := info.Sys().(*syscall.Stat_t); ok {
-			inode = stat.Ino
-			nlink = stat.Nlink
+	if query.Get("format") == "ndjson" {
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		w.Header().Set("X-Total-Count", strconv.Itoa(total))
+		if nextCursor != "" {
+			w.Header().Set("X-Next-Cursor", nextCursor)
 		}
+		w.WriteHeader(http.StatusOK)
 
-		// Build relative path
-		entryRelPath := filepath.Join(relPath, entry.Name())
-		if !strings.HasPrefix(entryRelPath, "/") {
-			entryRelPath = "/" + entryRelPath
+		flusher, _ := w.(http.Flusher)
+		enc := json.NewEncoder(w)
+		for i, item := range page {
+			if r.Context().Err() != nil {
+				return
+			}
+			if entry, ok := entryOf(item); ok {
+				if err := enc.Encode(entry); err != nil {
+					return
+				}
+			}
+			if flusher != nil && (i+1)%500 == 0 {
+				flusher.Flush()
+			}
 		}
+		return
+	}
 
-		sizeHuman := ""
-		size := info.Size()
-		if !entry.IsDir() {
-			sizeHuman = humanSize(size)
+	// Initialize as empty slice to avoid null in JSON for empty directories
+	result := make([]FileEntry, 0, len(page))
+	for _, item := range page {
+		if entry, ok := entryOf(item); ok {
+			result = append(result, entry)
 		}
+	}
 
-		result = append(result, FileEntry{
-			Name:      entry.Name(),
-			Path:      entryRelPath,
-			IsDir:     entry.IsDir(),
-			Inode:     inode,
-			Nlink:     nlink,
-			Size:      size,
-			SizeHuman: sizeHuman,
-		})
+	response := map[string]interface{}{
+		"entries": result,
+		"total":   total,
+	}
+	if nextCursor != "" {
+		response["next_cursor"] = nextCursor
 	}
+	JSONResponse(w, http.StatusOK, response)
+}
 
-	// Sort: directories first, then by name
-	sort.Slice(result, func(i, j int) bool {
-		if result[i].IsDir != result[j].IsDir {
-			return result[i].IsDir
+// readListing reads a directory and sorts its entries
+func readListing(dir, sortKey string, desc bool) ([]listItem, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	items := make([]listItem, 0, len(entries))
+	for _, entry := range entries {
+		// Skip @eaDir and the trash
+		if fsutil.SkipDir(entry.Name()) {
+			continue
 		}
-		return strings.ToLower(result[i].Name) < strings.ToLower(result[j].Name)
-	})
 
-	JSONResponse(w, http.StatusOK, map[string]interface{}{
-		"entries": result,
+		item := listItem{entry: entry}
+		// Only the name sort can do without a stat of every entry
+		if sortKey != "name" {
+			info, err := entry.Info()
+			if err != nil {
+				log.Printf("Failed to stat %s: %v", filepath.Join(dir, entry.Name()), err)
+				continue
+			}
+			item.info = info
+			item.key = listSortKey(sortKey, info)
+		}
+		items = append(items, item)
+	}
+
+	sort.Slice(items, func(i, j int) bool {
+		return listBefore(items[i].position(), items[j].position(), desc)
 	})
+	return items, nil
+}
+
+// listSortKey returns the value of a numeric sort key
+func listSortKey(sortKey string, info os.FileInfo) int64 {
+	_, inode, nlink, _ := fsutil.Identity(info)
+	switch sortKey {
+	case "size":
+		return info.Size()
+	case "mtime":
+		return info.ModTime().UnixNano()
+	case "nlink":
+		return int64(nlink)
+	case "inode":
+		return int64(inode)
+	}
+	return 0
 }
 
 // FileDetails represents detailed file information
 type FileDetails struct {
 	Name      string   `json:"name"`
 	Path      string   `json:"path"`
+	Dev       uint64   `json:"dev"`
 	Inode     uint64   `json:"inode"`
 	Nlink     uint64   `json:"nlink"`
 	Size      int64    `json:"size"`
 	SizeHuman string   `json:"size_human"`
 	AllPaths  []string `json:"all_paths"`
+	Source    string   `json:"source"` // "index" or "walk"
 }
 
 // GetDetails returns detailed information about a file
@@ -173,14 +459,10 @@ func (h *ExplorerHandler) GetDetails(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	var inode, nlink uint64
-	if stat, ok := info.Sys().(*syscall.Stat_t); ok {
-		inode = stat.Ino
-		nlink = stat.Nlink
-	}
+	dev, inode, nlink, _ := fsutil.Identity(info)
 
 	// Find all paths with the same inode
-	allPaths, err := h.findAllPathsByInode(inode)
+	allPaths, source, err := h.findPaths(targetPath, info)
 	if err != nil {
 		log.Printf("Error finding paths by inode: %v", err)
 		allPaths = []string{relPath}
@@ -193,18 +475,97 @@ func (h *ExplorerHandler) GetDetails(w http.ResponseWriter, r *http.Request) {
 	details := FileDetails{
 		Name:      filepath.Base(targetPath),
 		Path:      relPath,
+		Dev:       dev,
 		Inode:     inode,
 		Nlink:     nlink,
 		Size:      info.Size(),
 		SizeHuman: humanSize(info.Size()),
 		AllPaths:  allPaths,
+		Source:    source,
 	}
 
 	JSONResponse(w, http.StatusOK, details)
 }
 
-// findAllPathsByInode finds all paths with the same inode
-func (h *ExplorerHandler) findAllPathsByInode(inode uint64) ([]string, error) {
+// findPaths returns all relative paths linked to the inode of info.
+// It answers from the inode index, verifying every indexed path and pruning
+// the stale ones, and only walks the data root while the index is still
+// being built and doesn't account for every link.
+func (h *ExplorerHandler) findPaths(targetPath string, info os.FileInfo) ([]string, string, error) {
+	dev, inode, nlink, _ := fsutil.Identity(info)
+	indexed, err := h.db.GetInodePaths(dev, inode)
+	if err != nil {
+		return nil, "", err
+	}
+
+	var paths []string
+	seenTarget := false
+	for _, p := range indexed {
+		if !sameInode(p, dev, inode) {
+			indexer.RemovePath(h.db, dev, inode, p)
+			continue
+		}
+		if p == targetPath {
+			seenTarget = true
+		}
+		if rel := h.relPath(p); rel != "" {
+			paths = append(paths, rel)
+		}
+	}
+
+	if !seenTarget {
+		indexer.AddPath(h.db, dev, inode, targetPath)
+		if rel := h.relPath(targetPath); rel != "" {
+			paths = append(paths, rel)
+		}
+	}
+
+	if uint64(len(paths)) >= nlink || (h.indexer != nil && h.indexer.Ready()) {
+		sort.Strings(paths)
+		return paths, "index", nil
+	}
+
+	// Index not built yet: fall back to a walk and remember what it finds
+	walked, err := h.findAllPathsByInode(dev, inode)
+	if err != nil {
+		return nil, "", err
+	}
+
+	entries := make([]storage.InodeEntry, 0, len(walked))
+	for _, p := range walked {
+		entries = append(entries, storage.InodeEntry{
+			Dev:   dev,
+			Inode: inode,
+			Path:  filepath.Join(h.cfg.DataRoot, strings.TrimPrefix(p, "/")),
+			Size:  info.Size(),
+			Mtime: info.ModTime().Unix(),
+			Nlink: nlink,
+		})
+	}
+	if err := h.db.BatchAddInodePaths(entries); err != nil {
+		log.Printf("Failed to update inode index: %v", err)
+	}
+
+	return walked, "walk", nil
+}
+
+// relPath converts an absolute path under DataRoot to a "/"-prefixed relative path
+func (h *ExplorerHandler) relPath(path string) string {
+	return rootRelPath(h.cfg.DataRoot, path)
+}
+
+// sameInode reports whether path still refers to the given inode
+func sameInode(path string, dev, inode uint64) bool {
+	info, err := os.Lstat(path)
+	if err != nil {
+		return false
+	}
+	d, i, _, ok := fsutil.Identity(info)
+	return ok && d == dev && i == inode
+}
+
+// findAllPathsByInode finds all paths with the same inode by walking the data root
+func (h *ExplorerHandler) findAllPathsByInode(dev, inode uint64) ([]string, error) {
 	var paths []string
 
 	err := filepath.Walk(h.cfg.DataRoot, func(path string, info os.FileInfo, err error) error {
@@ -212,14 +573,14 @@ func (h *ExplorerHandler) findAllPathsByInode(inode uint64) ([]string, error) {
 			return nil // skip errors
 		}
 
-		// Skip @eaDir directories
-		if info.IsDir() && info.Name() == "@eaDir" {
+		// Skip @eaDir directories and the trash
+		if info.IsDir() && fsutil.SkipDir(info.Name()) {
 			return filepath.SkipDir
 		}
 
 		if !info.IsDir() {
-			if stat, ok := info.Sys().(*syscall.Stat_t); ok {
-				if stat.Ino == inode {
+			if d, i, _, ok := fsutil.Identity(info); ok {
+				if d == dev && i == inode {
 					relPath, _ := filepath.Rel(h.cfg.DataRoot, path)
 					if relPath != "" {
 						paths = append(paths, "/"+relPath)
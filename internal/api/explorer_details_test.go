@@ -0,0 +1,97 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gosiva/hardlink-ui/internal/config"
+	"github.com/gosiva/hardlink-ui/internal/indexer"
+	"github.com/gosiva/hardlink-ui/internal/storage"
+)
+
+// TestGetDetailsUsesInodeIndex verifies that details are answered from the
+// inode index and that stale indexed paths are pruned
+func TestGetDetailsUsesInodeIndex(t *testing.T) {
+	tmpDir := t.TempDir()
+	dataDir := filepath.Join(tmpDir, "data")
+	if err := os.MkdirAll(filepath.Join(dataDir, "movies"), 0755); err != nil {
+		t.Fatalf("Failed to create data dir: %v", err)
+	}
+
+	original := filepath.Join(dataDir, "movies", "film.mkv")
+	if err := os.WriteFile(original, []byte("video"), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	for _, name := range []string{"link1.mkv", "link2.mkv"} {
+		if err := os.Link(original, filepath.Join(dataDir, name)); err != nil {
+			t.Fatalf("Failed to create hardlink: %v", err)
+		}
+	}
+
+	db, err := storage.New(filepath.Join(tmpDir, "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+	defer db.Close()
+
+	idx := indexer.NewIndexer(db, dataDir, 0)
+	if err := idx.Rebuild(context.Background()); err != nil {
+		t.Fatalf("Failed to build index: %v", err)
+	}
+
+	cfg := &config.Config{DataRoot: dataDir}
+	handler, err := NewExplorerHandler(db, cfg, idx, filepath.Join("..", "..", "web", "templates"))
+	if err != nil {
+		t.Fatalf("Failed to create handler: %v", err)
+	}
+
+	getDetails := func() FileDetails {
+		req := httptest.NewRequest("GET", "/api/details?path=/movies/film.mkv", nil)
+		w := httptest.NewRecorder()
+		handler.GetDetails(w, req)
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+		}
+		var details FileDetails
+		if err := json.NewDecoder(w.Body).Decode(&details); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+		return details
+	}
+
+	details := getDetails()
+	if details.Source != "index" {
+		t.Errorf("Expected details from index, got source %q", details.Source)
+	}
+	if len(details.AllPaths) != 3 {
+		t.Fatalf("Expected 3 paths, got %v", details.AllPaths)
+	}
+
+	// Removing a link outside the application leaves a stale index entry
+	if err := os.Remove(filepath.Join(dataDir, "link2.mkv")); err != nil {
+		t.Fatalf("Failed to remove link: %v", err)
+	}
+
+	details = getDetails()
+	if len(details.AllPaths) != 2 {
+		t.Fatalf("Expected stale path to be pruned, got %v", details.AllPaths)
+	}
+	for _, p := range details.AllPaths {
+		if p == "/link2.mkv" {
+			t.Errorf("Stale path /link2.mkv still reported")
+		}
+	}
+
+	count, err := db.GetPathCount()
+	if err != nil {
+		t.Fatalf("Failed to count index paths: %v", err)
+	}
+	if count != 2 {
+		t.Errorf("Expected 2 indexed paths after pruning, got %d", count)
+	}
+}
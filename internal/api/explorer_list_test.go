@@ -0,0 +1,172 @@
+package api
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/gosiva/hardlink-ui/internal/config"
+	"github.com/gosiva/hardlink-ui/internal/storage"
+)
+
+// TestListDirectoryPagination verifies the sorting, cursor pagination and
+// NDJSON streaming of directory listings
+func TestListDirectoryPagination(t *testing.T) {
+	tmpDir := t.TempDir()
+	dataDir := filepath.Join(tmpDir, "data")
+	dir := filepath.Join(dataDir, "downloads")
+
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	files := []struct {
+		name string
+		size int
+	}{
+		{"b.mkv", 300},
+		{"A.mkv", 100},
+		{"c.nfo", 200},
+		{"d.srt", 50},
+	}
+	for _, name := range []string{"zeta", "Alpha"} {
+		if err := os.MkdirAll(filepath.Join(dir, name), 0755); err != nil {
+			t.Fatalf("Failed to create dir: %v", err)
+		}
+	}
+	for i, f := range files {
+		path := filepath.Join(dir, f.name)
+		if err := os.WriteFile(path, make([]byte, f.size), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", f.name, err)
+		}
+		mtime := base.Add(time.Duration(i) * time.Hour)
+		if err := os.Chtimes(path, mtime, mtime); err != nil {
+			t.Fatalf("Failed to set times: %v", err)
+		}
+	}
+
+	db, err := storage.New(filepath.Join(tmpDir, "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+	defer db.Close()
+
+	cfg := &config.Config{DataRoot: dataDir}
+	handler, err := NewExplorerHandler(db, cfg, nil, filepath.Join("..", "..", "web", "templates"))
+	if err != nil {
+		t.Fatalf("Failed to create handler: %v", err)
+	}
+
+	type listResponse struct {
+		Entries    []FileEntry `json:"entries"`
+		Total      int         `json:"total"`
+		NextCursor string      `json:"next_cursor"`
+	}
+	list := func(params url.Values, status int) listResponse {
+		t.Helper()
+		params.Set("path", "/downloads")
+		req := httptest.NewRequest("GET", "/api/list?"+params.Encode(), nil)
+		w := httptest.NewRecorder()
+		handler.ListDirectory(w, req)
+		if w.Code != status {
+			t.Fatalf("Expected status %d, got %d: %s", status, w.Code, w.Body.String())
+		}
+		var resp listResponse
+		if status == http.StatusOK {
+			if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+		}
+		return resp
+	}
+	names := func(entries []FileEntry) []string {
+		var result []string
+		for _, e := range entries {
+			result = append(result, e.Name)
+		}
+		return result
+	}
+	expect := func(got []string, want ...string) {
+		t.Helper()
+		if len(got) != len(want) {
+			t.Fatalf("Expected %v, got %v", want, got)
+		}
+		for i := range want {
+			if got[i] != want[i] {
+				t.Fatalf("Expected %v, got %v", want, got)
+			}
+		}
+	}
+
+	// Without parameters, everything: directories first, then by name
+	resp := list(url.Values{}, http.StatusOK)
+	expect(names(resp.Entries), "Alpha", "zeta", "A.mkv", "b.mkv", "c.nfo", "d.srt")
+	if resp.Total != 6 || resp.NextCursor != "" {
+		t.Errorf("Expected 6 entries without cursor, got %d %q", resp.Total, resp.NextCursor)
+	}
+	for _, e := range resp.Entries {
+		if e.Name == "A.mkv" && (e.Mtime != base.Add(time.Hour).Unix() || e.UID != uint32(os.Getuid()) || e.Path != "/downloads/A.mkv") {
+			t.Errorf("Unexpected entry fields %+v", e)
+		}
+	}
+
+	// Pages follow the cursor, even when entries are removed in between
+	resp = list(url.Values{"sort": {"size"}, "order": {"desc"}, "limit": {"3"}}, http.StatusOK)
+	expect(names(resp.Entries), "zeta", "Alpha", "b.mkv")
+	if resp.NextCursor == "" {
+		t.Fatal("Expected a next cursor")
+	}
+	if err := os.Remove(filepath.Join(dir, "b.mkv")); err != nil {
+		t.Fatalf("Failed to remove: %v", err)
+	}
+	resp = list(url.Values{"sort": {"size"}, "order": {"desc"}, "limit": {"2"}, "cursor": {resp.NextCursor}}, http.StatusOK)
+	expect(names(resp.Entries), "c.nfo", "A.mkv")
+	resp = list(url.Values{"sort": {"size"}, "order": {"desc"}, "limit": {"2"}, "cursor": {resp.NextCursor}}, http.StatusOK)
+	expect(names(resp.Entries), "d.srt")
+	if resp.NextCursor != "" {
+		t.Errorf("Expected the last page, got cursor %q", resp.NextCursor)
+	}
+
+	resp = list(url.Values{"sort": {"mtime"}, "order": {"desc"}}, http.StatusOK)
+	expect(names(resp.Entries)[2:], "d.srt", "c.nfo", "A.mkv")
+
+	// NDJSON streams one entry per line, the cursor in a header
+	req := httptest.NewRequest("GET", "/api/list?path=/downloads&format=ndjson&limit=4", nil)
+	w := httptest.NewRecorder()
+	handler.ListDirectory(w, req)
+	if w.Header().Get("Content-Type") != "application/x-ndjson" || w.Header().Get("X-Total-Count") != strconv.Itoa(5) || w.Header().Get("X-Next-Cursor") == "" {
+		t.Errorf("Unexpected NDJSON headers %v", w.Header())
+	}
+	var streamed []FileEntry
+	scanner := bufio.NewScanner(w.Body)
+	for scanner.Scan() {
+		var e FileEntry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			t.Fatalf("Failed to decode line %q: %v", scanner.Text(), err)
+		}
+		streamed = append(streamed, e)
+	}
+	expect(names(streamed), "Alpha", "zeta", "A.mkv", "c.nfo")
+
+	// Pages are served from a cached listing, until the directory changes
+	resp = list(url.Values{"limit": {"3"}}, http.StatusOK)
+	expect(names(resp.Entries), "Alpha", "zeta", "A.mkv")
+	if len(handler.listings.listings) == 0 {
+		t.Error("Expected the listing to be cached")
+	}
+	if err := os.WriteFile(filepath.Join(dir, "B.mkv"), nil, 0644); err != nil {
+		t.Fatalf("Failed to write B.mkv: %v", err)
+	}
+	resp = list(url.Values{"limit": {"3"}, "cursor": {resp.NextCursor}}, http.StatusOK)
+	expect(names(resp.Entries), "B.mkv", "c.nfo", "d.srt")
+	if resp.Total != 6 {
+		t.Errorf("Expected the new file to be counted, got %d", resp.Total)
+	}
+
+	list(url.Values{"sort": {"color"}}, http.StatusBadRequest)
+	list(url.Values{"cursor": {"not a cursor"}}, http.StatusBadRequest)
+}
@@ -1,38 +1,58 @@
 package api
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
-	"io/fs"
 	"log"
 	"net/http"
 	"os"
 	"path/filepath"
 	"strings"
-	"syscall"
 
 	"github.com/gosiva/hardlink-ui/internal/config"
+	"github.com/gosiva/hardlink-ui/internal/filter"
+	"github.com/gosiva/hardlink-ui/internal/fsutil"
+	"github.com/gosiva/hardlink-ui/internal/indexer"
+	"github.com/gosiva/hardlink-ui/internal/jobs"
+	"github.com/gosiva/hardlink-ui/internal/journal"
+	"github.com/gosiva/hardlink-ui/internal/rename"
 	"github.com/gosiva/hardlink-ui/internal/storage"
+	"github.com/gosiva/hardlink-ui/internal/trash"
 )
 
 // HardlinkHandler handles hardlink operations
 type HardlinkHandler struct {
-	db  *storage.DB
-	cfg *config.Config
+	db      *storage.DB
+	cfg     *config.Config
+	journal *journal.Journal
+	jobs    *jobs.Runner
+	// trash receives the deleted links and folders, nil when disabled
+	trash *trash.Trash
 }
 
-// NewHardlinkHandler creates a new hardlink handler
-func NewHardlinkHandler(db *storage.DB, cfg *config.Config) *HardlinkHandler {
-	return &HardlinkHandler{
-		db:  db,
-		cfg: cfg,
+// NewHardlinkHandler creates a new hardlink handler. Without a job runner,
+// folders can't be linked in the background.
+func NewHardlinkHandler(db *storage.DB, cfg *config.Config, runner *jobs.Runner) *HardlinkHandler {
+	h := &HardlinkHandler{
+		db:      db,
+		cfg:     cfg,
+		journal: journal.New(db, cfg.DataRoot),
+		jobs:    runner,
 	}
+	if cfg.TrashEnabled {
+		h.trash = newTrash(db, cfg)
+	}
+	return h
 }
 
 // CreateHardlinkRequest represents a hardlink creation request
 type CreateHardlinkRequest struct {
 	Source string `json:"source"`
-	Dest   string `json:"dest"`
+	// Dest is the path of the hardlink, or the folder it goes into with a
+	// rename template
+	Dest string `json:"dest"`
+	rename.Options
 }
 
 // CreateHardlink creates a single hardlink
@@ -48,12 +68,26 @@ func (h *HardlinkHandler) CreateHardlink(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	renamer, err := req.Options.Compile()
+	if err != nil {
+		JSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
 	srcPath := filepath.Join(h.cfg.DataRoot, strings.TrimPrefix(req.Source, "/"))
 	srcPath = filepath.Clean(srcPath)
 
 	destPath := filepath.Join(h.cfg.DataRoot, strings.TrimPrefix(req.Dest, "/"))
 	destPath = filepath.Clean(destPath)
 
+	if renamer != nil {
+		destPath, err = renamedPath(renamer, destPath, srcPath, filepath.Base(srcPath))
+		if err != nil {
+			JSONError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+
 	if !strings.HasPrefix(srcPath, h.cfg.DataRoot) || !strings.HasPrefix(destPath, h.cfg.DataRoot) {
 		JSONError(w, http.StatusBadRequest, "Path outside root")
 		return
@@ -77,6 +111,16 @@ func (h *HardlinkHandler) CreateHardlink(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	// Hardlinks can't span filesystems: refuse before creating anything
+	if err := fsutil.SameDevice(srcPath, destPath); err != nil {
+		if fsutil.IsCrossDevice(err) {
+			JSONError(w, http.StatusBadRequest, "Cross-device link: source and destination are on different filesystems")
+			return
+		}
+		JSONError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to check destination device: %v", err))
+		return
+	}
+
 	// Create parent directories if needed
 	destDir := filepath.Dir(destPath)
 	if err := os.MkdirAll(destDir, 0755); err != nil {
@@ -86,24 +130,61 @@ func (h *HardlinkHandler) CreateHardlink(w http.ResponseWriter, r *http.Request)
 
 	// Create hardlink
 	if err := os.Link(srcPath, destPath); err != nil {
+		if fsutil.IsCrossDevice(err) {
+			JSONError(w, http.StatusBadRequest, "Cross-device link: source and destination are on different filesystems")
+			return
+		}
 		JSONError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to create hardlink: %v", err))
 		return
 	}
 
 	// Update inode index
-	srcStat := srcInfo.Sys()
-	if stat, ok := srcStat.(*syscall.Stat_t); ok {
-		h.db.AddInodePath(stat.Ino, destPath)
+	if dev, ino, _, ok := fsutil.Identity(srcInfo); ok {
+		indexer.AddPath(h.db, dev, ino, destPath)
 	}
 
+	opID := h.journal.RecordLink(destPath, srcPath, srcInfo, GetUsername(r))
+
 	log.Printf("HARDLINK CREATE %s -> %s by %s", srcPath, destPath, GetUsername(r))
-	JSONResponse(w, http.StatusOK, map[string]bool{"ok": true})
+	JSONResponse(w, http.StatusOK, map[string]interface{}{
+		"ok":           true,
+		"operation_id": opID,
+	})
 }
 
 // CreateHardlinksFolderRequest represents a bulk folder hardlink request
 type CreateHardlinksFolderRequest struct {
 	Source   string `json:"source"`
 	DestRoot string `json:"dest_root"`
+	// Mode is the sync mode, add by default
+	Mode SyncMode `json:"mode"`
+	// Overwrite lets the replace and mirror modes replace a destination file
+	// that is the last link to its data
+	Overwrite bool `json:"overwrite"`
+	// Rules selects the files to link, paths being relative to the source
+	filter.Rules
+	// Options renames the destination files, relative to the destination
+	// root
+	rename.Options
+	// CreateDirs recreates every source directory, even those without
+	// linked files. It can't be combined with a rename template.
+	CreateDirs bool `json:"create_dirs"`
+	// DryRun returns the plan without touching the filesystem
+	DryRun bool `json:"dry_run"`
+	// Async links the folder in a background job and returns its ID
+	Async bool `json:"async"`
+}
+
+// LinkPlanItem is a file of a folder hardlinking plan or diff report
+type LinkPlanItem struct {
+	Source string `json:"source,omitempty"` // empty for a removed file
+	Dest   string `json:"dest"`
+	Size   int64  `json:"size"`
+	Action string `json:"action,omitempty"` // link, replace or remove
+	Status string `json:"status"`           // planned, done, exists, protected or failed
+	// Differs marks an existing destination that isn't the source's file
+	Differs bool   `json:"differs,omitempty"`
+	Error   string `json:"error,omitempty"`
 }
 
 // CreateHardlinksFolder creates hardlinks for an entire folder
@@ -119,6 +200,29 @@ func (h *HardlinkHandler) CreateHardlinksFolder(w http.ResponseWriter, r *http.R
 		return
 	}
 
+	mode, err := ParseSyncMode(string(req.Mode))
+	if err != nil {
+		JSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	req.Mode = mode
+
+	matcher, err := req.Rules.Compile()
+	if err != nil {
+		JSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	renamer, err := req.Options.Compile()
+	if err != nil {
+		JSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if renamer != nil && req.CreateDirs {
+		JSONError(w, http.StatusBadRequest, "Directories can't be created with a rename template")
+		return
+	}
+
 	srcPath := filepath.Join(h.cfg.DataRoot, strings.TrimPrefix(req.Source, "/"))
 	srcPath = filepath.Clean(srcPath)
 
@@ -142,84 +246,53 @@ func (h *HardlinkHandler) CreateHardlinksFolder(w http.ResponseWriter, r *http.R
 		return
 	}
 
-	created := 0
-	var errors []string
-
-	log.Printf("HARDLINK FOLDER START src=%s dest=%s by %s", srcPath, destRootPath, GetUsername(r))
+	// Mirroring would remove the files of a nested source
+	if mode == SyncMirror && (isSubPath(srcPath, destRootPath) || isSubPath(destRootPath, srcPath)) {
+		JSONError(w, http.StatusBadRequest, "Source and destination must not overlap in mirror mode")
+		return
+	}
 
-	// Walk source directory
-	err = filepath.WalkDir(srcPath, func(path string, d fs.DirEntry, err error) error {
-		if err != nil {
-			return nil // skip errors
+	// Hardlinks can't span filesystems: refuse the whole folder up front
+	if err := fsutil.SameDevice(srcPath, destRootPath); err != nil {
+		if fsutil.IsCrossDevice(err) {
+			JSONError(w, http.StatusBadRequest, "Cross-device link: source and destination are on different filesystems")
+			return
 		}
+		JSONError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to check destination device: %v", err))
+		return
+	}
 
-		// Skip @eaDir
-		if d.IsDir() && d.Name() == "@eaDir" {
-			return fs.SkipDir
-		}
+	if req.Async && h.jobs == nil {
+		JSONError(w, http.StatusServiceUnavailable, "Background jobs are not available")
+		return
+	}
 
-		// Skip directories, only process files
-		if d.IsDir() {
-			return nil
-		}
+	username := GetUsername(r)
 
-		// Calculate relative path
-		relPath, err := filepath.Rel(srcPath, path)
+	if !req.Async {
+		response, err := h.newFolderSync(srcPath, destRootPath, req, matcher, renamer, username).run(context.Background(), nil)
 		if err != nil {
-			errors = append(errors, fmt.Sprintf("%s: %v", d.Name(), err))
-			return nil
-		}
-
-		// Destination path
-		destPath := filepath.Join(destRootPath, relPath)
-
-		// Skip if destination already exists
-		if _, err := os.Stat(destPath); err == nil {
-			return nil
-		}
-
-		// Create parent directory
-		destDir := filepath.Dir(destPath)
-		if err := os.MkdirAll(destDir, 0755); err != nil {
-			errors = append(errors, fmt.Sprintf("%s: failed to create directory: %v", d.Name(), err))
-			return nil
-		}
-
-		// Create hardlink
-		if err := os.Link(path, destPath); err != nil {
-			errors = append(errors, fmt.Sprintf("%s: %v", d.Name(), err))
-			return nil
-		}
-
-		// Update inode index
-		info, err := d.Info()
-		if err == nil {
-			if stat, ok := info.Sys().(*syscall.Stat_t); ok {
-				h.db.AddInodePath(stat.Ino, destPath)
-			}
+			JSONError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to walk directory: %v", err))
+			return
 		}
+		JSONResponse(w, http.StatusOK, response)
+		return
+	}
 
-		created++
-		return nil
+	jobID, err := h.jobs.Start("link-folder", username, req, func(ctx context.Context, t *jobs.Tracker) (interface{}, error) {
+		folder := h.newFolderSync(srcPath, destRootPath, req, matcher, renamer, username)
+		t.SetTotal(folder.count())
+		return folder.run(ctx, t)
 	})
-
 	if err != nil {
-		JSONError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to walk directory: %v", err))
+		JSONError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to start job: %v", err))
 		return
 	}
 
-	log.Printf("HARDLINK FOLDER END src=%s dest=%s created=%d errors=%d", srcPath, destRootPath, created, len(errors))
-
-	response := map[string]interface{}{
-		"ok":      true,
-		"created": created,
-	}
-
-	if len(errors) > 0 {
-		response["errors"] = errors
-	}
-
-	JSONResponse(w, http.StatusOK, response)
+	JSONResponse(w, http.StatusAccepted, map[string]interface{}{
+		"ok":     true,
+		"job_id": jobID,
+	})
 }
 
 // DeleteHardlinkRequest represents a hardlink deletion request
@@ -286,19 +359,41 @@ func (h *HardlinkHandler) DeleteHardlink(w http.ResponseWriter, r *http.Request)
 	}
 
 	// Handle file deletion with hardlink protection
-	stat, ok := info.Sys().(*syscall.Stat_t)
+	dev, ino, nlink, ok := fsutil.Identity(info)
 	if !ok {
 		JSONError(w, http.StatusInternalServerError, "Failed to get file stats")
 		return
 	}
 
-	nlink := stat.Nlink
-
-	// Protect last link
-	if nlink <= 1 {
+	// Protect last link, the links in the trash being as good as deleted
+	trashed, err := h.db.CountTrashedLinks(dev, ino)
+	if err != nil {
+		JSONError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to count trashed links: %v", err))
+		return
+	}
+	if nlink <= 1+uint64(trashed) {
 		JSONError(w, http.StatusForbidden, "Cannot delete the last link to this file")
 		return
 	}
+	remaining := nlink - 1 - uint64(trashed)
+
+	// The trash keeps the link, and the inode, until it is emptied
+	if h.trash != nil {
+		item, err := h.trash.Move(targetPath, GetUsername(r))
+		if err != nil {
+			JSONError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to move to the trash: %v", err))
+			return
+		}
+
+		log.Printf("DELETE HARDLINK %s remaining_links=%d trashed by %s", targetPath, remaining, GetUsername(r))
+		JSONResponse(w, http.StatusOK, map[string]interface{}{
+			"ok":              true,
+			"remaining_links": remaining,
+			"is_dir":          false,
+			"trash_id":        item.ID,
+		})
+		return
+	}
 
 	// Delete the hardlink
 	if err := os.Remove(targetPath); err != nil {
@@ -307,12 +402,14 @@ func (h *HardlinkHandler) DeleteHardlink(w http.ResponseWriter, r *http.Request)
 	}
 
 	// Remove from inode index
-	h.db.RemoveInodePath(stat.Ino, targetPath)
+	indexer.RemovePath(h.db, dev, ino, targetPath)
+	opID := h.journal.RecordUnlink(targetPath, info, GetUsername(r))
 
-	log.Printf("DELETE HARDLINK %s remaining_links=%d by %s", targetPath, nlink-1, GetUsername(r))
+	log.Printf("DELETE HARDLINK %s remaining_links=%d by %s", targetPath, remaining, GetUsername(r))
 	JSONResponse(w, http.StatusOK, map[string]interface{}{
 		"ok":              true,
-		"remaining_links": nlink - 1,
+		"remaining_links": remaining,
 		"is_dir":          false,
+		"operation_id":    opID,
 	})
 }
@@ -0,0 +1,359 @@
+package api
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gosiva/hardlink-ui/internal/fsutil"
+	"github.com/gosiva/hardlink-ui/internal/indexer"
+)
+
+// deleteTokenTTL is how long a folder deletion report can be confirmed
+const deleteTokenTTL = 10 * time.Minute
+
+// maxDeleteReportItems caps the files listed in a deletion report
+const maxDeleteReportItems = 100
+
+// DeleteFolderRequest represents a recursive folder deletion request
+type DeleteFolderRequest struct {
+	Path string `json:"path"`
+	// Token confirms the report returned without it. The folder is only
+	// deleted if it still matches that report.
+	Token string `json:"token"`
+}
+
+// DeleteReport describes what deleting a folder would remove
+type DeleteReport struct {
+	Path  string `json:"path"`
+	Files int    `json:"files"`
+	Dirs  int    `json:"dirs"`
+	// Others counts symlinks and special files
+	Others int   `json:"others"`
+	Bytes  int64 `json:"bytes"`
+	// LinkedFiles still have a link outside the folder: deleting them frees
+	// no space
+	LinkedFiles int   `json:"linked_files"`
+	LinkedBytes int64 `json:"linked_bytes"`
+	// LostFiles have all their links in the folder: their data is lost
+	LostFiles int                `json:"lost_files"`
+	LostBytes int64              `json:"lost_bytes"`
+	Lost      []DeleteReportItem `json:"lost"`
+	// Truncated is set when Lost doesn't list every lost file
+	Truncated bool   `json:"truncated"`
+	Token     string `json:"token"`
+
+	entries []deleteEntry
+	dirs    []string
+	// kept are the directories holding a skipped one, never removed
+	kept        map[string]bool
+	fingerprint string
+}
+
+// DeleteReportItem is a file whose data would be lost, or that failed to be
+// deleted
+type DeleteReportItem struct {
+	Path  string `json:"path"`
+	Size  int64  `json:"size"`
+	Nlink uint64 `json:"nlink"`
+	Error string `json:"error,omitempty"`
+}
+
+// deleteEntry is a non-directory entry of a folder to delete
+type deleteEntry struct {
+	path string
+	info os.FileInfo
+	// linked is set for a file with a link outside the folder
+	linked bool
+}
+
+// inodeID identifies an inode across devices
+type inodeID struct {
+	dev, ino uint64
+}
+
+// DeleteFolder deletes a folder and everything in it. Without a token it only
+// returns the report of what would be deleted, with the token confirming it.
+func (h *HardlinkHandler) DeleteFolder(w http.ResponseWriter, r *http.Request) {
+	var req DeleteFolderRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		JSONError(w, http.StatusBadRequest, "Invalid request")
+		return
+	}
+
+	if req.Path == "" {
+		JSONError(w, http.StatusBadRequest, "Path is required")
+		return
+	}
+
+	targetPath := filepath.Join(h.cfg.DataRoot, strings.TrimPrefix(req.Path, "/"))
+	targetPath = filepath.Clean(targetPath)
+
+	if !strings.HasPrefix(targetPath, h.cfg.DataRoot) {
+		JSONError(w, http.StatusBadRequest, "Path outside root")
+		return
+	}
+	if targetPath == h.cfg.DataRoot {
+		JSONError(w, http.StatusBadRequest, "Cannot delete the data root")
+		return
+	}
+
+	info, err := os.Lstat(targetPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			JSONError(w, http.StatusNotFound, "Directory not found")
+			return
+		}
+		JSONError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to stat: %v", err))
+		return
+	}
+	if !info.IsDir() {
+		JSONError(w, http.StatusBadRequest, "Path must be a directory")
+		return
+	}
+
+	report, err := h.deleteReport(targetPath)
+	if err != nil {
+		JSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if req.Token == "" {
+		report.Token = h.deleteToken(report.fingerprint, time.Now().Add(deleteTokenTTL))
+		JSONResponse(w, http.StatusOK, map[string]interface{}{
+			"ok":      true,
+			"dry_run": true,
+			"report":  report,
+		})
+		return
+	}
+
+	if err := h.checkDeleteToken(req.Token, report.fingerprint); err != nil {
+		// The new report can be confirmed instead
+		report.Token = h.deleteToken(report.fingerprint, time.Now().Add(deleteTokenTTL))
+		JSONResponse(w, http.StatusConflict, map[string]interface{}{
+			"error":  err.Error(),
+			"report": report,
+		})
+		return
+	}
+
+	h.deleteFolder(w, r, targetPath, report)
+}
+
+// deleteReport walks a folder and sorts its files between the ones linked
+// outside the folder and the ones whose data would be lost
+func (h *HardlinkHandler) deleteReport(root string) (*DeleteReport, error) {
+	rootDev, err := fsutil.Device(root)
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat %s: %w", root, err)
+	}
+
+	report := &DeleteReport{
+		Path: rootRelPath(h.cfg.DataRoot, root),
+		Lost: make([]DeleteReportItem, 0),
+		kept: make(map[string]bool),
+	}
+	inside := make(map[inodeID]uint64)
+	fingerprint := sha256.New()
+
+	err = filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		// Like the scanner and the indexer, the walk leaves @eaDir and the
+		// trash alone: they are kept with their parents
+		if d.IsDir() && fsutil.SkipDir(d.Name()) {
+			for dir := filepath.Dir(path); strings.HasPrefix(dir, root); dir = filepath.Dir(dir) {
+				report.kept[dir] = true
+			}
+			return fs.SkipDir
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		dev, ino, nlink, _ := fsutil.Identity(info)
+		fmt.Fprintf(fingerprint, "%s\x00%d\x00%d\x00%d\x00%d\n", path, dev, ino, nlink, info.Size())
+
+		if d.IsDir() {
+			// Another filesystem mounted in the folder must not be emptied
+			if dev != rootDev {
+				return fmt.Errorf("%s is a mount point", rootRelPath(h.cfg.DataRoot, path))
+			}
+			report.dirs = append(report.dirs, path)
+			return nil
+		}
+
+		report.entries = append(report.entries, deleteEntry{path: path, info: info})
+		if info.Mode().IsRegular() {
+			inside[inodeID{dev, ino}]++
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to walk directory: %w", err)
+	}
+
+	// A file is lost when every link of its inode is in the folder or in
+	// the trash. Its links are counted once for the lost data.
+	counted := make(map[inodeID]bool)
+	for i := range report.entries {
+		e := &report.entries[i]
+		if !e.info.Mode().IsRegular() {
+			report.Others++
+			continue
+		}
+		report.Files++
+		report.Bytes += e.info.Size()
+
+		dev, ino, nlink, _ := fsutil.Identity(e.info)
+		id := inodeID{dev, ino}
+		trashed, err := h.db.CountTrashedLinks(dev, ino)
+		if err != nil {
+			return nil, fmt.Errorf("failed to count trashed links: %w", err)
+		}
+		if nlink > inside[id]+uint64(trashed) {
+			e.linked = true
+			report.LinkedFiles++
+			report.LinkedBytes += e.info.Size()
+			continue
+		}
+
+		report.LostFiles++
+		if !counted[id] {
+			counted[id] = true
+			report.LostBytes += e.info.Size()
+		}
+		if len(report.Lost) < maxDeleteReportItems {
+			report.Lost = append(report.Lost, DeleteReportItem{
+				Path:  rootRelPath(h.cfg.DataRoot, e.path),
+				Size:  e.info.Size(),
+				Nlink: nlink,
+			})
+		} else {
+			report.Truncated = true
+		}
+	}
+	report.Dirs = len(report.dirs)
+	report.fingerprint = hex.EncodeToString(fingerprint.Sum(nil))
+
+	return report, nil
+}
+
+// deleteToken signs the fingerprint of a report until expires
+func (h *HardlinkHandler) deleteToken(fingerprint string, expires time.Time) string {
+	exp := strconv.FormatInt(expires.Unix(), 10)
+	mac := hmac.New(sha256.New, []byte(h.cfg.SecretKey))
+	mac.Write([]byte(exp + "." + fingerprint))
+	return exp + "." + hex.EncodeToString(mac.Sum(nil))
+}
+
+// checkDeleteToken verifies that a token confirms the report of fingerprint
+// and hasn't expired
+func (h *HardlinkHandler) checkDeleteToken(token, fingerprint string) error {
+	exp, _, ok := strings.Cut(token, ".")
+	expires, err := strconv.ParseInt(exp, 10, 64)
+	if !ok || err != nil {
+		return fmt.Errorf("invalid confirmation token")
+	}
+	if time.Now().Unix() > expires {
+		return fmt.Errorf("confirmation token expired")
+	}
+	if !hmac.Equal([]byte(token), []byte(h.deleteToken(fingerprint, time.Unix(expires, 0)))) {
+		return fmt.Errorf("the folder changed since the report")
+	}
+	return nil
+}
+
+// deleteFolder removes the files of a confirmed report, then its folders
+// deepest first. With the trash, the folder is moved there instead.
+func (h *HardlinkHandler) deleteFolder(w http.ResponseWriter, r *http.Request, root string, report *DeleteReport) {
+	username := GetUsername(r)
+
+	if h.trash != nil {
+		item, err := h.trash.Move(root, username)
+		if err != nil {
+			JSONError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to move to the trash: %v", err))
+			return
+		}
+
+		log.Printf("DELETE FOLDER %s files=%d dirs=%d lost=%d trashed by %s",
+			root, report.Files+report.Others, report.Dirs, report.LostFiles, username)
+		JSONResponse(w, http.StatusOK, map[string]interface{}{
+			"ok":            true,
+			"trash_id":      item.ID,
+			"trashed_files": report.Files + report.Others,
+			"trashed_dirs":  report.Dirs,
+			"trashed_bytes": item.Size,
+		})
+		return
+	}
+
+	failed := make([]DeleteReportItem, 0)
+	fail := func(path string, err error) {
+		failed = append(failed, DeleteReportItem{
+			Path:  rootRelPath(h.cfg.DataRoot, path),
+			Error: err.Error(),
+		})
+	}
+
+	deletedFiles := 0
+	var freedBytes int64
+	freed := make(map[inodeID]bool)
+	for _, e := range report.entries {
+		if err := os.Remove(e.path); err != nil {
+			fail(e.path, err)
+			continue
+		}
+		deletedFiles++
+
+		dev, ino, _, ok := fsutil.Identity(e.info)
+		if !ok || !e.info.Mode().IsRegular() {
+			continue
+		}
+		indexer.RemovePath(h.db, dev, ino, e.path)
+		// Only a link with a copy left can be restored
+		if e.linked {
+			h.journal.RecordUnlink(e.path, e.info, username)
+		} else if !freed[inodeID{dev, ino}] {
+			freed[inodeID{dev, ino}] = true
+			freedBytes += e.info.Size()
+		}
+	}
+
+	deletedDirs := 0
+	keptDirs := 0
+	for i := len(report.dirs) - 1; i >= 0; i-- {
+		if report.kept[report.dirs[i]] {
+			keptDirs++
+			continue
+		}
+		if err := os.Remove(report.dirs[i]); err != nil {
+			fail(report.dirs[i], err)
+			continue
+		}
+		deletedDirs++
+	}
+
+	log.Printf("DELETE FOLDER %s files=%d dirs=%d kept=%d lost=%d failed=%d by %s",
+		root, deletedFiles, deletedDirs, keptDirs, report.LostFiles, len(failed), username)
+	JSONResponse(w, http.StatusOK, map[string]interface{}{
+		"ok":            true,
+		"deleted_files": deletedFiles,
+		"deleted_dirs":  deletedDirs,
+		"kept_dirs":     keptDirs,
+		"freed_bytes":   freedBytes,
+		"failed":        failed,
+	})
+}
@@ -0,0 +1,139 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gosiva/hardlink-ui/internal/config"
+	"github.com/gosiva/hardlink-ui/internal/fsutil"
+	"github.com/gosiva/hardlink-ui/internal/storage"
+)
+
+// TestDeleteFolder verifies that a folder is only deleted once its report is
+// confirmed, and that the report tells lost files from linked ones
+func TestDeleteFolder(t *testing.T) {
+	tmpDir := t.TempDir()
+	dataDir := filepath.Join(tmpDir, "data")
+	seed := filepath.Join(dataDir, "downloads", "Film.2019")
+
+	write := func(path string, size int) {
+		t.Helper()
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Failed to create dir: %v", err)
+		}
+		if err := os.WriteFile(path, make([]byte, size), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", path, err)
+		}
+	}
+	link := func(src, dest string) {
+		t.Helper()
+		if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+			t.Fatalf("Failed to create dir: %v", err)
+		}
+		if err := os.Link(src, dest); err != nil {
+			t.Fatalf("Failed to link: %v", err)
+		}
+	}
+
+	// film.mkv is in the library, sample.mkv only twice in the folder
+	write(filepath.Join(seed, "film.mkv"), 1000)
+	link(filepath.Join(seed, "film.mkv"), filepath.Join(dataDir, "media", "Film (2019).mkv"))
+	write(filepath.Join(seed, "Sample", "sample.mkv"), 100)
+	link(filepath.Join(seed, "Sample", "sample.mkv"), filepath.Join(seed, "sample.mkv"))
+	write(filepath.Join(seed, "film.nfo"), 10)
+
+	db, err := storage.New(filepath.Join(tmpDir, "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+	defer db.Close()
+
+	cfg := &config.Config{DataRoot: dataDir, SecretKey: "secret"}
+	handler := NewHardlinkHandler(db, cfg, nil)
+
+	type deleteResponse struct {
+		Report       DeleteReport `json:"report"`
+		DeletedFiles int          `json:"deleted_files"`
+		DeletedDirs  int          `json:"deleted_dirs"`
+		KeptDirs     int          `json:"kept_dirs"`
+		FreedBytes   int64        `json:"freed_bytes"`
+	}
+	post := func(body DeleteFolderRequest, status int) deleteResponse {
+		t.Helper()
+		data, _ := json.Marshal(body)
+		req := httptest.NewRequest("POST", "/api/delete-folder", bytes.NewReader(data))
+		w := httptest.NewRecorder()
+		handler.DeleteFolder(w, req)
+		if w.Code != status {
+			t.Fatalf("Expected status %d, got %d: %s", status, w.Code, w.Body.String())
+		}
+		var resp deleteResponse
+		json.NewDecoder(w.Body).Decode(&resp)
+		return resp
+	}
+
+	resp := post(DeleteFolderRequest{Path: "/downloads/Film.2019"}, http.StatusOK)
+	report := resp.Report
+	if report.Files != 4 || report.Dirs != 2 || report.LinkedFiles != 1 || report.LinkedBytes != 1000 {
+		t.Errorf("Unexpected report %+v", report)
+	}
+	if report.LostFiles != 3 || report.LostBytes != 110 || len(report.Lost) != 3 || report.Token == "" {
+		t.Errorf("Expected 3 lost files of 110 bytes, got %+v", report)
+	}
+	if _, err := os.Stat(seed); err != nil {
+		t.Fatal("Expected the report to delete nothing")
+	}
+
+	// A changed folder invalidates the token
+	write(filepath.Join(seed, "film.srt"), 5)
+	resp = post(DeleteFolderRequest{Path: "/downloads/Film.2019", Token: report.Token}, http.StatusConflict)
+	if resp.Report.Files != 5 || resp.Report.Token == "" {
+		t.Errorf("Expected a new report, got %+v", resp.Report)
+	}
+	post(DeleteFolderRequest{Path: "/downloads/Film.2019", Token: "1.forged"}, http.StatusConflict)
+
+	resp = post(DeleteFolderRequest{Path: "/downloads/Film.2019", Token: resp.Report.Token}, http.StatusOK)
+	if resp.DeletedFiles != 5 || resp.DeletedDirs != 2 || resp.FreedBytes != 115 {
+		t.Errorf("Unexpected deletion result %+v", resp)
+	}
+	if _, err := os.Lstat(seed); !os.IsNotExist(err) {
+		t.Error("Expected the folder to be deleted")
+	}
+	if _, err := os.Stat(filepath.Join(dataDir, "media", "Film (2019).mkv")); err != nil {
+		t.Error("Expected the library link to be kept")
+	}
+
+	// @eaDir and the trash are neither reported nor deleted, nor are their
+	// parents
+	show := filepath.Join(dataDir, "downloads", "Show")
+	write(filepath.Join(show, "e01.mkv"), 20)
+	write(filepath.Join(show, "Season 1", "@eaDir", "e02.mkv", "SYNOINDEX_MEDIA_INFO"), 30)
+	write(filepath.Join(show, "Season 1", "e02.mkv"), 40)
+	write(filepath.Join(show, fsutil.TrashDir(), "old.mkv"), 50)
+	resp = post(DeleteFolderRequest{Path: "/downloads/Show"}, http.StatusOK)
+	if resp.Report.Files != 2 || resp.Report.Dirs != 2 || resp.Report.LostBytes != 60 {
+		t.Errorf("Expected the skipped directories out of the report, got %+v", resp.Report)
+	}
+	resp = post(DeleteFolderRequest{Path: "/downloads/Show", Token: resp.Report.Token}, http.StatusOK)
+	if resp.DeletedFiles != 2 || resp.DeletedDirs != 0 || resp.KeptDirs != 2 {
+		t.Errorf("Expected the files deleted and the directories kept, got %+v", resp)
+	}
+	for _, path := range []string{
+		filepath.Join(show, "Season 1", "@eaDir", "e02.mkv", "SYNOINDEX_MEDIA_INFO"),
+		filepath.Join(show, fsutil.TrashDir(), "old.mkv"),
+	} {
+		if _, err := os.Stat(path); err != nil {
+			t.Errorf("Expected %s to be kept", path)
+		}
+	}
+
+	post(DeleteFolderRequest{Path: "/"}, http.StatusBadRequest)
+	post(DeleteFolderRequest{Path: "/../outside"}, http.StatusBadRequest)
+	post(DeleteFolderRequest{Path: "/media/Film (2019).mkv"}, http.StatusBadRequest)
+	post(DeleteFolderRequest{Path: "/missing"}, http.StatusNotFound)
+}
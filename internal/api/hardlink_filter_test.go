@@ -0,0 +1,127 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gosiva/hardlink-ui/internal/config"
+	"github.com/gosiva/hardlink-ui/internal/storage"
+)
+
+// TestFolderFilters verifies that folder hardlinking only links the files
+// matching the filter rules
+func TestFolderFilters(t *testing.T) {
+	tmpDir := t.TempDir()
+	dbPath := filepath.Join(tmpDir, "test.db")
+	dataDir := filepath.Join(tmpDir, "data")
+
+	write := func(name string, size int) {
+		t.Helper()
+		path := filepath.Join(dataDir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Failed to create dir: %v", err)
+		}
+		if err := os.WriteFile(path, bytes.Repeat([]byte("x"), size), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+	}
+	exists := func(name string) bool {
+		_, err := os.Lstat(filepath.Join(dataDir, name))
+		return err == nil
+	}
+
+	write("film/film.mkv", 100)
+	write("film/film.nfo", 100)
+	write("film/film-sample.mkv", 100)
+	write("film/.film.mkv", 100)
+	write("film/tiny.mkv", 10)
+	write("film/extras/bonus.mkv", 100)
+	write("film/subs/film.srt", 100)
+	if err := os.MkdirAll(filepath.Join(dataDir, "film/empty"), 0755); err != nil {
+		t.Fatalf("Failed to create dir: %v", err)
+	}
+
+	db, err := storage.New(dbPath)
+	if err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+	defer db.Close()
+
+	cfg := &config.Config{DataRoot: dataDir}
+	handler := NewHardlinkHandler(db, cfg, nil)
+
+	type syncResponse struct {
+		Created     int `json:"created"`
+		Removed     int `json:"removed"`
+		Filtered    int `json:"filtered"`
+		DirsCreated int `json:"dirs_created"`
+	}
+	sync := func(body string, status int) syncResponse {
+		t.Helper()
+		req := httptest.NewRequest("POST", "/", bytes.NewBufferString(body))
+		w := httptest.NewRecorder()
+		handler.CreateHardlinksFolder(w, req)
+		if w.Code != status {
+			t.Fatalf("Expected status %d, got %d: %s", status, w.Code, w.Body.String())
+		}
+		var resp syncResponse
+		if status == http.StatusOK {
+			if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+		}
+		return resp
+	}
+
+	// Only the video files, without samples, hidden or tiny files, nor the
+	// excluded extras directory
+	resp := sync(`{"source":"/film","dest_root":"/movies","extensions":["mkv"],"exclude":["*sample*","/extras"],"min_size":50,"skip_hidden":true}`, http.StatusOK)
+	if resp.Created != 1 || resp.Filtered != 5 {
+		t.Fatalf("Expected 1 created and 5 filtered files, got %+v", resp)
+	}
+	if !exists("movies/film.mkv") {
+		t.Error("Expected movies/film.mkv to be linked")
+	}
+	for _, name := range []string{"movies/film.nfo", "movies/film-sample.mkv", "movies/.film.mkv", "movies/tiny.mkv", "movies/extras", "movies/subs", "movies/empty"} {
+		if exists(name) {
+			t.Errorf("Expected %s to be filtered out", name)
+		}
+	}
+
+	// Include patterns, with the source directories recreated
+	resp = sync(`{"source":"/film","dest_root":"/subs","include":["*.srt"],"create_dirs":true}`, http.StatusOK)
+	if resp.Created != 1 || resp.DirsCreated != 3 {
+		t.Fatalf("Expected 1 created file and 3 directories, got %+v", resp)
+	}
+	if !exists("subs/subs/film.srt") || !exists("subs/empty") || !exists("subs/extras") {
+		t.Error("Expected the source directories to be recreated")
+	}
+
+	// Mirror leaves the destination files not matching the filters alone
+	write("other/notes.txt", 10)
+	if err := os.Link(filepath.Join(dataDir, "other/notes.txt"), filepath.Join(dataDir, "movies/notes.txt")); err != nil {
+		t.Fatalf("Failed to link: %v", err)
+	}
+	if err := os.Link(filepath.Join(dataDir, "film/film.mkv"), filepath.Join(dataDir, "other/film.mkv")); err != nil {
+		t.Fatalf("Failed to link: %v", err)
+	}
+	if err := os.Remove(filepath.Join(dataDir, "film/film.mkv")); err != nil {
+		t.Fatalf("Failed to remove: %v", err)
+	}
+	resp = sync(`{"source":"/film","dest_root":"/movies","mode":"mirror","extensions":["mkv"]}`, http.StatusOK)
+	if resp.Removed != 1 || exists("movies/film.mkv") || !exists("movies/notes.txt") {
+		t.Fatalf("Expected only the matching file to be removed, got %+v", resp)
+	}
+
+	w := httptest.NewRecorder()
+	handler.CreateHardlinksFolder(w, httptest.NewRequest("POST", "/", bytes.NewBufferString(`{"source":"/film","dest_root":"/bad","exclude":["re:("]}`)))
+	if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "invalid exclude regex") {
+		t.Errorf("Expected invalid regex to be rejected, got %d: %s", w.Code, w.Body.String())
+	}
+}
@@ -0,0 +1,175 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/gosiva/hardlink-ui/internal/filter"
+	"github.com/gosiva/hardlink-ui/internal/fsutil"
+	"github.com/gosiva/hardlink-ui/internal/rename"
+)
+
+// Bounds of a rename preview
+const (
+	defaultPreviewLimit = 200
+	maxPreviewLimit     = 1000
+)
+
+// renamedPath returns the destination under destRoot of the source file at
+// path, rel being its path relative to the walked source. Without renamer,
+// the relative path is kept.
+func renamedPath(renamer *rename.Renamer, destRoot, path, rel string) (string, error) {
+	name, err := renamer.Apply(filepath.Base(filepath.Dir(path)), filepath.ToSlash(rel))
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(destRoot, filepath.FromSlash(name)), nil
+}
+
+// RenamePreviewRequest represents a rename template preview request
+type RenamePreviewRequest struct {
+	// Source is a file or a folder, as sent to CreateHardlink or
+	// CreateHardlinksFolder
+	Source   string `json:"source"`
+	DestRoot string `json:"dest_root"`
+	rename.Options
+	filter.Rules
+	Limit int `json:"limit"`
+}
+
+// RenamePreviewItem is the destination of a source file
+type RenamePreviewItem struct {
+	Source string `json:"source"`
+	Dest   string `json:"dest,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+// PreviewRename returns the destinations a rename template gives to the files
+// of a source, without touching the filesystem
+func (h *HardlinkHandler) PreviewRename(w http.ResponseWriter, r *http.Request) {
+	var req RenamePreviewRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		JSONError(w, http.StatusBadRequest, "Invalid request")
+		return
+	}
+
+	if req.Source == "" || req.DestRoot == "" {
+		JSONError(w, http.StatusBadRequest, "Source and destination root are required")
+		return
+	}
+
+	renamer, err := req.Options.Compile()
+	if err != nil {
+		JSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	matcher, err := req.Rules.Compile()
+	if err != nil {
+		JSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	limit := req.Limit
+	if limit <= 0 {
+		limit = defaultPreviewLimit
+	}
+	if limit > maxPreviewLimit {
+		limit = maxPreviewLimit
+	}
+
+	srcPath := filepath.Join(h.cfg.DataRoot, strings.TrimPrefix(req.Source, "/"))
+	srcPath = filepath.Clean(srcPath)
+
+	destRootPath := filepath.Join(h.cfg.DataRoot, strings.TrimPrefix(req.DestRoot, "/"))
+	destRootPath = filepath.Clean(destRootPath)
+
+	if !strings.HasPrefix(srcPath, h.cfg.DataRoot) || !strings.HasPrefix(destRootPath, h.cfg.DataRoot) {
+		JSONError(w, http.StatusBadRequest, "Path outside root")
+		return
+	}
+
+	srcInfo, err := os.Stat(srcPath)
+	if err != nil {
+		JSONError(w, http.StatusNotFound, fmt.Sprintf("Source not found: %v", err))
+		return
+	}
+
+	var items []RenamePreviewItem
+	total, filtered, unmatched, conflicts := 0, 0, 0, 0
+	targets := make(map[string]string)
+
+	preview := func(path, rel string) {
+		total++
+		item := RenamePreviewItem{Source: rootRelPath(h.cfg.DataRoot, path)}
+		destPath, err := renamedPath(renamer, destRootPath, path, rel)
+		switch {
+		case errors.Is(err, rename.ErrNoMatch):
+			unmatched++
+			item.Error = err.Error()
+		case err != nil:
+			item.Error = err.Error()
+		default:
+			item.Dest = rootRelPath(h.cfg.DataRoot, destPath)
+			if other, ok := targets[destPath]; ok {
+				conflicts++
+				item.Error = fmt.Sprintf("same destination as %s", rootRelPath(h.cfg.DataRoot, other))
+			} else {
+				targets[destPath] = path
+			}
+		}
+		if len(items) < limit {
+			items = append(items, item)
+		}
+	}
+
+	if !srcInfo.IsDir() {
+		// A single file is renamed into the destination folder
+		preview(srcPath, filepath.Base(srcPath))
+	} else {
+		err = filepath.WalkDir(srcPath, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return nil // skip errors
+			}
+			if d.IsDir() {
+				if fsutil.SkipDir(d.Name()) || (path != srcPath && matcher.Excluded(filterPath(srcPath, path))) {
+					return fs.SkipDir
+				}
+				return nil
+			}
+			info, err := d.Info()
+			if err != nil {
+				return nil
+			}
+			if !matcher.MatchFile(filterPath(srcPath, path), info.Size()) {
+				filtered++
+				return nil
+			}
+			rel, err := filepath.Rel(srcPath, path)
+			if err != nil {
+				return nil
+			}
+			preview(path, rel)
+			return nil
+		})
+		if err != nil {
+			JSONError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to walk directory: %v", err))
+			return
+		}
+	}
+
+	JSONResponse(w, http.StatusOK, map[string]interface{}{
+		"ok":        true,
+		"items":     items,
+		"total":     total,
+		"filtered":  filtered,
+		"unmatched": unmatched,
+		"conflicts": conflicts,
+		"truncated": total > len(items),
+	})
+}
@@ -0,0 +1,111 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gosiva/hardlink-ui/internal/config"
+	"github.com/gosiva/hardlink-ui/internal/storage"
+)
+
+// TestRenameTemplates verifies that hardlinks can be renamed with a template,
+// and that the preview matches what is linked
+func TestRenameTemplates(t *testing.T) {
+	tmpDir := t.TempDir()
+	dbPath := filepath.Join(tmpDir, "test.db")
+	dataDir := filepath.Join(tmpDir, "data")
+
+	write := func(name string) {
+		t.Helper()
+		path := filepath.Join(dataDir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Failed to create dir: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(name), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+	}
+	exists := func(name string) bool {
+		_, err := os.Lstat(filepath.Join(dataDir, name))
+		return err == nil
+	}
+
+	write("downloads/Show.S01E01.1080p.WEB/show.s01e01.mkv")
+	write("downloads/Show.S01E02.1080p.WEB/show.s01e02.mkv")
+	write("downloads/Show.S01E02.1080p.WEB/show.s01e02.proper.mkv")
+	write("downloads/readme.txt")
+	write("movies/Film: The Sequel?.mkv")
+
+	db, err := storage.New(dbPath)
+	if err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+	defer db.Close()
+
+	cfg := &config.Config{DataRoot: dataDir}
+	handler := NewHardlinkHandler(db, cfg, nil)
+
+	post := func(handle http.HandlerFunc, body string, status int, resp interface{}) {
+		t.Helper()
+		req := httptest.NewRequest("POST", "/", bytes.NewBufferString(body))
+		w := httptest.NewRecorder()
+		handle(w, req)
+		if w.Code != status {
+			t.Fatalf("Expected status %d, got %d: %s", status, w.Code, w.Body.String())
+		}
+		if resp != nil {
+			if err := json.NewDecoder(w.Body).Decode(resp); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+		}
+	}
+
+	const episodes = `"source":"/downloads","dest_root":"/tv/Show","template":"Season {season}/Show - S{season}E{episode}.{ext}","pattern":"(?i)s(?P<season>\\d+)e(?P<episode>\\d+)"`
+
+	// The preview gives the destinations, the files not matching the
+	// pattern and the conflicts
+	var preview struct {
+		Items     []RenamePreviewItem `json:"items"`
+		Total     int                 `json:"total"`
+		Unmatched int                 `json:"unmatched"`
+		Conflicts int                 `json:"conflicts"`
+	}
+	post(handler.PreviewRename, `{`+episodes+`}`, http.StatusOK, &preview)
+	if preview.Total != 4 || preview.Unmatched != 1 || preview.Conflicts != 1 {
+		t.Fatalf("Expected 4 files, 1 unmatched and 1 conflict, got %+v", preview)
+	}
+	if preview.Items[0].Dest != "/tv/Show/Season 01/Show - S01E01.mkv" {
+		t.Errorf("Unexpected destination %q", preview.Items[0].Dest)
+	}
+	if exists("tv") {
+		t.Error("Expected the preview to leave the filesystem untouched")
+	}
+
+	// The folder is linked as previewed
+	var resp struct {
+		Created  int `json:"created"`
+		Filtered int `json:"filtered"`
+	}
+	post(handler.CreateHardlinksFolder, `{`+episodes+`}`, http.StatusOK, &resp)
+	if resp.Created != 2 || resp.Filtered != 1 {
+		t.Fatalf("Expected 2 created and 1 filtered files, got %+v", resp)
+	}
+	if !exists("tv/Show/Season 01/Show - S01E01.mkv") || !exists("tv/Show/Season 01/Show - S01E02.mkv") {
+		t.Error("Expected the episodes to be linked with their new names")
+	}
+
+	// Illegal characters are sanitized, and a template can't leave the
+	// destination
+	post(handler.CreateHardlink, `{"source":"/movies/Film: The Sequel?.mkv","dest":"/library","template":"{parent}/../../{name}.{ext}"}`, http.StatusOK, nil)
+	if !exists("library/movies/Film_ The Sequel_.mkv") {
+		t.Error("Expected the file name to be sanitized")
+	}
+
+	post(handler.CreateHardlink, `{"source":"/movies/Film: The Sequel?.mkv","dest":"/library","template":"{title}"}`, http.StatusBadRequest, nil)
+	post(handler.CreateHardlinksFolder, `{"source":"/downloads","dest_root":"/tv","template":"{name}","create_dirs":true}`, http.StatusBadRequest, nil)
+}
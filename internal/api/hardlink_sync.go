@@ -0,0 +1,570 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+
+	"github.com/gosiva/hardlink-ui/internal/filter"
+	"github.com/gosiva/hardlink-ui/internal/fsutil"
+	"github.com/gosiva/hardlink-ui/internal/indexer"
+	"github.com/gosiva/hardlink-ui/internal/jobs"
+	"github.com/gosiva/hardlink-ui/internal/journal"
+	"github.com/gosiva/hardlink-ui/internal/rename"
+	"github.com/gosiva/hardlink-ui/internal/storage"
+)
+
+// SyncMode is how CreateHardlinksFolder treats the destination folder
+type SyncMode string
+
+// Sync modes
+const (
+	// SyncAdd only links the files missing from the destination
+	SyncAdd SyncMode = "add"
+	// SyncReplace also relinks the destination files that aren't the
+	// source's file, e.g. after an upgrade of the source, except the last
+	// link to a file without overwrite
+	SyncReplace SyncMode = "replace"
+	// SyncMirror also removes the destination files whose source vanished,
+	// except the last link to a file
+	SyncMirror SyncMode = "mirror"
+)
+
+// ParseSyncMode validates a sync mode, empty meaning SyncAdd
+func ParseSyncMode(value string) (SyncMode, error) {
+	switch mode := SyncMode(value); mode {
+	case "":
+		return SyncAdd, nil
+	case SyncAdd, SyncReplace, SyncMirror:
+		return mode, nil
+	default:
+		return "", fmt.Errorf("unknown sync mode %q", value)
+	}
+}
+
+// Actions and statuses of a LinkPlanItem
+const (
+	actionLink    = "link"
+	actionReplace = "replace"
+	actionRemove  = "remove"
+
+	linkPlanned   = "planned"
+	linkDone      = "done"
+	linkExists    = "exists"
+	linkProtected = "protected"
+	linkFailed    = "failed"
+)
+
+// folderSync hardlinks the files of a source folder into a destination
+// folder and reports the differences
+type folderSync struct {
+	h          *HardlinkHandler
+	srcPath    string
+	destPath   string
+	mode       SyncMode
+	overwrite  bool
+	matcher    *filter.Matcher
+	renamer    *rename.Renamer
+	createDirs bool
+	dryRun     bool
+	username   string
+	tracker    *jobs.Tracker
+
+	created, replaced, removed      int
+	unchanged, differing, protected int
+	planned, filtered, dirs         int
+	bytesSaved                      int64
+	errors                          []string
+	plan                            []LinkPlanItem    // dry run: every file
+	diff                            []LinkPlanItem    // every file but the unchanged ones
+	targets                         map[string]string // renamed destination -> source
+}
+
+// newFolderSync creates the sync of srcPath into destPath requested by req
+func (h *HardlinkHandler) newFolderSync(srcPath, destPath string, req CreateHardlinksFolderRequest, matcher *filter.Matcher, renamer *rename.Renamer, username string) *folderSync {
+	return &folderSync{
+		h:          h,
+		srcPath:    srcPath,
+		destPath:   destPath,
+		mode:       req.Mode,
+		overwrite:  req.Overwrite,
+		matcher:    matcher,
+		renamer:    renamer,
+		createDirs: req.CreateDirs,
+		dryRun:     req.DryRun,
+		username:   username,
+		targets:    make(map[string]string),
+	}
+}
+
+// count returns the number of files run goes through
+func (s *folderSync) count() int {
+	count := countFiles(s.srcPath, s.matcher)
+	if s.mode == SyncMirror {
+		count += countFiles(s.destPath, s.matcher)
+	}
+	return count
+}
+
+// skipDir reports whether the walk of root skips the directory at path:
+// @eaDir, the trash and the excluded directories
+func (s *folderSync) skipDir(root, path string, d fs.DirEntry) bool {
+	return fsutil.SkipDir(d.Name()) || (path != root && s.matcher.Excluded(filterPath(root, path)))
+}
+
+// run syncs the folders and returns the response of CreateHardlinksFolder.
+// Each file is reported to tracker, if not nil, and run stops when ctx is
+// cancelled.
+func (s *folderSync) run(ctx context.Context, tracker *jobs.Tracker) (map[string]interface{}, error) {
+	s.tracker = tracker
+
+	log.Printf("HARDLINK FOLDER START src=%s dest=%s mode=%s dry_run=%v by %s", s.srcPath, s.destPath, s.mode, s.dryRun, s.username)
+
+	// Walk source directory
+	err := filepath.WalkDir(s.srcPath, func(path string, d fs.DirEntry, err error) error {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if err != nil {
+			return nil // skip errors
+		}
+
+		// Skip @eaDir, the trash and excluded directories
+		if d.IsDir() && s.skipDir(s.srcPath, path, d) {
+			return fs.SkipDir
+		}
+
+		if !d.IsDir() {
+			s.linkFile(path, d)
+		} else if s.createDirs && path != s.srcPath {
+			s.createDir(path)
+		}
+		return nil
+	})
+
+	if err == nil && s.mode == SyncMirror {
+		err = s.prune(ctx)
+	}
+
+	// A cancelled job reports the files synced so far
+	if err != nil && ctx.Err() == nil {
+		return nil, err
+	}
+
+	log.Printf("HARDLINK FOLDER END src=%s dest=%s created=%d replaced=%d removed=%d planned=%d filtered=%d errors=%d",
+		s.srcPath, s.destPath, s.created, s.replaced, s.removed, s.planned, s.filtered, len(s.errors))
+
+	return s.response(), nil
+}
+
+// linkFile links a source file into the destination, according to the mode
+func (s *folderSync) linkFile(path string, d fs.DirEntry) {
+	relPath, err := filepath.Rel(s.srcPath, path)
+	if err != nil {
+		s.record(path, path, 0, actionLink, linkFailed, err)
+		return
+	}
+
+	// Destination path, files not matching the rename pattern are filtered
+	destPath, err := renamedPath(s.renamer, s.destPath, path, relPath)
+	if errors.Is(err, rename.ErrNoMatch) {
+		s.filtered++
+		s.step(rootRelPath(s.h.cfg.DataRoot, path), nil)
+		return
+	} else if err != nil {
+		s.record(path, path, 0, actionLink, linkFailed, err)
+		return
+	}
+
+	info, err := d.Info()
+	if err != nil {
+		s.record(path, destPath, 0, actionLink, linkFailed, err)
+		return
+	}
+	size := info.Size()
+
+	if !s.matcher.MatchFile(filterPath(s.srcPath, path), size) {
+		s.filtered++
+		s.step(rootRelPath(s.h.cfg.DataRoot, path), nil)
+		return
+	}
+
+	// A template may give several files the same name: the first one wins
+	if s.renamer != nil {
+		if other, ok := s.targets[destPath]; ok {
+			s.record(path, destPath, size, actionLink, linkFailed, fmt.Errorf("same destination as %s", rootRelPath(s.h.cfg.DataRoot, other)))
+			return
+		}
+		s.targets[destPath] = path
+	}
+
+	action := actionLink
+	destInfo, err := os.Lstat(destPath)
+	if err == nil {
+		switch {
+		case os.SameFile(info, destInfo):
+			s.record(path, destPath, size, "", linkExists, nil)
+			return
+		case s.mode == SyncAdd:
+			// Kept, but reported as differing
+			s.add(LinkPlanItem{
+				Source:  rootRelPath(s.h.cfg.DataRoot, path),
+				Dest:    rootRelPath(s.h.cfg.DataRoot, destPath),
+				Size:    size,
+				Status:  linkExists,
+				Differs: true,
+			}, nil)
+			return
+		case !destInfo.Mode().IsRegular():
+			s.record(path, destPath, size, actionReplace, linkFailed, fmt.Errorf("destination is not a regular file"))
+			return
+		}
+		if !s.overwrite {
+			last, err := s.lastLink(destInfo)
+			if err != nil {
+				s.record(path, destPath, size, actionReplace, linkFailed, err)
+				return
+			}
+			if last {
+				// Its data would be lost
+				s.record(path, destPath, size, actionReplace, linkProtected, nil)
+				return
+			}
+		}
+		action = actionReplace
+	}
+
+	destDir := filepath.Dir(destPath)
+	if s.dryRun {
+		// Parent directories would be created: check the nearest existing one
+		if err := fsutil.Writable(destDir); err != nil {
+			s.record(path, destPath, size, action, linkFailed, err)
+			return
+		}
+	} else if err := os.MkdirAll(destDir, 0755); err != nil {
+		// Create parent directory
+		s.record(path, destPath, size, action, linkFailed, fmt.Errorf("failed to create directory: %w", err))
+		return
+	}
+
+	// Nested mount points may still put a file on another filesystem
+	srcDev, srcIno, _, _ := fsutil.Identity(info)
+	if destDev, err := fsutil.Device(destDir); err == nil && destDev != srcDev {
+		s.record(path, destPath, size, action, linkFailed, fsutil.ErrCrossDevice)
+		return
+	}
+
+	if s.dryRun {
+		s.bytesSaved += size
+		s.record(path, destPath, size, action, linkPlanned, nil)
+		return
+	}
+
+	if action == actionReplace {
+		err = s.replace(path, destPath, destInfo)
+	} else {
+		err = os.Link(path, destPath)
+	}
+	if err != nil {
+		if fsutil.IsCrossDevice(err) {
+			err = fsutil.ErrCrossDevice
+		}
+		s.record(path, destPath, size, action, linkFailed, err)
+		return
+	}
+
+	// Update inode index
+	indexer.AddPath(s.h.db, srcDev, srcIno, destPath)
+	s.h.journal.RecordLink(destPath, path, info, s.username)
+
+	s.record(path, destPath, size, action, linkDone, nil)
+}
+
+// createDir creates the destination of a source directory
+func (s *folderSync) createDir(path string) {
+	relPath, err := filepath.Rel(s.srcPath, path)
+	if err != nil {
+		return
+	}
+	destDir := filepath.Join(s.destPath, relPath)
+	if _, err := os.Lstat(destDir); err == nil {
+		return
+	}
+
+	if s.dryRun {
+		if err := fsutil.Writable(destDir); err != nil {
+			s.errors = append(s.errors, fmt.Sprintf("%s: %v", filepath.Base(destDir), err))
+			return
+		}
+	} else if err := os.MkdirAll(destDir, 0755); err != nil {
+		s.errors = append(s.errors, fmt.Sprintf("%s: failed to create directory: %v", filepath.Base(destDir), err))
+		return
+	}
+	s.dirs++
+}
+
+// replace relinks a destination file to its source. The hardlink is renamed
+// over the file, so the destination path always names a file. When the trash
+// is enabled, the replaced file is first linked into it.
+func (s *folderSync) replace(path, destPath string, destInfo os.FileInfo) error {
+	tmpPath, err := journal.TempLinkPath(destPath)
+	if err != nil {
+		return err
+	}
+	if err := os.Link(path, tmpPath); err != nil {
+		return err
+	}
+
+	var item *storage.TrashItem
+	if s.h.trash != nil {
+		if item, err = s.h.trash.Keep(destPath, s.username); err != nil {
+			_ = os.Remove(tmpPath)
+			return fmt.Errorf("failed to move to the trash: %w", err)
+		}
+	}
+	if err := os.Rename(tmpPath, destPath); err != nil {
+		_ = os.Remove(tmpPath)
+		if item != nil {
+			if _, terr := s.h.trash.Delete(item.ID, s.username); terr != nil {
+				log.Printf("Failed to delete trash item %d: %v", item.ID, terr)
+			}
+		}
+		return fmt.Errorf("failed to replace destination: %w", err)
+	}
+
+	if dev, ino, _, ok := fsutil.Identity(destInfo); ok {
+		indexer.RemovePath(s.h.db, dev, ino, destPath)
+	}
+	// Without the trash, the replaced file is journaled as an unlink,
+	// restorable while it has other links
+	if item == nil {
+		s.h.journal.RecordUnlink(destPath, destInfo, s.username)
+	}
+	return nil
+}
+
+// prune removes the destination files whose source vanished, moving them to
+// the trash when it is enabled. Like DeleteHardlink, it keeps the last link
+// to a file. Files not matching the rules are left alone.
+func (s *folderSync) prune(ctx context.Context) error {
+	return filepath.WalkDir(s.destPath, func(path string, d fs.DirEntry, err error) error {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if err != nil {
+			return nil // skip errors
+		}
+		if d.IsDir() && s.skipDir(s.destPath, path, d) {
+			return fs.SkipDir
+		}
+		if d.IsDir() {
+			return nil
+		}
+
+		if s.hasSource(path) {
+			// Already reported with its source
+			s.step(rootRelPath(s.h.cfg.DataRoot, path), nil)
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			s.record("", path, 0, actionRemove, linkFailed, err)
+			return nil
+		}
+		if !s.matcher.MatchFile(filterPath(s.destPath, path), info.Size()) {
+			s.filtered++
+			s.step(rootRelPath(s.h.cfg.DataRoot, path), nil)
+			return nil
+		}
+		dev, ino, _, _ := fsutil.Identity(info)
+
+		last, err := s.lastLink(info)
+		switch {
+		case err != nil:
+			s.record("", path, info.Size(), actionRemove, linkFailed, err)
+		case last:
+			s.record("", path, info.Size(), actionRemove, linkProtected, nil)
+		case s.dryRun:
+			s.record("", path, info.Size(), actionRemove, linkPlanned, nil)
+		case s.h.trash != nil:
+			if _, err := s.h.trash.Move(path, s.username); err != nil {
+				s.record("", path, info.Size(), actionRemove, linkFailed, err)
+				return nil
+			}
+			s.record("", path, info.Size(), actionRemove, linkDone, nil)
+		default:
+			if err := os.Remove(path); err != nil {
+				s.record("", path, info.Size(), actionRemove, linkFailed, err)
+				return nil
+			}
+			indexer.RemovePath(s.h.db, dev, ino, path)
+			s.h.journal.RecordUnlink(path, info, s.username)
+			s.record("", path, info.Size(), actionRemove, linkDone, nil)
+		}
+		return nil
+	})
+}
+
+// lastLink reports whether a file is the last link to its data, the links in
+// the trash being as good as deleted
+func (s *folderSync) lastLink(info os.FileInfo) (bool, error) {
+	dev, ino, nlink, _ := fsutil.Identity(info)
+	trashed, err := s.h.db.CountTrashedLinks(dev, ino)
+	if err != nil {
+		return false, fmt.Errorf("failed to count trashed links: %w", err)
+	}
+	return nlink <= 1+uint64(trashed), nil
+}
+
+// hasSource reports whether the destination file at path has a source: a
+// renamed file linked by this run, or the file at the same relative path
+func (s *folderSync) hasSource(path string) bool {
+	if s.renamer != nil {
+		_, ok := s.targets[path]
+		return ok
+	}
+	relPath, err := filepath.Rel(s.destPath, path)
+	if err != nil {
+		return true
+	}
+	_, err = os.Lstat(filepath.Join(s.srcPath, relPath))
+	return err == nil
+}
+
+// record adds a file to the report. src is empty for a destination file
+// without source.
+func (s *folderSync) record(src, dest string, size int64, action, status string, err error) {
+	item := LinkPlanItem{
+		Dest:   rootRelPath(s.h.cfg.DataRoot, dest),
+		Size:   size,
+		Action: action,
+		Status: status,
+	}
+	if src != "" {
+		item.Source = rootRelPath(s.h.cfg.DataRoot, src)
+	}
+	s.add(item, err)
+}
+
+// add counts a file of the report and reports it to the tracker
+func (s *folderSync) add(item LinkPlanItem, err error) {
+	if err != nil {
+		item.Error = err.Error()
+		s.errors = append(s.errors, fmt.Sprintf("%s: %v", path.Base(item.Dest), err))
+	}
+
+	switch item.Status {
+	case linkPlanned:
+		s.planned++
+	case linkDone:
+		switch item.Action {
+		case actionLink:
+			s.created++
+		case actionReplace:
+			s.replaced++
+		case actionRemove:
+			s.removed++
+		}
+	case linkExists:
+		if item.Differs {
+			s.differing++
+		} else {
+			s.unchanged++
+		}
+	case linkProtected:
+		s.protected++
+	}
+
+	if s.dryRun {
+		s.plan = append(s.plan, item)
+	}
+	if item.Status != linkExists || item.Differs {
+		s.diff = append(s.diff, item)
+	}
+
+	if item.Source != "" {
+		s.step(item.Source, err)
+	} else {
+		s.step(item.Dest, err)
+	}
+}
+
+// step reports a processed file to the tracker
+func (s *folderSync) step(item string, err error) {
+	if s.tracker != nil {
+		s.tracker.Step(item, err)
+	}
+}
+
+// response builds the response of CreateHardlinksFolder
+func (s *folderSync) response() map[string]interface{} {
+	if s.diff == nil {
+		s.diff = make([]LinkPlanItem, 0)
+	}
+
+	response := map[string]interface{}{
+		"ok":           true,
+		"mode":         s.mode,
+		"created":      s.created,
+		"replaced":     s.replaced,
+		"removed":      s.removed,
+		"unchanged":    s.unchanged,
+		"differing":    s.differing,
+		"protected":    s.protected,
+		"filtered":     s.filtered,
+		"dirs_created": s.dirs,
+		"diff":         s.diff,
+	}
+
+	if s.dryRun {
+		if s.plan == nil {
+			s.plan = make([]LinkPlanItem, 0)
+		}
+		response["dry_run"] = true
+		response["planned"] = s.planned
+		response["existing"] = s.unchanged + s.differing
+		response["bytes_saved"] = s.bytesSaved
+		response["bytes_saved_human"] = humanSize(s.bytesSaved)
+		response["plan"] = s.plan
+	}
+
+	if len(s.errors) > 0 {
+		response["errors"] = s.errors
+	}
+
+	return response
+}
+
+// filterPath returns the path matched by filter rules: relative to root,
+// with a leading slash
+func filterPath(root, path string) string {
+	rel, err := filepath.Rel(root, path)
+	if err != nil {
+		return path
+	}
+	return "/" + filepath.ToSlash(rel)
+}
+
+// countFiles counts the files of a folder walked by folderSync
+func countFiles(root string, matcher *filter.Matcher) int {
+	count := 0
+	filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if d.IsDir() && (fsutil.SkipDir(d.Name()) || (path != root && matcher.Excluded(filterPath(root, path)))) {
+			return fs.SkipDir
+		}
+		if !d.IsDir() {
+			count++
+		}
+		return nil
+	})
+	return count
+}
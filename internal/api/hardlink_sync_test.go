@@ -0,0 +1,306 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gosiva/hardlink-ui/internal/config"
+	"github.com/gosiva/hardlink-ui/internal/fsutil"
+	"github.com/gosiva/hardlink-ui/internal/storage"
+)
+
+// syncCounts are the counters of a folder hardlinking response
+type syncCounts struct {
+	Created   int `json:"created"`
+	Replaced  int `json:"replaced"`
+	Removed   int `json:"removed"`
+	Unchanged int `json:"unchanged"`
+	Differing int `json:"differing"`
+	Protected int `json:"protected"`
+	Planned   int `json:"planned"`
+}
+
+type syncResponse struct {
+	syncCounts
+	Diff []LinkPlanItem `json:"diff"`
+}
+
+// TestFolderSyncModes verifies that folder hardlinking adds, replaces and
+// prunes destination files according to the sync mode
+func TestFolderSyncModes(t *testing.T) {
+	tmpDir := t.TempDir()
+	dataDir := filepath.Join(tmpDir, "data")
+
+	write := func(name, content string) {
+		t.Helper()
+		path := filepath.Join(dataDir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Failed to create dir: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+	}
+	sameFile := func(a, b string) bool {
+		infoA, errA := os.Stat(filepath.Join(dataDir, a))
+		infoB, errB := os.Stat(filepath.Join(dataDir, b))
+		return errA == nil && errB == nil && os.SameFile(infoA, infoB)
+	}
+	exists := func(name string) bool {
+		_, err := os.Lstat(filepath.Join(dataDir, name))
+		return err == nil
+	}
+
+	write("library/a.mkv", "a")
+	write("library/b.mkv", "b")
+	write("library/sub/c.nfo", "c")
+	write("library/d.mkv", "d")
+	write("other/x.mkv", "x")
+
+	db, err := storage.New(filepath.Join(tmpDir, "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+	defer db.Close()
+
+	cfg := &config.Config{DataRoot: dataDir}
+	handler := NewHardlinkHandler(db, cfg, nil)
+
+	sync := func(t *testing.T, body string, status int) syncResponse {
+		t.Helper()
+		req := httptest.NewRequest("POST", "/", bytes.NewBufferString(body))
+		w := httptest.NewRecorder()
+		handler.CreateHardlinksFolder(w, req)
+		if w.Code != status {
+			t.Fatalf("Expected status %d, got %d: %s", status, w.Code, w.Body.String())
+		}
+		var resp syncResponse
+		if status == http.StatusOK {
+			if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+		}
+		return resp
+	}
+
+	resp := sync(t, `{"source":"/library","dest_root":"/seed"}`, http.StatusOK)
+	if resp.Created != 4 || len(resp.Diff) != 4 {
+		t.Fatalf("Expected 4 created links, got %+v", resp)
+	}
+
+	// The library upgrades a.mkv, keeping the old file elsewhere, and
+	// deletes b.mkv. The seed folder has its own d.mkv, and a link to a file
+	// outside the library.
+	if err := os.Rename(filepath.Join(dataDir, "library/a.mkv"), filepath.Join(dataDir, "other/a.old.mkv")); err != nil {
+		t.Fatalf("Failed to rename: %v", err)
+	}
+	write("library/a.mkv", "a upgraded")
+	if err := os.Remove(filepath.Join(dataDir, "library/b.mkv")); err != nil {
+		t.Fatalf("Failed to remove: %v", err)
+	}
+	if err := os.Remove(filepath.Join(dataDir, "seed/d.mkv")); err != nil {
+		t.Fatalf("Failed to remove: %v", err)
+	}
+	write("seed/d.mkv", "d edited")
+	if err := os.Link(filepath.Join(dataDir, "other/x.mkv"), filepath.Join(dataDir, "seed/x.mkv")); err != nil {
+		t.Fatalf("Failed to link: %v", err)
+	}
+
+	// Each step runs on the result of the previous one
+	steps := []struct {
+		name  string
+		body  string
+		want  syncCounts
+		check func(t *testing.T, resp syncResponse)
+	}{
+		{
+			name: "add reports the differing files",
+			body: `{"source":"/library","dest_root":"/seed","mode":"add"}`,
+			want: syncCounts{Differing: 2, Unchanged: 1},
+			check: func(t *testing.T, resp syncResponse) {
+				if len(resp.Diff) != 2 || !resp.Diff[0].Differs {
+					t.Errorf("Expected 2 differing files in the diff, got %+v", resp.Diff)
+				}
+			},
+		},
+		{
+			name: "dry run plans the replacement",
+			body: `{"source":"/library","dest_root":"/seed","mode":"replace","dry_run":true}`,
+			want: syncCounts{Planned: 1, Protected: 1, Unchanged: 1},
+			check: func(t *testing.T, resp syncResponse) {
+				if resp.Diff[0].Action != "replace" {
+					t.Errorf("Expected a planned replacement, got %+v", resp.Diff[0])
+				}
+				if sameFile("library/a.mkv", "seed/a.mkv") {
+					t.Error("Expected dry run to leave the destination untouched")
+				}
+			},
+		},
+		{
+			name: "replace protects the last link",
+			body: `{"source":"/library","dest_root":"/seed","mode":"replace"}`,
+			want: syncCounts{Replaced: 1, Protected: 1, Unchanged: 1},
+			check: func(t *testing.T, resp syncResponse) {
+				if !sameFile("library/a.mkv", "seed/a.mkv") {
+					t.Error("Expected seed/a.mkv to be relinked to the upgraded file")
+				}
+				if sameFile("library/d.mkv", "seed/d.mkv") {
+					t.Error("Expected seed/d.mkv to be kept")
+				}
+			},
+		},
+		{
+			name: "overwrite replaces the last link",
+			body: `{"source":"/library","dest_root":"/seed","mode":"replace","overwrite":true}`,
+			want: syncCounts{Replaced: 1, Unchanged: 2},
+			check: func(t *testing.T, resp syncResponse) {
+				if !sameFile("library/d.mkv", "seed/d.mkv") {
+					t.Error("Expected seed/d.mkv to be overwritten")
+				}
+			},
+		},
+		{
+			name: "mirror removes the links whose source vanished",
+			body: `{"source":"/library","dest_root":"/seed","mode":"mirror"}`,
+			want: syncCounts{Removed: 1, Protected: 1, Unchanged: 3},
+			check: func(t *testing.T, resp syncResponse) {
+				if exists("seed/x.mkv") {
+					t.Error("Expected seed/x.mkv to be removed")
+				}
+				if !exists("other/x.mkv") || !exists("seed/b.mkv") {
+					t.Error("Expected other/x.mkv and the last link seed/b.mkv to be kept")
+				}
+			},
+		},
+	}
+
+	for _, step := range steps {
+		t.Run(step.name, func(t *testing.T) {
+			resp := sync(t, step.body, http.StatusOK)
+			if resp.syncCounts != step.want {
+				t.Fatalf("Expected %+v, got %+v", step.want, resp.syncCounts)
+			}
+			step.check(t, resp)
+		})
+	}
+
+	sync(t, `{"source":"/library","dest_root":"/library/seed","mode":"mirror"}`, http.StatusBadRequest)
+	sync(t, `{"source":"/library","dest_root":"/seed","mode":"sideways"}`, http.StatusBadRequest)
+}
+
+// TestFolderSyncTrash verifies that, with the trash enabled, the replaced and
+// pruned destination files go to the trash, and that a file whose other
+// links are in the trash is protected
+func TestFolderSyncTrash(t *testing.T) {
+	tmpDir := t.TempDir()
+	dataDir := filepath.Join(tmpDir, "data")
+
+	write := func(name, content string) {
+		t.Helper()
+		path := filepath.Join(dataDir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Failed to create dir: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+	}
+	nlink := func(name string) uint64 {
+		t.Helper()
+		info, err := os.Lstat(filepath.Join(dataDir, name))
+		if err != nil {
+			t.Fatalf("Failed to stat %s: %v", name, err)
+		}
+		_, _, n, _ := fsutil.Identity(info)
+		return n
+	}
+	exists := func(name string) bool {
+		_, err := os.Lstat(filepath.Join(dataDir, name))
+		return err == nil
+	}
+
+	write("library/a.mkv", "a")
+	write("library/k.mkv", "k")
+	write("library/gone.mkv", "gone")
+
+	db, err := storage.New(filepath.Join(tmpDir, "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+	defer db.Close()
+
+	cfg := &config.Config{DataRoot: dataDir, SecretKey: "secret", TrashEnabled: true, TrashRetentionDays: 30}
+	handler := NewHardlinkHandler(db, cfg, nil)
+
+	post := func(handle http.HandlerFunc, body string) syncResponse {
+		t.Helper()
+		req := httptest.NewRequest("POST", "/", bytes.NewBufferString(body))
+		w := httptest.NewRecorder()
+		handle(w, req)
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+		}
+		var resp syncResponse
+		if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+		return resp
+	}
+
+	post(handler.CreateHardlinksFolder, `{"source":"/library","dest_root":"/seed"}`)
+
+	// The library upgrades a.mkv, keeping the old file elsewhere, and
+	// deletes gone.mkv to the trash. The seed folder has a link to a file
+	// outside the library.
+	if err := os.MkdirAll(filepath.Join(dataDir, "other"), 0755); err != nil {
+		t.Fatalf("Failed to create dir: %v", err)
+	}
+	if err := os.Rename(filepath.Join(dataDir, "library/a.mkv"), filepath.Join(dataDir, "other/a.old.mkv")); err != nil {
+		t.Fatalf("Failed to rename: %v", err)
+	}
+	write("library/a.mkv", "a upgraded")
+	post(handler.DeleteHardlink, `{"path":"/library/gone.mkv"}`)
+	write("other/p.mkv", "p")
+	if err := os.Link(filepath.Join(dataDir, "other/p.mkv"), filepath.Join(dataDir, "seed/p.mkv")); err != nil {
+		t.Fatalf("Failed to link: %v", err)
+	}
+
+	resp := post(handler.CreateHardlinksFolder, `{"source":"/library","dest_root":"/seed","mode":"replace"}`)
+	if want := (syncCounts{Replaced: 1, Unchanged: 1}); resp.syncCounts != want {
+		t.Fatalf("Expected %+v, got %+v", want, resp.syncCounts)
+	}
+	if nlink("seed/a.mkv") != 2 {
+		t.Error("Expected seed/a.mkv to be relinked to the upgraded file")
+	}
+	if n := nlink("other/a.old.mkv"); n != 2 {
+		t.Errorf("Expected the replaced file to be kept in the trash, got %d links", n)
+	}
+
+	resp = post(handler.CreateHardlinksFolder, `{"source":"/library","dest_root":"/seed","mode":"mirror"}`)
+	if want := (syncCounts{Removed: 1, Protected: 1, Unchanged: 2}); resp.syncCounts != want {
+		t.Fatalf("Expected %+v, got %+v", want, resp.syncCounts)
+	}
+	if !exists("seed/gone.mkv") {
+		t.Error("Expected seed/gone.mkv, whose other link is trashed, to be protected")
+	}
+	if exists("seed/p.mkv") || nlink("other/p.mkv") != 2 {
+		t.Error("Expected seed/p.mkv to be moved to the trash")
+	}
+
+	items, total, _, err := db.ListTrashItems(10, 0)
+	if err != nil {
+		t.Fatalf("Failed to list the trash: %v", err)
+	}
+	paths := map[string]bool{}
+	for _, item := range items {
+		paths[item.Path] = true
+	}
+	if total != 3 || !paths["/seed/a.mkv"] || !paths["/seed/p.mkv"] || !paths["/library/gone.mkv"] {
+		t.Errorf("Expected the replaced and pruned files in the trash, got %+v", items)
+	}
+}
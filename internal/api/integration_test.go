@@ -35,7 +35,7 @@ func TestSSEIntegrationWithMiddleware(t *testing.T) {
 	scan := scanner.NewScanner(db, cfg.DataRoot)
 
 	// Create handlers
-	dupHandler := NewDuplicatesHandler(db, cfg, scan)
+	dupHandler := NewDuplicatesHandler(db, cfg, scan, nil)
 	middleware := NewMiddleware(db, cfg)
 
 	// Start a scan job
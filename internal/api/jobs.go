@@ -0,0 +1,158 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/gosiva/hardlink-ui/internal/config"
+	"github.com/gosiva/hardlink-ui/internal/jobs"
+	"github.com/gosiva/hardlink-ui/internal/storage"
+)
+
+// JobsHandler exposes the background jobs of bulk file operations
+type JobsHandler struct {
+	db   *storage.DB
+	cfg  *config.Config
+	jobs *jobs.Runner
+}
+
+// NewJobsHandler creates a new jobs handler
+func NewJobsHandler(db *storage.DB, cfg *config.Config, runner *jobs.Runner) *JobsHandler {
+	return &JobsHandler{
+		db:   db,
+		cfg:  cfg,
+		jobs: runner,
+	}
+}
+
+// JobSummary is a background job without its report
+type JobSummary struct {
+	JobID       string `json:"job_id"`
+	Kind        string `json:"kind"`
+	Status      string `json:"status"`
+	Total       int    `json:"total"`
+	Processed   int    `json:"processed"`
+	Failed      int    `json:"failed"`
+	Error       string `json:"error,omitempty"`
+	Username    string `json:"username,omitempty"`
+	StartedAt   int64  `json:"started_at"`
+	CompletedAt *int64 `json:"completed_at,omitempty"`
+}
+
+// ListJobs returns a page of background jobs, most recent first
+func (h *JobsHandler) ListJobs(w http.ResponseWriter, r *http.Request) {
+	limit := 50
+	if v, err := strconv.Atoi(r.URL.Query().Get("limit")); err == nil && v > 0 {
+		limit = min(v, 500)
+	}
+	offset := 0
+	if v, err := strconv.Atoi(r.URL.Query().Get("offset")); err == nil && v > 0 {
+		offset = v
+	}
+
+	list, total, err := h.db.ListJobs(limit, offset)
+	if err != nil {
+		JSONError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to list jobs: %v", err))
+		return
+	}
+
+	items := make([]JobSummary, 0, len(list))
+	for _, job := range list {
+		item := JobSummary{
+			JobID:     job.JobID,
+			Kind:      job.Kind,
+			Status:    job.Status,
+			Total:     job.Total,
+			Processed: job.Processed,
+			Failed:    job.Failed,
+			Error:     job.Error.String,
+			Username:  job.Username,
+			StartedAt: job.StartedAt,
+		}
+		if job.CompletedAt.Valid {
+			completedAt := job.CompletedAt.Int64
+			item.CompletedAt = &completedAt
+		}
+		// The database is only updated every few items
+		if job.Status == jobs.StatusRunning {
+			if progress, err := h.jobs.Get(job.JobID); err == nil && progress != nil {
+				item.Status = progress.Status
+				item.Total = progress.Total
+				item.Processed = progress.Processed
+				item.Failed = progress.Failed
+			}
+		}
+		items = append(items, item)
+	}
+
+	JSONResponse(w, http.StatusOK, map[string]interface{}{
+		"items": items,
+		"total": total,
+	})
+}
+
+// GetJob returns the progress of a job, with its report once finished
+func (h *JobsHandler) GetJob(w http.ResponseWriter, r *http.Request) {
+	jobID := chi.URLParam(r, "job_id")
+
+	progress, err := h.jobs.Get(jobID)
+	if err != nil {
+		JSONError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to get job: %v", err))
+		return
+	}
+	if progress == nil {
+		JSONError(w, http.StatusNotFound, "Job not found")
+		return
+	}
+
+	JSONResponse(w, http.StatusOK, progress)
+}
+
+// GetProgress streams the progress of a job via SSE. The last event has the
+// report of the job.
+func (h *JobsHandler) GetProgress(w http.ResponseWriter, r *http.Request) {
+	jobID := chi.URLParam(r, "job_id")
+
+	if progress, err := h.jobs.Get(jobID); err != nil || progress == nil {
+		JSONError(w, http.StatusNotFound, "Job not found")
+		return
+	}
+
+	streamProgress(w, r, jobID, func() *progressSnapshot {
+		progress, err := h.jobs.Get(jobID)
+		if err != nil || progress == nil {
+			return nil
+		}
+		return &progressSnapshot{
+			Data:      progress,
+			Status:    progress.Status,
+			Processed: progress.Processed,
+			Total:     progress.Total,
+		}
+	})
+}
+
+// CancelJob stops a running job
+func (h *JobsHandler) CancelJob(w http.ResponseWriter, r *http.Request) {
+	jobID := chi.URLParam(r, "job_id")
+
+	if err := h.jobs.Cancel(jobID); err != nil {
+		switch {
+		case errors.Is(err, jobs.ErrJobNotFound):
+			JSONError(w, http.StatusNotFound, "Job not found")
+		case errors.Is(err, jobs.ErrJobNotRunning):
+			JSONError(w, http.StatusConflict, err.Error())
+		default:
+			JSONError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to cancel job: %v", err))
+		}
+		return
+	}
+
+	log.Printf("JOB CANCEL job=%s by %s", jobID, GetUsername(r))
+	JSONResponse(w, http.StatusOK, map[string]interface{}{"ok": true})
+}
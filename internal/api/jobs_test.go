@@ -0,0 +1,163 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/gosiva/hardlink-ui/internal/config"
+	"github.com/gosiva/hardlink-ui/internal/jobs"
+	"github.com/gosiva/hardlink-ui/internal/scanner"
+	"github.com/gosiva/hardlink-ui/internal/storage"
+)
+
+// TestBackgroundJobs verifies that conversions and folder hardlinking run
+// as background jobs with a final report
+func TestBackgroundJobs(t *testing.T) {
+	tmpDir := t.TempDir()
+	dbPath := filepath.Join(tmpDir, "test.db")
+	dataDir := filepath.Join(tmpDir, "data")
+
+	if err := os.MkdirAll(filepath.Join(dataDir, "src/sub"), 0755); err != nil {
+		t.Fatalf("Failed to create data dir: %v", err)
+	}
+	for _, name := range []string{"a.bin", "b.bin", "src/c.bin", "src/sub/d.bin"} {
+		if err := os.WriteFile(filepath.Join(dataDir, name), []byte("identical content"), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+	}
+
+	db, err := storage.New(dbPath)
+	if err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+	defer db.Close()
+
+	runner := jobs.NewRunner(db)
+	defer runner.Shutdown()
+
+	cfg := &config.Config{DataRoot: dataDir, VerifyPolicy: "quick", MetadataPolicy: "warn"}
+	duplicates := NewDuplicatesHandler(db, cfg, scanner.NewScanner(db, dataDir), runner)
+	hardlinks := NewHardlinkHandler(db, cfg, runner)
+	jobsHandler := NewJobsHandler(db, cfg, runner)
+
+	router := chi.NewRouter()
+	router.Get("/api/jobs", jobsHandler.ListJobs)
+	router.Get("/api/jobs/{job_id}", jobsHandler.GetJob)
+	router.Delete("/api/jobs/{job_id}", jobsHandler.CancelJob)
+
+	start := func(handler http.HandlerFunc, body string) string {
+		t.Helper()
+		req := httptest.NewRequest("POST", "/", bytes.NewBufferString(body))
+		w := httptest.NewRecorder()
+		handler(w, req)
+		if w.Code != http.StatusAccepted {
+			t.Fatalf("Expected status 202, got %d: %s", w.Code, w.Body.String())
+		}
+		var resp struct {
+			JobID string `json:"job_id"`
+		}
+		if err := json.NewDecoder(w.Body).Decode(&resp); err != nil || resp.JobID == "" {
+			t.Fatalf("Expected a job ID, got %s", w.Body.String())
+		}
+		return resp.JobID
+	}
+	wait := func(jobID string) *jobs.Progress {
+		t.Helper()
+		deadline := time.Now().Add(10 * time.Second)
+		for time.Now().Before(deadline) {
+			req := httptest.NewRequest("GET", "/api/jobs/"+jobID, nil)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+			if w.Code != http.StatusOK {
+				t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+			}
+			progress := &jobs.Progress{}
+			if err := json.NewDecoder(w.Body).Decode(progress); err != nil {
+				t.Fatalf("Failed to decode job: %v", err)
+			}
+			if progress.Status != jobs.StatusRunning {
+				return progress
+			}
+			time.Sleep(20 * time.Millisecond)
+		}
+		t.Fatalf("Job %s did not finish", jobID)
+		return nil
+	}
+	status := func(method, path string) int {
+		req := httptest.NewRequest(method, path, nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	// Conversion
+	jobID := start(duplicates.ConvertDuplicates, `{"async":true,"groups":[{"master":"/a.bin","others":["/b.bin"]}]}`)
+	progress := wait(jobID)
+
+	if progress.Status != jobs.StatusCompleted || progress.Total != 1 || progress.Processed != 1 || progress.Failed != 0 {
+		t.Fatalf("Unexpected conversion job %+v", progress)
+	}
+	var report struct {
+		Created int `json:"created"`
+	}
+	if err := json.Unmarshal(progress.Report, &report); err != nil || report.Created != 1 {
+		t.Fatalf("Expected 1 created link in report, got %s", progress.Report)
+	}
+
+	a, _ := os.Stat(filepath.Join(dataDir, "a.bin"))
+	b, _ := os.Stat(filepath.Join(dataDir, "b.bin"))
+	if !os.SameFile(a, b) {
+		t.Error("Expected b.bin to be linked to a.bin")
+	}
+
+	// Folder hardlinking
+	jobID = start(hardlinks.CreateHardlinksFolder, `{"async":true,"source":"/src","dest_root":"/dest"}`)
+	progress = wait(jobID)
+
+	if progress.Status != jobs.StatusCompleted || progress.Total != 2 || progress.Processed != 2 {
+		t.Fatalf("Unexpected folder job %+v", progress)
+	}
+	if _, err := os.Stat(filepath.Join(dataDir, "dest/sub/d.bin")); err != nil {
+		t.Errorf("Expected linked file: %v", err)
+	}
+
+	// A finished job can't be cancelled
+	if code := status("DELETE", "/api/jobs/"+jobID); code != http.StatusConflict {
+		t.Errorf("Expected status 409 cancelling a finished job, got %d", code)
+	}
+	if code := status("DELETE", "/api/jobs/unknown"); code != http.StatusNotFound {
+		t.Errorf("Expected status 404 cancelling an unknown job, got %d", code)
+	}
+	if code := status("GET", "/api/jobs/unknown"); code != http.StatusNotFound {
+		t.Errorf("Expected status 404 for an unknown job, got %d", code)
+	}
+
+	req := httptest.NewRequest("GET", "/api/jobs", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	var list struct {
+		Items []JobSummary `json:"items"`
+		Total int          `json:"total"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&list); err != nil {
+		t.Fatalf("Failed to decode jobs: %v", err)
+	}
+	if list.Total != 2 || len(list.Items) != 2 || list.Items[0].Kind != "link-folder" || list.Items[1].Kind != "convert" {
+		t.Fatalf("Expected 2 jobs, got %+v", list)
+	}
+
+	// Without a runner, requests stay synchronous only
+	req = httptest.NewRequest("POST", "/", bytes.NewBufferString(`{"async":true,"groups":[]}`))
+	w = httptest.NewRecorder()
+	NewDuplicatesHandler(db, cfg, scanner.NewScanner(db, dataDir), nil).ConvertDuplicates(w, req)
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected status 503 without a runner, got %d", w.Code)
+	}
+}
@@ -0,0 +1,185 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/gosiva/hardlink-ui/internal/fsutil"
+)
+
+// RenameRequest represents a file or folder rename request
+type RenameRequest struct {
+	Path string `json:"path"`
+	// Name is the new name, in the same folder
+	Name string `json:"name"`
+}
+
+// MoveRequest represents a file or folder move request
+type MoveRequest struct {
+	Source string `json:"source"`
+	// DestDir is the existing folder the source is moved into
+	DestDir string `json:"dest_dir"`
+	// Name renames the source while moving it, keeping its name when empty
+	Name string `json:"name"`
+}
+
+// Rename renames a file or folder in place
+func (h *HardlinkHandler) Rename(w http.ResponseWriter, r *http.Request) {
+	var req RenameRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		JSONError(w, http.StatusBadRequest, "Invalid request")
+		return
+	}
+
+	if req.Path == "" || req.Name == "" {
+		JSONError(w, http.StatusBadRequest, "Path and name are required")
+		return
+	}
+
+	name, err := validName(req.Name)
+	if err != nil {
+		JSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	srcPath := filepath.Join(h.cfg.DataRoot, strings.TrimPrefix(req.Path, "/"))
+	srcPath = filepath.Clean(srcPath)
+
+	h.move(w, r, "RENAME", srcPath, filepath.Join(filepath.Dir(srcPath), name))
+}
+
+// Move moves a file or folder into another folder of the same filesystem
+func (h *HardlinkHandler) Move(w http.ResponseWriter, r *http.Request) {
+	var req MoveRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		JSONError(w, http.StatusBadRequest, "Invalid request")
+		return
+	}
+
+	if req.Source == "" || req.DestDir == "" {
+		JSONError(w, http.StatusBadRequest, "Source and destination folder are required")
+		return
+	}
+
+	srcPath := filepath.Join(h.cfg.DataRoot, strings.TrimPrefix(req.Source, "/"))
+	srcPath = filepath.Clean(srcPath)
+
+	destDir := filepath.Join(h.cfg.DataRoot, strings.TrimPrefix(req.DestDir, "/"))
+	destDir = filepath.Clean(destDir)
+
+	if !strings.HasPrefix(destDir, h.cfg.DataRoot) {
+		JSONError(w, http.StatusBadRequest, "Path outside root")
+		return
+	}
+
+	name := filepath.Base(srcPath)
+	if req.Name != "" {
+		var err error
+		if name, err = validName(req.Name); err != nil {
+			JSONError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+
+	info, err := os.Stat(destDir)
+	if err != nil {
+		JSONError(w, http.StatusNotFound, fmt.Sprintf("Destination folder not found: %v", err))
+		return
+	}
+	if !info.IsDir() {
+		JSONError(w, http.StatusBadRequest, "Destination must be a folder")
+		return
+	}
+
+	h.move(w, r, "MOVE", srcPath, filepath.Join(destDir, name))
+}
+
+// move renames srcPath to destPath after the checks shared by Rename and
+// Move, and updates the inode index and the journal
+func (h *HardlinkHandler) move(w http.ResponseWriter, r *http.Request, action, srcPath, destPath string) {
+	if !strings.HasPrefix(srcPath, h.cfg.DataRoot) || !strings.HasPrefix(destPath, h.cfg.DataRoot) {
+		JSONError(w, http.StatusBadRequest, "Path outside root")
+		return
+	}
+	if srcPath == h.cfg.DataRoot {
+		JSONError(w, http.StatusBadRequest, "Cannot move the data root")
+		return
+	}
+
+	info, err := os.Lstat(srcPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			JSONError(w, http.StatusNotFound, "File or directory not found")
+			return
+		}
+		JSONError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to stat: %v", err))
+		return
+	}
+
+	if destPath == srcPath {
+		JSONError(w, http.StatusBadRequest, "Source and destination are the same")
+		return
+	}
+	if info.IsDir() && isSubPath(srcPath, destPath) {
+		JSONError(w, http.StatusBadRequest, "Cannot move a folder into itself")
+		return
+	}
+
+	// Check if destination already exists. A case-only rename finds the
+	// source itself on case-insensitive filesystems.
+	if destInfo, err := os.Lstat(destPath); err == nil && !os.SameFile(info, destInfo) {
+		JSONError(w, http.StatusConflict, "Destination already exists")
+		return
+	}
+
+	// A move across filesystems would copy the files, splitting their
+	// hardlinks: refuse it
+	if err := fsutil.SameDevice(srcPath, destPath); err != nil {
+		if fsutil.IsCrossDevice(err) {
+			JSONError(w, http.StatusBadRequest, "Cross-device move: source and destination are on different filesystems")
+			return
+		}
+		JSONError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to check destination device: %v", err))
+		return
+	}
+
+	if err := os.Rename(srcPath, destPath); err != nil {
+		if fsutil.IsCrossDevice(err) {
+			JSONError(w, http.StatusBadRequest, "Cross-device move: source and destination are on different filesystems")
+			return
+		}
+		JSONError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to move: %v", err))
+		return
+	}
+
+	// Update inode index
+	if err := h.db.MoveInodePaths(srcPath, destPath); err != nil {
+		log.Printf("Failed to update inode index for %s: %v", destPath, err)
+	}
+
+	opID := h.journal.RecordRename(srcPath, destPath, info, GetUsername(r))
+
+	log.Printf("%s %s -> %s by %s", action, srcPath, destPath, GetUsername(r))
+	JSONResponse(w, http.StatusOK, map[string]interface{}{
+		"ok":           true,
+		"path":         rootRelPath(h.cfg.DataRoot, destPath),
+		"operation_id": opID,
+	})
+}
+
+// validName checks a new file or folder name, returning it trimmed
+func validName(name string) (string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" || name == "." || name == ".." {
+		return "", fmt.Errorf("invalid name %q", name)
+	}
+	if strings.ContainsAny(name, `/\:*?"<>|`) {
+		return "", fmt.Errorf("name %q contains forbidden characters", name)
+	}
+	return name, nil
+}
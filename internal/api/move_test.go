@@ -0,0 +1,123 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gosiva/hardlink-ui/internal/config"
+	"github.com/gosiva/hardlink-ui/internal/fsutil"
+	"github.com/gosiva/hardlink-ui/internal/journal"
+	"github.com/gosiva/hardlink-ui/internal/storage"
+)
+
+// TestRenameAndMove verifies that files and folders are renamed and moved
+// with their inode index paths, and that the moves can be undone
+func TestRenameAndMove(t *testing.T) {
+	tmpDir := t.TempDir()
+	dataDir := filepath.Join(tmpDir, "data")
+
+	db, err := storage.New(filepath.Join(tmpDir, "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+	defer db.Close()
+
+	write := func(name string) {
+		t.Helper()
+		path := filepath.Join(dataDir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Failed to create dir: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(name), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+		info, err := os.Stat(path)
+		if err != nil {
+			t.Fatalf("Failed to stat %s: %v", name, err)
+		}
+		dev, ino, _, _ := fsutil.Identity(info)
+		if err := db.AddInodePath(dev, ino, path); err != nil {
+			t.Fatalf("Failed to index %s: %v", name, err)
+		}
+	}
+	write("downloads/Film.2019/film.mkv")
+	write("downloads/Film.2019/film.nfo")
+	write("downloads/notes.txt")
+	write("media/existing.txt")
+
+	cfg := &config.Config{DataRoot: dataDir}
+	handler := NewHardlinkHandler(db, cfg, nil)
+
+	post := func(handle http.HandlerFunc, body interface{}, status int) map[string]interface{} {
+		t.Helper()
+		data, _ := json.Marshal(body)
+		req := httptest.NewRequest("POST", "/api/move", bytes.NewReader(data))
+		w := httptest.NewRecorder()
+		handle(w, req)
+		if w.Code != status {
+			t.Fatalf("Expected status %d for %v, got %d: %s", status, body, w.Code, w.Body.String())
+		}
+		var resp map[string]interface{}
+		json.NewDecoder(w.Body).Decode(&resp)
+		return resp
+	}
+	indexed := func(name string) bool {
+		t.Helper()
+		entries, _, err := db.SearchFiles(storage.SearchQuery{Text: name, Limit: 10})
+		if err != nil {
+			t.Fatalf("Failed to search: %v", err)
+		}
+		for _, e := range entries {
+			if e.Path == filepath.Join(dataDir, name) {
+				return true
+			}
+		}
+		return false
+	}
+	exists := func(name string) bool {
+		_, err := os.Lstat(filepath.Join(dataDir, name))
+		return err == nil
+	}
+
+	resp := post(handler.Rename, RenameRequest{Path: "/downloads/notes.txt", Name: "todo.txt"}, http.StatusOK)
+	if resp["path"] != "/downloads/todo.txt" || !exists("downloads/todo.txt") || exists("downloads/notes.txt") {
+		t.Errorf("Expected notes.txt renamed to todo.txt, got %v", resp)
+	}
+	if !indexed("downloads/todo.txt") || indexed("downloads/notes.txt") {
+		t.Error("Expected the index to follow the rename")
+	}
+
+	// A folder moves with everything under it
+	resp = post(handler.Move, MoveRequest{Source: "/downloads/Film.2019", DestDir: "/media", Name: "Film (2019)"}, http.StatusOK)
+	if !exists("media/Film (2019)/film.mkv") || exists("downloads/Film.2019") {
+		t.Error("Expected the folder to be moved")
+	}
+	if !indexed("media/Film (2019)/film.mkv") || !indexed("media/Film (2019)/film.nfo") || indexed("downloads/Film.2019/film.mkv") {
+		t.Error("Expected the index to follow the move")
+	}
+
+	post(handler.Rename, RenameRequest{Path: "/downloads/todo.txt", Name: "../escape.txt"}, http.StatusBadRequest)
+	post(handler.Rename, RenameRequest{Path: "/downloads/todo.txt", Name: ".."}, http.StatusBadRequest)
+	post(handler.Rename, RenameRequest{Path: "/downloads/missing.txt", Name: "found.txt"}, http.StatusNotFound)
+	post(handler.Move, MoveRequest{Source: "/downloads/todo.txt", DestDir: "/media", Name: "existing.txt"}, http.StatusConflict)
+	post(handler.Move, MoveRequest{Source: "/media", DestDir: "/media/Film (2019)"}, http.StatusBadRequest)
+	post(handler.Move, MoveRequest{Source: "/downloads/todo.txt", DestDir: "/../outside"}, http.StatusBadRequest)
+	post(handler.Move, MoveRequest{Source: "/downloads/todo.txt", DestDir: "/missing"}, http.StatusNotFound)
+	post(handler.Move, MoveRequest{Source: "/", DestDir: "/media"}, http.StatusBadRequest)
+
+	// Undoing the move puts the folder and its index paths back
+	if _, err := journal.New(db, dataDir).Undo(int64(resp["operation_id"].(float64)), "admin"); err != nil {
+		t.Fatalf("Failed to undo: %v", err)
+	}
+	if !exists("downloads/Film.2019/film.nfo") || exists("media/Film (2019)") {
+		t.Error("Expected the folder to be moved back")
+	}
+	if !indexed("downloads/Film.2019/film.nfo") || indexed("media/Film (2019)/film.nfo") {
+		t.Error("Expected the index to follow the undo")
+	}
+}
@@ -0,0 +1,119 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/gosiva/hardlink-ui/internal/config"
+	"github.com/gosiva/hardlink-ui/internal/journal"
+	"github.com/gosiva/hardlink-ui/internal/storage"
+)
+
+// OperationsHandler exposes the journal of file operations
+type OperationsHandler struct {
+	db      *storage.DB
+	cfg     *config.Config
+	journal *journal.Journal
+}
+
+// NewOperationsHandler creates a new operations handler
+func NewOperationsHandler(db *storage.DB, cfg *config.Config) *OperationsHandler {
+	return &OperationsHandler{
+		db:      db,
+		cfg:     cfg,
+		journal: journal.New(db, cfg.DataRoot),
+	}
+}
+
+// OperationResponse is a journaled operation
+type OperationResponse struct {
+	ID        int64  `json:"id"`
+	Kind      string `json:"kind"`
+	Path      string `json:"path"`
+	Target    string `json:"target,omitempty"`
+	Inode     uint64 `json:"inode"`
+	Size      int64  `json:"size"`
+	Username  string `json:"username,omitempty"`
+	CreatedAt int64  `json:"created_at"`
+	UndoneAt  *int64 `json:"undone_at,omitempty"`
+}
+
+// newOperationResponse converts a journal entry
+func newOperationResponse(op *storage.Operation) OperationResponse {
+	resp := OperationResponse{
+		ID:        op.ID,
+		Kind:      op.Kind,
+		Path:      op.Path,
+		Target:    op.Target,
+		Inode:     op.Inode,
+		Size:      op.Size,
+		Username:  op.Username,
+		CreatedAt: op.CreatedAt,
+	}
+	if op.UndoneAt.Valid {
+		undoneAt := op.UndoneAt.Int64
+		resp.UndoneAt = &undoneAt
+	}
+	return resp
+}
+
+// ListOperations returns a page of the journal, most recent first
+func (h *OperationsHandler) ListOperations(w http.ResponseWriter, r *http.Request) {
+	limit := 50
+	if v, err := strconv.Atoi(r.URL.Query().Get("limit")); err == nil && v > 0 {
+		limit = min(v, 500)
+	}
+	offset := 0
+	if v, err := strconv.Atoi(r.URL.Query().Get("offset")); err == nil && v > 0 {
+		offset = v
+	}
+
+	ops, total, err := h.db.ListOperations(limit, offset)
+	if err != nil {
+		JSONError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to list operations: %v", err))
+		return
+	}
+
+	items := make([]OperationResponse, 0, len(ops))
+	for i := range ops {
+		items = append(items, newOperationResponse(&ops[i]))
+	}
+
+	JSONResponse(w, http.StatusOK, map[string]interface{}{
+		"items":  items,
+		"total":  total,
+		"limit":  limit,
+		"offset": offset,
+	})
+}
+
+// UndoOperation reverses a journaled operation
+func (h *OperationsHandler) UndoOperation(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		JSONError(w, http.StatusBadRequest, "Invalid operation ID")
+		return
+	}
+
+	op, err := h.journal.Undo(id, GetUsername(r))
+	switch {
+	case errors.Is(err, journal.ErrNotFound):
+		JSONError(w, http.StatusNotFound, "Operation not found")
+		return
+	case errors.Is(err, journal.ErrUndone), errors.Is(err, journal.ErrNotUndoable):
+		JSONError(w, http.StatusConflict, err.Error())
+		return
+	case err != nil:
+		JSONError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to undo operation: %v", err))
+		return
+	}
+
+	JSONResponse(w, http.StatusOK, map[string]interface{}{
+		"ok":        true,
+		"operation": newOperationResponse(op),
+	})
+}
@@ -0,0 +1,166 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/gosiva/hardlink-ui/internal/config"
+	"github.com/gosiva/hardlink-ui/internal/dedup"
+	"github.com/gosiva/hardlink-ui/internal/scanner"
+	"github.com/gosiva/hardlink-ui/internal/storage"
+)
+
+// TestUndoOperations verifies that conversions, created links and deleted
+// links are journaled and can be undone once
+func TestUndoOperations(t *testing.T) {
+	tmpDir := t.TempDir()
+	dbPath := filepath.Join(tmpDir, "test.db")
+	dataDir := filepath.Join(tmpDir, "data")
+
+	if err := os.MkdirAll(dataDir, 0755); err != nil {
+		t.Fatalf("Failed to create data dir: %v", err)
+	}
+	for _, name := range []string{"a.bin", "b.bin"} {
+		if err := os.WriteFile(filepath.Join(dataDir, name), []byte("identical content"), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+	}
+	// The duplicate has its own mode and mtime, restored by the undo
+	old := time.Now().Add(-24 * time.Hour).Truncate(time.Second)
+	if err := os.Chmod(filepath.Join(dataDir, "b.bin"), 0600); err != nil {
+		t.Fatalf("Failed to chmod: %v", err)
+	}
+	if err := os.Chtimes(filepath.Join(dataDir, "b.bin"), old, old); err != nil {
+		t.Fatalf("Failed to set mtime: %v", err)
+	}
+
+	db, err := storage.New(dbPath)
+	if err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+	defer db.Close()
+
+	cfg := &config.Config{DataRoot: dataDir, VerifyPolicy: "quick", MetadataPolicy: "warn"}
+	duplicates := NewDuplicatesHandler(db, cfg, scanner.NewScanner(db, dataDir), nil)
+	hardlinks := NewHardlinkHandler(db, cfg, nil)
+	operations := NewOperationsHandler(db, cfg)
+
+	router := chi.NewRouter()
+	router.Get("/api/operations", operations.ListOperations)
+	router.Post("/api/operations/{id}/undo", operations.UndoOperation)
+
+	post := func(handler http.HandlerFunc, body string) map[string]interface{} {
+		t.Helper()
+		req := httptest.NewRequest("POST", "/", bytes.NewBufferString(body))
+		w := httptest.NewRecorder()
+		handler(w, req)
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+		}
+		var resp map[string]interface{}
+		if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+		return resp
+	}
+	undo := func(id int64, status int) {
+		t.Helper()
+		req := httptest.NewRequest("POST", "/api/operations/"+strconv.FormatInt(id, 10)+"/undo", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		if w.Code != status {
+			t.Fatalf("Undo %d: expected status %d, got %d: %s", id, status, w.Code, w.Body.String())
+		}
+	}
+	stat := func(name string) os.FileInfo {
+		t.Helper()
+		info, err := os.Stat(filepath.Join(dataDir, name))
+		if err != nil {
+			t.Fatalf("Failed to stat %s: %v", name, err)
+		}
+		return info
+	}
+
+	// A converted duplicate becomes a separate file again
+	resp := post(duplicates.ConvertDuplicates, `{"groups":[{"master":"/a.bin","others":["/b.bin"]}]}`)
+	data, _ := json.Marshal(resp["files"])
+	var files []dedup.FileReport
+	if err := json.Unmarshal(data, &files); err != nil || len(files) != 1 || files[0].OperationID == 0 {
+		t.Fatalf("Expected a journaled conversion, got %s", data)
+	}
+	if !os.SameFile(stat("a.bin"), stat("b.bin")) {
+		t.Fatal("Expected b.bin to be linked to a.bin")
+	}
+
+	undo(files[0].OperationID, http.StatusOK)
+
+	info := stat("b.bin")
+	if os.SameFile(stat("a.bin"), info) {
+		t.Error("Expected b.bin to be a separate file again")
+	}
+	if mode := info.Mode().Perm(); mode != 0600 {
+		t.Errorf("Expected restored mode 0600, got %04o", mode)
+	}
+	if !info.ModTime().Equal(old) {
+		t.Errorf("Expected restored mtime %v, got %v", old, info.ModTime())
+	}
+	if content, _ := os.ReadFile(filepath.Join(dataDir, "b.bin")); string(content) != "identical content" {
+		t.Errorf("Unexpected content %q", content)
+	}
+
+	// An operation is only undone once
+	undo(files[0].OperationID, http.StatusConflict)
+
+	// A created link is removed
+	resp = post(hardlinks.CreateHardlink, `{"source":"/a.bin","dest":"/links/c.bin"}`)
+	id := int64(resp["operation_id"].(float64))
+
+	undo(id, http.StatusOK)
+
+	if _, err := os.Stat(filepath.Join(dataDir, "links/c.bin")); !os.IsNotExist(err) {
+		t.Errorf("Expected created link to be removed, got %v", err)
+	}
+
+	// A deleted link is recreated from the remaining one
+	if err := os.Link(filepath.Join(dataDir, "a.bin"), filepath.Join(dataDir, "d.bin")); err != nil {
+		t.Fatalf("Failed to create link: %v", err)
+	}
+	resp = post(hardlinks.DeleteHardlink, `{"path":"/d.bin"}`)
+	id = int64(resp["operation_id"].(float64))
+
+	undo(id, http.StatusOK)
+
+	if !os.SameFile(stat("a.bin"), stat("d.bin")) {
+		t.Error("Expected deleted link to be recreated")
+	}
+
+	undo(9999, http.StatusNotFound)
+
+	req := httptest.NewRequest("GET", "/api/operations", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	var list struct {
+		Items []OperationResponse `json:"items"`
+		Total int                 `json:"total"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&list); err != nil {
+		t.Fatalf("Failed to decode operations: %v", err)
+	}
+	if list.Total != 3 || len(list.Items) != 3 {
+		t.Fatalf("Expected 3 operations, got %+v", list)
+	}
+	kinds := []string{"unlink", "link", "replace"}
+	for i, item := range list.Items {
+		if item.Kind != kinds[i] || item.UndoneAt == nil {
+			t.Errorf("Expected undone %s operation, got %+v", kinds[i], item)
+		}
+	}
+}
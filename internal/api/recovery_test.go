@@ -0,0 +1,196 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gosiva/hardlink-ui/internal/config"
+	"github.com/gosiva/hardlink-ui/internal/fsutil"
+	"github.com/gosiva/hardlink-ui/internal/journal"
+	"github.com/gosiva/hardlink-ui/internal/scanner"
+	"github.com/gosiva/hardlink-ui/internal/storage"
+)
+
+// TestConversionRecovery verifies that conversions interrupted by a crash
+// are completed or rolled back, and that leftover temporary hardlinks are
+// repaired
+func TestConversionRecovery(t *testing.T) {
+	tmpDir := t.TempDir()
+	dbPath := filepath.Join(tmpDir, "test.db")
+	dataDir := filepath.Join(tmpDir, "data")
+
+	if err := os.MkdirAll(dataDir, 0755); err != nil {
+		t.Fatalf("Failed to create data dir: %v", err)
+	}
+	for _, name := range []string{"master.bin", "a.bin", "b.bin", "c.bin", "e.bin"} {
+		if err := os.WriteFile(filepath.Join(dataDir, name), []byte("identical content"), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+	}
+	// Unrelated files named like temporary hardlinks, the last links to
+	// their data
+	for _, name := range []string{"a.bin.tmp-hardlink", ".a.bin.tmp-hardlink-notrandom"} {
+		if err := os.WriteFile(filepath.Join(dataDir, name), []byte("keep me"), 0644); err != nil {
+			t.Fatalf("Failed to write collision file: %v", err)
+		}
+	}
+
+	db, err := storage.New(dbPath)
+	if err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+	defer db.Close()
+
+	cfg := &config.Config{DataRoot: dataDir, VerifyPolicy: "quick", MetadataPolicy: "warn"}
+	handler := NewDuplicatesHandler(db, cfg, scanner.NewScanner(db, dataDir), nil)
+	fileJournal := journal.New(db, dataDir)
+
+	path := func(name string) string {
+		return filepath.Join(dataDir, name)
+	}
+	stat := func(name string) os.FileInfo {
+		t.Helper()
+		info, err := os.Stat(path(name))
+		if err != nil {
+			t.Fatalf("Failed to stat %s: %v", name, err)
+		}
+		return info
+	}
+	// interrupt runs a conversion of name up to a step, as a crash would
+	interrupt := func(name string, rename bool) string {
+		t.Helper()
+		tmpPath, err := journal.TempLinkPath(path(name))
+		if err != nil {
+			t.Fatalf("Failed to get temporary path: %v", err)
+		}
+		if _, err := fileJournal.BeginReplace(path(name), tmpPath, path("master.bin"), stat("master.bin"), stat(name), &journal.Metadata{Mode: 0644}, "test"); err != nil {
+			t.Fatalf("Failed to begin replace: %v", err)
+		}
+		if err := os.Link(path("master.bin"), tmpPath); err != nil {
+			t.Fatalf("Failed to link: %v", err)
+		}
+		if rename {
+			if err := os.Rename(tmpPath, path(name)); err != nil {
+				t.Fatalf("Failed to rename: %v", err)
+			}
+		}
+		return tmpPath
+	}
+
+	// A conversion doesn't touch a file named like a temporary hardlink
+	req := httptest.NewRequest("POST", "/", bytes.NewBufferString(`{"groups":[{"master":"/master.bin","others":["/a.bin"]}]}`))
+	w := httptest.NewRecorder()
+	handler.ConvertDuplicates(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if !os.SameFile(stat("master.bin"), stat("a.bin")) {
+		t.Error("Expected a.bin to be linked to master.bin")
+	}
+	if content, _ := os.ReadFile(path("a.bin.tmp-hardlink")); string(content) != "keep me" {
+		t.Errorf("Expected collision file to be untouched, got %q", content)
+	}
+
+	// A crash before the rename is rolled back, a crash after it is completed
+	rolledBack := interrupt("b.bin", false)
+	interrupt("c.bin", true)
+
+	count, err := fileJournal.RecoverReplaces()
+	if err != nil || count != 2 {
+		t.Fatalf("Expected 2 interrupted conversions, got %d: %v", count, err)
+	}
+
+	if _, err := os.Lstat(rolledBack); !os.IsNotExist(err) {
+		t.Errorf("Expected temporary hardlink to be removed, got %v", err)
+	}
+	if os.SameFile(stat("master.bin"), stat("b.bin")) {
+		t.Error("Expected b.bin to be rolled back")
+	}
+	if !os.SameFile(stat("master.bin"), stat("c.bin")) {
+		t.Error("Expected c.bin to stay linked to master.bin")
+	}
+
+	ops, total, err := db.ListOperations(10, 0)
+	if err != nil {
+		t.Fatalf("Failed to list operations: %v", err)
+	}
+	if total != 2 || ops[0].Kind != journal.KindReplace || ops[0].Path != "/c.bin" {
+		t.Fatalf("Expected the completed conversion to be journaled, got %+v", ops)
+	}
+	if count, _ := fileJournal.RecoverReplaces(); count != 0 {
+		t.Errorf("Expected intents to be cleared, got %d", count)
+	}
+
+	// Temporary hardlinks without intent, after the loss of the database:
+	// the missing duplicate is restored, the extra link is removed. The
+	// trash is left alone.
+	link := func(name string) string {
+		t.Helper()
+		tmpPath, err := journal.TempLinkPath(path(name))
+		if err != nil {
+			t.Fatalf("Failed to get temporary path: %v", err)
+		}
+		if err := os.MkdirAll(filepath.Dir(tmpPath), 0755); err != nil {
+			t.Fatalf("Failed to create dir: %v", err)
+		}
+		if err := os.Link(path("master.bin"), tmpPath); err != nil {
+			t.Fatalf("Failed to link: %v", err)
+		}
+		return tmpPath
+	}
+	// legacy links name like the temporary hardlinks of older versions
+	legacy := func(name string) {
+		t.Helper()
+		if err := os.Link(path("master.bin"), path(name+".tmp-hardlink")); err != nil {
+			t.Fatalf("Failed to link: %v", err)
+		}
+	}
+	link("d.bin")
+	link("e.bin")
+	trashed := link(filepath.Join(fsutil.TrashDir(), "f.bin"))
+	legacy("g.bin")
+	legacy("b.bin")
+
+	if repaired := fileJournal.SweepTempLinks(context.Background()); repaired != 4 {
+		t.Errorf("Expected 4 repaired temporary hardlinks, got %d", repaired)
+	}
+
+	if !os.SameFile(stat("master.bin"), stat("d.bin")) {
+		t.Error("Expected d.bin to be restored from its temporary hardlink")
+	}
+	if !os.SameFile(stat("master.bin"), stat("g.bin")) {
+		t.Error("Expected g.bin to be restored from its legacy temporary hardlink")
+	}
+	if _, err := os.Lstat(path("b.bin.tmp-hardlink")); !os.IsNotExist(err) {
+		t.Errorf("Expected the extra legacy temporary hardlink to be removed, got %v", err)
+	}
+	if os.SameFile(stat("master.bin"), stat("b.bin")) {
+		t.Error("Expected b.bin to be left alone")
+	}
+	entries, _ := os.ReadDir(dataDir)
+	for _, entry := range entries {
+		if strings.Contains(entry.Name(), ".tmp-hardlink") && !strings.Contains(entry.Name(), "a.bin") {
+			t.Errorf("Unexpected leftover %s", entry.Name())
+		}
+	}
+	for _, name := range []string{"a.bin.tmp-hardlink", ".a.bin.tmp-hardlink-notrandom"} {
+		if content, _ := os.ReadFile(path(name)); string(content) != "keep me" {
+			t.Errorf("Expected %s to be untouched, got %q", name, content)
+		}
+	}
+	if _, err := os.Lstat(trashed); err != nil {
+		t.Errorf("Expected the trash to be skipped, got %v", err)
+	}
+
+	// The data root is only swept once
+	link("h.bin")
+	if repaired := fileJournal.SweepTempLinks(context.Background()); repaired != 0 {
+		t.Errorf("Expected no second sweep, got %d repaired", repaired)
+	}
+}
@@ -8,12 +8,15 @@ import (
 	"github.com/go-chi/chi/v5"
 
 	"github.com/gosiva/hardlink-ui/internal/config"
+	"github.com/gosiva/hardlink-ui/internal/dedup"
+	"github.com/gosiva/hardlink-ui/internal/indexer"
+	"github.com/gosiva/hardlink-ui/internal/jobs"
 	"github.com/gosiva/hardlink-ui/internal/scanner"
 	"github.com/gosiva/hardlink-ui/internal/storage"
 )
 
 // Router sets up all HTTP routes
-func Router(db *storage.DB, cfg *config.Config, scan *scanner.Scanner, webPath string) (http.Handler, error) {
+func Router(db *storage.DB, cfg *config.Config, scan *scanner.Scanner, idx *indexer.Indexer, engine *dedup.Engine, runner *jobs.Runner, webPath string) (http.Handler, error) {
 	r := chi.NewRouter()
 
 	// Create handlers
@@ -24,13 +27,18 @@ func Router(db *storage.DB, cfg *config.Config, scan *scanner.Scanner, webPath s
 		return nil, err
 	}
 
-	explorerHandler, err := NewExplorerHandler(db, cfg, templatesPath)
+	explorerHandler, err := NewExplorerHandler(db, cfg, idx, templatesPath)
 	if err != nil {
 		return nil, err
 	}
 
-	hardlinkHandler := NewHardlinkHandler(db, cfg)
-	duplicatesHandler := NewDuplicatesHandler(db, cfg, scan)
+	hardlinkHandler := NewHardlinkHandler(db, cfg, runner)
+	duplicatesHandler := NewDuplicatesHandler(db, cfg, scan, runner)
+	schedulesHandler := NewSchedulesHandler(db, cfg, scan)
+	rulesHandler := NewRulesHandler(db, cfg, engine)
+	operationsHandler := NewOperationsHandler(db, cfg)
+	trashHandler := NewTrashHandler(db, cfg)
+	jobsHandler := NewJobsHandler(db, cfg, runner)
 
 	// Middleware
 	middleware := NewMiddleware(db, cfg)
@@ -61,20 +69,63 @@ func Router(db *storage.DB, cfg *config.Config, scan *scanner.Scanner, webPath s
 			// Explorer
 			r.Get("/list", explorerHandler.ListDirectory)
 			r.Get("/details", explorerHandler.GetDetails)
+			r.Get("/search", explorerHandler.Search)
 			r.Post("/create-folder", explorerHandler.CreateFolder)
 
 			// Hardlinks
 			r.Post("/create-hardlink", hardlinkHandler.CreateHardlink)
 			r.Post("/create-hardlinks-folder", hardlinkHandler.CreateHardlinksFolder)
 			r.Post("/delete-hardlink", hardlinkHandler.DeleteHardlink)
+			r.Post("/delete-folder", hardlinkHandler.DeleteFolder)
+			r.Post("/rename-preview", hardlinkHandler.PreviewRename)
+			r.Post("/rename", hardlinkHandler.Rename)
+			r.Post("/move", hardlinkHandler.Move)
+
+			// Operations journal
+			r.Get("/operations", operationsHandler.ListOperations)
+			r.Post("/operations/{id}/undo", operationsHandler.UndoOperation)
+
+			// Trash
+			r.Get("/trash", trashHandler.ListTrash)
+			r.Post("/trash/empty", trashHandler.EmptyTrash)
+			r.Post("/trash/{id}/restore", trashHandler.RestoreTrashItem)
+			r.Delete("/trash/{id}", trashHandler.DeleteTrashItem)
+
+			// Background jobs
+			r.Get("/jobs", jobsHandler.ListJobs)
+			r.Get("/jobs/{job_id}", jobsHandler.GetJob)
+			r.Get("/jobs/{job_id}/progress", jobsHandler.GetProgress)
+			r.Delete("/jobs/{job_id}", jobsHandler.CancelJob)
 
 			// Duplicates
 			r.Get("/duplicates/scan", duplicatesHandler.StartScan)
+			r.Post("/duplicates/scan", duplicatesHandler.StartScan)
+			r.Delete("/duplicates/scan/{job_id}", duplicatesHandler.CancelScan)
+			r.Post("/duplicates/scan/{job_id}/pause", duplicatesHandler.PauseScan)
+			r.Post("/duplicates/scan/{job_id}/resume", duplicatesHandler.ResumeScan)
 			r.Get("/duplicates/progress/{job_id}", duplicatesHandler.GetProgress)
 			r.Get("/duplicates/progress", duplicatesHandler.GetProgress) // with query param
 			r.Get("/duplicates/results/{job_id}", duplicatesHandler.GetResults)
 			r.Get("/duplicates/results", duplicatesHandler.GetResults) // with query param
+			r.Get("/duplicates/latest", duplicatesHandler.GetLatestResults)
 			r.Post("/duplicates/convert", duplicatesHandler.ConvertDuplicates)
+			r.Get("/duplicates/jobs", duplicatesHandler.ListJobs)
+			r.Post("/duplicates/jobs/prune", duplicatesHandler.PruneJobs)
+			r.Delete("/duplicates/jobs/{job_id}", duplicatesHandler.DeleteJob)
+
+			// Scheduled scans
+			r.Get("/schedules", schedulesHandler.ListSchedules)
+			r.Post("/schedules", schedulesHandler.CreateSchedule)
+			r.Put("/schedules/{id}", schedulesHandler.UpdateSchedule)
+			r.Delete("/schedules/{id}", schedulesHandler.DeleteSchedule)
+
+			// Automatic conversion rules
+			r.Get("/duplicates/rules", rulesHandler.ListRules)
+			r.Post("/duplicates/rules", rulesHandler.CreateRule)
+			r.Post("/duplicates/rules/evaluate", rulesHandler.EvaluateRules)
+			r.Put("/duplicates/rules/{id}", rulesHandler.UpdateRule)
+			r.Delete("/duplicates/rules/{id}", rulesHandler.DeleteRule)
+			r.Get("/duplicates/jobs/{job_id}/report", rulesHandler.GetReport)
 		})
 	})
 
@@ -0,0 +1,242 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/gosiva/hardlink-ui/internal/config"
+	"github.com/gosiva/hardlink-ui/internal/dedup"
+	"github.com/gosiva/hardlink-ui/internal/scanner"
+	"github.com/gosiva/hardlink-ui/internal/storage"
+)
+
+// RulesHandler manages the automatic duplicate conversion rules
+type RulesHandler struct {
+	db     *storage.DB
+	cfg    *config.Config
+	engine *dedup.Engine
+}
+
+// NewRulesHandler creates a new rules handler
+func NewRulesHandler(db *storage.DB, cfg *config.Config, engine *dedup.Engine) *RulesHandler {
+	return &RulesHandler{
+		db:     db,
+		cfg:    cfg,
+		engine: engine,
+	}
+}
+
+// ListRules returns all rules in evaluation order
+func (h *RulesHandler) ListRules(w http.ResponseWriter, r *http.Request) {
+	rules, err := h.engine.ListRules()
+	if err != nil {
+		JSONError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to list rules: %v", err))
+		return
+	}
+
+	JSONResponse(w, http.StatusOK, map[string]interface{}{
+		"items": rules,
+	})
+}
+
+// CreateRule creates a rule. Rules are enabled and report-only by default.
+func (h *RulesHandler) CreateRule(w http.ResponseWriter, r *http.Request) {
+	rule := dedup.Rule{Enabled: true}
+	if err := json.NewDecoder(r.Body).Decode(&rule); err != nil {
+		JSONError(w, http.StatusBadRequest, "Invalid request")
+		return
+	}
+
+	record := &storage.ConversionRule{}
+	if err := applyRule(record, &rule); err != nil {
+		JSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	id, err := h.db.CreateConversionRule(record)
+	if err != nil {
+		JSONError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to create rule: %v", err))
+		return
+	}
+	rule.ID = id
+
+	log.Printf("RULE CREATE id=%d name=%q auto_apply=%v by %s", id, rule.Name, rule.AutoApply, GetUsername(r))
+
+	JSONResponse(w, http.StatusOK, rule)
+}
+
+// UpdateRule updates a rule, fields missing from the request keep their value
+func (h *RulesHandler) UpdateRule(w http.ResponseWriter, r *http.Request) {
+	record, ok := h.loadRule(w, r)
+	if !ok {
+		return
+	}
+
+	rule := dedup.Rule{Name: record.Name, Enabled: record.Enabled, AutoApply: record.AutoApply}
+	if err := json.Unmarshal([]byte(record.Conditions), &rule.Conditions); err != nil {
+		JSONError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to decode rule: %v", err))
+		return
+	}
+	if err := json.NewDecoder(r.Body).Decode(&rule); err != nil {
+		JSONError(w, http.StatusBadRequest, "Invalid request")
+		return
+	}
+	rule.ID = record.ID
+
+	if err := applyRule(record, &rule); err != nil {
+		JSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if err := h.db.UpdateConversionRule(record); err != nil {
+		JSONError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to update rule: %v", err))
+		return
+	}
+
+	log.Printf("RULE UPDATE id=%d name=%q enabled=%v auto_apply=%v by %s",
+		rule.ID, rule.Name, rule.Enabled, rule.AutoApply, GetUsername(r))
+
+	JSONResponse(w, http.StatusOK, rule)
+}
+
+// DeleteRule deletes a rule
+func (h *RulesHandler) DeleteRule(w http.ResponseWriter, r *http.Request) {
+	record, ok := h.loadRule(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.db.DeleteConversionRule(record.ID); err != nil {
+		JSONError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to delete rule: %v", err))
+		return
+	}
+
+	log.Printf("RULE DELETE id=%d name=%q by %s", record.ID, record.Name, GetUsername(r))
+
+	JSONResponse(w, http.StatusOK, map[string]interface{}{
+		"ok": true,
+		"id": record.ID,
+	})
+}
+
+// EvaluateRulesRequest selects the scan job to evaluate the rules on
+type EvaluateRulesRequest struct {
+	// JobID defaults to the most recently completed scan
+	JobID string `json:"job_id"`
+	// Apply converts every match instead of only reporting them
+	Apply bool `json:"apply"`
+}
+
+// EvaluateRules evaluates the enabled rules on the results of a scan job and
+// returns the report, a dry run unless apply is set
+func (h *RulesHandler) EvaluateRules(w http.ResponseWriter, r *http.Request) {
+	var req EvaluateRulesRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		JSONError(w, http.StatusBadRequest, "Invalid request")
+		return
+	}
+
+	if req.JobID == "" {
+		job, err := h.db.GetLatestCompletedScanJob()
+		if err != nil {
+			JSONError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to load latest scan: %v", err))
+			return
+		}
+		if job == nil {
+			JSONError(w, http.StatusNotFound, "No completed scan")
+			return
+		}
+		req.JobID = job.JobID
+	}
+
+	mode := dedup.DryRun
+	if req.Apply {
+		mode = dedup.ApplyAll
+		log.Printf("DUPRULES APPLY job=%s by %s", req.JobID, GetUsername(r))
+	}
+
+	report, err := h.engine.Evaluate(req.JobID, mode)
+	switch {
+	case errors.Is(err, scanner.ErrJobNotFound):
+		JSONError(w, http.StatusNotFound, err.Error())
+		return
+	case errors.Is(err, dedup.ErrJobNotCompleted):
+		JSONError(w, http.StatusConflict, err.Error())
+		return
+	case err != nil:
+		JSONError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to evaluate rules: %v", err))
+		return
+	}
+
+	if req.Apply {
+		log.Printf("DUPRULES END job=%s converted=%d bytes_saved=%d", req.JobID, report.Converted, report.BytesSaved)
+	}
+
+	JSONResponse(w, http.StatusOK, report)
+}
+
+// GetReport returns the last rule report of a scan job
+func (h *RulesHandler) GetReport(w http.ResponseWriter, r *http.Request) {
+	report, err := h.engine.GetReport(chi.URLParam(r, "job_id"))
+	if err != nil {
+		JSONError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to load report: %v", err))
+		return
+	}
+	if report == nil {
+		JSONError(w, http.StatusNotFound, "No report for this job")
+		return
+	}
+
+	JSONResponse(w, http.StatusOK, report)
+}
+
+// loadRule reads the rule of the {id} URL parameter, writing an error
+// response when it doesn't exist
+func (h *RulesHandler) loadRule(w http.ResponseWriter, r *http.Request) (*storage.ConversionRule, bool) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		JSONError(w, http.StatusBadRequest, "Invalid rule ID")
+		return nil, false
+	}
+
+	record, err := h.db.GetConversionRule(id)
+	if err != nil {
+		JSONError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to load rule: %v", err))
+		return nil, false
+	}
+	if record == nil {
+		JSONError(w, http.StatusNotFound, "Rule not found")
+		return nil, false
+	}
+
+	return record, true
+}
+
+// applyRule validates a rule and copies it into record
+func applyRule(record *storage.ConversionRule, rule *dedup.Rule) error {
+	rule.Name = strings.TrimSpace(rule.Name)
+	if rule.Name == "" {
+		return errors.New("name is required")
+	}
+	if err := rule.Conditions.Validate(); err != nil {
+		return err
+	}
+
+	conditions, err := json.Marshal(rule.Conditions)
+	if err != nil {
+		return fmt.Errorf("failed to encode rule: %w", err)
+	}
+
+	record.Name = rule.Name
+	record.Conditions = string(conditions)
+	record.Enabled = rule.Enabled
+	record.AutoApply = rule.AutoApply
+	return nil
+}
@@ -0,0 +1,157 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/gosiva/hardlink-ui/internal/config"
+	"github.com/gosiva/hardlink-ui/internal/dedup"
+	"github.com/gosiva/hardlink-ui/internal/scanner"
+	"github.com/gosiva/hardlink-ui/internal/storage"
+)
+
+// TestConversionRules verifies that rules are reported after each scan and
+// only convert the matching groups once auto-apply is enabled
+func TestConversionRules(t *testing.T) {
+	tmpDir := t.TempDir()
+	dbPath := filepath.Join(tmpDir, "test.db")
+	dataDir := filepath.Join(tmpDir, "data")
+
+	files := map[string]string{
+		"media/a.mkv": "movie content",
+		"media/b.mkv": "movie content",
+		"other/c.txt": "text content",
+		"other/d.txt": "text content",
+	}
+	for name, content := range files {
+		path := filepath.Join(dataDir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Failed to create dir: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+	}
+	// b.mkv is the oldest copy, and e.mkv another link to it
+	old := time.Now().Add(-48 * time.Hour)
+	if err := os.Chtimes(filepath.Join(dataDir, "media/b.mkv"), old, old); err != nil {
+		t.Fatalf("Failed to set mtime: %v", err)
+	}
+	if err := os.Link(filepath.Join(dataDir, "media/b.mkv"), filepath.Join(dataDir, "media/e.mkv")); err != nil {
+		t.Fatalf("Failed to link: %v", err)
+	}
+
+	db, err := storage.New(dbPath)
+	if err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+	defer db.Close()
+
+	scan := scanner.NewScanner(db, dataDir)
+	engine := dedup.NewEngine(db, dataDir, scanner.VerifyQuick, dedup.MetadataWarn)
+	scan.OnComplete(engine.OnScanComplete)
+
+	cfg := &config.Config{DataRoot: dataDir, VerifyPolicy: "quick"}
+	handler := NewRulesHandler(db, cfg, engine)
+	r := chi.NewRouter()
+	r.Post("/api/duplicates/rules", handler.CreateRule)
+	r.Put("/api/duplicates/rules/{id}", handler.UpdateRule)
+	r.Post("/api/duplicates/rules/evaluate", handler.EvaluateRules)
+	r.Get("/api/duplicates/jobs/{job_id}/report", handler.GetReport)
+
+	do := func(method, path, body string, status int) *httptest.ResponseRecorder {
+		t.Helper()
+		req := httptest.NewRequest(method, path, bytes.NewBufferString(body))
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		if w.Code != status {
+			t.Fatalf("%s %s: expected status %d, got %d: %s", method, path, status, w.Code, w.Body.String())
+		}
+		return w
+	}
+	decodeReport := func(w *httptest.ResponseRecorder) dedup.Report {
+		t.Helper()
+		var report dedup.Report
+		if err := json.NewDecoder(w.Body).Decode(&report); err != nil {
+			t.Fatalf("Failed to decode report: %v", err)
+		}
+		return report
+	}
+	runScan := func(jobID string) {
+		t.Helper()
+		// The scan keeps a.mkv, so both links of b.mkv are in the group
+		if err := scan.StartScanWithOptions(jobID, scanner.ScanOptions{Master: scanner.MasterNewest}); err != nil {
+			t.Fatalf("Failed to start scan: %v", err)
+		}
+		if progress := waitForScan(t, scan, jobID); progress.Status != "completed" {
+			t.Fatalf("Expected completed scan, got status %s (%s)", progress.Status, progress.Error)
+		}
+	}
+	sameInode := func(a, b string) bool {
+		infoA, errA := os.Stat(filepath.Join(dataDir, a))
+		infoB, errB := os.Stat(filepath.Join(dataDir, b))
+		return errA == nil && errB == nil && os.SameFile(infoA, infoB)
+	}
+
+	do("POST", "/api/duplicates/rules", `{"name":"bad","master":"biggest"}`, http.StatusBadRequest)
+	do("POST", "/api/duplicates/rules", `{"name":" ","roots":["/media"]}`, http.StatusBadRequest)
+	do("POST", "/api/duplicates/rules", `{"name":"bad","exclude":["re:("]}`, http.StatusBadRequest)
+
+	w := do("POST", "/api/duplicates/rules", `{"name":"Films","roots":["/media"],"extensions":["mkv"],"master":"oldest-mtime"}`, http.StatusOK)
+	var rule dedup.Rule
+	if err := json.NewDecoder(w.Body).Decode(&rule); err != nil {
+		t.Fatalf("Failed to decode rule: %v", err)
+	}
+	if !rule.Enabled || rule.AutoApply {
+		t.Errorf("Expected an enabled report-only rule, got %+v", rule)
+	}
+
+	// Report only: the match is listed but nothing is converted
+	runScan("report-job")
+	report := decodeReport(do("GET", "/api/duplicates/jobs/report-job/report", "", http.StatusOK))
+	if report.Groups != 1 || len(report.Matches) != 1 {
+		t.Fatalf("Expected 1 matched group, got %+v", report)
+	}
+	match := report.Matches[0]
+	if match.Master != "/media/b.mkv" || len(match.Others) != 2 || match.Others[0] != "/media/a.mkv" {
+		t.Errorf("Expected oldest file as master, got %+v", match)
+	}
+	// e.mkv is already a link of the master, only a.mkv is freed
+	if match.Applied || match.Reclaimable != int64(len("movie content")) {
+		t.Errorf("Expected an unapplied match, got %+v", match)
+	}
+	if sameInode("media/a.mkv", "media/b.mkv") {
+		t.Fatal("Expected report-only rule not to convert files")
+	}
+
+	report = decodeReport(do("POST", "/api/duplicates/rules/evaluate", `{}`, http.StatusOK))
+	if !report.DryRun || report.JobID != "report-job" || report.Groups != 1 || report.Converted != 0 {
+		t.Errorf("Expected dry run of the latest scan, got %+v", report)
+	}
+	do("POST", "/api/duplicates/rules/evaluate", `{"job_id":"missing"}`, http.StatusNotFound)
+
+	// Auto-apply converts the matches when the next scan completes
+	do("PUT", "/api/duplicates/rules/"+strconv.FormatInt(rule.ID, 10), `{"auto_apply":true}`, http.StatusOK)
+	runScan("apply-job")
+	report = decodeReport(do("GET", "/api/duplicates/jobs/apply-job/report", "", http.StatusOK))
+	if report.Converted != 1 || len(report.Matches) != 1 || !report.Matches[0].Applied {
+		t.Fatalf("Expected 1 converted file, got %+v", report)
+	}
+	if !sameInode("media/a.mkv", "media/b.mkv") {
+		t.Error("Expected media files to be hardlinked")
+	}
+	if sameInode("other/c.txt", "other/d.txt") {
+		t.Error("Expected files outside the rule to be untouched")
+	}
+	if info, err := os.Stat(filepath.Join(dataDir, "media/a.mkv")); err != nil || !info.ModTime().Equal(old) {
+		t.Errorf("Expected a.mkv to link to the oldest copy, got %v (%v)", info, err)
+	}
+}
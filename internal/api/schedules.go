@@ -0,0 +1,230 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/gosiva/hardlink-ui/internal/config"
+	"github.com/gosiva/hardlink-ui/internal/scanner"
+	"github.com/gosiva/hardlink-ui/internal/scheduler"
+	"github.com/gosiva/hardlink-ui/internal/storage"
+)
+
+// SchedulesHandler manages recurring duplicate scans
+type SchedulesHandler struct {
+	db      *storage.DB
+	cfg     *config.Config
+	scanner *scanner.Scanner
+}
+
+// NewSchedulesHandler creates a new schedules handler
+func NewSchedulesHandler(db *storage.DB, cfg *config.Config, scan *scanner.Scanner) *SchedulesHandler {
+	return &SchedulesHandler{
+		db:      db,
+		cfg:     cfg,
+		scanner: scan,
+	}
+}
+
+// ScheduleRequest creates or updates a schedule
+type ScheduleRequest struct {
+	Name string `json:"name"`
+	// Cron is a 5-field cron expression or a macro such as @nightly
+	Cron string `json:"cron"`
+	// Options are the scan options, missing fields use the server defaults
+	Options scanner.ScanOptions `json:"options"`
+	Enabled *bool               `json:"enabled"`
+}
+
+// ScheduleResponse is a schedule as returned by the API
+type ScheduleResponse struct {
+	ID        int64               `json:"id"`
+	Name      string              `json:"name"`
+	Cron      string              `json:"cron"`
+	Options   scanner.ScanOptions `json:"options"`
+	Enabled   bool                `json:"enabled"`
+	NextRunAt *int64              `json:"next_run_at,omitempty"`
+	LastRunAt *int64              `json:"last_run_at,omitempty"`
+	LastJobID string              `json:"last_job_id,omitempty"`
+}
+
+// ListSchedules returns all schedules
+func (h *SchedulesHandler) ListSchedules(w http.ResponseWriter, r *http.Request) {
+	schedules, err := h.db.ListScanSchedules()
+	if err != nil {
+		JSONError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to list schedules: %v", err))
+		return
+	}
+
+	items := make([]ScheduleResponse, 0, len(schedules))
+	for i := range schedules {
+		items = append(items, scheduleResponse(&schedules[i]))
+	}
+
+	JSONResponse(w, http.StatusOK, map[string]interface{}{
+		"items": items,
+	})
+}
+
+// CreateSchedule creates a schedule
+func (h *SchedulesHandler) CreateSchedule(w http.ResponseWriter, r *http.Request) {
+	req := ScheduleRequest{Options: defaultScanOptions(h.cfg)}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		JSONError(w, http.StatusBadRequest, "Invalid request")
+		return
+	}
+
+	schedule := &storage.ScanSchedule{Enabled: true}
+	if err := h.apply(schedule, &req); err != nil {
+		JSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	id, err := h.db.CreateScanSchedule(schedule)
+	if err != nil {
+		JSONError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to create schedule: %v", err))
+		return
+	}
+	schedule.ID = id
+
+	log.Printf("SCHEDULE CREATE id=%d name=%q cron=%q by %s", id, schedule.Name, schedule.Cron, GetUsername(r))
+
+	JSONResponse(w, http.StatusOK, scheduleResponse(schedule))
+}
+
+// UpdateSchedule replaces the settings of a schedule
+func (h *SchedulesHandler) UpdateSchedule(w http.ResponseWriter, r *http.Request) {
+	schedule, ok := h.loadSchedule(w, r)
+	if !ok {
+		return
+	}
+
+	var opts scanner.ScanOptions
+	if err := json.Unmarshal([]byte(schedule.Options), &opts); err != nil {
+		opts = defaultScanOptions(h.cfg)
+	}
+
+	// Fields missing from the request keep their current value
+	req := ScheduleRequest{Name: schedule.Name, Cron: schedule.Cron, Options: opts}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		JSONError(w, http.StatusBadRequest, "Invalid request")
+		return
+	}
+
+	if err := h.apply(schedule, &req); err != nil {
+		JSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if err := h.db.UpdateScanSchedule(schedule); err != nil {
+		JSONError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to update schedule: %v", err))
+		return
+	}
+
+	log.Printf("SCHEDULE UPDATE id=%d name=%q cron=%q enabled=%v by %s",
+		schedule.ID, schedule.Name, schedule.Cron, schedule.Enabled, GetUsername(r))
+
+	JSONResponse(w, http.StatusOK, scheduleResponse(schedule))
+}
+
+// DeleteSchedule deletes a schedule, the scans it started are kept
+func (h *SchedulesHandler) DeleteSchedule(w http.ResponseWriter, r *http.Request) {
+	schedule, ok := h.loadSchedule(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.db.DeleteScanSchedule(schedule.ID); err != nil {
+		JSONError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to delete schedule: %v", err))
+		return
+	}
+
+	log.Printf("SCHEDULE DELETE id=%d name=%q by %s", schedule.ID, schedule.Name, GetUsername(r))
+
+	JSONResponse(w, http.StatusOK, map[string]interface{}{
+		"ok": true,
+		"id": schedule.ID,
+	})
+}
+
+// loadSchedule reads the schedule of the {id} URL parameter, writing an error
+// response when it doesn't exist
+func (h *SchedulesHandler) loadSchedule(w http.ResponseWriter, r *http.Request) (*storage.ScanSchedule, bool) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		JSONError(w, http.StatusBadRequest, "Invalid schedule ID")
+		return nil, false
+	}
+
+	schedule, err := h.db.GetScanSchedule(id)
+	if err != nil {
+		JSONError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to load schedule: %v", err))
+		return nil, false
+	}
+	if schedule == nil {
+		JSONError(w, http.StatusNotFound, "Schedule not found")
+		return nil, false
+	}
+
+	return schedule, true
+}
+
+// apply validates a request and copies it into schedule, computing its next run
+func (h *SchedulesHandler) apply(schedule *storage.ScanSchedule, req *ScheduleRequest) error {
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" {
+		return errors.New("name is required")
+	}
+
+	next, err := scheduler.NextRun(req.Cron, time.Now())
+	if err != nil {
+		return err
+	}
+
+	if err := h.scanner.ValidateOptions(&req.Options); err != nil {
+		return err
+	}
+	options, err := json.Marshal(req.Options)
+	if err != nil {
+		return fmt.Errorf("failed to encode scan options: %w", err)
+	}
+
+	schedule.Name = req.Name
+	schedule.Cron = strings.TrimSpace(req.Cron)
+	schedule.Options = string(options)
+	schedule.NextRunAt = next
+	if req.Enabled != nil {
+		schedule.Enabled = *req.Enabled
+	}
+
+	return nil
+}
+
+// scheduleResponse converts a stored schedule for the API
+func scheduleResponse(s *storage.ScanSchedule) ScheduleResponse {
+	resp := ScheduleResponse{
+		ID:        s.ID,
+		Name:      s.Name,
+		Cron:      s.Cron,
+		Enabled:   s.Enabled,
+		LastJobID: s.LastJobID.String,
+	}
+	_ = json.Unmarshal([]byte(s.Options), &resp.Options)
+	if s.NextRunAt.Valid {
+		next := s.NextRunAt.Int64
+		resp.NextRunAt = &next
+	}
+	if s.LastRunAt.Valid {
+		last := s.LastRunAt.Int64
+		resp.LastRunAt = &last
+	}
+	return resp
+}
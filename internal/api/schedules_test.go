@@ -0,0 +1,164 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/gosiva/hardlink-ui/internal/config"
+	"github.com/gosiva/hardlink-ui/internal/scanner"
+	"github.com/gosiva/hardlink-ui/internal/scheduler"
+	"github.com/gosiva/hardlink-ui/internal/storage"
+)
+
+// TestScanSchedules verifies managing schedules, running the due ones and
+// reading the latest results
+func TestScanSchedules(t *testing.T) {
+	tmpDir := t.TempDir()
+	dbPath := filepath.Join(tmpDir, "test.db")
+	dataDir := filepath.Join(tmpDir, "data")
+
+	if err := os.MkdirAll(filepath.Join(dataDir, "movies"), 0755); err != nil {
+		t.Fatalf("Failed to create data dir: %v", err)
+	}
+	for _, name := range []string{"movies/a.mkv", "movies/b.mkv"} {
+		if err := os.WriteFile(filepath.Join(dataDir, name), []byte("same content"), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+	}
+
+	db, err := storage.New(dbPath)
+	if err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+	defer db.Close()
+
+	scan := scanner.NewScanner(db, dataDir)
+	cfg := &config.Config{DataRoot: dataDir, VerifyPolicy: "quick"}
+	schedules := NewSchedulesHandler(db, cfg, scan)
+	duplicates := NewDuplicatesHandler(db, cfg, scan, nil)
+
+	r := chi.NewRouter()
+	r.Get("/api/schedules", schedules.ListSchedules)
+	r.Post("/api/schedules", schedules.CreateSchedule)
+	r.Put("/api/schedules/{id}", schedules.UpdateSchedule)
+	r.Delete("/api/schedules/{id}", schedules.DeleteSchedule)
+	r.Get("/api/duplicates/latest", duplicates.GetLatestResults)
+
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		t.Helper()
+		req := httptest.NewRequest(method, path, bytes.NewBufferString(body))
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	if w := do("GET", "/api/duplicates/latest", ""); w.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 before any scan, got %d", w.Code)
+	}
+
+	invalid := []string{
+		`{"name":"bad","cron":"every night"}`,
+		`{"name":"","cron":"@nightly"}`,
+		`{"name":"bad","cron":"@nightly","options":{"roots":["/missing"]}}`,
+	}
+	for _, body := range invalid {
+		if w := do("POST", "/api/schedules", body); w.Code != http.StatusBadRequest {
+			t.Errorf("Expected status 400 for %s, got %d", body, w.Code)
+		}
+	}
+
+	w := do("POST", "/api/schedules", `{"name":"Films","cron":"@nightly","options":{"roots":["/movies"]}}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var created ScheduleResponse
+	if err := json.NewDecoder(w.Body).Decode(&created); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if !created.Enabled || created.NextRunAt == nil || *created.NextRunAt <= time.Now().Unix() {
+		t.Errorf("Expected an enabled schedule with a future run, got %+v", created)
+	}
+	if len(created.Options.Roots) != 1 || created.Options.Verify != scanner.VerifyQuick {
+		t.Errorf("Expected options with defaults, got %+v", created.Options)
+	}
+
+	path := "/api/schedules/" + strconv.FormatInt(created.ID, 10)
+	if w := do("PUT", path, `{"cron":"0 4 * * 1","enabled":false}`); w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	stored, err := db.GetScanSchedule(created.ID)
+	if err != nil || stored == nil {
+		t.Fatalf("Failed to load schedule: %v", err)
+	}
+	if stored.Name != "Films" || stored.Cron != "0 4 * * 1" || stored.Enabled {
+		t.Errorf("Unexpected updated schedule: %+v", stored)
+	}
+
+	// Disabled schedules don't run even when due
+	if _, err := db.Exec(`UPDATE scan_schedules SET next_run_at = ?`, time.Now().Add(-time.Minute).Unix()); err != nil {
+		t.Fatalf("Failed to make schedule due: %v", err)
+	}
+	sched := scheduler.NewScheduler(db, scan)
+	sched.RunDue()
+	if stored, _ := db.GetScanSchedule(created.ID); stored.LastJobID.Valid {
+		t.Fatalf("Expected disabled schedule not to run, got job %s", stored.LastJobID.String)
+	}
+
+	if w := do("PUT", path, `{"enabled":true}`); w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if _, err := db.Exec(`UPDATE scan_schedules SET next_run_at = ?`, time.Now().Add(-time.Minute).Unix()); err != nil {
+		t.Fatalf("Failed to make schedule due: %v", err)
+	}
+	sched.RunDue()
+
+	stored, err = db.GetScanSchedule(created.ID)
+	if err != nil || stored == nil || !stored.LastJobID.Valid {
+		t.Fatalf("Expected schedule to start a scan, got %+v (%v)", stored, err)
+	}
+	if !stored.NextRunAt.Valid || stored.NextRunAt.Int64 <= time.Now().Unix() {
+		t.Errorf("Expected next run to move forward, got %v", stored.NextRunAt)
+	}
+	jobID := stored.LastJobID.String
+	if progress := waitForScan(t, scan, jobID); progress.Status != "completed" {
+		t.Fatalf("Expected completed scan, got status %s (%s)", progress.Status, progress.Error)
+	}
+
+	w = do("GET", "/api/duplicates/latest", "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var latest struct {
+		JobID string                   `json:"job_id"`
+		Items []scanner.DuplicateGroup `json:"items"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&latest); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if latest.JobID != jobID || len(latest.Items) != 1 {
+		t.Errorf("Expected 1 group from job %s, got %+v", jobID, latest)
+	}
+
+	if w := do("DELETE", path, ""); w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if w := do("DELETE", path, ""); w.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 for deleted schedule, got %d", w.Code)
+	}
+
+	w = do("GET", "/api/schedules", "")
+	var list struct {
+		Items []ScheduleResponse `json:"items"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&list); err != nil || len(list.Items) != 0 {
+		t.Errorf("Expected no schedules, got %+v (%v)", list.Items, err)
+	}
+}
@@ -0,0 +1,168 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gosiva/hardlink-ui/internal/config"
+	"github.com/gosiva/hardlink-ui/internal/fsutil"
+	"github.com/gosiva/hardlink-ui/internal/indexer"
+	"github.com/gosiva/hardlink-ui/internal/storage"
+)
+
+// Search returns the indexed files matching the query parameters: q (words
+// of the path), name (substring or glob of the file name), path (folder to
+// search), min_size and max_size, modified_after and modified_before (unix
+// time or date), links ("multiple" or "single") and same_inode (a path),
+// paginated by limit and offset
+func (h *ExplorerHandler) Search(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	limit := 100
+	if v, err := strconv.Atoi(query.Get("limit")); err == nil && v > 0 {
+		limit = min(v, 1000)
+	}
+	offset := 0
+	if v, err := strconv.Atoi(query.Get("offset")); err == nil && v > 0 {
+		offset = v
+	}
+
+	q := storage.SearchQuery{
+		Text:   query.Get("q"),
+		Name:   query.Get("name"),
+		Limit:  limit,
+		Offset: offset,
+	}
+
+	if v := query.Get("path"); v != "" {
+		root, ok := h.resolvePath(v)
+		if !ok {
+			JSONError(w, http.StatusBadRequest, "Path outside root")
+			return
+		}
+		q.Root = root
+	}
+
+	var err error
+	if q.MinSize, err = sizeParam(query.Get("min_size")); err != nil {
+		JSONError(w, http.StatusBadRequest, fmt.Sprintf("Invalid min_size: %v", err))
+		return
+	}
+	if q.MaxSize, err = sizeParam(query.Get("max_size")); err != nil {
+		JSONError(w, http.StatusBadRequest, fmt.Sprintf("Invalid max_size: %v", err))
+		return
+	}
+	if q.MinMtime, err = timeParam(query.Get("modified_after")); err != nil {
+		JSONError(w, http.StatusBadRequest, fmt.Sprintf("Invalid modified_after: %v", err))
+		return
+	}
+	if q.MaxMtime, err = timeParam(query.Get("modified_before")); err != nil {
+		JSONError(w, http.StatusBadRequest, fmt.Sprintf("Invalid modified_before: %v", err))
+		return
+	}
+
+	switch query.Get("links") {
+	case "":
+	case "multiple":
+		q.MinLinks = 2
+	case "single":
+		q.MaxLinks = 1
+	default:
+		JSONError(w, http.StatusBadRequest, "links must be multiple or single")
+		return
+	}
+
+	if v := query.Get("same_inode"); v != "" {
+		targetPath, ok := h.resolvePath(v)
+		if !ok {
+			JSONError(w, http.StatusBadRequest, "Path outside root")
+			return
+		}
+		info, err := os.Stat(targetPath)
+		if err != nil {
+			JSONError(w, http.StatusNotFound, fmt.Sprintf("File not found: %v", err))
+			return
+		}
+		q.Dev, q.Inode, _, _ = fsutil.Identity(info)
+		q.SameInode = true
+	}
+
+	found, total, err := h.db.SearchFiles(q)
+	if err != nil {
+		JSONError(w, http.StatusInternalServerError, fmt.Sprintf("Search failed: %v", err))
+		return
+	}
+
+	// Results are refreshed from the filesystem, and the stale ones pruned
+	results := make([]FileEntry, 0, len(found))
+	for _, entry := range found {
+		info, err := os.Lstat(entry.Path)
+		var dev, ino uint64
+		if err == nil {
+			dev, ino, _, _ = fsutil.Identity(info)
+		}
+		if err != nil || dev != entry.Dev || ino != entry.Inode {
+			indexer.RemovePath(h.db, entry.Dev, entry.Inode, entry.Path)
+			total--
+			continue
+		}
+		rel := h.relPath(entry.Path)
+		if rel == "" {
+			continue
+		}
+		results = append(results, newFileEntry(rel, info))
+	}
+
+	JSONResponse(w, http.StatusOK, map[string]interface{}{
+		"ok":      true,
+		"results": results,
+		"total":   total,
+		"limit":   limit,
+		"offset":  offset,
+		"indexed": h.indexer == nil || h.indexer.Ready(),
+	})
+}
+
+// resolvePath converts a "/"-prefixed path relative to the data root to an
+// absolute path, and false for a path outside the data root
+func (h *ExplorerHandler) resolvePath(relPath string) (string, bool) {
+	targetPath := filepath.Join(h.cfg.DataRoot, strings.TrimPrefix(relPath, "/"))
+	targetPath = filepath.Clean(targetPath)
+	return targetPath, strings.HasPrefix(targetPath, h.cfg.DataRoot)
+}
+
+// sizeParam parses an optional size such as "500" or "100M", 0 when empty
+func sizeParam(value string) (int64, error) {
+	if value == "" {
+		return 0, nil
+	}
+	size, err := config.ParseByteSize(value)
+	if err == nil && size < 0 {
+		err = fmt.Errorf("size must not be negative")
+	}
+	return size, err
+}
+
+// timeParam parses an optional unix time, date (2006-01-02) or RFC 3339
+// time, 0 when empty
+func timeParam(value string) (int64, error) {
+	if value == "" {
+		return 0, nil
+	}
+	if n, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return n, nil
+	}
+	if t, err := time.ParseInLocation("2006-01-02", value, time.Local); err == nil {
+		return t.Unix(), nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return 0, fmt.Errorf("expected a unix time, a date or an RFC 3339 time")
+	}
+	return t.Unix(), nil
+}
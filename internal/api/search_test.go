@@ -0,0 +1,153 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gosiva/hardlink-ui/internal/config"
+	"github.com/gosiva/hardlink-ui/internal/indexer"
+	"github.com/gosiva/hardlink-ui/internal/storage"
+)
+
+// TestSearch verifies that files are searched by path words, name, size,
+// modification time and links through the inode index
+func TestSearch(t *testing.T) {
+	tmpDir := t.TempDir()
+	dataDir := filepath.Join(tmpDir, "data")
+
+	write := func(name string, size int, mtime time.Time) {
+		t.Helper()
+		path := filepath.Join(dataDir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Failed to create dir: %v", err)
+		}
+		if err := os.WriteFile(path, make([]byte, size), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+		if err := os.Chtimes(path, mtime, mtime); err != nil {
+			t.Fatalf("Failed to set times of %s: %v", name, err)
+		}
+	}
+
+	old := time.Date(2020, 1, 1, 0, 0, 0, 0, time.Local)
+	recent := time.Date(2024, 6, 1, 0, 0, 0, 0, time.Local)
+	write("downloads/Show.S01E01.1080p/show.s01e01.mkv", 300, recent)
+	write("downloads/Show.S01E02.1080p/show.s01e02.mkv", 200, recent)
+	write("downloads/Film.2019/film.2019.mkv", 1000, old)
+	write("downloads/Film.2019/film.nfo", 10, old)
+	if err := os.MkdirAll(filepath.Join(dataDir, "media"), 0755); err != nil {
+		t.Fatalf("Failed to create dir: %v", err)
+	}
+	if err := os.Link(filepath.Join(dataDir, "downloads/Film.2019/film.2019.mkv"), filepath.Join(dataDir, "media/Film (2019).mkv")); err != nil {
+		t.Fatalf("Failed to link: %v", err)
+	}
+
+	db, err := storage.New(filepath.Join(tmpDir, "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+	defer db.Close()
+
+	idx := indexer.NewIndexer(db, dataDir, 0)
+	if err := idx.Rebuild(context.Background()); err != nil {
+		t.Fatalf("Failed to build index: %v", err)
+	}
+
+	cfg := &config.Config{DataRoot: dataDir}
+	handler, err := NewExplorerHandler(db, cfg, idx, filepath.Join("..", "..", "web", "templates"))
+	if err != nil {
+		t.Fatalf("Failed to create handler: %v", err)
+	}
+
+	type searchResponse struct {
+		Results []FileEntry `json:"results"`
+		Total   int         `json:"total"`
+	}
+	search := func(query string, status int) searchResponse {
+		t.Helper()
+		req := httptest.NewRequest("GET", "/api/search?"+query, nil)
+		w := httptest.NewRecorder()
+		handler.Search(w, req)
+		if w.Code != status {
+			t.Fatalf("Expected status %d for %q, got %d: %s", status, query, w.Code, w.Body.String())
+		}
+		var resp searchResponse
+		if status == http.StatusOK {
+			if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+		}
+		return resp
+	}
+	expect := func(query string, paths ...string) {
+		t.Helper()
+		resp := search(query, http.StatusOK)
+		if resp.Total != len(paths) || len(resp.Results) != len(paths) {
+			t.Fatalf("Expected %d results for %q, got %d: %+v", len(paths), query, resp.Total, resp.Results)
+		}
+		for i, path := range paths {
+			if resp.Results[i].Path != path {
+				t.Errorf("Expected result %d of %q to be %s, got %s", i, query, path, resp.Results[i].Path)
+			}
+		}
+	}
+
+	expect("q=s01", "/downloads/Show.S01E01.1080p/show.s01e01.mkv", "/downloads/Show.S01E02.1080p/show.s01e02.mkv")
+	expect("q=show+s01e02", "/downloads/Show.S01E02.1080p/show.s01e02.mkv")
+	expect("name=ilm.n", "/downloads/Film.2019/film.nfo")
+	expect("name=*.NFO", "/downloads/Film.2019/film.nfo")
+	expect("min_size=250&max_size=500", "/downloads/Show.S01E01.1080p/show.s01e01.mkv")
+	expect("modified_before=2021-01-01&name=*.mkv", "/downloads/Film.2019/film.2019.mkv", "/media/Film (2019).mkv")
+	expect("modified_after=2024-01-01&path=/downloads/Show.S01E02.1080p", "/downloads/Show.S01E02.1080p/show.s01e02.mkv")
+	expect("links=multiple", "/downloads/Film.2019/film.2019.mkv", "/media/Film (2019).mkv")
+	expect("same_inode=/media/Film+(2019).mkv&path=/downloads", "/downloads/Film.2019/film.2019.mkv")
+
+	resp := search("links=single&limit=2&offset=2", http.StatusOK)
+	if resp.Total != 3 || len(resp.Results) != 1 {
+		t.Errorf("Expected the last page of 3 single links, got %d: %+v", resp.Total, resp.Results)
+	}
+
+	// The link counts follow the links created and deleted since the pass
+	hardlinks := NewHardlinkHandler(db, cfg, nil)
+	post := func(handle http.HandlerFunc, body string) {
+		t.Helper()
+		req := httptest.NewRequest("POST", "/", strings.NewReader(body))
+		w := httptest.NewRecorder()
+		handle(w, req)
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected status 200 for %s, got %d: %s", body, w.Code, w.Body.String())
+		}
+	}
+	post(hardlinks.CreateHardlink, `{"source":"/downloads/Show.S01E01.1080p/show.s01e01.mkv","dest":"/media/show.s01e01.mkv"}`)
+	expect("links=multiple&q=s01e01", "/downloads/Show.S01E01.1080p/show.s01e01.mkv", "/media/show.s01e01.mkv")
+	expect("links=single&q=s01e01")
+	post(hardlinks.DeleteHardlink, `{"path":"/media/show.s01e01.mkv"}`)
+	expect("links=single&q=s01e01", "/downloads/Show.S01E01.1080p/show.s01e01.mkv")
+
+	// Names are matched case insensitively beyond ASCII
+	write("media/ÉTÉ.srt", 5, recent)
+	if err := idx.Rebuild(context.Background()); err != nil {
+		t.Fatalf("Failed to rebuild index: %v", err)
+	}
+	expect("name=été", "/media/ÉTÉ.srt")
+	expect("name=Été.*", "/media/ÉTÉ.srt")
+
+	// Files removed since the last pass are pruned from the results
+	if err := os.Remove(filepath.Join(dataDir, "downloads/Film.2019/film.nfo")); err != nil {
+		t.Fatalf("Failed to remove: %v", err)
+	}
+	expect("name=film.nfo")
+	expect("name=film.nfo")
+
+	search("links=some", http.StatusBadRequest)
+	search("min_size=big", http.StatusBadRequest)
+	search("modified_after=yesterday", http.StatusBadRequest)
+	search("same_inode=/missing", http.StatusNotFound)
+}
@@ -0,0 +1,101 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+)
+
+// progressSnapshot is the state of a job sent as an SSE event
+type progressSnapshot struct {
+	Data      interface{}
+	Status    string
+	Processed int
+	Total     int
+}
+
+// streamProgress sends the snapshots returned by poll as SSE events until the
+// job stops running. poll returns nil once the job is gone.
+func streamProgress(w http.ResponseWriter, r *http.Request, jobID string, poll func() *progressSnapshot) {
+	username := GetUsername(r)
+	remoteAddr := r.RemoteAddr
+
+	w.Header().Set("Content-Type", "text/event-stream; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-cache, no-transform")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.Header().Set("Content-Encoding", "identity")
+
+	origin := r.Header.Get("Origin")
+	if origin != "" && isAllowedOrigin(origin, r.Host) {
+		w.Header().Set("Access-Control-Allow-Origin", origin)
+		w.Header().Set("Access-Control-Allow-Credentials", "true")
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		log.Printf("SSE CONNECT job=%s user=%s remote=%s flusher=NO", jobID, username, remoteAddr)
+		JSONError(w, http.StatusInternalServerError, "Streaming not supported")
+		return
+	}
+
+	log.Printf("SSE CONNECT job=%s user=%s remote=%s flusher=YES", jobID, username, remoteAddr)
+
+	fmt.Fprintf(w, ": ping\n\n")
+	flusher.Flush()
+
+	connectedEvent := fmt.Sprintf("event: connected\ndata: {\"job_id\": \"%s\"}\n\n", jobID)
+	fmt.Fprint(w, connectedEvent)
+	flusher.Flush()
+
+	ticker := time.NewTicker(500 * time.Millisecond)
+	defer ticker.Stop()
+
+	heartbeatTicker := time.NewTicker(15 * time.Second)
+	defer heartbeatTicker.Stop()
+
+	timeout := time.After(10 * time.Minute)
+
+	lastProgressStatus := ""
+
+	for {
+		select {
+		case <-ticker.C:
+			progress := poll()
+			if progress == nil {
+				fmt.Fprintf(w, "event: error\ndata: {\"error\": \"Job not found\"}\n\n")
+				flusher.Flush()
+				return
+			}
+
+			data, _ := json.Marshal(progress.Data)
+			fmt.Fprintf(w, "data: %s\n\n", data)
+			flusher.Flush()
+
+			if progress.Status != lastProgressStatus {
+				log.Printf("SSE SENT job=%s event=progress status=%s processed=%d total=%d",
+					jobID, progress.Status, progress.Processed, progress.Total)
+				lastProgressStatus = progress.Status
+			}
+
+			if progress.Status != "running" {
+				log.Printf("SSE DISCONNECT job=%s status=%s", jobID, progress.Status)
+				return
+			}
+
+		case <-heartbeatTicker.C:
+			fmt.Fprintf(w, ": heartbeat\n\n")
+			flusher.Flush()
+
+		case <-timeout:
+			fmt.Fprintf(w, "event: timeout\ndata: {\"error\": \"Timeout\"}\n\n")
+			flusher.Flush()
+			return
+
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
@@ -0,0 +1,195 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/gosiva/hardlink-ui/internal/config"
+	"github.com/gosiva/hardlink-ui/internal/storage"
+	"github.com/gosiva/hardlink-ui/internal/trash"
+)
+
+// TrashHandler exposes the trash of deleted links and folders. Items trashed
+// before the trash was disabled stay available.
+type TrashHandler struct {
+	db    *storage.DB
+	cfg   *config.Config
+	trash *trash.Trash
+}
+
+// NewTrashHandler creates a new trash handler
+func NewTrashHandler(db *storage.DB, cfg *config.Config) *TrashHandler {
+	return &TrashHandler{
+		db:    db,
+		cfg:   cfg,
+		trash: newTrash(db, cfg),
+	}
+}
+
+// newTrash creates the trash with the configured retention
+func newTrash(db *storage.DB, cfg *config.Config) *trash.Trash {
+	return trash.New(db, cfg.DataRoot, time.Duration(cfg.TrashRetentionDays)*24*time.Hour)
+}
+
+// TrashItemResponse is an item of the trash
+type TrashItemResponse struct {
+	ID        int64  `json:"id"`
+	Path      string `json:"path"`
+	TrashPath string `json:"trash_path"`
+	Inode     uint64 `json:"inode"`
+	Size      int64  `json:"size"`
+	IsDir     bool   `json:"is_dir"`
+	Username  string `json:"username,omitempty"`
+	DeletedAt int64  `json:"deleted_at"`
+	// ExpiresAt is when the retention purges the item, 0 without retention
+	ExpiresAt int64 `json:"expires_at,omitempty"`
+}
+
+// newTrashItemResponse converts a trash item
+func (h *TrashHandler) newTrashItemResponse(item *storage.TrashItem) TrashItemResponse {
+	resp := TrashItemResponse{
+		ID:        item.ID,
+		Path:      item.Path,
+		TrashPath: item.TrashPath,
+		Inode:     item.Inode,
+		Size:      item.Size,
+		IsDir:     item.IsDir,
+		Username:  item.Username,
+		DeletedAt: item.DeletedAt,
+	}
+	if h.cfg.TrashRetentionDays > 0 {
+		resp.ExpiresAt = item.DeletedAt + int64(h.cfg.TrashRetentionDays)*86400
+	}
+	return resp
+}
+
+// ListTrash returns a page of the trash, most recent first
+func (h *TrashHandler) ListTrash(w http.ResponseWriter, r *http.Request) {
+	limit := 50
+	if v, err := strconv.Atoi(r.URL.Query().Get("limit")); err == nil && v > 0 {
+		limit = min(v, 500)
+	}
+	offset := 0
+	if v, err := strconv.Atoi(r.URL.Query().Get("offset")); err == nil && v > 0 {
+		offset = v
+	}
+
+	trashed, total, size, err := h.db.ListTrashItems(limit, offset)
+	if err != nil {
+		JSONError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to list the trash: %v", err))
+		return
+	}
+
+	items := make([]TrashItemResponse, 0, len(trashed))
+	for i := range trashed {
+		items = append(items, h.newTrashItemResponse(&trashed[i]))
+	}
+
+	JSONResponse(w, http.StatusOK, map[string]interface{}{
+		"items":          items,
+		"total":          total,
+		"size":           size,
+		"limit":          limit,
+		"offset":         offset,
+		"enabled":        h.cfg.TrashEnabled,
+		"retention_days": h.cfg.TrashRetentionDays,
+	})
+}
+
+// RestoreTrashItem moves a trashed item back to its original path
+func (h *TrashHandler) RestoreTrashItem(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		JSONError(w, http.StatusBadRequest, "Invalid trash item ID")
+		return
+	}
+
+	item, err := h.trash.Restore(id, GetUsername(r))
+	if !h.trashError(w, err) {
+		return
+	}
+
+	JSONResponse(w, http.StatusOK, map[string]interface{}{
+		"ok":   true,
+		"item": h.newTrashItemResponse(item),
+	})
+}
+
+// DeleteTrashItem permanently deletes a trashed item
+func (h *TrashHandler) DeleteTrashItem(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		JSONError(w, http.StatusBadRequest, "Invalid trash item ID")
+		return
+	}
+
+	item, err := h.trash.Delete(id, GetUsername(r))
+	if !h.trashError(w, err) {
+		return
+	}
+
+	JSONResponse(w, http.StatusOK, map[string]interface{}{
+		"ok":          true,
+		"freed_bytes": item.Size,
+	})
+}
+
+// EmptyTrashRequest represents a trash emptying request
+type EmptyTrashRequest struct {
+	// OlderThanDays only empties the items trashed earlier, 0 empties all
+	OlderThanDays int `json:"older_than_days"`
+}
+
+// EmptyTrash permanently deletes the trashed items
+func (h *TrashHandler) EmptyTrash(w http.ResponseWriter, r *http.Request) {
+	var req EmptyTrashRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			JSONError(w, http.StatusBadRequest, "Invalid request")
+			return
+		}
+	}
+	if req.OlderThanDays < 0 {
+		JSONError(w, http.StatusBadRequest, "older_than_days must not be negative")
+		return
+	}
+
+	var before time.Time
+	if req.OlderThanDays > 0 {
+		before = time.Now().AddDate(0, 0, -req.OlderThanDays)
+	}
+
+	count, size, err := h.trash.Empty(before, GetUsername(r))
+	if err != nil {
+		JSONError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to empty the trash: %v", err))
+		return
+	}
+
+	JSONResponse(w, http.StatusOK, map[string]interface{}{
+		"ok":          true,
+		"deleted":     count,
+		"freed_bytes": size,
+	})
+}
+
+// trashError writes the response of a trash error, and reports whether there
+// was none
+func (h *TrashHandler) trashError(w http.ResponseWriter, err error) bool {
+	switch {
+	case err == nil:
+		return true
+	case errors.Is(err, trash.ErrNotFound):
+		JSONError(w, http.StatusNotFound, err.Error())
+	case errors.Is(err, trash.ErrPathTaken):
+		JSONError(w, http.StatusConflict, err.Error())
+	default:
+		JSONError(w, http.StatusInternalServerError, err.Error())
+	}
+	return false
+}
@@ -0,0 +1,176 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/gosiva/hardlink-ui/internal/config"
+	"github.com/gosiva/hardlink-ui/internal/fsutil"
+	"github.com/gosiva/hardlink-ui/internal/storage"
+)
+
+// TestTrash verifies that deleted links and folders are moved to the trash
+// with their inode, and can be restored or deleted for good
+func TestTrash(t *testing.T) {
+	tmpDir := t.TempDir()
+	dataDir := filepath.Join(tmpDir, "data")
+
+	write := func(name string, size int) {
+		t.Helper()
+		path := filepath.Join(dataDir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Failed to create dir: %v", err)
+		}
+		if err := os.WriteFile(path, make([]byte, size), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+	}
+	stat := func(name string) os.FileInfo {
+		t.Helper()
+		info, err := os.Lstat(filepath.Join(dataDir, name))
+		if err != nil {
+			t.Fatalf("Failed to stat %s: %v", name, err)
+		}
+		return info
+	}
+	exists := func(name string) bool {
+		_, err := os.Lstat(filepath.Join(dataDir, name))
+		return err == nil
+	}
+
+	write("media/film.mkv", 1000)
+	if err := os.MkdirAll(filepath.Join(dataDir, "downloads"), 0755); err != nil {
+		t.Fatalf("Failed to create dir: %v", err)
+	}
+	if err := os.Link(filepath.Join(dataDir, "media/film.mkv"), filepath.Join(dataDir, "downloads/film.mkv")); err != nil {
+		t.Fatalf("Failed to link: %v", err)
+	}
+	write("downloads/Show/e01.mkv", 200)
+	write("downloads/Show/e02.mkv", 300)
+	film := stat("media/film.mkv")
+
+	db, err := storage.New(filepath.Join(tmpDir, "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+	defer db.Close()
+
+	cfg := &config.Config{DataRoot: dataDir, SecretKey: "secret", TrashEnabled: true, TrashRetentionDays: 30}
+	hardlinks := NewHardlinkHandler(db, cfg, nil)
+	trashHandler := NewTrashHandler(db, cfg)
+
+	router := chi.NewRouter()
+	router.Post("/api/delete-hardlink", hardlinks.DeleteHardlink)
+	router.Post("/api/delete-folder", hardlinks.DeleteFolder)
+	router.Get("/api/trash", trashHandler.ListTrash)
+	router.Post("/api/trash/empty", trashHandler.EmptyTrash)
+	router.Post("/api/trash/{id}/restore", trashHandler.RestoreTrashItem)
+	router.Delete("/api/trash/{id}", trashHandler.DeleteTrashItem)
+
+	call := func(method, url, body string, status int) map[string]interface{} {
+		t.Helper()
+		req := httptest.NewRequest(method, url, bytes.NewBufferString(body))
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		if w.Code != status {
+			t.Fatalf("%s %s: expected status %d, got %d: %s", method, url, status, w.Code, w.Body.String())
+		}
+		var resp map[string]interface{}
+		json.NewDecoder(w.Body).Decode(&resp)
+		return resp
+	}
+
+	// A deleted link keeps its inode in the trash
+	resp := call("POST", "/api/delete-hardlink", `{"path":"/downloads/film.mkv"}`, http.StatusOK)
+	linkID := int64(resp["trash_id"].(float64))
+	if exists("downloads/film.mkv") || resp["remaining_links"].(float64) != 1 {
+		t.Errorf("Expected the link to be trashed, got %v", resp)
+	}
+	if _, _, nlink, _ := fsutil.Identity(stat("media/film.mkv")); nlink != 2 {
+		t.Errorf("Expected the trashed link to keep the inode, got %d links", nlink)
+	}
+
+	// The last link outside the trash is protected
+	call("POST", "/api/delete-hardlink", `{"path":"/media/film.mkv"}`, http.StatusForbidden)
+
+	resp = call("GET", "/api/trash", "", http.StatusOK)
+	items := resp["items"].([]interface{})
+	if len(items) != 1 || resp["size"].(float64) != 1000 {
+		t.Fatalf("Expected the link in the trash, got %v", resp)
+	}
+	if item := items[0].(map[string]interface{}); item["path"] != "/downloads/film.mkv" || item["expires_at"].(float64) == 0 {
+		t.Errorf("Unexpected trash item %v", item)
+	}
+
+	// The restored link is the same file
+	call("POST", fmt.Sprintf("/api/trash/%d/restore", linkID), "", http.StatusOK)
+	if !os.SameFile(film, stat("downloads/film.mkv")) {
+		t.Error("Expected the restored link to share the inode")
+	}
+	call("POST", fmt.Sprintf("/api/trash/%d/restore", linkID), "", http.StatusNotFound)
+
+	// A taken path isn't overwritten
+	resp = call("POST", "/api/delete-hardlink", `{"path":"/downloads/film.mkv"}`, http.StatusOK)
+	linkID = int64(resp["trash_id"].(float64))
+	write("downloads/film.mkv", 10)
+	call("POST", fmt.Sprintf("/api/trash/%d/restore", linkID), "", http.StatusConflict)
+
+	// A deleted folder goes to the trash in one piece
+	resp = call("POST", "/api/delete-folder", `{"path":"/downloads/Show"}`, http.StatusOK)
+	token := resp["report"].(map[string]interface{})["token"].(string)
+	resp = call("POST", "/api/delete-folder", fmt.Sprintf(`{"path":"/downloads/Show","token":%q}`, token), http.StatusOK)
+	folderID := int64(resp["trash_id"].(float64))
+	if exists("downloads/Show") || resp["trashed_files"].(float64) != 2 || resp["trashed_bytes"].(float64) != 500 {
+		t.Errorf("Expected the folder to be trashed, got %v", resp)
+	}
+	if entries, _ := os.ReadDir(filepath.Join(dataDir, fsutil.TrashDir())); len(entries) != 2 {
+		t.Errorf("Expected 2 items in the trash directory, got %d", len(entries))
+	}
+
+	resp = call("DELETE", fmt.Sprintf("/api/trash/%d", folderID), "", http.StatusOK)
+	if resp["freed_bytes"].(float64) != 500 {
+		t.Errorf("Expected 500 bytes freed, got %v", resp)
+	}
+
+	// Recent items are kept by an older_than_days empty
+	resp = call("POST", "/api/trash/empty", `{"older_than_days":1}`, http.StatusOK)
+	if resp["deleted"].(float64) != 0 {
+		t.Errorf("Expected recent items to be kept, got %v", resp)
+	}
+	resp = call("POST", "/api/trash/empty", "", http.StatusOK)
+	if resp["deleted"].(float64) != 1 {
+		t.Errorf("Expected the trash to be emptied, got %v", resp)
+	}
+	if entries, _ := os.ReadDir(filepath.Join(dataDir, fsutil.TrashDir())); len(entries) != 0 {
+		t.Errorf("Expected an empty trash directory, got %d entries", len(entries))
+	}
+	if _, _, nlink, _ := fsutil.Identity(stat("media/film.mkv")); nlink != 1 {
+		t.Errorf("Expected the emptied link to be removed, got %d links", nlink)
+	}
+
+	// The links inside a trashed folder protect the last link outside
+	write("media/clip.mkv", 50)
+	if err := os.MkdirAll(filepath.Join(dataDir, "downloads/Extra"), 0755); err != nil {
+		t.Fatalf("Failed to create dir: %v", err)
+	}
+	if err := os.Link(filepath.Join(dataDir, "media/clip.mkv"), filepath.Join(dataDir, "downloads/Extra/clip.mkv")); err != nil {
+		t.Fatalf("Failed to link: %v", err)
+	}
+	resp = call("POST", "/api/delete-folder", `{"path":"/downloads/Extra"}`, http.StatusOK)
+	token = resp["report"].(map[string]interface{})["token"].(string)
+	call("POST", "/api/delete-folder", fmt.Sprintf(`{"path":"/downloads/Extra","token":%q}`, token), http.StatusOK)
+	call("POST", "/api/delete-hardlink", `{"path":"/media/clip.mkv"}`, http.StatusForbidden)
+
+	// Nor do they keep the data of a folder to delete
+	resp = call("POST", "/api/delete-folder", `{"path":"/media"}`, http.StatusOK)
+	if report := resp["report"].(map[string]interface{}); report["lost_files"].(float64) != 2 || report["linked_files"].(float64) != 0 {
+		t.Errorf("Expected the trashed links to be lost with the folder, got %v", report)
+	}
+}
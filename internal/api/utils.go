@@ -1,6 +1,10 @@
 package api
 
-import "fmt"
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
 
 // humanSize formats a byte size in human-readable format
 func humanSize(size int64) string {
@@ -18,3 +22,28 @@ func humanSize(size int64) string {
 	units := []string{"Ko", "Mo", "Go", "To"}
 	return fmt.Sprintf("%.1f %s", float64(size)/float64(div), units[exp])
 }
+
+// isTruthy reports whether a query parameter value enables an option
+func isTruthy(value string) bool {
+	switch strings.ToLower(value) {
+	case "1", "true", "yes", "on":
+		return true
+	}
+	return false
+}
+
+// rootRelPath converts an absolute path under root to a "/"-prefixed relative
+// path, or "" when it is outside root
+func rootRelPath(root, path string) string {
+	rel, err := filepath.Rel(root, path)
+	if err != nil || rel == "" || strings.HasPrefix(rel, "..") {
+		return ""
+	}
+	return "/" + rel
+}
+
+// isSubPath reports whether path is dir or one of its descendants
+func isSubPath(dir, path string) bool {
+	rel, err := filepath.Rel(dir, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, "../")
+}
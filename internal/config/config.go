@@ -3,6 +3,7 @@ package config
 import (
 	"os"
 	"strconv"
+	"strings"
 )
 
 // Config holds all application configuration
@@ -26,6 +27,32 @@ type Config struct {
 
 	// Session
 	SessionTimeout int // seconds
+
+	// Inode index
+	IndexInterval int // seconds between two full index passes, 0 runs a single pass
+
+	// Duplicate scanner
+	ScanWorkers     int   // files hashed in parallel
+	ScanReadLimit   int64 // bytes per second, 0 is unlimited
+	ScanLowPriority bool  // idle I/O class for hashing workers
+
+	// VerifyPolicy is the default content verification: quick, sampled,
+	// sha256, blake3 or full
+	VerifyPolicy string
+
+	// MetadataPolicy is the default handling of metadata when merging
+	// duplicates: warn, refuse, union or normalize
+	MetadataPolicy string
+
+	// MasterStrategy is the default master selection of duplicate groups and
+	// PreferPaths the path prefixes of the prefer-path strategy
+	MasterStrategy string
+	PreferPaths    []string
+
+	// Trash
+	TrashEnabled       bool   // deleted links are moved to the trash of their device
+	TrashRetentionDays int    // days before trashed items are purged, 0 keeps them
+	TrashDir           string // name of the trash directory at the top of each device
 }
 
 // Load loads configuration from environment variables
@@ -37,6 +64,49 @@ func Load() *Config {
 		}
 	}
 
+	indexInterval := 6 * 3600 // default 6 hours
+	if ii := os.Getenv("INDEX_INTERVAL"); ii != "" {
+		if parsed, err := strconv.Atoi(ii); err == nil {
+			indexInterval = parsed
+		}
+	}
+
+	scanWorkers := 2
+	if sw := os.Getenv("SCAN_WORKERS"); sw != "" {
+		if parsed, err := strconv.Atoi(sw); err == nil && parsed > 0 {
+			scanWorkers = parsed
+		}
+	}
+
+	var scanReadLimit int64
+	if rl := os.Getenv("SCAN_READ_LIMIT"); rl != "" {
+		if parsed, err := ParseByteSize(rl); err == nil {
+			scanReadLimit = parsed
+		}
+	}
+
+	scanLowPriority, _ := strconv.ParseBool(os.Getenv("SCAN_LOW_PRIORITY"))
+
+	trashEnabled := true
+	if te := os.Getenv("TRASH_ENABLED"); te != "" {
+		if parsed, err := strconv.ParseBool(te); err == nil {
+			trashEnabled = parsed
+		}
+	}
+
+	trashRetentionDays := 30
+	if tr := os.Getenv("TRASH_RETENTION_DAYS"); tr != "" {
+		if parsed, err := strconv.Atoi(tr); err == nil && parsed >= 0 {
+			trashRetentionDays = parsed
+		}
+	}
+
+	// The trash is a single directory name, never a path
+	trashDir := getEnv("TRASH_DIR", ".hardlink-ui-trash")
+	if strings.ContainsRune(trashDir, '/') || trashDir == "." || trashDir == ".." {
+		trashDir = ".hardlink-ui-trash"
+	}
+
 	dataRoot := os.Getenv("APP_DATA_ROOT")
 	if dataRoot == "" {
 		dataRoot = "/data"
@@ -58,12 +128,63 @@ func Load() *Config {
 		DBPath:         dbPath,
 		LogLevel:       getEnv("LOG_LEVEL", "INFO"),
 		SessionTimeout: sessionTimeout,
+		IndexInterval:  indexInterval,
+
+		ScanWorkers:     scanWorkers,
+		ScanReadLimit:   scanReadLimit,
+		ScanLowPriority: scanLowPriority,
+
+		VerifyPolicy:   strings.ToLower(getEnv("VERIFY_POLICY", "quick")),
+		MetadataPolicy: strings.ToLower(getEnv("METADATA_POLICY", "warn")),
+
+		MasterStrategy: strings.ToLower(getEnv("MASTER_STRATEGY", "lowest-inode")),
+		PreferPaths:    splitList(os.Getenv("PREFER_PATHS")),
+
+		TrashEnabled:       trashEnabled,
+		TrashRetentionDays: trashRetentionDays,
+		TrashDir:           trashDir,
 	}
 }
 
+// ParseByteSize parses a size such as "52428800", "50M" or "1G" (binary units)
+func ParseByteSize(value string) (int64, error) {
+	value = strings.ToUpper(strings.TrimSpace(value))
+	value = strings.TrimSuffix(value, "B")
+
+	multiplier := int64(1)
+	switch {
+	case strings.HasSuffix(value, "K"):
+		multiplier = 1 << 10
+	case strings.HasSuffix(value, "M"):
+		multiplier = 1 << 20
+	case strings.HasSuffix(value, "G"):
+		multiplier = 1 << 30
+	}
+	if multiplier > 1 {
+		value = value[:len(value)-1]
+	}
+
+	n, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	return n * multiplier, nil
+}
+
 func getEnv(key, defaultValue string) string {
 	if value := os.Getenv(key); value != "" {
 		return value
 	}
 	return defaultValue
 }
+
+// splitList splits a comma separated value into trimmed, non-empty items
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
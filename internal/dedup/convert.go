@@ -0,0 +1,391 @@
+package dedup
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/gosiva/hardlink-ui/internal/fsutil"
+	"github.com/gosiva/hardlink-ui/internal/indexer"
+	"github.com/gosiva/hardlink-ui/internal/journal"
+	"github.com/gosiva/hardlink-ui/internal/scanner"
+	"github.com/gosiva/hardlink-ui/internal/storage"
+)
+
+// Group is a set of identical files to merge: the others become hardlinks
+// to the master. Paths are relative to the data root.
+type Group struct {
+	Master string   `json:"master"`
+	Others []string `json:"others"`
+	// Strategy overrides the master: it is selected again among all the
+	// files of the group
+	Strategy scanner.MasterStrategy `json:"master_strategy,omitempty"`
+	// PreferPaths are the path prefixes of scanner.MasterPreferPath
+	PreferPaths []string `json:"prefer_paths,omitempty"`
+	// Verify is the policy that confirmed the group. The files are verified
+	// with it when it is stronger than the requested one.
+	Verify scanner.VerifyPolicy `json:"verify,omitempty"`
+}
+
+// reselect applies the group's master strategy, if any
+func (g *Group) reselect(dataRoot string) error {
+	if g.Strategy == "" {
+		return nil
+	}
+
+	members := append([]string{g.Master}, g.Others...)
+	master, err := scanner.SelectMaster(dataRoot, members, g.Strategy, g.PreferPaths)
+	if err != nil {
+		return err
+	}
+
+	g.Master = master
+	g.Others = g.Others[:0:0]
+	for _, member := range members {
+		if member != master {
+			g.Others = append(g.Others, member)
+		}
+	}
+	return nil
+}
+
+// File statuses of a conversion report
+const (
+	StatusConverted = "converted"
+	StatusPlanned   = "planned" // would be converted, see Converter.DryRun
+	StatusSkipped   = "skipped"
+	StatusFailed    = "failed"
+)
+
+// AlreadyLinked is the error of a file skipped because it is already a link
+// of its master. It isn't reported in Result.Errors.
+const AlreadyLinked = "already linked to master"
+
+// inodeKey identifies an inode across devices
+type inodeKey struct {
+	dev, ino uint64
+}
+
+// FileReport is the outcome of converting one file
+type FileReport struct {
+	Path      string     `json:"path"`
+	Master    string     `json:"master"`
+	Status    string     `json:"status"`
+	Error     string     `json:"error,omitempty"`
+	Conflicts []Conflict `json:"conflicts,omitempty"` // metadata differing from the master
+	Merged    []string   `json:"merged,omitempty"`    // fields merged into the master
+	// OperationID is the journal entry undoing the conversion
+	OperationID int64 `json:"operation_id,omitempty"`
+}
+
+// Result summarizes a conversion
+type Result struct {
+	Created int
+	Planned int
+	Skipped int
+	// BytesSaved counts the duplicates whose last link was replaced, it is
+	// projected when planned
+	BytesSaved int64
+	Errors     []string
+	Files      []FileReport
+}
+
+// Converter replaces duplicate files with hardlinks to a master file
+type Converter struct {
+	db       *storage.DB
+	dataRoot string
+	metadata MetadataPolicy
+	journal  *journal.Journal
+	uid, gid int
+	// Username is recorded in the journal with each converted file
+	Username string
+	// DryRun runs every check without touching the filesystem: the files
+	// that would be converted are reported as planned
+	DryRun bool
+}
+
+// NewConverter creates a new converter. With MetadataNormalize, files are
+// normalized to the PUID/PGID owner when both are set.
+func NewConverter(db *storage.DB, dataRoot string, metadata MetadataPolicy) *Converter {
+	uid, _ := strconv.Atoi(os.Getenv("PUID"))
+	gid, _ := strconv.Atoi(os.Getenv("PGID"))
+	return &Converter{
+		db:       db,
+		dataRoot: dataRoot,
+		metadata: metadata,
+		journal:  journal.New(db, dataRoot),
+		uid:      uid,
+		gid:      gid,
+	}
+}
+
+// Convert verifies each group with policy, or the group's own when stronger,
+// and replaces its other files with
+// hardlinks to the master. Failures are collected and don't stop the others.
+func (c *Converter) Convert(groups []Group, policy scanner.VerifyPolicy) Result {
+	return c.ConvertContext(context.Background(), groups, policy, nil)
+}
+
+// ConvertContext is Convert stopping when ctx is cancelled, with the files
+// converted so far. onFile, if not nil, is called with the report of each file.
+func (c *Converter) ConvertContext(ctx context.Context, groups []Group, policy scanner.VerifyPolicy, onFile func(FileReport)) Result {
+	var result Result
+
+	report := func(file FileReport) {
+		result.Files = append(result.Files, file)
+		if onFile != nil {
+			onFile(file)
+		}
+	}
+
+	// fail records an error preventing the conversion of a whole group
+	fail := func(group Group, msg string) {
+		result.Errors = append(result.Errors, msg)
+		for _, other := range group.Others {
+			report(FileReport{
+				Path:   other,
+				Master: group.Master,
+				Status: StatusFailed,
+				Error:  msg,
+			})
+		}
+	}
+
+	for _, group := range groups {
+		if ctx.Err() != nil {
+			break
+		}
+		if group.Master == "" || len(group.Others) == 0 {
+			continue
+		}
+
+		if err := group.reselect(c.dataRoot); err != nil {
+			fail(group, fmt.Sprintf("Failed to select master of %s: %v", group.Master, err))
+			continue
+		}
+
+		masterPath := filepath.Join(c.dataRoot, strings.TrimPrefix(group.Master, "/"))
+		masterPath = filepath.Clean(masterPath)
+
+		if !strings.HasPrefix(masterPath, c.dataRoot) {
+			fail(group, fmt.Sprintf("Master path outside root: %s", group.Master))
+			continue
+		}
+
+		masterInfo, err := os.Stat(masterPath)
+		if err != nil {
+			fail(group, fmt.Sprintf("Failed to stat master %s: %v", group.Master, err))
+			continue
+		}
+
+		if !masterInfo.Mode().IsRegular() {
+			fail(group, fmt.Sprintf("Master is not a regular file: %s", group.Master))
+			continue
+		}
+
+		// Normalize master permissions
+		if c.normalize() && !c.DryRun {
+			_ = os.Chown(masterPath, c.uid, c.gid)
+			_ = os.Chmod(masterPath, 0o644)
+			if info, err := os.Stat(masterPath); err == nil {
+				masterInfo = info
+			}
+		}
+
+		masterMeta, err := readMetadata(masterPath, masterInfo)
+		if err != nil {
+			fail(group, fmt.Sprintf("Failed to read metadata of master %s: %v", group.Master, err))
+			continue
+		}
+
+		// Links of a duplicate inode replaced by a dry run, which still
+		// count in its nlink
+		planned := make(map[inodeKey]uint64)
+
+		for _, otherRel := range group.Others {
+			if ctx.Err() != nil {
+				break
+			}
+
+			file, size := c.convertFile(masterPath, masterInfo, masterMeta, group.Master, otherRel, policy.Stronger(group.Verify), planned)
+
+			switch file.Status {
+			case StatusConverted:
+				result.Created++
+				result.BytesSaved += size
+				if file.Error != "" {
+					result.Errors = append(result.Errors, fmt.Sprintf("%s: %s", otherRel, file.Error))
+				}
+			case StatusPlanned:
+				result.Planned++
+				result.BytesSaved += size
+			case StatusSkipped:
+				result.Skipped++
+				if file.Error != AlreadyLinked {
+					result.Errors = append(result.Errors, fmt.Sprintf("%s: %s", otherRel, file.Error))
+				}
+			default:
+				result.Errors = append(result.Errors, file.Error)
+			}
+			report(file)
+		}
+	}
+
+	return result
+}
+
+// normalize reports whether files are chowned to PUID/PGID
+func (c *Converter) normalize() bool {
+	return c.metadata == MetadataNormalize && c.uid > 0 && c.gid > 0
+}
+
+// convertFile replaces one duplicate with a hardlink to the master and
+// returns its report and the number of bytes freed: the size of the
+// duplicate if it was the last link of its inode. planned counts the links
+// replaced by a dry run.
+func (c *Converter) convertFile(masterPath string, masterInfo os.FileInfo, masterMeta *fileMetadata, masterRel, otherRel string, policy scanner.VerifyPolicy, planned map[inodeKey]uint64) (FileReport, int64) {
+	report := FileReport{Path: otherRel, Master: masterRel, Status: StatusFailed}
+	failed := func(format string, args ...interface{}) (FileReport, int64) {
+		report.Error = fmt.Sprintf(format, args...)
+		return report, 0
+	}
+
+	otherPath := filepath.Join(c.dataRoot, strings.TrimPrefix(otherRel, "/"))
+	otherPath = filepath.Clean(otherPath)
+
+	if !strings.HasPrefix(otherPath, c.dataRoot) {
+		return failed("Path outside root: %s", otherRel)
+	}
+
+	otherInfo, err := os.Stat(otherPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return failed("File not found: %s", otherRel)
+		}
+		return failed("Failed to stat %s: %v", otherRel, err)
+	}
+
+	// Hardlinks can't span filesystems: refuse before touching anything
+	if !sameDevice(masterInfo, otherInfo) {
+		return failed("%s: %v", otherRel, fsutil.ErrCrossDevice)
+	}
+
+	masterDev, masterIno, _, _ := fsutil.Identity(masterInfo)
+	otherDev, otherIno, otherNlink, _ := fsutil.Identity(otherInfo)
+	if masterDev == otherDev && masterIno == otherIno {
+		report.Status = StatusSkipped
+		report.Error = AlreadyLinked
+		return report, 0
+	}
+
+	// The data is only freed with the last link of the duplicate
+	var size int64
+	id := inodeKey{otherDev, otherIno}
+	if otherNlink <= planned[id]+1 {
+		size = otherInfo.Size()
+	}
+
+	// Verify files are identical
+	identical, err := scanner.VerifyFiles(masterPath, otherPath, policy)
+	if err != nil {
+		return failed("%s: verification error: %v", otherRel, err)
+	}
+
+	if !identical {
+		return failed("%s: files are not identical", otherRel)
+	}
+
+	// The file's own metadata is lost once it shares the master's inode
+	otherMeta, err := readMetadata(otherPath, otherInfo)
+	if err != nil {
+		return failed("%s: %v", otherRel, err)
+	}
+	for _, conflict := range compareMetadata(masterMeta, otherMeta) {
+		// Normalized files get the same owner and mode anyway
+		if c.normalize() && (conflict.Field == "owner" || conflict.Field == "mode") {
+			continue
+		}
+		report.Conflicts = append(report.Conflicts, conflict)
+	}
+
+	if c.metadata == MetadataRefuse {
+		for _, conflict := range report.Conflicts {
+			if conflict.blocking() {
+				report.Status = StatusSkipped
+				report.Error = fmt.Sprintf("%s differs from master", conflict.Field)
+				return report, 0
+			}
+		}
+	}
+
+	// The duplicate is replaced within its directory
+	if err := fsutil.Writable(filepath.Dir(otherPath)); err != nil {
+		return failed("%s: %v", otherRel, err)
+	}
+
+	if c.DryRun {
+		planned[id]++
+		report.Status = StatusPlanned
+		return report, size
+	}
+
+	// A unique name can't collide with an existing file, even a leftover
+	tmpPath, err := journal.TempLinkPath(otherPath)
+	if err != nil {
+		return failed("%s: %v", otherRel, err)
+	}
+
+	// Each step is recorded before it is done, so that a conversion
+	// interrupted by a crash is repaired at startup
+	intent, err := c.journal.BeginReplace(otherPath, tmpPath, masterPath, masterInfo, otherInfo, otherMeta.snapshot(), c.Username)
+	if err != nil {
+		return failed("%s: %v", otherRel, err)
+	}
+
+	// 1. Create temporary hardlink
+	if err := os.Link(masterPath, tmpPath); err != nil {
+		intent.Abort()
+		return failed("%s: failed to create temporary hardlink: %v", otherRel, err)
+	}
+
+	// ❌ DO NOT CHOWN THE HARDLINK — impossible and useless
+	// It shares the inode with master, so permissions come from master.
+
+	// 2. Atomically replace the duplicate: its path always names a file
+	if err := intent.Step(journal.StepRename); err != nil {
+		_ = os.Remove(tmpPath)
+		intent.Abort()
+		return failed("%s: %v", otherRel, err)
+	}
+	if err := os.Rename(tmpPath, otherPath); err != nil {
+		_ = os.Remove(tmpPath)
+		intent.Abort()
+		return failed("%s: failed to replace with hardlink: %v", otherRel, err)
+	}
+
+	// Update inode index: the path now points to the master inode
+	indexer.RemovePath(c.db, otherDev, otherIno, otherPath)
+	indexer.AddPath(c.db, masterDev, masterIno, otherPath)
+
+	report.OperationID = intent.Commit()
+	report.Status = StatusConverted
+	if c.metadata == MetadataUnion {
+		merged, err := mergeMetadata(masterPath, masterMeta, otherMeta)
+		report.Merged = merged
+		if err != nil {
+			report.Error = err.Error()
+		}
+	}
+
+	return report, size
+}
+
+// sameDevice reports whether two files live on the same filesystem
+func sameDevice(a, b os.FileInfo) bool {
+	devA, _, _, okA := fsutil.Identity(a)
+	devB, _, _, okB := fsutil.Identity(b)
+	return okA && okB && devA == devB
+}
@@ -0,0 +1,211 @@
+package dedup
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gosiva/hardlink-ui/internal/fsutil"
+	"github.com/gosiva/hardlink-ui/internal/journal"
+)
+
+// MetadataPolicy selects how the metadata of merged files is handled. A
+// hardlink shares the master's inode, so the ownership, mode, timestamps and
+// extended attributes of a replaced file are lost unless merged into it.
+type MetadataPolicy string
+
+const (
+	// MetadataWarn keeps the master's metadata and reports the differences
+	MetadataWarn MetadataPolicy = "warn"
+	// MetadataRefuse skips files whose ownership, mode, ACLs or extended
+	// attributes differ from the master's
+	MetadataRefuse MetadataPolicy = "refuse"
+	// MetadataUnion merges into the master the permission bits and the
+	// extended attributes it lacks, and keeps the oldest mtime
+	MetadataUnion MetadataPolicy = "union"
+	// MetadataNormalize chowns the files to PUID/PGID and sets mode 0644
+	MetadataNormalize MetadataPolicy = "normalize"
+)
+
+// ParseMetadataPolicy validates a policy name, empty meaning MetadataWarn
+func ParseMetadataPolicy(value string) (MetadataPolicy, error) {
+	switch policy := MetadataPolicy(value); policy {
+	case "":
+		return MetadataWarn, nil
+	case MetadataWarn, MetadataRefuse, MetadataUnion, MetadataNormalize:
+		return policy, nil
+	default:
+		return "", fmt.Errorf("unknown metadata policy %q", value)
+	}
+}
+
+// aclPrefix is the namespace of POSIX ACLs stored as extended attributes
+const aclPrefix = "system.posix_acl_"
+
+// Conflict is a metadata difference between a file and its master
+type Conflict struct {
+	Field  string `json:"field"` // owner, mode, mtime, acl:<name> or xattr:<name>
+	Master string `json:"master"`
+	File   string `json:"file"`
+}
+
+// blocking reports whether the conflict makes MetadataRefuse skip the file.
+// Modification times of copies almost always differ and are only reported.
+func (c Conflict) blocking() bool {
+	return c.Field != "mtime"
+}
+
+// fileMetadata is the metadata of a file that a hardlink doesn't keep
+type fileMetadata struct {
+	uid, gid uint32
+	mode     os.FileMode
+	mtime    time.Time
+	xattrs   map[string][]byte
+}
+
+// readMetadata reads the metadata of a file
+func readMetadata(path string, info os.FileInfo) (*fileMetadata, error) {
+	uid, gid, _ := fsutil.Owner(info)
+	xattrs, err := fsutil.Xattrs(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read extended attributes: %w", err)
+	}
+	return &fileMetadata{
+		uid:    uid,
+		gid:    gid,
+		mode:   info.Mode().Perm(),
+		mtime:  info.ModTime(),
+		xattrs: xattrs,
+	}, nil
+}
+
+// snapshot returns the metadata to restore when the conversion is undone
+func (m *fileMetadata) snapshot() *journal.Metadata {
+	return &journal.Metadata{
+		UID:    m.uid,
+		GID:    m.gid,
+		Mode:   m.mode,
+		Mtime:  m.mtime.UnixNano(),
+		Xattrs: m.xattrs,
+	}
+}
+
+// compareMetadata lists the differences between a file and its master
+func compareMetadata(master, file *fileMetadata) []Conflict {
+	var conflicts []Conflict
+
+	if master.uid != file.uid || master.gid != file.gid {
+		conflicts = append(conflicts, Conflict{
+			Field:  "owner",
+			Master: fmt.Sprintf("%d:%d", master.uid, master.gid),
+			File:   fmt.Sprintf("%d:%d", file.uid, file.gid),
+		})
+	}
+	if master.mode != file.mode {
+		conflicts = append(conflicts, Conflict{
+			Field:  "mode",
+			Master: fmt.Sprintf("%04o", master.mode),
+			File:   fmt.Sprintf("%04o", file.mode),
+		})
+	}
+	if !master.mtime.Equal(file.mtime) {
+		conflicts = append(conflicts, Conflict{
+			Field:  "mtime",
+			Master: master.mtime.Format(time.RFC3339),
+			File:   file.mtime.Format(time.RFC3339),
+		})
+	}
+
+	names := make(map[string]bool)
+	for name := range master.xattrs {
+		names[name] = true
+	}
+	for name := range file.xattrs {
+		names[name] = true
+	}
+	sorted := make([]string, 0, len(names))
+	for name := range names {
+		sorted = append(sorted, name)
+	}
+	sort.Strings(sorted)
+
+	for _, name := range sorted {
+		masterValue, inMaster := master.xattrs[name]
+		fileValue, inFile := file.xattrs[name]
+		if inMaster && inFile && bytes.Equal(masterValue, fileValue) {
+			continue
+		}
+		conflicts = append(conflicts, Conflict{
+			Field:  xattrField(name),
+			Master: formatXattr(masterValue, inMaster),
+			File:   formatXattr(fileValue, inFile),
+		})
+	}
+
+	return conflicts
+}
+
+// xattrField names the conflict field of an extended attribute
+func xattrField(name string) string {
+	if strings.HasPrefix(name, aclPrefix) {
+		return "acl:" + strings.TrimPrefix(name, aclPrefix)
+	}
+	return "xattr:" + name
+}
+
+// formatXattr describes an extended attribute value for a report
+func formatXattr(value []byte, present bool) string {
+	switch {
+	case !present:
+		return "absent"
+	case utf8.Valid(value) && len(value) <= 64 && !bytes.ContainsRune(value, 0):
+		return string(value)
+	default:
+		return fmt.Sprintf("%d bytes", len(value))
+	}
+}
+
+// mergeMetadata applies MetadataUnion: the master gains the permission bits
+// and extended attributes of file it lacks, and the older mtime. Owners and
+// attributes set on both with different values keep the master's. It returns
+// the merged fields.
+func mergeMetadata(masterPath string, master, file *fileMetadata) ([]string, error) {
+	var merged []string
+
+	if mode := master.mode | file.mode; mode != master.mode {
+		if err := os.Chmod(masterPath, mode); err != nil {
+			return merged, fmt.Errorf("failed to merge mode: %w", err)
+		}
+		master.mode = mode
+		merged = append(merged, "mode")
+	}
+
+	for name, value := range file.xattrs {
+		if _, ok := master.xattrs[name]; ok {
+			continue
+		}
+		if err := fsutil.SetXattr(masterPath, name, value); err != nil {
+			return merged, fmt.Errorf("failed to merge %s: %w", xattrField(name), err)
+		}
+		if master.xattrs == nil {
+			master.xattrs = make(map[string][]byte)
+		}
+		master.xattrs[name] = value
+		merged = append(merged, xattrField(name))
+	}
+
+	if file.mtime.Before(master.mtime) {
+		if err := os.Chtimes(masterPath, file.mtime, file.mtime); err != nil {
+			return merged, fmt.Errorf("failed to merge mtime: %w", err)
+		}
+		master.mtime = file.mtime
+		merged = append(merged, "mtime")
+	}
+
+	sort.Strings(merged)
+	return merged, nil
+}
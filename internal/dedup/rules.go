@@ -0,0 +1,389 @@
+package dedup
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/gosiva/hardlink-ui/internal/filter"
+	"github.com/gosiva/hardlink-ui/internal/fsutil"
+	"github.com/gosiva/hardlink-ui/internal/scanner"
+	"github.com/gosiva/hardlink-ui/internal/storage"
+)
+
+var (
+	// ErrInvalidRule is returned when rule settings are rejected
+	ErrInvalidRule = errors.New("invalid rule")
+	// ErrJobNotCompleted is returned when evaluating rules on a scan job
+	// without results
+	ErrJobNotCompleted = errors.New("scan job is not completed")
+)
+
+// Conditions are the settings of a rule. A duplicate group matches when every
+// one of its files passes them.
+type Conditions struct {
+	// Roots requires the files to be under one of these directories
+	Roots []string `json:"roots,omitempty"`
+	filter.Rules
+	// Master selects the file the others are linked to, the master chosen
+	// by the scan is kept when empty
+	Master scanner.MasterStrategy `json:"master,omitempty"`
+	// PreferPaths are the path prefixes of scanner.MasterPreferPath
+	PreferPaths []string `json:"prefer_paths,omitempty"`
+	// Verify overrides the configured verification policy
+	Verify scanner.VerifyPolicy `json:"verify,omitempty"`
+	// Metadata overrides the configured metadata policy
+	Metadata MetadataPolicy `json:"metadata,omitempty"`
+}
+
+// Rule selects duplicate groups to convert without manual selection
+type Rule struct {
+	ID      int64  `json:"id"`
+	Name    string `json:"name"`
+	Enabled bool   `json:"enabled"`
+	// AutoApply converts the matching groups when a scan completes, otherwise
+	// they are only reported
+	AutoApply bool `json:"auto_apply"`
+	Conditions
+}
+
+// ApplyMode selects which matches an evaluation converts
+type ApplyMode int
+
+const (
+	// DryRun only reports the matches
+	DryRun ApplyMode = iota
+	// ApplyAuto converts the matches of the auto-apply rules
+	ApplyAuto
+	// ApplyAll converts every match
+	ApplyAll
+)
+
+// Match is a duplicate group selected by a rule
+type Match struct {
+	RuleID      int64        `json:"rule_id"`
+	Rule        string       `json:"rule"`
+	Size        int64        `json:"size"`
+	Master      string       `json:"master"`
+	Others      []string     `json:"others"`
+	Reclaimable int64        `json:"reclaimable"`
+	Applied     bool         `json:"applied"`
+	Created     int          `json:"created,omitempty"`
+	Errors      []string     `json:"errors,omitempty"`
+	Files       []FileReport `json:"files,omitempty"`
+}
+
+// Report is the outcome of evaluating the rules on the results of a scan job
+type Report struct {
+	JobID       string  `json:"job_id"`
+	CreatedAt   int64   `json:"created_at"`
+	DryRun      bool    `json:"dry_run"`
+	Groups      int     `json:"groups"`      // groups matched by a rule
+	Reclaimable int64   `json:"reclaimable"` // bytes freed by converting every match
+	Converted   int     `json:"converted"`   // files replaced by hardlinks
+	BytesSaved  int64   `json:"bytes_saved"`
+	Matches     []Match `json:"matches"`
+}
+
+// Engine evaluates the conversion rules on scan results
+type Engine struct {
+	db       *storage.DB
+	dataRoot string
+	verify   scanner.VerifyPolicy
+	metadata MetadataPolicy
+}
+
+// NewEngine creates a new rule engine. verify and metadata are the policies
+// of rules that don't set them.
+func NewEngine(db *storage.DB, dataRoot string, verify scanner.VerifyPolicy, metadata MetadataPolicy) *Engine {
+	return &Engine{
+		db:       db,
+		dataRoot: dataRoot,
+		verify:   verify,
+		metadata: metadata,
+	}
+}
+
+// Validate checks the conditions of a rule and normalizes its roots
+func (c *Conditions) Validate() error {
+	for i, root := range c.Roots {
+		root = path.Clean("/" + strings.TrimSpace(root))
+		c.Roots[i] = root
+	}
+
+	if c.Master != "" {
+		if _, err := scanner.ParseMasterStrategy(string(c.Master)); err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidRule, err)
+		}
+	}
+
+	if c.Verify != "" {
+		if _, err := scanner.ParseVerifyPolicy(string(c.Verify)); err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidRule, err)
+		}
+	}
+
+	if c.Metadata != "" {
+		if _, err := ParseMetadataPolicy(string(c.Metadata)); err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidRule, err)
+		}
+	}
+
+	if _, err := c.Rules.Compile(); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidRule, err)
+	}
+	return nil
+}
+
+// ListRules returns the stored rules in evaluation order
+func (e *Engine) ListRules() ([]Rule, error) {
+	records, err := e.db.ListConversionRules()
+	if err != nil {
+		return nil, err
+	}
+
+	rules := make([]Rule, 0, len(records))
+	for _, record := range records {
+		rule := Rule{
+			ID:        record.ID,
+			Name:      record.Name,
+			Enabled:   record.Enabled,
+			AutoApply: record.AutoApply,
+		}
+		if err := json.Unmarshal([]byte(record.Conditions), &rule.Conditions); err != nil {
+			return nil, fmt.Errorf("failed to decode rule %d: %w", record.ID, err)
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+// compiledRule is an enabled rule ready for matching
+type compiledRule struct {
+	Rule
+	matcher   *filter.Matcher
+	verify    scanner.VerifyPolicy
+	converter *Converter
+}
+
+// matches reports whether every file of a group passes the rule
+func (r *compiledRule) matches(size int64, paths []string) bool {
+	for _, p := range paths {
+		if !underRoots(p, r.Roots) || !r.matcher.MatchPath(p, size) {
+			return false
+		}
+	}
+	return true
+}
+
+// underRoots reports whether rel is inside one of roots, or roots is empty
+func underRoots(rel string, roots []string) bool {
+	if len(roots) == 0 {
+		return true
+	}
+	for _, root := range roots {
+		if root == "/" || rel == root || strings.HasPrefix(rel, root+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// Evaluate matches the results of a completed scan job against the enabled
+// rules, converts the matches selected by mode and stores the report. A group
+// is handled by the first rule it matches.
+func (e *Engine) Evaluate(jobID string, mode ApplyMode) (*Report, error) {
+	job, err := e.db.GetScanJob(jobID)
+	if err != nil {
+		return nil, err
+	}
+	if job == nil {
+		return nil, scanner.ErrJobNotFound
+	}
+	if job.Status != "completed" {
+		return nil, ErrJobNotCompleted
+	}
+
+	rules, err := e.ListRules()
+	if err != nil {
+		return nil, err
+	}
+	var compiled []compiledRule
+	for _, rule := range rules {
+		if !rule.Enabled {
+			continue
+		}
+		matcher, err := rule.Rules.Compile()
+		if err != nil {
+			log.Printf("Rule %d (%s) skipped: %v", rule.ID, rule.Name, err)
+			continue
+		}
+		verify := rule.Verify
+		if verify == "" {
+			verify = e.verify
+		}
+		metadata := rule.Metadata
+		if metadata == "" {
+			metadata = e.metadata
+		}
+		converter := NewConverter(e.db, e.dataRoot, metadata)
+		converter.Username = fmt.Sprintf("rule:%d", rule.ID)
+		compiled = append(compiled, compiledRule{
+			Rule:      rule,
+			matcher:   matcher,
+			verify:    verify,
+			converter: converter,
+		})
+	}
+
+	groups, err := e.db.GetDuplicateGroups(jobID)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &Report{
+		JobID:     jobID,
+		CreatedAt: time.Now().Unix(),
+		DryRun:    mode == DryRun,
+		Matches:   make([]Match, 0),
+	}
+
+	for _, group := range groups {
+		members := append([]string{group.Master}, group.Others...)
+		for i := range compiled {
+			rule := &compiled[i]
+			if !rule.matches(group.Size, members) {
+				continue
+			}
+
+			master := e.selectMaster(&rule.Conditions, members)
+			match := Match{
+				RuleID: rule.ID,
+				Rule:   rule.Name,
+				Size:   group.Size,
+				Master: master,
+			}
+			for _, member := range members {
+				if member != master {
+					match.Others = append(match.Others, member)
+				}
+			}
+			match.Reclaimable = e.reclaimable(group.Size, match.Master, match.Others)
+
+			if mode == ApplyAll || (mode == ApplyAuto && rule.AutoApply) {
+				result := rule.converter.Convert([]Group{{Master: match.Master, Others: match.Others, Verify: scanner.VerifyPolicy(group.Verify)}}, rule.verify)
+				match.Applied = true
+				match.Created = result.Created
+				match.Errors = result.Errors
+				match.Files = result.Files
+				report.Converted += result.Created
+				report.BytesSaved += result.BytesSaved
+			}
+
+			report.Groups++
+			report.Reclaimable += match.Reclaimable
+			report.Matches = append(report.Matches, match)
+			break
+		}
+	}
+
+	data, err := json.Marshal(report)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode report: %w", err)
+	}
+	if err := e.db.SaveConversionReport(jobID, string(data)); err != nil {
+		return nil, fmt.Errorf("failed to save report: %w", err)
+	}
+
+	return report, nil
+}
+
+// selectMaster picks the master of a matched group. members starts with the
+// master chosen by the scan, which is kept when the rule has no strategy.
+func (e *Engine) selectMaster(c *Conditions, members []string) string {
+	if c.Master == "" {
+		return members[0]
+	}
+
+	master, err := scanner.SelectMaster(e.dataRoot, members, c.Master, c.PreferPaths)
+	if err != nil {
+		return members[0]
+	}
+	return master
+}
+
+// reclaimable returns the space freed by linking others to master: the size
+// of each distinct inode among others, other than the master's. Links of an
+// inode are only counted once, and files that can't be read aren't counted.
+func (e *Engine) reclaimable(size int64, master string, others []string) int64 {
+	seen := make(map[inodeKey]bool)
+	if info, err := os.Stat(e.path(master)); err == nil {
+		dev, ino, _, _ := fsutil.Identity(info)
+		seen[inodeKey{dev, ino}] = true
+	}
+
+	var total int64
+	for _, other := range others {
+		info, err := os.Stat(e.path(other))
+		if err != nil {
+			continue
+		}
+		dev, ino, _, _ := fsutil.Identity(info)
+		if key := (inodeKey{dev, ino}); !seen[key] {
+			seen[key] = true
+			total += size
+		}
+	}
+	return total
+}
+
+// path returns the absolute path of a path relative to the data root
+func (e *Engine) path(rel string) string {
+	return filepath.Join(e.dataRoot, strings.TrimPrefix(rel, "/"))
+}
+
+// GetReport returns the stored report of a scan job, or nil if there is none
+func (e *Engine) GetReport(jobID string) (*Report, error) {
+	data, err := e.db.GetConversionReport(jobID)
+	if err != nil || data == "" {
+		return nil, err
+	}
+
+	var report Report
+	if err := json.Unmarshal([]byte(data), &report); err != nil {
+		return nil, fmt.Errorf("failed to decode report: %w", err)
+	}
+	return &report, nil
+}
+
+// OnScanComplete evaluates the rules when a scan completes, converting the
+// matches of the auto-apply rules
+func (e *Engine) OnScanComplete(jobID string) {
+	rules, err := e.ListRules()
+	if err != nil {
+		log.Printf("DUPRULES job=%s: failed to list rules: %v", jobID, err)
+		return
+	}
+	enabled := false
+	for _, rule := range rules {
+		enabled = enabled || rule.Enabled
+	}
+	if !enabled {
+		return
+	}
+
+	report, err := e.Evaluate(jobID, ApplyAuto)
+	if err != nil {
+		log.Printf("DUPRULES job=%s: %v", jobID, err)
+		return
+	}
+
+	log.Printf("DUPRULES job=%s matched=%d reclaimable=%d converted=%d bytes_saved=%d",
+		jobID, report.Groups, report.Reclaimable, report.Converted, report.BytesSaved)
+}
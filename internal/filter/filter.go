@@ -0,0 +1,184 @@
+package filter
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// regexPrefix marks an exclude pattern as a regular expression
+const regexPrefix = "re:"
+
+// Rules selects the files a walk should consider
+type Rules struct {
+	// Exclude skips matching files and directories. A pattern without "/"
+	// is a glob matched against each name (e.g. "#recycle", "*.nfo"), a
+	// pattern with "/" is a glob matched against the path relative to the
+	// walked root, the data root for scans (e.g. "/downloads/tmp"), and "re:"
+	// introduces a regular expression matched against that relative path.
+	Exclude []string `json:"exclude,omitempty"`
+	// Include keeps only files matching one of these patterns, with the
+	// syntax of Exclude. Directories are always walked.
+	Include []string `json:"include,omitempty"`
+	// MinSize skips smaller files, 0 disables the check
+	MinSize int64 `json:"min_size,omitempty"`
+	// MaxSize skips larger files, 0 disables the check
+	MaxSize int64 `json:"max_size,omitempty"`
+	// Extensions keeps only files with one of these extensions (case insensitive)
+	Extensions []string `json:"extensions,omitempty"`
+	// SkipHidden skips the files and directories whose name starts with "."
+	SkipHidden bool `json:"skip_hidden,omitempty"`
+}
+
+// patterns are compiled Exclude or Include patterns
+type patterns struct {
+	names   []string
+	paths   []string
+	regexps []*regexp.Regexp
+}
+
+// compile adds patterns of the given kind ("exclude" or "include")
+func (p *patterns) compile(kind string, list []string) error {
+	for _, pattern := range list {
+		pattern = strings.TrimSpace(pattern)
+		switch {
+		case pattern == "":
+			continue
+		case strings.HasPrefix(pattern, regexPrefix):
+			re, err := regexp.Compile(strings.TrimPrefix(pattern, regexPrefix))
+			if err != nil {
+				return fmt.Errorf("invalid %s regex %q: %w", kind, pattern, err)
+			}
+			p.regexps = append(p.regexps, re)
+		case strings.Contains(pattern, "/"):
+			pattern = "/" + strings.Trim(pattern, "/")
+			if _, err := path.Match(pattern, ""); err != nil {
+				return fmt.Errorf("invalid %s pattern %q: %w", kind, pattern, err)
+			}
+			p.paths = append(p.paths, pattern)
+		default:
+			if _, err := path.Match(pattern, ""); err != nil {
+				return fmt.Errorf("invalid %s pattern %q: %w", kind, pattern, err)
+			}
+			p.names = append(p.names, pattern)
+		}
+	}
+	return nil
+}
+
+// empty reports whether there is no pattern
+func (p *patterns) empty() bool {
+	return len(p.names) == 0 && len(p.paths) == 0 && len(p.regexps) == 0
+}
+
+// match reports whether rel matches a pattern
+func (p *patterns) match(rel string) bool {
+	name := path.Base(rel)
+	for _, pattern := range p.names {
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+	for _, pattern := range p.paths {
+		if ok, _ := path.Match(pattern, rel); ok {
+			return true
+		}
+	}
+	for _, re := range p.regexps {
+		if re.MatchString(rel) {
+			return true
+		}
+	}
+	return false
+}
+
+// Matcher applies compiled rules
+type Matcher struct {
+	exclude    patterns
+	include    patterns
+	minSize    int64
+	maxSize    int64
+	extensions map[string]bool
+	skipHidden bool
+}
+
+// Compile validates the rules and prepares them for matching
+func (r Rules) Compile() (*Matcher, error) {
+	if r.MinSize < 0 || r.MaxSize < 0 {
+		return nil, fmt.Errorf("sizes must not be negative")
+	}
+	if r.MaxSize > 0 && r.MinSize > r.MaxSize {
+		return nil, fmt.Errorf("min_size %d is greater than max_size %d", r.MinSize, r.MaxSize)
+	}
+
+	m := &Matcher{
+		minSize:    r.MinSize,
+		maxSize:    r.MaxSize,
+		skipHidden: r.SkipHidden,
+	}
+
+	if err := m.exclude.compile("exclude", r.Exclude); err != nil {
+		return nil, err
+	}
+	if err := m.include.compile("include", r.Include); err != nil {
+		return nil, err
+	}
+
+	for _, ext := range r.Extensions {
+		ext = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(ext), "."))
+		if ext == "" {
+			continue
+		}
+		if m.extensions == nil {
+			m.extensions = make(map[string]bool)
+		}
+		m.extensions[ext] = true
+	}
+
+	return m, nil
+}
+
+// Excluded reports whether a file or directory matches an exclude pattern,
+// or is hidden with SkipHidden. rel is the "/"-prefixed path relative to the
+// walked root.
+func (m *Matcher) Excluded(rel string) bool {
+	if m.skipHidden && strings.HasPrefix(path.Base(rel), ".") {
+		return true
+	}
+	return m.exclude.match(rel)
+}
+
+// MatchFile reports whether a regular file passes every rule
+func (m *Matcher) MatchFile(rel string, size int64) bool {
+	if m.minSize > 0 && size < m.minSize {
+		return false
+	}
+	if m.maxSize > 0 && size > m.maxSize {
+		return false
+	}
+	if m.extensions != nil {
+		ext := strings.ToLower(strings.TrimPrefix(path.Ext(rel), "."))
+		if !m.extensions[ext] {
+			return false
+		}
+	}
+	if !m.include.empty() && !m.include.match(rel) {
+		return false
+	}
+	return !m.Excluded(rel)
+}
+
+// MatchPath is MatchFile for a file that wasn't found by a walk: exclude
+// patterns are also applied to each of its parent directories
+func (m *Matcher) MatchPath(rel string, size int64) bool {
+	if !m.MatchFile(rel, size) {
+		return false
+	}
+	for dir := path.Dir(rel); dir != "/" && dir != "."; dir = path.Dir(dir) {
+		if m.Excluded(dir) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,114 @@
+package filter
+
+import (
+	"strings"
+	"testing"
+)
+
+// TestMatchFile verifies each rule on the files found by a walk
+func TestMatchFile(t *testing.T) {
+	tests := []struct {
+		name  string
+		rules Rules
+		rel   string
+		size  int64
+		want  bool
+	}{
+		{"no rules", Rules{}, "/film/film.mkv", 0, true},
+		{"name glob", Rules{Exclude: []string{"*.nfo"}}, "/film/film.nfo", 10, false},
+		{"name glob elsewhere", Rules{Exclude: []string{"*.nfo"}}, "/film/film.mkv", 10, true},
+		{"path glob", Rules{Exclude: []string{"/film/*.mkv"}}, "/film/film.mkv", 10, false},
+		{"path glob is anchored", Rules{Exclude: []string{"/film/*.mkv"}}, "/other/film/film.mkv", 10, true},
+		{"path glob without leading slash", Rules{Exclude: []string{"film/*.mkv/"}}, "/film/film.mkv", 10, false},
+		{"regex", Rules{Exclude: []string{`re:(?i)sample`}}, "/film/Film-SAMPLE.mkv", 10, false},
+		{"regex matches the path", Rules{Exclude: []string{`re:^/tmp/`}}, "/tmp/film.mkv", 10, false},
+		{"blank pattern", Rules{Exclude: []string{" "}}, "/film/film.mkv", 10, true},
+		{"hidden", Rules{SkipHidden: true}, "/film/.film.mkv", 10, false},
+		{"hidden kept", Rules{}, "/film/.film.mkv", 10, true},
+		{"below min size", Rules{MinSize: 50}, "/film/film.mkv", 49, false},
+		{"at min size", Rules{MinSize: 50}, "/film/film.mkv", 50, true},
+		{"above max size", Rules{MaxSize: 50}, "/film/film.mkv", 51, false},
+		{"at max size", Rules{MaxSize: 50}, "/film/film.mkv", 50, true},
+		{"extension", Rules{Extensions: []string{".MKV", "avi"}}, "/film/film.Mkv", 10, true},
+		{"other extension", Rules{Extensions: []string{"mkv"}}, "/film/film.nfo", 10, false},
+		{"no extension", Rules{Extensions: []string{"mkv"}}, "/film/README", 10, false},
+		{"blank extensions", Rules{Extensions: []string{" ", "."}}, "/film/film.nfo", 10, true},
+		{"include", Rules{Include: []string{"*.srt"}}, "/film/subs/film.srt", 10, true},
+		{"not included", Rules{Include: []string{"*.srt"}}, "/film/film.mkv", 10, false},
+		{"excluded include", Rules{Include: []string{"*.srt"}, Exclude: []string{"*.forced.srt"}}, "/film/film.forced.srt", 10, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, err := tt.rules.Compile()
+			if err != nil {
+				t.Fatalf("Failed to compile: %v", err)
+			}
+			if got := m.MatchFile(tt.rel, tt.size); got != tt.want {
+				t.Errorf("Expected MatchFile(%q, %d) to be %v", tt.rel, tt.size, tt.want)
+			}
+		})
+	}
+}
+
+// TestMatchPath verifies that the exclude patterns also apply to the parent
+// directories of a file that wasn't found by a walk
+func TestMatchPath(t *testing.T) {
+	m, err := Rules{Exclude: []string{"#recycle", "/downloads/tmp"}, SkipHidden: true}.Compile()
+	if err != nil {
+		t.Fatalf("Failed to compile: %v", err)
+	}
+
+	tests := []struct {
+		rel  string
+		want bool
+	}{
+		{"/downloads/film.mkv", true},
+		{"/downloads/tmp/film.mkv", false},
+		{"/downloads/tmp2/film.mkv", true},
+		{"/share/#recycle/old/film.mkv", false},
+		{"/.cache/film.mkv", false},
+		{"film.mkv", true},
+	}
+	for _, tt := range tests {
+		if got := m.MatchPath(tt.rel, 10); got != tt.want {
+			t.Errorf("Expected MatchPath(%q) to be %v", tt.rel, tt.want)
+		}
+	}
+
+	// A walk skips the excluded directories itself, MatchFile only looks at
+	// the file
+	if !m.MatchFile("/downloads/tmp/film.mkv", 10) {
+		t.Error("Expected MatchFile to ignore the parent directories")
+	}
+}
+
+// TestCompileErrors verifies that invalid rules are rejected
+func TestCompileErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		rules Rules
+		want  string
+	}{
+		{"negative size", Rules{MinSize: -1}, "negative"},
+		{"min above max", Rules{MinSize: 10, MaxSize: 5}, "greater than max_size"},
+		{"exclude regex", Rules{Exclude: []string{"re:("}}, "invalid exclude regex"},
+		{"include regex", Rules{Include: []string{"re:["}}, "invalid include regex"},
+		{"name glob", Rules{Exclude: []string{"[a-"}}, "invalid exclude pattern"},
+		{"path glob", Rules{Include: []string{"/film/[a-"}}, "invalid include pattern"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := tt.rules.Compile()
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Expected an error containing %q, got %v", tt.want, err)
+			}
+		})
+	}
+
+	// Without max_size, any min_size is allowed
+	if _, err := (Rules{MinSize: 10}).Compile(); err != nil {
+		t.Errorf("Expected min_size alone to be valid, got %v", err)
+	}
+}
@@ -0,0 +1,13 @@
+//go:build !linux
+
+package fsutil
+
+import (
+	"errors"
+	"os"
+)
+
+// cloneFile is not supported on this platform: CopyFile falls back to a copy
+func cloneFile(dst, src *os.File) error {
+	return errors.New("reflinks are not supported on this platform")
+}
@@ -0,0 +1,44 @@
+package fsutil
+
+import (
+	"fmt"
+	"io"
+	"os"
+)
+
+// CopyFile copies src to a new file dst, which must not exist. The copy
+// shares the data blocks of src (reflink) when the filesystem supports it,
+// e.g. Btrfs or XFS, and is a regular copy otherwise. It reports whether the
+// data was cloned.
+func CopyFile(src, dst string, perm os.FileMode) (bool, error) {
+	in, err := os.Open(src)
+	if err != nil {
+		return false, err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, perm)
+	if err != nil {
+		return false, err
+	}
+
+	cloned := cloneFile(out, in) == nil
+	if !cloned {
+		if _, err := io.Copy(out, in); err != nil {
+			out.Close()
+			os.Remove(dst)
+			return false, fmt.Errorf("failed to copy data: %w", err)
+		}
+	}
+
+	if err := out.Sync(); err != nil {
+		out.Close()
+		os.Remove(dst)
+		return false, fmt.Errorf("failed to sync copy: %w", err)
+	}
+	if err := out.Close(); err != nil {
+		os.Remove(dst)
+		return false, err
+	}
+	return cloned, nil
+}
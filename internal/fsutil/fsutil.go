@@ -0,0 +1,138 @@
+package fsutil
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"syscall"
+)
+
+// ErrCrossDevice is returned when a hardlink would span two filesystems
+var ErrCrossDevice = errors.New("cross-device link: source and destination are on different filesystems")
+
+// DefaultTrashDir is the default name of the trash directory at the top of
+// each device
+const DefaultTrashDir = ".hardlink-ui-trash"
+
+// trashDir is the name of the trash directory, set by SetTrashDir
+var trashDir = DefaultTrashDir
+
+// SetTrashDir sets the name of the trash directory. It is called once at
+// startup, before any walk.
+func SetTrashDir(name string) {
+	trashDir = name
+}
+
+// TrashDir returns the name of the trash directory at the top of each device
+func TrashDir() string {
+	return trashDir
+}
+
+// SkipDir reports whether a directory is never listed, indexed or scanned:
+// Synology's @eaDir and the trash
+func SkipDir(name string) bool {
+	return name == "@eaDir" || name == trashDir
+}
+
+// Identity returns the device, inode and link count of a file.
+// Inode numbers are only unique per device, so both are needed to identify a file.
+func Identity(info os.FileInfo) (dev, ino, nlink uint64, ok bool) {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return 0, 0, 0, false
+	}
+	return uint64(stat.Dev), stat.Ino, uint64(stat.Nlink), true
+}
+
+// Owner returns the user and group IDs owning a file
+func Owner(info os.FileInfo) (uid, gid uint32, ok bool) {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return 0, 0, false
+	}
+	return stat.Uid, stat.Gid, true
+}
+
+// ChangeTime returns the inode change time (ctime) of a file in nanoseconds.
+// Unlike mtime it can't be set from userspace, so it catches rewrites that
+// preserve the modification time.
+func ChangeTime(info os.FileInfo) int64 {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return 0
+	}
+	return stat.Ctim.Nano()
+}
+
+// Device returns the device of path, or of its nearest existing parent
+// when path doesn't exist yet (e.g. a hardlink destination)
+func Device(path string) (uint64, error) {
+	path = filepath.Clean(path)
+	for {
+		info, err := os.Stat(path)
+		if err == nil {
+			dev, _, _, ok := Identity(info)
+			if !ok {
+				return 0, errors.New("failed to get file stats")
+			}
+			return dev, nil
+		}
+		if !os.IsNotExist(err) {
+			return 0, err
+		}
+
+		parent := filepath.Dir(path)
+		if parent == path {
+			return 0, err
+		}
+		path = parent
+	}
+}
+
+// wOK is the write permission mode of access(2)
+const wOK = 2
+
+// Writable returns an error when the process can't create or remove entries
+// in dir, or in its nearest existing parent when dir doesn't exist yet
+func Writable(dir string) error {
+	dir = filepath.Clean(dir)
+	for {
+		err := syscall.Access(dir, wOK)
+		if err == nil {
+			return nil
+		}
+		if !errors.Is(err, syscall.ENOENT) {
+			return fmt.Errorf("%s is not writable: %w", dir, err)
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return err
+		}
+		dir = parent
+	}
+}
+
+// SameDevice returns ErrCrossDevice when src and dest live on different
+// filesystems. dest doesn't need to exist yet.
+func SameDevice(src, dest string) error {
+	srcDev, err := Device(src)
+	if err != nil {
+		return err
+	}
+	destDev, err := Device(dest)
+	if err != nil {
+		return err
+	}
+	if srcDev != destDev {
+		return ErrCrossDevice
+	}
+	return nil
+}
+
+// IsCrossDevice reports whether err is a cross-device error, either detected
+// beforehand or returned by the kernel (EXDEV)
+func IsCrossDevice(err error) bool {
+	return errors.Is(err, ErrCrossDevice) || errors.Is(err, syscall.EXDEV)
+}
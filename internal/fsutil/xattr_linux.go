@@ -0,0 +1,66 @@
+//go:build linux
+
+package fsutil
+
+import (
+	"errors"
+	"strings"
+	"syscall"
+)
+
+// Xattrs returns the extended attributes of a file, POSIX ACLs included
+// (system.posix_acl_access). Filesystems without xattr support have none.
+func Xattrs(path string) (map[string][]byte, error) {
+	size, err := syscall.Listxattr(path, nil)
+	if err != nil {
+		if errors.Is(err, syscall.ENOTSUP) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	if size == 0 {
+		return nil, nil
+	}
+
+	buf := make([]byte, size)
+	size, err = syscall.Listxattr(path, buf)
+	if err != nil {
+		return nil, err
+	}
+
+	attrs := make(map[string][]byte)
+	for _, name := range strings.Split(string(buf[:size]), "\x00") {
+		if name == "" {
+			continue
+		}
+		value, err := getXattr(path, name)
+		if err != nil {
+			// Removed since it was listed
+			if errors.Is(err, syscall.ENODATA) {
+				continue
+			}
+			return nil, err
+		}
+		attrs[name] = value
+	}
+	return attrs, nil
+}
+
+// getXattr reads the value of one extended attribute
+func getXattr(path, name string) ([]byte, error) {
+	size, err := syscall.Getxattr(path, name, nil)
+	if err != nil {
+		return nil, err
+	}
+	value := make([]byte, size)
+	size, err = syscall.Getxattr(path, name, value)
+	if err != nil {
+		return nil, err
+	}
+	return value[:size], nil
+}
+
+// SetXattr sets an extended attribute of a file
+func SetXattr(path, name string, value []byte) error {
+	return syscall.Setxattr(path, name, value, 0)
+}
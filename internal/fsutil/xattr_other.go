@@ -0,0 +1,15 @@
+//go:build !linux
+
+package fsutil
+
+import "errors"
+
+// Xattrs is not supported on this platform: files have no extended attributes
+func Xattrs(path string) (map[string][]byte, error) {
+	return nil, nil
+}
+
+// SetXattr is not supported on this platform
+func SetXattr(path, name string, value []byte) error {
+	return errors.New("extended attributes are not supported on this platform")
+}
@@ -0,0 +1,179 @@
+package indexer
+
+import (
+	"context"
+	"io/fs"
+	"log"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gosiva/hardlink-ui/internal/fsutil"
+	"github.com/gosiva/hardlink-ui/internal/storage"
+)
+
+// batchSize is the number of entries written per transaction
+const batchSize = 1000
+
+// Indexer keeps the inode_index table in sync with the data root by walking
+// it in the background, so hardlink lookups don't need a full tree walk
+type Indexer struct {
+	db       *storage.DB
+	dataRoot string
+	interval time.Duration
+
+	mu      sync.Mutex
+	running bool
+	ready   bool
+}
+
+// NewIndexer creates a new indexer instance.
+// interval is the delay between two full passes.
+func NewIndexer(db *storage.DB, dataRoot string, interval time.Duration) *Indexer {
+	ix := &Indexer{
+		db:       db,
+		dataRoot: dataRoot,
+		interval: interval,
+	}
+
+	// An index completed by a previous run is usable right away
+	if value, err := db.GetState(storage.IndexCompletedStateKey); err == nil && value != "" {
+		ix.ready = true
+	}
+
+	return ix
+}
+
+// Run performs a full pass immediately, then one every interval until ctx is done
+func (ix *Indexer) Run(ctx context.Context) {
+	if err := ix.Rebuild(ctx); err != nil {
+		log.Printf("Indexer: pass failed: %v", err)
+	}
+
+	if ix.interval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(ix.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if err := ix.Rebuild(ctx); err != nil {
+				log.Printf("Indexer: pass failed: %v", err)
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// Ready reports whether a full pass has completed, now or in a previous run
+func (ix *Indexer) Ready() bool {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	return ix.ready
+}
+
+// Rebuild walks the whole data root, refreshes every inode-path mapping and
+// prunes the mappings that were not seen during the walk
+func (ix *Indexer) Rebuild(ctx context.Context) error {
+	ix.mu.Lock()
+	if ix.running {
+		ix.mu.Unlock()
+		return nil
+	}
+	ix.running = true
+	ix.mu.Unlock()
+
+	defer func() {
+		ix.mu.Lock()
+		ix.running = false
+		ix.mu.Unlock()
+	}()
+
+	start := time.Now()
+	log.Printf("Indexer: starting pass on %s", ix.dataRoot)
+
+	batch := make([]storage.InodeEntry, 0, batchSize)
+	indexed := 0
+
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		if err := ix.db.BatchAddInodePaths(batch); err != nil {
+			return err
+		}
+		indexed += len(batch)
+		batch = batch[:0]
+		return nil
+	}
+
+	err := filepath.WalkDir(ix.dataRoot, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil // skip errors
+		}
+
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
+
+		// Skip @eaDir directories (Synology) and the trash
+		if d.IsDir() && fsutil.SkipDir(d.Name()) {
+			return fs.SkipDir
+		}
+
+		if !d.Type().IsRegular() {
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+
+		dev, ino, nlink, ok := fsutil.Identity(info)
+		if !ok {
+			return nil
+		}
+
+		batch = append(batch, storage.InodeEntry{
+			Dev:   dev,
+			Inode: ino,
+			Path:  path,
+			Size:  info.Size(),
+			Mtime: info.ModTime().Unix(),
+			Nlink: nlink,
+		})
+
+		if len(batch) >= batchSize {
+			return flush()
+		}
+		return nil
+	})
+	if err == nil {
+		err = flush()
+	}
+	if err != nil {
+		return err
+	}
+
+	// Everything still present was refreshed during the walk
+	if err := ix.db.PruneInodeEntries(start.Unix()); err != nil {
+		return err
+	}
+
+	if err := ix.db.SetState(storage.IndexCompletedStateKey, strconv.FormatInt(time.Now().Unix(), 10)); err != nil {
+		return err
+	}
+
+	ix.mu.Lock()
+	ix.ready = true
+	ix.mu.Unlock()
+
+	log.Printf("Indexer: pass completed, %d paths indexed in %v", indexed, time.Since(start).Round(time.Second))
+	return nil
+}
@@ -0,0 +1,46 @@
+package indexer
+
+import (
+	"os"
+
+	"github.com/gosiva/hardlink-ui/internal/fsutil"
+	"github.com/gosiva/hardlink-ui/internal/storage"
+)
+
+// AddPath indexes a new link of an inode between two passes, and refreshes
+// the attributes of its other paths, its link count included
+func AddPath(db *storage.DB, dev, ino uint64, path string) error {
+	if err := db.AddInodePath(dev, ino, path); err != nil {
+		return err
+	}
+	return refresh(db, dev, ino)
+}
+
+// RemovePath drops a removed link of an inode between two passes, and
+// refreshes the attributes of its other paths
+func RemovePath(db *storage.DB, dev, ino uint64, path string) error {
+	if err := db.RemoveInodePath(dev, ino, path); err != nil {
+		return err
+	}
+	return refresh(db, dev, ino)
+}
+
+// refresh updates the attributes indexed for the paths of an inode from the
+// first one still naming it
+func refresh(db *storage.DB, dev, ino uint64) error {
+	paths, err := db.GetInodePaths(dev, ino)
+	if err != nil {
+		return err
+	}
+
+	for _, path := range paths {
+		info, err := os.Lstat(path)
+		if err != nil {
+			continue
+		}
+		if d, i, nlink, ok := fsutil.Identity(info); ok && d == dev && i == ino {
+			return db.UpdateInodeAttrs(dev, ino, info.Size(), info.ModTime().Unix(), nlink)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,295 @@
+package jobs
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+
+	"github.com/gosiva/hardlink-ui/internal/storage"
+)
+
+// Job statuses
+const (
+	StatusRunning   = "running"
+	StatusCompleted = "completed"
+	StatusFailed    = "failed"
+	StatusCancelled = "cancelled"
+)
+
+var (
+	// ErrJobNotFound is returned when no job has the given ID
+	ErrJobNotFound = errors.New("job not found")
+	// ErrJobNotRunning is returned when cancelling a finished job
+	ErrJobNotRunning = errors.New("job is not running")
+)
+
+// progressInterval is the number of items between two progress writes to
+// the database
+const progressInterval = 100
+
+// Func runs the items of a job, reporting each one to the tracker. It stops
+// when ctx is cancelled and returns the report of the items done so far.
+type Func func(ctx context.Context, t *Tracker) (report interface{}, err error)
+
+// Progress tracks the progress of a job
+type Progress struct {
+	JobID     string          `json:"job_id"`
+	Kind      string          `json:"kind"`
+	Status    string          `json:"status"`
+	Total     int             `json:"total"`
+	Processed int             `json:"processed"`
+	Failed    int             `json:"failed"`
+	Current   string          `json:"current,omitempty"` // last item processed
+	Error     string          `json:"error,omitempty"`
+	Report    json.RawMessage `json:"report,omitempty"` // once finished
+	mu        sync.Mutex
+}
+
+// Tracker reports the progress of a running job
+type Tracker struct {
+	runner   *Runner
+	progress *Progress
+}
+
+// SetTotal sets the number of items of the job
+func (t *Tracker) SetTotal(total int) {
+	t.progress.mu.Lock()
+	t.progress.Total = total
+	t.progress.mu.Unlock()
+}
+
+// Step records a processed item, failed when err is not nil
+func (t *Tracker) Step(item string, err error) {
+	p := t.progress
+	p.mu.Lock()
+	p.Processed++
+	if err != nil {
+		p.Failed++
+	}
+	p.Current = item
+	total, processed, failed := p.Total, p.Processed, p.Failed
+	p.mu.Unlock()
+
+	if processed%progressInterval == 0 {
+		t.runner.db.UpdateJobProgress(p.JobID, total, processed, failed)
+	}
+}
+
+// Runner runs bulk file operations as background jobs
+type Runner struct {
+	db       *storage.DB
+	mu       sync.Mutex
+	jobs     map[string]*Progress          // running jobs
+	controls map[string]context.CancelFunc // running jobs
+	running  sync.WaitGroup
+}
+
+// NewRunner creates a new job runner
+func NewRunner(db *storage.DB) *Runner {
+	return &Runner{
+		db:       db,
+		jobs:     make(map[string]*Progress),
+		controls: make(map[string]context.CancelFunc),
+	}
+}
+
+// newJobID generates a random job ID
+func newJobID() (string, error) {
+	bytes := make([]byte, 16)
+	if _, err := rand.Read(bytes); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(bytes), nil
+}
+
+// Start runs fn in a background job and returns its ID. params is the JSON
+// encodable request the job was started with.
+func (r *Runner) Start(kind, username string, params interface{}, fn Func) (string, error) {
+	jobID, err := newJobID()
+	if err != nil {
+		return "", fmt.Errorf("failed to generate job ID: %w", err)
+	}
+
+	paramsJSON, err := json.Marshal(params)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode job params: %w", err)
+	}
+
+	if err := r.db.CreateJob(&storage.Job{
+		JobID:    jobID,
+		Kind:     kind,
+		Params:   string(paramsJSON),
+		Username: username,
+	}); err != nil {
+		return "", fmt.Errorf("failed to create job: %w", err)
+	}
+
+	progress := &Progress{
+		JobID:  jobID,
+		Kind:   kind,
+		Status: StatusRunning,
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+
+	r.mu.Lock()
+	r.jobs[jobID] = progress
+	r.controls[jobID] = cancel
+	r.running.Add(1)
+	r.mu.Unlock()
+
+	log.Printf("JOB START job=%s kind=%s by %s", jobID, kind, username)
+
+	go func() {
+		defer r.running.Done()
+		defer cancel()
+
+		report, err := fn(ctx, &Tracker{runner: r, progress: progress})
+		r.finish(ctx, progress, report, err)
+	}()
+
+	return jobID, nil
+}
+
+// finish records the outcome of a job. Finished jobs are read back from the
+// database.
+func (r *Runner) finish(ctx context.Context, progress *Progress, report interface{}, err error) {
+	progress.mu.Lock()
+	job := &storage.Job{
+		JobID:     progress.JobID,
+		Status:    StatusCompleted,
+		Total:     progress.Total,
+		Processed: progress.Processed,
+		Failed:    progress.Failed,
+	}
+	progress.mu.Unlock()
+
+	switch {
+	case ctx.Err() != nil:
+		job.Status = StatusCancelled
+	case err != nil:
+		job.Status = StatusFailed
+		job.Error = sql.NullString{String: err.Error(), Valid: true}
+	}
+
+	if report != nil {
+		data, encodeErr := json.Marshal(report)
+		if encodeErr != nil {
+			job.Status = StatusFailed
+			job.Error = sql.NullString{String: fmt.Sprintf("failed to encode report: %v", encodeErr), Valid: true}
+		} else {
+			job.Report = string(data)
+		}
+	}
+
+	if err := r.db.FinishJob(job); err != nil {
+		log.Printf("Job %s: Failed to save outcome: %v", job.JobID, err)
+	}
+
+	progress.mu.Lock()
+	progress.Status = job.Status
+	progress.Error = job.Error.String
+	progress.Report = json.RawMessage(job.Report)
+	progress.mu.Unlock()
+
+	r.mu.Lock()
+	delete(r.jobs, job.JobID)
+	delete(r.controls, job.JobID)
+	r.mu.Unlock()
+
+	log.Printf("JOB END job=%s status=%s processed=%d failed=%d", job.JobID, job.Status, job.Processed, job.Failed)
+}
+
+// Cancel stops a running job, which keeps the report of the items done
+func (r *Runner) Cancel(jobID string) error {
+	r.mu.Lock()
+	cancel, running := r.controls[jobID]
+	r.mu.Unlock()
+
+	if running {
+		cancel()
+		return nil
+	}
+
+	job, err := r.db.GetJob(jobID)
+	if err != nil {
+		return err
+	}
+	if job == nil {
+		return ErrJobNotFound
+	}
+	return ErrJobNotRunning
+}
+
+// Get returns the progress of a job, or nil if there is none
+func (r *Runner) Get(jobID string) (*Progress, error) {
+	r.mu.Lock()
+	progress, running := r.jobs[jobID]
+	r.mu.Unlock()
+
+	if running {
+		progress.mu.Lock()
+		defer progress.mu.Unlock()
+
+		// Return a copy
+		return &Progress{
+			JobID:     progress.JobID,
+			Kind:      progress.Kind,
+			Status:    progress.Status,
+			Total:     progress.Total,
+			Processed: progress.Processed,
+			Failed:    progress.Failed,
+			Current:   progress.Current,
+			Error:     progress.Error,
+			Report:    progress.Report,
+		}, nil
+	}
+
+	job, err := r.db.GetJob(jobID)
+	if err != nil || job == nil {
+		return nil, err
+	}
+
+	progress = &Progress{
+		JobID:     job.JobID,
+		Kind:      job.Kind,
+		Status:    job.Status,
+		Total:     job.Total,
+		Processed: job.Processed,
+		Failed:    job.Failed,
+		Error:     job.Error.String,
+	}
+	if job.Report != "" {
+		progress.Report = json.RawMessage(job.Report)
+	}
+	return progress, nil
+}
+
+// Shutdown cancels every running job and waits for their reports to be saved
+func (r *Runner) Shutdown() {
+	r.mu.Lock()
+	for _, cancel := range r.controls {
+		cancel()
+	}
+	r.mu.Unlock()
+
+	r.running.Wait()
+}
+
+// RecoverInterruptedJobs marks the jobs left running by a previous process
+// as failed
+func (r *Runner) RecoverInterruptedJobs() error {
+	count, err := r.db.FailInterruptedJobs()
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		log.Printf("Marked %d interrupted job(s) as failed", count)
+	}
+	return nil
+}
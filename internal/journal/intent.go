@@ -0,0 +1,300 @@
+package journal
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gosiva/hardlink-ui/internal/fsutil"
+	"github.com/gosiva/hardlink-ui/internal/indexer"
+	"github.com/gosiva/hardlink-ui/internal/storage"
+)
+
+// Steps of a conversion, recorded in its intent before they are done
+const (
+	// StepLink creates a temporary hardlink to the master
+	StepLink = "link"
+	// StepRename renames the temporary hardlink over the duplicate
+	StepRename = "rename"
+)
+
+const (
+	// tmpLinkSuffix marks the temporary hardlinks of conversions, named
+	// .<file>.tmp-hardlink-<random>
+	tmpLinkSuffix = ".tmp-hardlink-"
+	// tmpLinkRandom is the number of random bytes of a temporary hardlink
+	tmpLinkRandom = 6
+	// legacyTmpLinkSuffix marks the temporary hardlinks of older versions,
+	// named <file>.tmp-hardlink
+	legacyTmpLinkSuffix = ".tmp-hardlink"
+	// sweptStateKey records when SweepTempLinks last completed
+	sweptStateKey = "temp_links_swept_at"
+)
+
+// TempLinkPath returns a unique temporary path for a hardlink replacing path
+func TempLinkPath(path string) (string, error) {
+	random := make([]byte, tmpLinkRandom)
+	if _, err := rand.Read(random); err != nil {
+		return "", fmt.Errorf("failed to generate temporary name: %w", err)
+	}
+	name := fmt.Sprintf(".%s%s%s", filepath.Base(path), tmpLinkSuffix, hex.EncodeToString(random))
+	return filepath.Join(filepath.Dir(path), name), nil
+}
+
+// tempLinkTarget returns the name of the file a temporary hardlink was to
+// replace, and false if name isn't exactly a name of TempLinkPath or of an
+// older version
+func tempLinkTarget(name string) (string, bool) {
+	if target := strings.TrimSuffix(name, legacyTmpLinkSuffix); target != name {
+		return target, target != ""
+	}
+
+	i := strings.LastIndex(name, tmpLinkSuffix)
+	if i < 2 || !strings.HasPrefix(name, ".") {
+		return "", false
+	}
+	random := name[i+len(tmpLinkSuffix):]
+	if len(random) != 2*tmpLinkRandom || strings.ToLower(random) != random {
+		return "", false
+	}
+	if _, err := hex.DecodeString(random); err != nil {
+		return "", false
+	}
+	return name[1:i], true
+}
+
+// Intent is a conversion in progress. Until it is committed or aborted, a
+// crash leaves it in the database for RecoverReplaces.
+type Intent struct {
+	journal *Journal
+	record  *storage.ReplaceIntent
+}
+
+// BeginReplace records the intent to replace the duplicate at path with
+// tmpPath, a temporary hardlink to master. info and meta describe the
+// duplicate.
+func (j *Journal) BeginReplace(path, tmpPath, master string, masterInfo, info os.FileInfo, meta *Metadata, username string) (*Intent, error) {
+	data, err := meta.encode()
+	if err != nil {
+		return nil, err
+	}
+
+	masterDev, masterIno, _, _ := fsutil.Identity(masterInfo)
+	dev, ino, _, _ := fsutil.Identity(info)
+	record := &storage.ReplaceIntent{
+		Path:        j.rel(path),
+		TmpPath:     j.rel(tmpPath),
+		Master:      j.rel(master),
+		MasterDev:   masterDev,
+		MasterInode: masterIno,
+		Dev:         dev,
+		Inode:       ino,
+		Size:        info.Size(),
+		Metadata:    data,
+		Username:    username,
+		Step:        StepLink,
+	}
+
+	record.ID, err = j.db.CreateReplaceIntent(record)
+	if err != nil {
+		return nil, fmt.Errorf("failed to record intent: %w", err)
+	}
+	return &Intent{journal: j, record: record}, nil
+}
+
+// Step records the next step of the conversion
+func (in *Intent) Step(step string) error {
+	if err := in.journal.db.SetReplaceIntentStep(in.record.ID, step); err != nil {
+		return fmt.Errorf("failed to record intent: %w", err)
+	}
+	in.record.Step = step
+	return nil
+}
+
+// Abort forgets a conversion that failed, once its temporary hardlink is
+// removed
+func (in *Intent) Abort() {
+	if err := in.journal.db.DeleteReplaceIntent(in.record.ID); err != nil {
+		log.Printf("Failed to remove intent of %s: %v", in.record.Path, err)
+	}
+}
+
+// Commit journals the completed conversion and returns the ID of its
+// operation. On failure, the intent is left for RecoverReplaces.
+func (in *Intent) Commit() int64 {
+	id, err := in.journal.db.CommitReplaceIntent(in.record.ID, replaceOperation(in.record))
+	if err != nil {
+		log.Printf("Failed to journal replace %s: %v", in.record.Path, err)
+		return 0
+	}
+	return id
+}
+
+// RecoverReplaces repairs the conversions interrupted by a crash: those whose
+// hardlink took the place of the duplicate are completed and journaled, the
+// others are rolled back. It returns the number of interrupted conversions.
+func (j *Journal) RecoverReplaces() (int, error) {
+	intents, err := j.db.ListReplaceIntents()
+	if err != nil {
+		return 0, fmt.Errorf("failed to list intents: %w", err)
+	}
+
+	for i := range intents {
+		if err := j.recoverReplace(&intents[i]); err != nil {
+			log.Printf("Failed to recover conversion of %s: %v", intents[i].Path, err)
+		}
+	}
+	return len(intents), nil
+}
+
+// recoverReplace completes or rolls back one interrupted conversion
+func (j *Journal) recoverReplace(in *storage.ReplaceIntent) error {
+	path, err := j.abs(in.Path)
+	if err != nil {
+		return err
+	}
+	tmpPath, err := j.abs(in.TmpPath)
+	if err != nil {
+		return err
+	}
+
+	isMaster := func(path string) bool {
+		info, err := os.Lstat(path)
+		if err != nil {
+			return false
+		}
+		dev, ino, _, ok := fsutil.Identity(info)
+		return ok && dev == in.MasterDev && ino == in.MasterInode
+	}
+
+	switch {
+	case isMaster(path):
+		// The hardlink was renamed over the duplicate
+	case isMaster(tmpPath) && !exists(path):
+		// The duplicate is gone: the hardlink takes its place
+		if err := os.Rename(tmpPath, path); err != nil {
+			return fmt.Errorf("failed to rename temporary hardlink: %w", err)
+		}
+	default:
+		// The duplicate is untouched
+		if isMaster(tmpPath) {
+			if err := os.Remove(tmpPath); err != nil {
+				return fmt.Errorf("failed to remove temporary hardlink: %w", err)
+			}
+		}
+		log.Printf("CONVERSION ROLLBACK path=%s step=%s", in.Path, in.Step)
+		return j.db.DeleteReplaceIntent(in.ID)
+	}
+
+	indexer.RemovePath(j.db, in.Dev, in.Inode, path)
+	indexer.AddPath(j.db, in.MasterDev, in.MasterInode, path)
+
+	id, err := j.db.CommitReplaceIntent(in.ID, replaceOperation(in))
+	if err != nil {
+		return fmt.Errorf("failed to journal replace: %w", err)
+	}
+	log.Printf("CONVERSION COMPLETE path=%s step=%s operation=%d", in.Path, in.Step, id)
+	return nil
+}
+
+// SweepTempLinks repairs the temporary hardlinks left in the data root
+// without intent, after the loss of the database: the intents of the current
+// one are repaired by RecoverReplaces. The <file>.tmp-hardlink links of
+// older versions are repaired too. A temporary hardlink takes the place
+// of its missing duplicate, and is removed otherwise when it isn't the last
+// link to its file. The data root is only walked once per database. It
+// returns the number of files repaired.
+func (j *Journal) SweepTempLinks(ctx context.Context) int {
+	if swept, err := j.db.GetState(sweptStateKey); err != nil || swept != "" {
+		return 0
+	}
+
+	repaired := 0
+	err := filepath.WalkDir(j.dataRoot, func(path string, d fs.DirEntry, err error) error {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if err != nil {
+			return nil
+		}
+		if d.IsDir() && fsutil.SkipDir(d.Name()) {
+			return fs.SkipDir
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		target, ok := tempLinkTarget(d.Name())
+		if !ok {
+			return nil
+		}
+		// Conversions in progress own their temporary hardlink
+		if pending, err := j.db.HasReplaceIntent(j.rel(path)); err != nil || pending {
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		dev, ino, nlink, _ := fsutil.Identity(info)
+		targetPath := filepath.Join(filepath.Dir(path), target)
+
+		switch {
+		case !exists(targetPath):
+			if err := os.Rename(path, targetPath); err != nil {
+				log.Printf("Failed to restore %s: %v", j.rel(targetPath), err)
+				return nil
+			}
+			indexer.RemovePath(j.db, dev, ino, path)
+			indexer.AddPath(j.db, dev, ino, targetPath)
+			log.Printf("TEMP LINK RESTORED path=%s", j.rel(targetPath))
+		case nlink > 1:
+			if err := os.Remove(path); err != nil {
+				log.Printf("Failed to remove %s: %v", j.rel(path), err)
+				return nil
+			}
+			indexer.RemovePath(j.db, dev, ino, path)
+			log.Printf("TEMP LINK REMOVED path=%s", j.rel(path))
+		default:
+			log.Printf("TEMP LINK KEPT path=%s: last link to its file", j.rel(path))
+			return nil
+		}
+		repaired++
+		return nil
+	})
+
+	if err == nil {
+		if err := j.db.SetState(sweptStateKey, strconv.FormatInt(time.Now().Unix(), 10)); err != nil {
+			log.Printf("Failed to record the temporary hardlinks sweep: %v", err)
+		}
+	}
+	return repaired
+}
+
+// replaceOperation returns the journal entry of a completed conversion
+func replaceOperation(in *storage.ReplaceIntent) *storage.Operation {
+	return &storage.Operation{
+		Kind:     KindReplace,
+		Path:     in.Path,
+		Target:   in.Master,
+		Dev:      in.Dev,
+		Inode:    in.Inode,
+		Size:     in.Size,
+		Metadata: in.Metadata,
+		Username: in.Username,
+	}
+}
+
+// exists reports whether a file exists at path
+func exists(path string) bool {
+	_, err := os.Lstat(path)
+	return err == nil
+}
@@ -0,0 +1,357 @@
+package journal
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/gosiva/hardlink-ui/internal/fsutil"
+	"github.com/gosiva/hardlink-ui/internal/indexer"
+	"github.com/gosiva/hardlink-ui/internal/storage"
+)
+
+// Operation kinds
+const (
+	// KindLink is a hardlink created at Path to the inode of Target
+	KindLink = "link"
+	// KindReplace is a duplicate at Path replaced by a hardlink to Target
+	KindReplace = "replace"
+	// KindUnlink is a hardlink removed at Path, the inode keeping other links
+	KindUnlink = "unlink"
+	// KindRename is a file or directory moved from Path to Target
+	KindRename = "rename"
+)
+
+var (
+	// ErrNotFound is returned when undoing an unknown operation
+	ErrNotFound = errors.New("operation not found")
+	// ErrUndone is returned when undoing an operation twice
+	ErrUndone = errors.New("operation already undone")
+	// ErrNotUndoable is returned when the files changed since the operation
+	ErrNotUndoable = errors.New("operation can't be undone")
+)
+
+// undoMu serializes undos, so an operation can't be undone twice concurrently
+var undoMu sync.Mutex
+
+// Journal records file operations so they can be undone
+type Journal struct {
+	db       *storage.DB
+	dataRoot string
+}
+
+// New creates a new journal
+func New(db *storage.DB, dataRoot string) *Journal {
+	return &Journal{
+		db:       db,
+		dataRoot: dataRoot,
+	}
+}
+
+// record stores an operation and returns its ID. A journal failure doesn't
+// fail the operation, which is already done: it is only logged.
+func (j *Journal) record(op *storage.Operation) int64 {
+	id, err := j.db.RecordOperation(op)
+	if err != nil {
+		log.Printf("Failed to journal %s %s: %v", op.Kind, op.Path, err)
+		return 0
+	}
+	return id
+}
+
+// RecordLink journals a hardlink created at path to source. info is the
+// source's file info.
+func (j *Journal) RecordLink(path, source string, info os.FileInfo, username string) int64 {
+	dev, ino, _, _ := fsutil.Identity(info)
+	return j.record(&storage.Operation{
+		Kind:     KindLink,
+		Path:     j.rel(path),
+		Target:   j.rel(source),
+		Dev:      dev,
+		Inode:    ino,
+		Size:     info.Size(),
+		Username: username,
+	})
+}
+
+// RecordUnlink journals a hardlink removed at path. info is the removed
+// link's file info.
+func (j *Journal) RecordUnlink(path string, info os.FileInfo, username string) int64 {
+	dev, ino, _, _ := fsutil.Identity(info)
+	op := &storage.Operation{
+		Kind:     KindUnlink,
+		Path:     j.rel(path),
+		Dev:      dev,
+		Inode:    ino,
+		Size:     info.Size(),
+		Username: username,
+	}
+	// Remember a remaining link to restore this one from
+	if source := j.findLink(dev, ino); source != "" {
+		op.Target = j.rel(source)
+	}
+	return j.record(op)
+}
+
+// RecordRename journals a file or directory moved from path to dest. info is
+// the moved file's info.
+func (j *Journal) RecordRename(path, dest string, info os.FileInfo, username string) int64 {
+	dev, ino, _, _ := fsutil.Identity(info)
+	return j.record(&storage.Operation{
+		Kind:     KindRename,
+		Path:     j.rel(path),
+		Target:   j.rel(dest),
+		Dev:      dev,
+		Inode:    ino,
+		Size:     info.Size(),
+		Username: username,
+	})
+}
+
+// Undo reverses an operation and returns it
+func (j *Journal) Undo(id int64, username string) (*storage.Operation, error) {
+	undoMu.Lock()
+	defer undoMu.Unlock()
+
+	op, err := j.db.GetOperation(id)
+	if err != nil {
+		return nil, err
+	}
+	if op == nil {
+		return nil, ErrNotFound
+	}
+	if op.UndoneAt.Valid {
+		return nil, ErrUndone
+	}
+
+	path, err := j.abs(op.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	switch op.Kind {
+	case KindLink:
+		err = j.undoLink(op, path)
+	case KindReplace:
+		err = j.undoReplace(op, path)
+	case KindUnlink:
+		err = j.undoUnlink(op, path)
+	case KindRename:
+		err = j.undoRename(op, path)
+	default:
+		err = fmt.Errorf("%w: unknown kind %q", ErrNotUndoable, op.Kind)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := j.db.MarkOperationUndone(op.ID); err != nil {
+		return nil, fmt.Errorf("failed to mark operation undone: %w", err)
+	}
+
+	log.Printf("OPERATION UNDO id=%d kind=%s path=%s by %s", op.ID, op.Kind, op.Path, username)
+	return op, nil
+}
+
+// undoLink removes a created hardlink, as long as it still shares the inode
+// with another link
+func (j *Journal) undoLink(op *storage.Operation, path string) error {
+	info, err := j.statOperation(op, path)
+	if err != nil {
+		return err
+	}
+	if _, _, nlink, _ := fsutil.Identity(info); nlink <= 1 {
+		return fmt.Errorf("%w: %s is the last link to its file", ErrNotUndoable, op.Path)
+	}
+
+	if err := os.Remove(path); err != nil {
+		return fmt.Errorf("failed to remove %s: %w", op.Path, err)
+	}
+	indexer.RemovePath(j.db, op.Dev, op.Inode, path)
+	return nil
+}
+
+// undoReplace turns a converted duplicate back into a separate file: a copy
+// of the shared content (a reflink when possible) with its former metadata
+func (j *Journal) undoReplace(op *storage.Operation, path string) error {
+	info, err := os.Lstat(path)
+	if err != nil {
+		return j.statError(op, err)
+	}
+	dev, ino, nlink, _ := fsutil.Identity(info)
+	if !info.Mode().IsRegular() || dev != op.Dev {
+		return fmt.Errorf("%w: %s was replaced", ErrNotUndoable, op.Path)
+	}
+	if nlink <= 1 {
+		return fmt.Errorf("%w: %s is already a separate file", ErrNotUndoable, op.Path)
+	}
+	if info.Size() != op.Size {
+		return fmt.Errorf("%w: content of %s changed", ErrNotUndoable, op.Path)
+	}
+
+	meta, err := decodeMetadata(op.Metadata)
+	if err != nil {
+		return err
+	}
+
+	tmpPath := filepath.Join(filepath.Dir(path), fmt.Sprintf(".%s.undo-%d", filepath.Base(path), op.ID))
+	if _, err := fsutil.CopyFile(path, tmpPath, meta.Mode.Perm()); err != nil {
+		return fmt.Errorf("failed to copy %s: %w", op.Path, err)
+	}
+	if err := meta.Apply(tmpPath); err != nil {
+		_ = os.Remove(tmpPath)
+		return err
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		_ = os.Remove(tmpPath)
+		return fmt.Errorf("failed to replace %s: %w", op.Path, err)
+	}
+
+	indexer.RemovePath(j.db, dev, ino, path)
+	if copyInfo, err := os.Stat(path); err == nil {
+		if copyDev, copyIno, _, ok := fsutil.Identity(copyInfo); ok {
+			indexer.AddPath(j.db, copyDev, copyIno, path)
+		}
+	}
+	return nil
+}
+
+// undoUnlink recreates a removed hardlink from a remaining link of its inode
+func (j *Journal) undoUnlink(op *storage.Operation, path string) error {
+	if _, err := os.Lstat(path); err == nil {
+		return fmt.Errorf("%w: %s exists again", ErrNotUndoable, op.Path)
+	}
+
+	var candidates []string
+	if op.Target != "" {
+		if target, err := j.abs(op.Target); err == nil {
+			candidates = append(candidates, target)
+		}
+	}
+	source := j.findLink(op.Dev, op.Inode, candidates...)
+	if source == "" {
+		// The inode index may be stale or not built yet
+		source = j.walkLink(op.Dev, op.Inode, op.Size)
+	}
+	if source == "" {
+		return fmt.Errorf("%w: the file of %s no longer exists", ErrNotUndoable, op.Path)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create parent directory: %w", err)
+	}
+	if err := os.Link(source, path); err != nil {
+		return fmt.Errorf("failed to recreate %s: %w", op.Path, err)
+	}
+	indexer.AddPath(j.db, op.Dev, op.Inode, path)
+	return nil
+}
+
+// undoRename moves a renamed file or directory back
+func (j *Journal) undoRename(op *storage.Operation, path string) error {
+	target, err := j.abs(op.Target)
+	if err != nil {
+		return err
+	}
+	info, err := j.statOperation(op, target)
+	if err != nil {
+		return err
+	}
+	if _, err := os.Lstat(path); err == nil {
+		return fmt.Errorf("%w: %s exists again", ErrNotUndoable, op.Path)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create parent directory: %w", err)
+	}
+	if err := os.Rename(target, path); err != nil {
+		return fmt.Errorf("failed to move %s back: %w", op.Target, err)
+	}
+	j.db.MoveInodePaths(target, path)
+	if !info.IsDir() {
+		indexer.AddPath(j.db, op.Dev, op.Inode, path)
+	}
+	return nil
+}
+
+// statOperation checks that path is still the inode of the operation
+func (j *Journal) statOperation(op *storage.Operation, path string) (os.FileInfo, error) {
+	info, err := os.Lstat(path)
+	if err != nil {
+		return nil, j.statError(op, err)
+	}
+	if dev, ino, _, ok := fsutil.Identity(info); !ok || dev != op.Dev || ino != op.Inode {
+		return nil, fmt.Errorf("%w: %s was replaced", ErrNotUndoable, j.rel(path))
+	}
+	return info, nil
+}
+
+// statError describes a stat failure of an operation's file
+func (j *Journal) statError(op *storage.Operation, err error) error {
+	if os.IsNotExist(err) {
+		return fmt.Errorf("%w: %s no longer exists", ErrNotUndoable, op.Path)
+	}
+	return err
+}
+
+// findLink returns an existing link to an inode, trying candidates first and
+// then the paths of the inode index, or "" if none is left
+func (j *Journal) findLink(dev, ino uint64, candidates ...string) string {
+	if paths, err := j.db.GetInodePaths(dev, ino); err == nil {
+		candidates = append(candidates, paths...)
+	}
+	for _, candidate := range candidates {
+		info, err := os.Lstat(candidate)
+		if err != nil {
+			continue
+		}
+		if d, i, _, ok := fsutil.Identity(info); ok && d == dev && i == ino {
+			return candidate
+		}
+	}
+	return ""
+}
+
+// walkLink searches the data root for a link to an inode of the given size,
+// or returns "" if none is left
+func (j *Journal) walkLink(dev, ino uint64, size int64) string {
+	var found string
+	filepath.WalkDir(j.dataRoot, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || !d.Type().IsRegular() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil || info.Size() != size {
+			return nil
+		}
+		if d, i, _, ok := fsutil.Identity(info); ok && d == dev && i == ino {
+			found = path
+			return fs.SkipAll
+		}
+		return nil
+	})
+	return found
+}
+
+// rel returns path relative to the data root, with a leading slash
+func (j *Journal) rel(path string) string {
+	rel, err := filepath.Rel(j.dataRoot, path)
+	if err != nil {
+		return path
+	}
+	return "/" + filepath.ToSlash(rel)
+}
+
+// abs returns the absolute path of a journaled path
+func (j *Journal) abs(rel string) (string, error) {
+	path := filepath.Clean(filepath.Join(j.dataRoot, strings.TrimPrefix(rel, "/")))
+	if !strings.HasPrefix(path, j.dataRoot) {
+		return "", fmt.Errorf("%w: path outside root: %s", ErrNotUndoable, rel)
+	}
+	return path, nil
+}
@@ -0,0 +1,70 @@
+package journal
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/gosiva/hardlink-ui/internal/fsutil"
+)
+
+// Metadata is the metadata of a replaced file, restored when it is undone
+type Metadata struct {
+	UID    uint32            `json:"uid"`
+	GID    uint32            `json:"gid"`
+	Mode   os.FileMode       `json:"mode"`
+	Mtime  int64             `json:"mtime"` // nanoseconds
+	Xattrs map[string][]byte `json:"xattrs,omitempty"`
+}
+
+// encode returns the JSON form stored in the journal
+func (m *Metadata) encode() (string, error) {
+	if m == nil {
+		return "", nil
+	}
+	data, err := json.Marshal(m)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode metadata: %w", err)
+	}
+	return string(data), nil
+}
+
+// decodeMetadata parses journaled metadata
+func decodeMetadata(data string) (*Metadata, error) {
+	var m Metadata
+	if data == "" {
+		return nil, fmt.Errorf("%w: metadata was not recorded", ErrNotUndoable)
+	}
+	if err := json.Unmarshal([]byte(data), &m); err != nil {
+		return nil, fmt.Errorf("failed to decode metadata: %w", err)
+	}
+	return &m, nil
+}
+
+// Apply sets the metadata on path. The owner is only changed when it differs,
+// which requires privileges.
+func (m *Metadata) Apply(path string) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	if uid, gid, ok := fsutil.Owner(info); ok && (uid != m.UID || gid != m.GID) {
+		if err := os.Chown(path, int(m.UID), int(m.GID)); err != nil {
+			return fmt.Errorf("failed to restore owner: %w", err)
+		}
+	}
+	if err := os.Chmod(path, m.Mode.Perm()); err != nil {
+		return fmt.Errorf("failed to restore mode: %w", err)
+	}
+	for name, value := range m.Xattrs {
+		if err := fsutil.SetXattr(path, name, value); err != nil {
+			return fmt.Errorf("failed to restore extended attribute %s: %w", name, err)
+		}
+	}
+	mtime := time.Unix(0, m.Mtime)
+	if err := os.Chtimes(path, mtime, mtime); err != nil {
+		return fmt.Errorf("failed to restore mtime: %w", err)
+	}
+	return nil
+}
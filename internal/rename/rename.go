@@ -0,0 +1,183 @@
+package rename
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// ErrNoMatch is returned by Apply for a path not matching the pattern
+var ErrNoMatch = errors.New("path does not match the rename pattern")
+
+// Options renames the destination of hardlinks
+type Options struct {
+	// Template builds the destination path, relative to the destination
+	// root, from placeholders: {name} (file name without extension), {ext}
+	// (extension without the dot), {filename}, {parent} (name of the
+	// directory holding the file), {dir} (directory relative to the walked
+	// source, empty at its root), and the groups of Pattern by number ({1})
+	// or name. "/" creates directories.
+	Template string `json:"template,omitempty"`
+	// Pattern is a regular expression matched against the path relative to
+	// the walked source, whose groups the template can use. Files not
+	// matching it aren't linked.
+	Pattern string `json:"pattern,omitempty"`
+}
+
+// builtins are the placeholders available to every template
+var builtins = map[string]bool{
+	"name":     true,
+	"ext":      true,
+	"filename": true,
+	"parent":   true,
+	"dir":      true,
+}
+
+// segment is a literal part of a template, or a placeholder
+type segment struct {
+	text        string
+	placeholder bool
+}
+
+// Renamer applies compiled Options
+type Renamer struct {
+	segments []segment
+	pattern  *regexp.Regexp
+}
+
+// Compile validates the options and prepares them for renaming. It returns
+// nil without a template.
+func (o Options) Compile() (*Renamer, error) {
+	if strings.TrimSpace(o.Template) == "" {
+		if o.Pattern != "" {
+			return nil, fmt.Errorf("rename pattern requires a template")
+		}
+		return nil, nil
+	}
+
+	r := &Renamer{}
+	groups := map[string]bool{}
+	if o.Pattern != "" {
+		re, err := regexp.Compile(o.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid rename pattern %q: %w", o.Pattern, err)
+		}
+		r.pattern = re
+		for i, name := range re.SubexpNames() {
+			groups[fmt.Sprint(i)] = true
+			if name == "" {
+				continue
+			}
+			if builtins[name] {
+				return nil, fmt.Errorf("rename pattern group %q shadows a placeholder", name)
+			}
+			groups[name] = true
+		}
+	}
+
+	template := o.Template
+	for template != "" {
+		open := strings.IndexAny(template, "{}")
+		if open < 0 {
+			r.segments = append(r.segments, segment{text: template})
+			break
+		}
+		if template[open] == '}' {
+			return nil, fmt.Errorf("unbalanced brace in rename template %q", o.Template)
+		}
+		if open > 0 {
+			r.segments = append(r.segments, segment{text: template[:open]})
+		}
+		end := strings.IndexAny(template[open+1:], "{}")
+		if end < 0 || template[open+1+end] != '}' {
+			return nil, fmt.Errorf("unbalanced brace in rename template %q", o.Template)
+		}
+		name := template[open+1 : open+1+end]
+		if !builtins[name] && !groups[name] {
+			return nil, fmt.Errorf("unknown placeholder {%s} in rename template", name)
+		}
+		r.segments = append(r.segments, segment{text: name, placeholder: true})
+		template = template[open+2+end:]
+	}
+
+	return r, nil
+}
+
+// Apply returns the destination of rel, the "/"-separated path of a file
+// relative to the walked source, whose directory is named parent. The result
+// is a sanitized "/"-separated relative path. A nil Renamer keeps rel.
+func (r *Renamer) Apply(parent, rel string) (string, error) {
+	if r == nil {
+		return rel, nil
+	}
+
+	filename := path.Base(rel)
+	ext := path.Ext(filename)
+	dir := path.Dir(rel)
+	if dir == "." {
+		dir = ""
+	}
+	values := map[string]string{
+		"name":     strings.TrimSuffix(filename, ext),
+		"ext":      strings.TrimPrefix(ext, "."),
+		"filename": filename,
+		"parent":   parent,
+		"dir":      dir,
+	}
+
+	if r.pattern != nil {
+		match := r.pattern.FindStringSubmatch(rel)
+		if match == nil {
+			return "", ErrNoMatch
+		}
+		for i, name := range r.pattern.SubexpNames() {
+			values[fmt.Sprint(i)] = match[i]
+			if name != "" {
+				values[name] = match[i]
+			}
+		}
+	}
+
+	var b strings.Builder
+	for _, seg := range r.segments {
+		if seg.placeholder {
+			b.WriteString(values[seg.text])
+		} else {
+			b.WriteString(seg.text)
+		}
+	}
+
+	result := b.String()
+	if Sanitize(result[strings.LastIndex(result, "/")+1:]) == "" {
+		return "", fmt.Errorf("rename template gives no file name for %s", rel)
+	}
+	return Sanitize(result), nil
+}
+
+// illegal are the characters replaced in names, refused by Windows and SMB
+// clients
+const illegal = `<>:"\|?*`
+
+// Sanitize cleans each name of a "/"-separated path: illegal and control
+// characters become "_", blanks are collapsed, and leading or trailing
+// spaces and trailing dots are trimmed. Names left empty, "." and ".."
+// included, are dropped.
+func Sanitize(p string) string {
+	var names []string
+	for _, name := range strings.Split(p, "/") {
+		name = strings.Map(func(c rune) rune {
+			if c < 0x20 || c == 0x7f || strings.ContainsRune(illegal, c) {
+				return '_'
+			}
+			return c
+		}, name)
+		name = strings.Join(strings.Fields(name), " ")
+		name = strings.TrimRight(name, ". ")
+		if name != "" {
+			names = append(names, name)
+		}
+	}
+	return strings.Join(names, "/")
+}
@@ -0,0 +1,120 @@
+package rename
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+// TestSanitize verifies that names are cleaned and that empty names, "." and
+// ".." included, are dropped
+func TestSanitize(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"Film (2019).mkv", "Film (2019).mkv"},
+		{"Film: The Sequel?.mkv", "Film_ The Sequel_.mkv"},
+		{`a<b>c"d\e|f*g`, "a_b_c_d_e_f_g"},
+		{"tab\there\x7f", "tab_here_"},
+		{"  lots   of  spaces  ", "lots of spaces"},
+		{"Season 01./Show... ", "Season 01/Show"},
+		{"../../etc/passwd", "etc/passwd"},
+		{"a/./b/../c", "a/b/c"},
+		{"/a//b/", "a/b"},
+		{"..", ""},
+		{"", ""},
+	}
+
+	for _, tt := range tests {
+		if got := Sanitize(tt.in); got != tt.want {
+			t.Errorf("Sanitize(%q) = %q, expected %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+// TestApply verifies the placeholders of a template
+func TestApply(t *testing.T) {
+	const episode = `(?i)s(?P<season>\d+)e(?P<episode>\d+)`
+
+	tests := []struct {
+		name     string
+		options  Options
+		parent   string
+		rel      string
+		want     string
+		noMatch  bool
+		wantFail bool
+	}{
+		{"builtins", Options{Template: "{parent}/{name}.{ext}"}, "Film", "Film/film.mkv", "Film/film.mkv", false, false},
+		{"filename and dir", Options{Template: "{dir}/x-{filename}"}, "b", "a/b/film.mkv", "a/b/x-film.mkv", false, false},
+		{"empty dir", Options{Template: "{dir}/{filename}"}, "src", "film.mkv", "film.mkv", false, false},
+		{"no extension", Options{Template: "{name}.{ext}"}, "src", "README", "README", false, false},
+		{"named groups", Options{Template: "Season {season}/S{season}E{episode}.{ext}", Pattern: episode}, "src", "Show.S01E02/show.s01e02.mkv", "Season 01/S01E02.mkv", false, false},
+		{"numbered groups", Options{Template: "{1}-{2}.{ext}", Pattern: episode}, "src", "show.s01e02.mkv", "01-02.mkv", false, false},
+		{"no match", Options{Template: "{1}.{ext}", Pattern: episode}, "src", "readme.txt", "", true, false},
+		{"sanitized", Options{Template: "{parent}/{name}.{ext}"}, "Film: 2", "x/a?b.mkv", "Film_ 2/a_b.mkv", false, false},
+		{"can't leave the root", Options{Template: "{parent}/../../{filename}"}, "movies", "f.mkv", "movies/f.mkv", false, false},
+		{"no file name", Options{Template: "{name}/.."}, "src", "film.mkv", "", false, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := tt.options.Compile()
+			if err != nil {
+				t.Fatalf("Failed to compile: %v", err)
+			}
+			got, err := r.Apply(tt.parent, tt.rel)
+			switch {
+			case tt.noMatch:
+				if !errors.Is(err, ErrNoMatch) {
+					t.Errorf("Expected ErrNoMatch, got %q, %v", got, err)
+				}
+			case tt.wantFail:
+				if err == nil {
+					t.Errorf("Expected an error, got %q", got)
+				}
+			case err != nil:
+				t.Errorf("Failed to apply: %v", err)
+			case got != tt.want:
+				t.Errorf("Expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+
+	// Without a template, paths are kept
+	r, err := Options{}.Compile()
+	if err != nil || r != nil {
+		t.Fatalf("Expected no renamer without a template, got %v, %v", r, err)
+	}
+	if got, _ := r.Apply("src", "a/b.mkv"); got != "a/b.mkv" {
+		t.Errorf("Expected the path to be kept, got %q", got)
+	}
+}
+
+// TestCompileErrors verifies that invalid options are rejected
+func TestCompileErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		options Options
+		want    string
+	}{
+		{"pattern without template", Options{Pattern: "x"}, "requires a template"},
+		{"invalid pattern", Options{Template: "{name}", Pattern: "("}, "invalid rename pattern"},
+		{"shadowing group", Options{Template: "{name}", Pattern: "(?P<name>.*)"}, "shadows a placeholder"},
+		{"unknown placeholder", Options{Template: "{title}"}, "unknown placeholder {title}"},
+		{"missing group", Options{Template: "{2}", Pattern: "(a)"}, "unknown placeholder {2}"},
+		{"unclosed brace", Options{Template: "{name"}, "unbalanced brace"},
+		{"closing brace", Options{Template: "name}"}, "unbalanced brace"},
+		{"nested brace", Options{Template: "{na{me}}"}, "unbalanced brace"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := tt.options.Compile()
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Expected an error containing %q, got %v", tt.want, err)
+			}
+		})
+	}
+}
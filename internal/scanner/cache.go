@@ -0,0 +1,95 @@
+package scanner
+
+import (
+	"context"
+	"log"
+	"os"
+	"sync"
+
+	"github.com/gosiva/hardlink-ui/internal/fsutil"
+	"github.com/gosiva/hardlink-ui/internal/storage"
+)
+
+// cacheFlushSize is the number of new hashes buffered before being written
+const cacheFlushSize = 500
+
+// hashCache reuses the hashes of unchanged files across scans.
+// A file is considered unchanged while its device, inode, size, mtime and
+// ctime are the same as when it was hashed. Each policy has its own hashes.
+type hashCache struct {
+	db     *storage.DB
+	policy VerifyPolicy
+	// force ignores cached hashes; fresh hashes are still stored
+	force bool
+
+	mu      sync.Mutex
+	pending []storage.FileHash
+}
+
+func newHashCache(db *storage.DB, policy VerifyPolicy, force bool) *hashCache {
+	return &hashCache{db: db, policy: policy, force: force}
+}
+
+// hash returns the hash of a file, from the cache when possible.
+// Reads are throttled by limiter, which may be nil, until ctx is done.
+func (c *hashCache) hash(ctx context.Context, path string, limiter *rateLimiter) (hash string, cached bool, err error) {
+	// Stat before hashing: if the file changes while being hashed, its
+	// mtime/ctime won't match the stored key and it will be rehashed next time
+	info, statErr := os.Stat(path)
+	key, hasKey := storage.FileHashKey{}, false
+	if statErr == nil {
+		if dev, ino, _, ok := fsutil.Identity(info); ok {
+			key = storage.FileHashKey{
+				Dev:   dev,
+				Inode: ino,
+				Size:  info.Size(),
+				Mtime: info.ModTime().UnixNano(),
+				Ctime: fsutil.ChangeTime(info),
+				Algo:  c.policy.hashAlgo(),
+			}
+			hasKey = true
+		}
+	}
+
+	if hasKey && !c.force {
+		if h, found, err := c.db.GetFileHash(key); err != nil {
+			log.Printf("Hash cache lookup failed for %s: %v", path, err)
+		} else if found {
+			return h, true, nil
+		}
+	}
+
+	hash, err = computeHash(ctx, path, c.policy, limiter)
+	if err != nil {
+		return "", false, err
+	}
+
+	if hasKey {
+		c.mu.Lock()
+		c.pending = append(c.pending, storage.FileHash{FileHashKey: key, Hash: hash})
+		shouldFlush := len(c.pending) >= cacheFlushSize
+		c.mu.Unlock()
+
+		if shouldFlush {
+			c.flush()
+		}
+	}
+
+	return hash, false, nil
+}
+
+// flush writes buffered hashes to the database
+func (c *hashCache) flush() {
+	c.mu.Lock()
+	entries := c.pending
+	c.pending = nil
+	c.mu.Unlock()
+
+	if len(entries) == 0 {
+		return
+	}
+
+	if err := c.db.SaveFileHashes(entries); err != nil {
+		log.Printf("Failed to save %d hashes to cache: %v", len(entries), err)
+	}
+}
@@ -0,0 +1,344 @@
+package scanner
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/gosiva/hardlink-ui/internal/storage"
+)
+
+// Scan phases recorded in a checkpoint
+const (
+	phaseWalk = "walk"
+	phaseHash = "hash"
+)
+
+var (
+	// ErrJobNotFound is returned when no scan job has the given ID
+	ErrJobNotFound = errors.New("job not found")
+	// ErrJobNotRunning is returned when pausing a job that isn't running
+	ErrJobNotRunning = errors.New("job is not running")
+	// ErrJobRunning is returned when deleting a job that is still running
+	ErrJobRunning = errors.New("job is running")
+	// ErrJobNotPaused is returned when resuming a job that isn't paused
+	ErrJobNotPaused = errors.New("job is not paused")
+	// ErrInvalidOptions is returned when scan options are rejected
+	ErrInvalidOptions = errors.New("invalid scan options")
+)
+
+// scanControl stops a running scan job
+type scanControl struct {
+	cancel context.CancelFunc
+	pause  bool // checkpoint the job instead of discarding its progress
+}
+
+// checkpoint is the state a paused scan job resumes from
+type checkpoint struct {
+	Phase      string
+	Buckets    []sizeBucket // candidate files grouped by size
+	Position   int          // buckets fully hashed
+	TotalFiles int
+	Processed  int
+	Groups     []DuplicateGroup // groups found in the hashed buckets
+}
+
+// launch runs a scan job in the background. s.mu must be held.
+func (s *Scanner) launch(jobID string, progress *ScanProgress, opts ScanOptions, cp *checkpoint) {
+	ctx, cancel := context.WithCancel(context.Background())
+	s.controls[jobID] = &scanControl{cancel: cancel}
+	s.running.Add(1)
+
+	go func() {
+		defer s.running.Done()
+		defer cancel()
+
+		s.performScan(ctx, jobID, progress, opts, cp)
+
+		s.mu.Lock()
+		delete(s.controls, jobID)
+		s.mu.Unlock()
+	}()
+}
+
+// stop records a job interrupted by PauseScan, CancelScan or Shutdown
+func (s *Scanner) stop(jobID string, progress *ScanProgress, cp *checkpoint) {
+	s.mu.Lock()
+	pause := s.controls[jobID] != nil && s.controls[jobID].pause
+	s.mu.Unlock()
+
+	if !pause {
+		if err := s.db.CancelScanJob(jobID); err != nil {
+			log.Printf("Job %s: Failed to cancel: %v", jobID, err)
+		}
+		progress.mu.Lock()
+		progress.Status = "cancelled"
+		progress.mu.Unlock()
+		log.Printf("Job %s: Cancelled", jobID)
+		return
+	}
+
+	if err := s.saveCheckpoint(jobID, cp); err != nil {
+		progress.mu.Lock()
+		progress.Status = "failed"
+		progress.Error = fmt.Sprintf("failed to save checkpoint: %v", err)
+		progress.mu.Unlock()
+		s.db.FailScanJob(jobID, progress.Error)
+		log.Printf("Job %s: Failed to save checkpoint: %v", jobID, err)
+		return
+	}
+
+	progress.mu.Lock()
+	progress.Status = "paused"
+	progress.Processed = cp.Processed
+	progress.GroupsFound = len(cp.Groups)
+	progress.mu.Unlock()
+	log.Printf("Job %s: Paused in %s phase", jobID, cp.Phase)
+}
+
+// saveCheckpoint stores a checkpoint and marks the job as paused
+func (s *Scanner) saveCheckpoint(jobID string, cp *checkpoint) error {
+	buckets := cp.Buckets
+	if buckets == nil {
+		buckets = []sizeBucket{}
+	}
+	sizeMap, err := json.Marshal(buckets)
+	if err != nil {
+		return err
+	}
+
+	groups := cp.Groups
+	if groups == nil {
+		groups = []DuplicateGroup{}
+	}
+	groupsJSON, err := json.Marshal(groups)
+	if err != nil {
+		return err
+	}
+
+	return s.db.PauseScanJob(storage.ScanCheckpoint{
+		JobID:      jobID,
+		Phase:      cp.Phase,
+		SizeMap:    string(sizeMap),
+		Position:   cp.Position,
+		TotalFiles: cp.TotalFiles,
+		Processed:  cp.Processed,
+		Groups:     string(groupsJSON),
+	})
+}
+
+// loadCheckpoint reads back the checkpoint of a paused job
+func (s *Scanner) loadCheckpoint(jobID string) (*checkpoint, error) {
+	stored, err := s.db.GetScanCheckpoint(jobID)
+	if err != nil {
+		return nil, err
+	}
+	if stored == nil {
+		return nil, fmt.Errorf("no checkpoint for job %s", jobID)
+	}
+
+	cp := &checkpoint{
+		Phase:      stored.Phase,
+		Position:   stored.Position,
+		TotalFiles: stored.TotalFiles,
+		Processed:  stored.Processed,
+	}
+	if err := json.Unmarshal([]byte(stored.SizeMap), &cp.Buckets); err != nil {
+		return nil, fmt.Errorf("failed to decode size map: %w", err)
+	}
+	if err := json.Unmarshal([]byte(stored.Groups), &cp.Groups); err != nil {
+		return nil, fmt.Errorf("failed to decode groups: %w", err)
+	}
+	if cp.Position > len(cp.Buckets) {
+		return nil, fmt.Errorf("invalid checkpoint position %d", cp.Position)
+	}
+
+	return cp, nil
+}
+
+// PauseScan stops a running job and checkpoints it so it can be resumed,
+// even after a restart
+func (s *Scanner) PauseScan(jobID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	control, running := s.controls[jobID]
+	if !running {
+		return s.jobState(jobID, ErrJobNotRunning)
+	}
+
+	control.pause = true
+	control.cancel()
+	return nil
+}
+
+// CancelScan stops a running or paused job and discards its progress
+func (s *Scanner) CancelScan(jobID string) error {
+	s.mu.Lock()
+	if control, running := s.controls[jobID]; running {
+		control.pause = false
+		control.cancel()
+		s.mu.Unlock()
+		return nil
+	}
+	progress := s.jobs[jobID]
+	s.mu.Unlock()
+
+	job, err := s.db.GetScanJob(jobID)
+	if err != nil {
+		return err
+	}
+	if job == nil {
+		return ErrJobNotFound
+	}
+	if job.Status != "paused" {
+		return ErrJobNotRunning
+	}
+
+	if err := s.db.CancelScanJob(jobID); err != nil {
+		return fmt.Errorf("failed to cancel scan job: %w", err)
+	}
+	if progress != nil {
+		progress.mu.Lock()
+		progress.Status = "cancelled"
+		progress.mu.Unlock()
+	}
+
+	log.Printf("Job %s: Cancelled while paused", jobID)
+	return nil
+}
+
+// ResumeScan restarts a paused job from its checkpoint
+func (s *Scanner) ResumeScan(jobID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, running := s.controls[jobID]; running {
+		return ErrJobNotPaused
+	}
+
+	job, err := s.db.GetScanJob(jobID)
+	if err != nil {
+		return err
+	}
+	if job == nil {
+		return ErrJobNotFound
+	}
+	if job.Status != "paused" {
+		return ErrJobNotPaused
+	}
+
+	var opts ScanOptions
+	if err := json.Unmarshal([]byte(job.Options), &opts); err != nil {
+		return fmt.Errorf("failed to decode scan options: %w", err)
+	}
+
+	cp, err := s.loadCheckpoint(jobID)
+	if err != nil {
+		return err
+	}
+
+	if err := s.db.ResumeScanJob(jobID); err != nil {
+		return fmt.Errorf("failed to resume scan job: %w", err)
+	}
+
+	progress, exists := s.jobs[jobID]
+	if !exists {
+		progress = &ScanProgress{JobID: jobID}
+		s.jobs[jobID] = progress
+	}
+	progress.mu.Lock()
+	progress.Status = "running"
+	progress.Error = ""
+	progress.TotalFiles = cp.TotalFiles
+	progress.Processed = cp.Processed
+	progress.GroupsFound = len(cp.Groups)
+	progress.mu.Unlock()
+
+	s.launch(jobID, progress, opts, cp)
+	return nil
+}
+
+// Shutdown pauses every running job and waits for their checkpoints to be
+// saved, so they can be resumed after a restart
+func (s *Scanner) Shutdown() {
+	s.mu.Lock()
+	for _, control := range s.controls {
+		control.pause = true
+		control.cancel()
+	}
+	s.mu.Unlock()
+
+	s.running.Wait()
+}
+
+// RecoverInterruptedJobs marks the jobs left running by a previous process
+// as failed. Jobs paused at shutdown stay resumable.
+func (s *Scanner) RecoverInterruptedJobs() error {
+	count, err := s.db.FailInterruptedScanJobs()
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		log.Printf("Marked %d interrupted scan job(s) as failed", count)
+	}
+	return nil
+}
+
+// DeleteJob deletes a finished or paused job with its results
+func (s *Scanner) DeleteJob(jobID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, running := s.controls[jobID]; running {
+		return ErrJobRunning
+	}
+
+	job, err := s.db.GetScanJob(jobID)
+	if err != nil {
+		return err
+	}
+	if job == nil {
+		return ErrJobNotFound
+	}
+
+	if err := s.db.DeleteScanJob(jobID); err != nil {
+		return fmt.Errorf("failed to delete scan job: %w", err)
+	}
+	delete(s.jobs, jobID)
+	return nil
+}
+
+// PruneJobs deletes the finished jobs started before the given time, keeping
+// the keep most recent jobs, and returns the number of deleted jobs
+func (s *Scanner) PruneJobs(before time.Time, keep int) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	jobIDs, err := s.db.PruneScanJobs(before.Unix(), keep)
+	if err != nil {
+		return 0, fmt.Errorf("failed to prune scan jobs: %w", err)
+	}
+	for _, jobID := range jobIDs {
+		delete(s.jobs, jobID)
+	}
+	return len(jobIDs), nil
+}
+
+// jobState returns ErrJobNotFound for unknown jobs and fallback otherwise
+func (s *Scanner) jobState(jobID string, fallback error) error {
+	if _, exists := s.jobs[jobID]; exists {
+		return fallback
+	}
+	job, err := s.db.GetScanJob(jobID)
+	if err != nil {
+		return err
+	}
+	if job == nil {
+		return ErrJobNotFound
+	}
+	return fallback
+}
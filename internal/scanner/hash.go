@@ -1,6 +1,7 @@
 package scanner
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"os"
@@ -21,6 +22,12 @@ const (
 // For files < 100MB: full file hash
 // For files >= 100MB: hash first 256KB + last 256KB + file size
 func ComputeFileHash(path string) (string, error) {
+	return computeFileHash(context.Background(), path, nil)
+}
+
+// computeFileHash is ComputeFileHash with reads throttled by limiter (may be
+// nil) until ctx is done
+func computeFileHash(ctx context.Context, path string, limiter *rateLimiter) (string, error) {
 	file, err := os.Open(path)
 	if err != nil {
 		return "", fmt.Errorf("failed to open file: %w", err)
@@ -42,7 +49,7 @@ func ComputeFileHash(path string) (string, error) {
 
 	if size <= SmallFileThreshold {
 		// Small file: hash everything
-		if _, err := io.Copy(h, file); err != nil {
+		if _, err := io.Copy(h, throttle(ctx, file, limiter)); err != nil {
 			return "", fmt.Errorf("failed to hash file: %w", err)
 		}
 	} else {
@@ -53,6 +60,9 @@ func ComputeFileHash(path string) (string, error) {
 		if err != nil && err != io.ErrUnexpectedEOF {
 			return "", fmt.Errorf("failed to read file start: %w", err)
 		}
+		if err := limiter.wait(ctx, n); err != nil {
+			return "", err
+		}
 		h.Write(buf[:n])
 
 		// Read last PartialHashSize bytes if file is large enough
@@ -64,6 +74,9 @@ func ComputeFileHash(path string) (string, error) {
 			if err != nil && err != io.ErrUnexpectedEOF {
 				return "", fmt.Errorf("failed to read file end: %w", err)
 			}
+			if err := limiter.wait(ctx, n); err != nil {
+				return "", err
+			}
 			h.Write(buf[:n])
 		}
 
@@ -75,52 +88,15 @@ func ComputeFileHash(path string) (string, error) {
 	return fmt.Sprintf("%016x", h.Sum64()), nil
 }
 
-// VerifyFilesIdentical does a full byte-by-byte comparison
-// Used before converting duplicates to hardlinks
+// VerifyFilesIdentical checks two files with the quick policy: full byte
+// comparison under 100MB, xxHash of start + end above
 func VerifyFilesIdentical(path1, path2 string) (bool, error) {
-	// First check: file stats
-	stat1, err := os.Stat(path1)
-	if err != nil {
-		return false, err
-	}
-
-	stat2, err := os.Stat(path2)
-	if err != nil {
-		return false, err
-	}
-
-	// Size must match
-	if stat1.Size() != stat2.Size() {
-		return false, nil
-	}
-
-	// If they're already the same inode, they're identical
-	if os.SameFile(stat1, stat2) {
-		return true, nil
-	}
-
-	// For files under 100MB, do full byte comparison
-	// For larger files, trust the xxHash
-	if stat1.Size() <= SmallFileThreshold {
-		return compareFilesFull(path1, path2)
-	}
-
-	// For large files, just verify the hashes match
-	hash1, err := ComputeFileHash(path1)
-	if err != nil {
-		return false, err
-	}
-
-	hash2, err := ComputeFileHash(path2)
-	if err != nil {
-		return false, err
-	}
-
-	return hash1 == hash2, nil
+	return VerifyFiles(path1, path2, VerifyQuick)
 }
 
-// compareFilesFull does a complete byte-by-byte comparison
-func compareFilesFull(path1, path2 string) (bool, error) {
+// compareFiles does a complete byte-by-byte comparison, with reads throttled
+// by limiter (may be nil). It stops between two chunks once ctx is done.
+func compareFiles(ctx context.Context, path1, path2 string, limiter *rateLimiter) (bool, error) {
 	f1, err := os.Open(path1)
 	if err != nil {
 		return false, err
@@ -137,8 +113,15 @@ func compareFilesFull(path1, path2 string) (bool, error) {
 	buf2 := make([]byte, ChunkSize)
 
 	for {
-		n1, err1 := f1.Read(buf1)
-		n2, err2 := f2.Read(buf2)
+		if err := ctx.Err(); err != nil {
+			return false, err
+		}
+
+		n1, err1 := io.ReadFull(f1, buf1)
+		n2, err2 := io.ReadFull(f2, buf2)
+		if err := limiter.wait(ctx, n1+n2); err != nil {
+			return false, err
+		}
 
 		if n1 != n2 {
 			return false, nil
@@ -148,20 +131,25 @@ func compareFilesFull(path1, path2 string) (bool, error) {
 			return false, nil
 		}
 
-		if err1 == io.EOF && err2 == io.EOF {
+		if isEOF(err1) && isEOF(err2) {
 			return true, nil
 		}
 
-		if err1 != nil && err1 != io.EOF {
+		if err1 != nil && !isEOF(err1) {
 			return false, err1
 		}
 
-		if err2 != nil && err2 != io.EOF {
+		if err2 != nil && !isEOF(err2) {
 			return false, err2
 		}
 	}
 }
 
+// isEOF reports whether a full read stopped at the end of the file
+func isEOF(err error) bool {
+	return err == io.EOF || err == io.ErrUnexpectedEOF
+}
+
 func bytesEqual(a, b []byte) bool {
 	if len(a) != len(b) {
 		return false
@@ -0,0 +1,143 @@
+package scanner
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/gosiva/hardlink-ui/internal/fsutil"
+)
+
+// MasterStrategy selects the file of a duplicate group that is kept as
+// master: the others become hardlinks to its inode, so its ownership,
+// permissions and timestamps are the ones that remain
+type MasterStrategy string
+
+const (
+	// MasterLowestInode keeps the lowest inode number, the first path
+	// alphabetically among its links
+	MasterLowestInode MasterStrategy = "lowest-inode"
+	// MasterOldest keeps the file modified first
+	MasterOldest MasterStrategy = "oldest-mtime"
+	// MasterNewest keeps the file modified last
+	MasterNewest MasterStrategy = "newest-mtime"
+	// MasterMostLinks keeps the inode that already has the most hardlinks
+	MasterMostLinks MasterStrategy = "most-links"
+	// MasterPreferPath keeps the file under the first matching preferred
+	// path prefix, e.g. /media before /downloads
+	MasterPreferPath MasterStrategy = "prefer-path"
+	// MasterShortestPath keeps the file with the shortest path
+	MasterShortestPath MasterStrategy = "shortest-path"
+)
+
+// ParseMasterStrategy validates a strategy name, empty meaning MasterLowestInode
+func ParseMasterStrategy(value string) (MasterStrategy, error) {
+	switch strategy := MasterStrategy(value); strategy {
+	case "":
+		return MasterLowestInode, nil
+	case MasterLowestInode, MasterOldest, MasterNewest, MasterMostLinks, MasterPreferPath, MasterShortestPath:
+		return strategy, nil
+	default:
+		return "", fmt.Errorf("unknown master strategy %q", value)
+	}
+}
+
+// masterCandidate is a path of a duplicate group with its inode metadata
+type masterCandidate struct {
+	path  string // relative to the data root
+	ino   uint64
+	mtime int64
+	nlink uint64
+}
+
+// better reports whether a should be kept as master rather than b. Ties are
+// broken by inode number then path, so the choice is stable across scans.
+func (st MasterStrategy) better(a, b *masterCandidate, prefer []string) bool {
+	switch st {
+	case MasterOldest:
+		if a.mtime != b.mtime {
+			return a.mtime < b.mtime
+		}
+	case MasterNewest:
+		if a.mtime != b.mtime {
+			return a.mtime > b.mtime
+		}
+	case MasterMostLinks:
+		if a.nlink != b.nlink {
+			return a.nlink > b.nlink
+		}
+	case MasterPreferPath:
+		if ra, rb := prefixRank(a.path, prefer), prefixRank(b.path, prefer); ra != rb {
+			return ra < rb
+		}
+	case MasterShortestPath:
+		if len(a.path) != len(b.path) {
+			return len(a.path) < len(b.path)
+		}
+	}
+
+	if a.ino != b.ino {
+		return a.ino < b.ino
+	}
+	return a.path < b.path
+}
+
+// prefixRank returns the index of the first prefix containing rel, or
+// len(prefixes) when none does
+func prefixRank(rel string, prefixes []string) int {
+	for i, prefix := range prefixes {
+		prefix = path.Clean("/" + prefix)
+		if prefix == "/" || rel == prefix || strings.HasPrefix(rel, prefix+"/") {
+			return i
+		}
+	}
+	return len(prefixes)
+}
+
+// pickMaster returns the index of the candidate the strategy keeps
+func pickMaster(candidates []masterCandidate, strategy MasterStrategy, prefer []string) int {
+	best := 0
+	for i := 1; i < len(candidates); i++ {
+		if strategy.better(&candidates[i], &candidates[best], prefer) {
+			best = i
+		}
+	}
+	return best
+}
+
+// newCandidate reads the inode metadata of a file
+func newCandidate(rel string, info os.FileInfo) (masterCandidate, bool) {
+	_, ino, nlink, ok := fsutil.Identity(info)
+	if !ok {
+		return masterCandidate{}, false
+	}
+	return masterCandidate{
+		path:  rel,
+		ino:   ino,
+		mtime: info.ModTime().UnixNano(),
+		nlink: nlink,
+	}, true
+}
+
+// SelectMaster returns which of paths, relative to dataRoot, the strategy
+// keeps as master. Files that can't be read are skipped.
+func SelectMaster(dataRoot string, paths []string, strategy MasterStrategy, prefer []string) (string, error) {
+	var candidates []masterCandidate
+	for _, rel := range paths {
+		info, err := os.Stat(filepath.Join(dataRoot, strings.TrimPrefix(rel, "/")))
+		if err != nil {
+			continue
+		}
+		if candidate, ok := newCandidate(rel, info); ok {
+			candidates = append(candidates, candidate)
+		}
+	}
+
+	if len(candidates) == 0 {
+		return "", errors.New("no readable file to keep as master")
+	}
+	return candidates[pickMaster(candidates, strategy, prefer)].path, nil
+}
@@ -0,0 +1,21 @@
+//go:build linux
+
+package scanner
+
+import "syscall"
+
+const (
+	ioprioWhoProcess = 1
+	ioprioClassIdle  = 3
+	ioprioClassShift = 13
+)
+
+// setLowPriority moves the calling thread to the idle I/O class (like
+// `ionice -c3`) and the lowest CPU priority, so scans yield to other readers
+func setLowPriority() error {
+	// who=0 targets the calling thread
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOPRIO_SET, ioprioWhoProcess, 0, ioprioClassIdle<<ioprioClassShift); errno != 0 {
+		return errno
+	}
+	return syscall.Setpriority(syscall.PRIO_PROCESS, 0, 19)
+}
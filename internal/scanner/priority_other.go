@@ -0,0 +1,8 @@
+//go:build !linux
+
+package scanner
+
+// setLowPriority is not supported on this platform
+func setLowPriority() error {
+	return nil
+}
@@ -1,15 +1,21 @@
 package scanner
 
 import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
 	"fmt"
 	"io/fs"
 	"log"
 	"os"
 	"path/filepath"
 	"sort"
+	"strings"
 	"sync"
-	"syscall"
 
+	"github.com/gosiva/hardlink-ui/internal/filter"
+	"github.com/gosiva/hardlink-ui/internal/fsutil"
 	"github.com/gosiva/hardlink-ui/internal/storage"
 )
 
@@ -17,9 +23,12 @@ import (
 type DuplicateGroup struct {
 	Size       int64    `json:"size"`
 	SizeHuman  string   `json:"size_human"`
+	Dev        uint64   `json:"dev"`
 	Master     string   `json:"master"`
 	Others     []string `json:"others"`
 	InodeCount int      `json:"inode_count"` // number of unique inodes
+	// Verify is the verification policy that confirmed the files are identical
+	Verify VerifyPolicy `json:"verify"`
 }
 
 // Scanner handles duplicate file scanning
@@ -28,17 +37,45 @@ type Scanner struct {
 	dataRoot string
 	mu       sync.Mutex
 	jobs     map[string]*ScanProgress
+	controls map[string]*scanControl // running jobs
+	running  sync.WaitGroup
+	// onComplete is called with the ID of each job whose results are saved
+	onComplete []func(jobID string)
+}
+
+// ScanOptions controls how a scan job runs
+type ScanOptions struct {
+	// ForceRehash ignores the hash cache and rehashes every candidate file
+	ForceRehash bool `json:"force_rehash"`
+	// Workers is the number of files hashed in parallel
+	Workers int `json:"workers"`
+	// ReadLimit caps the bytes read per second by all workers, 0 is unlimited
+	ReadLimit int64 `json:"read_limit"`
+	// LowPriority runs workers in the idle I/O class, like `ionice -c3`
+	LowPriority bool `json:"low_priority"`
+	// Verify selects how file contents are compared, VerifyQuick when empty
+	Verify VerifyPolicy `json:"verify,omitempty"`
+	// Roots limits the scan to these paths relative to the data root,
+	// the whole data root is scanned when empty
+	Roots []string `json:"roots,omitempty"`
+	// Rules filters the files considered by the scan
+	filter.Rules
+	// Master selects the master of each group, MasterLowestInode when empty
+	Master MasterStrategy `json:"master,omitempty"`
+	// PreferPaths are the path prefixes of MasterPreferPath, most preferred first
+	PreferPaths []string `json:"prefer_paths,omitempty"`
 }
 
 // ScanProgress tracks the progress of a scan job
 type ScanProgress struct {
-	JobID       string
-	TotalFiles  int
-	Processed   int
-	GroupsFound int
-	Status      string
-	Error       string
-	Results     []DuplicateGroup
+	JobID       string           `json:"job_id"`
+	TotalFiles  int              `json:"total_files"`
+	Processed   int              `json:"processed"`
+	GroupsFound int              `json:"groups_found"`
+	CacheHits   int              `json:"cache_hits"`
+	Status      string           `json:"status"`
+	Error       string           `json:"error,omitempty"`
+	Results     []DuplicateGroup `json:"results,omitempty"`
 	mu          sync.Mutex
 }
 
@@ -48,11 +85,25 @@ func NewScanner(db *storage.DB, dataRoot string) *Scanner {
 		db:       db,
 		dataRoot: dataRoot,
 		jobs:     make(map[string]*ScanProgress),
+		controls: make(map[string]*scanControl),
 	}
 }
 
-// StartScan starts a background scan job
+// OnComplete registers fn to be called when a scan job completes, after its
+// results are saved and before its progress reports it as completed
+func (s *Scanner) OnComplete(fn func(jobID string)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.onComplete = append(s.onComplete, fn)
+}
+
+// StartScan starts a background scan job with default options
 func (s *Scanner) StartScan(jobID string) error {
+	return s.StartScanWithOptions(jobID, ScanOptions{})
+}
+
+// StartScanWithOptions starts a background scan job
+func (s *Scanner) StartScanWithOptions(jobID string, opts ScanOptions) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
@@ -60,23 +111,62 @@ func (s *Scanner) StartScan(jobID string) error {
 		return fmt.Errorf("job already exists")
 	}
 
+	if err := s.ValidateOptions(&opts); err != nil {
+		return err
+	}
+
 	progress := &ScanProgress{
 		JobID:  jobID,
 		Status: "running",
 	}
-	s.jobs[jobID] = progress
+	optionsJSON, err := json.Marshal(opts)
+	if err != nil {
+		return fmt.Errorf("failed to encode scan options: %w", err)
+	}
 
 	// Create job in database
-	if err := s.db.CreateScanJob(jobID); err != nil {
+	if err := s.db.CreateScanJob(jobID, string(optionsJSON)); err != nil {
 		return fmt.Errorf("failed to create scan job: %w", err)
 	}
+	s.jobs[jobID] = progress
 
 	// Start scan in background
-	go s.performScan(jobID, progress)
+	s.launch(jobID, progress, opts, nil)
 
 	return nil
 }
 
+// ValidateOptions checks scan options and fills in the verification policy,
+// returning an error wrapping ErrInvalidOptions when they are rejected
+func (s *Scanner) ValidateOptions(opts *ScanOptions) error {
+	if _, err := s.scanRoots(opts.Roots); err != nil {
+		return err
+	}
+	if _, err := opts.Rules.Compile(); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidOptions, err)
+	}
+	policy, err := ParseVerifyPolicy(string(opts.Verify))
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidOptions, err)
+	}
+	opts.Verify = policy
+	strategy, err := ParseMasterStrategy(string(opts.Master))
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidOptions, err)
+	}
+	opts.Master = strategy
+	return nil
+}
+
+// NewJobID generates a random scan job ID
+func NewJobID() (string, error) {
+	bytes := make([]byte, 16)
+	if _, err := rand.Read(bytes); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(bytes), nil
+}
+
 // GetProgress returns the current progress of a scan job
 func (s *Scanner) GetProgress(jobID string) *ScanProgress {
 	s.mu.Lock()
@@ -92,28 +182,86 @@ func (s *Scanner) GetProgress(jobID string) *ScanProgress {
 			TotalFiles:  progress.TotalFiles,
 			Processed:   progress.Processed,
 			GroupsFound: progress.GroupsFound,
+			CacheHits:   progress.CacheHits,
 			Status:      progress.Status,
 			Error:       progress.Error,
 			Results:     progress.Results,
 		}
 	}
 
-	// Check database for completed jobs
+	// Check database for jobs from a previous run
 	job, err := s.db.GetScanJob(jobID)
-	if err == nil && job != nil {
-		return &ScanProgress{
-			JobID:       job.JobID,
-			TotalFiles:  job.TotalFiles,
-			Processed:   job.Progress,
-			GroupsFound: job.GroupsFound,
-			Status:      job.Status,
+	if err != nil || job == nil {
+		return nil
+	}
+
+	progress := &ScanProgress{
+		JobID:       job.JobID,
+		TotalFiles:  job.TotalFiles,
+		Processed:   job.Progress,
+		GroupsFound: job.GroupsFound,
+		Status:      job.Status,
+		Error:       job.Error.String,
+	}
+
+	if job.Status == "completed" {
+		results, err := s.loadResults(jobID)
+		if err != nil {
+			// The job is still reported, with the reason its results are missing
+			log.Printf("Failed to load results for job %s: %v", jobID, err)
+			progress.Error = fmt.Sprintf("failed to load results: %v", err)
+			return progress
 		}
+		progress.Results = results
 	}
 
-	return nil
+	return progress
+}
+
+// GetResults returns the duplicate groups found by a completed scan job,
+// from memory if the job ran in this process or from the database otherwise
+func (s *Scanner) GetResults(jobID string) ([]DuplicateGroup, error) {
+	s.mu.Lock()
+	progress, exists := s.jobs[jobID]
+	s.mu.Unlock()
+
+	if exists {
+		progress.mu.Lock()
+		defer progress.mu.Unlock()
+		if progress.Status == "completed" {
+			return progress.Results, nil
+		}
+	}
+
+	return s.loadResults(jobID)
 }
 
-func (s *Scanner) performScan(jobID string, progress *ScanProgress) {
+// loadResults reads the persisted duplicate groups of a job
+func (s *Scanner) loadResults(jobID string) ([]DuplicateGroup, error) {
+	records, err := s.db.GetDuplicateGroups(jobID)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]DuplicateGroup, 0, len(records))
+	for _, r := range records {
+		results = append(results, DuplicateGroup{
+			Size:       r.Size,
+			SizeHuman:  humanSize(r.Size),
+			Dev:        r.Dev,
+			Master:     r.Master,
+			Others:     r.Others,
+			InodeCount: r.InodeCount,
+			Verify:     VerifyPolicy(r.Verify),
+		})
+	}
+
+	return results, nil
+}
+
+// performScan runs a scan job, from the beginning or from the checkpoint of
+// a paused job
+func (s *Scanner) performScan(ctx context.Context, jobID string, progress *ScanProgress, opts ScanOptions, cp *checkpoint) {
 	defer func() {
 		if r := recover(); r != nil {
 			log.Printf("Scanner panic for job %s: %v", jobID, r)
@@ -125,186 +273,381 @@ func (s *Scanner) performScan(jobID string, progress *ScanProgress) {
 		}
 	}()
 
-	log.Printf("Starting duplicate scan job: %s", jobID)
-
-	// Phase 1: Collect all files grouped by size
-	sizeMap := make(map[int64][]string)
-	totalFiles := 0
+	var buckets []sizeBucket
+	var totalFiles, processed, position int
+	var duplicateGroups []DuplicateGroup
 
-	err := filepath.WalkDir(s.dataRoot, func(path string, d fs.DirEntry, err error) error {
+	if cp != nil && cp.Phase == phaseHash {
+		// Phase 1 already done: pick up hashing where it stopped
+		log.Printf("Resuming duplicate scan job: %s (%d/%d size groups done)", jobID, cp.Position, len(cp.Buckets))
+		buckets = cp.Buckets
+		totalFiles = cp.TotalFiles
+		processed = cp.Processed
+		position = cp.Position
+		duplicateGroups = cp.Groups
+	} else {
+		log.Printf("Starting duplicate scan job: %s", jobID)
+
+		// Phase 1: Collect all files grouped by size
+		sizeMap, walked, err := s.collectFiles(ctx, jobID, progress, opts)
+		if ctx.Err() != nil {
+			// Walking is cheap compared to hashing, a resumed job starts it over
+			s.stop(jobID, progress, &checkpoint{Phase: phaseWalk})
+			return
+		}
 		if err != nil {
-			return nil // skip errors
+			progress.mu.Lock()
+			progress.Status = "failed"
+			progress.Error = fmt.Sprintf("failed to walk directory: %v", err)
+			progress.mu.Unlock()
+			s.db.FailScanJob(jobID, progress.Error)
+			return
 		}
 
-		// Skip @eaDir directories (Synology)
-		if d.IsDir() && d.Name() == "@eaDir" {
-			return fs.SkipDir
+		totalFiles = walked
+		log.Printf("Job %s: Found %d total files", jobID, totalFiles)
+
+		buckets = candidateBuckets(sizeMap)
+
+		// Files with a unique size can't have duplicates
+		processed = totalFiles
+		for _, b := range buckets {
+			processed -= len(b.Paths)
 		}
+	}
 
-		if !d.IsDir() {
-			info, err := d.Info()
+	progress.mu.Lock()
+	progress.TotalFiles = totalFiles
+	progress.Processed = processed
+	progress.GroupsFound = len(duplicateGroups)
+	progress.mu.Unlock()
+	s.db.UpdateScanJobProgress(jobID, processed, totalFiles)
+
+	// Phase 2: Find duplicates by hashing files with same size
+	// Unchanged files reuse the hash computed by a previous scan
+	if opts.Verify == "" {
+		opts.Verify = VerifyQuick
+	}
+	cache := newHashCache(s.db, opts.Verify, opts.ForceRehash)
+	limiter := newRateLimiter(opts.ReadLimit)
+	defer cache.flush()
+
+	pending := buckets[position:]
+	checkpointed := processed
+	groupsFound := len(duplicateGroups)
+	bucketGroups := make(map[int][]DuplicateGroup)
+	cacheHits := 0
+
+	onResult := func(res hashResult) {
+		processed++
+		if res.err != nil {
+			log.Printf("Failed to hash %s: %v", res.path, res.err)
+		} else if res.cached {
+			cacheHits++
+		}
+
+		if processed%100 == 0 {
+			progress.mu.Lock()
+			progress.Processed = processed
+			progress.CacheHits = cacheHits
+			progress.mu.Unlock()
+			s.db.UpdateScanJobProgress(jobID, processed, totalFiles)
+		}
+	}
+
+	onBucket := func(index int, hashMap map[string][]string) {
+		// Group by device and inode for each hash group
+		var groups []DuplicateGroup
+		for _, hashPaths := range hashMap {
+			if len(hashPaths) < 2 {
+				continue
+			}
+			if opts.Verify != VerifyFull {
+				groups = append(groups, s.groupByDevice(pending[index].Size, hashPaths, opts)...)
+				continue
+			}
+			// Files with the same quick hash are only grouped when byte-identical
+			sets, err := splitIdentical(ctx, hashPaths, limiter)
 			if err != nil {
-				return nil
+				return // interrupted, the bucket isn't reported
 			}
+			for _, identical := range sets {
+				if len(identical) >= 2 {
+					groups = append(groups, s.groupByDevice(pending[index].Size, identical, opts)...)
+				}
+			}
+		}
+		bucketGroups[index] = groups
+		groupsFound += len(groups)
 
-			size := info.Size()
-			sizeMap[size] = append(sizeMap[size], path)
-			totalFiles++
+		progress.mu.Lock()
+		progress.GroupsFound = groupsFound
+		progress.mu.Unlock()
+	}
 
-			if totalFiles%1000 == 0 {
-				progress.mu.Lock()
-				progress.TotalFiles = totalFiles
-				progress.mu.Unlock()
-				s.db.UpdateScanJobProgress(jobID, 0, totalFiles)
-			}
+	log.Printf("Job %s: Hashing %d candidate files with %d worker(s)", jobID, totalFiles-processed, max(opts.Workers, 1))
+	s.hashBuckets(ctx, pending, opts, cache, limiter, onResult, onBucket)
+
+	cache.flush()
+	progress.mu.Lock()
+	progress.CacheHits = cacheHits
+	progress.mu.Unlock()
+	log.Printf("Job %s: %d hashes reused from cache", jobID, cacheHits)
+
+	// Buckets complete in any order with several workers: keep them in order
+	// so a checkpoint only has to remember how many are done
+	done := 0
+	for ; done < len(pending); done++ {
+		groups, ok := bucketGroups[done]
+		if !ok {
+			break
 		}
+		duplicateGroups = append(duplicateGroups, groups...)
+		checkpointed += len(pending[done].Paths)
+	}
 
-		return nil
+	if ctx.Err() != nil {
+		s.stop(jobID, progress, &checkpoint{
+			Phase:      phaseHash,
+			Buckets:    buckets,
+			Position:   position + done,
+			TotalFiles: totalFiles,
+			Processed:  checkpointed,
+			Groups:     duplicateGroups,
+		})
+		return
+	}
+
+	// Sort groups by size descending
+	sort.SliceStable(duplicateGroups, func(i, j int) bool {
+		return duplicateGroups[i].Size > duplicateGroups[j].Size
 	})
 
-	if err != nil {
+	// Persist results before reporting completion so they survive restarts
+	records := make([]storage.DuplicateGroupRecord, 0, len(duplicateGroups))
+	for i, g := range duplicateGroups {
+		records = append(records, storage.DuplicateGroupRecord{
+			GroupID:    i + 1,
+			Size:       g.Size,
+			Dev:        g.Dev,
+			Master:     g.Master,
+			Others:     g.Others,
+			InodeCount: g.InodeCount,
+			Verify:     string(g.Verify),
+		})
+	}
+
+	if err := s.db.CompleteScanJob(jobID, records); err != nil {
 		progress.mu.Lock()
 		progress.Status = "failed"
-		progress.Error = fmt.Sprintf("failed to walk directory: %v", err)
+		progress.Error = fmt.Sprintf("failed to save results: %v", err)
 		progress.mu.Unlock()
 		s.db.FailScanJob(jobID, progress.Error)
+		log.Printf("Job %s: Failed to save results: %v", jobID, err)
 		return
 	}
 
+	s.mu.Lock()
+	hooks := s.onComplete
+	s.mu.Unlock()
+	for _, hook := range hooks {
+		hook(jobID)
+	}
+
 	progress.mu.Lock()
-	progress.TotalFiles = totalFiles
+	progress.Status = "completed"
+	progress.Processed = totalFiles
+	progress.GroupsFound = len(duplicateGroups)
+	progress.Results = duplicateGroups
 	progress.mu.Unlock()
-	s.db.UpdateScanJobProgress(jobID, 0, totalFiles)
 
-	log.Printf("Job %s: Found %d total files", jobID, totalFiles)
+	log.Printf("Job %s: Completed. Found %d duplicate groups", jobID, len(duplicateGroups))
+}
 
-	// Phase 2: Find duplicates by hashing files with same size
-	var duplicateGroups []DuplicateGroup
-	processed := 0
+// collectFiles walks the scan roots and groups the files kept by the
+// filter rules by size
+func (s *Scanner) collectFiles(ctx context.Context, jobID string, progress *ScanProgress, opts ScanOptions) (map[int64][]string, int, error) {
+	roots, err := s.scanRoots(opts.Roots)
+	if err != nil {
+		return nil, 0, err
+	}
+	matcher, err := opts.Rules.Compile()
+	if err != nil {
+		return nil, 0, err
+	}
 
-	for size, paths := range sizeMap {
-		if len(paths) < 2 {
-			processed += len(paths)
-			continue
-		}
+	sizeMap := make(map[int64][]string)
+	totalFiles := 0
 
-		// Hash all files with this size
-		hashMap := make(map[string][]string)
-		for _, path := range paths {
-			hash, err := ComputeFileHash(path)
+	for _, root := range roots {
+		err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
 			if err != nil {
-				log.Printf("Failed to hash %s: %v", path, err)
-				processed++
-				continue
+				return nil // skip errors
 			}
 
-			hashMap[hash] = append(hashMap[hash], path)
-			processed++
-
-			if processed%100 == 0 {
-				progress.mu.Lock()
-				progress.Processed = processed
-				progress.mu.Unlock()
-				s.db.UpdateScanJobProgress(jobID, processed, totalFiles)
+			if ctxErr := ctx.Err(); ctxErr != nil {
+				return ctxErr
 			}
-		}
 
-		// Group by inode for each hash group
-		for _, hashPaths := range hashMap {
-			if len(hashPaths) < 2 {
-				continue
+			// Skip @eaDir directories (Synology) and the trash
+			if d.IsDir() && fsutil.SkipDir(d.Name()) {
+				return fs.SkipDir
 			}
 
-			// Group by inode
-			inodeMap := make(map[uint64][]string)
-			for _, path := range hashPaths {
-				stat, err := os.Stat(path)
-				if err != nil {
-					continue
-				}
+			rel := s.relPath(path)
 
-				var inode uint64
-				if sys := stat.Sys(); sys != nil {
-					if statT, ok := sys.(*syscall.Stat_t); ok {
-						inode = statT.Ino
-					}
+			if d.IsDir() {
+				if path != root && matcher.Excluded(rel) {
+					return fs.SkipDir
 				}
-
-				inodeMap[inode] = append(inodeMap[inode], path)
+				return nil
 			}
 
-			// Only create a group if there are multiple inodes
-			if len(inodeMap) < 2 {
-				continue
+			info, err := d.Info()
+			if err != nil {
+				return nil
 			}
 
-			// Sort inodes and select first as master
-			var inodes []uint64
-			for ino := range inodeMap {
-				inodes = append(inodes, ino)
+			size := info.Size()
+			if !matcher.MatchFile(rel, size) {
+				return nil
 			}
-			sort.Slice(inodes, func(i, j int) bool { return inodes[i] < inodes[j] })
 
-			masterInode := inodes[0]
-			masterPaths := inodeMap[masterInode]
-			sort.Strings(masterPaths)
-			master := masterPaths[0]
+			sizeMap[size] = append(sizeMap[size], path)
+			totalFiles++
 
-			// Collect all other paths
-			var others []string
-			for _, ino := range inodes[1:] {
-				others = append(others, inodeMap[ino]...)
+			if totalFiles%1000 == 0 {
+				progress.mu.Lock()
+				progress.TotalFiles = totalFiles
+				progress.mu.Unlock()
+				s.db.UpdateScanJobProgress(jobID, 0, totalFiles)
 			}
-			sort.Strings(others)
 
-			if len(others) == 0 {
+			return nil
+		})
+		if err != nil {
+			return nil, 0, err
+		}
+	}
+
+	return sizeMap, totalFiles, nil
+}
+
+// scanRoots resolves the scan roots to absolute directories inside the data
+// root, dropping the roots nested in another one
+func (s *Scanner) scanRoots(roots []string) ([]string, error) {
+	if len(roots) == 0 {
+		return []string{s.dataRoot}, nil
+	}
+
+	var resolved []string
+	for _, root := range roots {
+		abs := filepath.Clean(filepath.Join(s.dataRoot, strings.TrimPrefix(root, "/")))
+		if abs != s.dataRoot && !strings.HasPrefix(abs, s.dataRoot+string(filepath.Separator)) {
+			return nil, fmt.Errorf("%w: root %s is outside the data root", ErrInvalidOptions, root)
+		}
+
+		info, err := os.Stat(abs)
+		if err != nil || !info.IsDir() {
+			return nil, fmt.Errorf("%w: root %s is not a directory", ErrInvalidOptions, root)
+		}
+		resolved = append(resolved, abs)
+	}
+
+	// Parents sort before their children
+	sort.Strings(resolved)
+	var kept []string
+	for _, root := range resolved {
+		if len(kept) > 0 {
+			last := kept[len(kept)-1]
+			if root == last || strings.HasPrefix(root, last+string(filepath.Separator)) {
 				continue
 			}
+		}
+		kept = append(kept, root)
+	}
 
-			// Convert absolute paths to relative
-			relMaster, _ := filepath.Rel(s.dataRoot, master)
-			if relMaster != "" {
-				master = "/" + relMaster
-			}
+	return kept, nil
+}
 
-			var relOthers []string
-			for _, o := range others {
-				relO, _ := filepath.Rel(s.dataRoot, o)
-				if relO != "" {
-					relOthers = append(relOthers, "/"+relO)
-				} else {
-					relOthers = append(relOthers, o)
-				}
-			}
+// relPath returns the "/"-prefixed path of a file relative to the data root
+func (s *Scanner) relPath(path string) string {
+	rel, err := filepath.Rel(s.dataRoot, path)
+	if err != nil || rel == "." {
+		return "/"
+	}
+	return "/" + filepath.ToSlash(rel)
+}
 
-			duplicateGroups = append(duplicateGroups, DuplicateGroup{
-				Size:       size,
-				SizeHuman:  humanSize(size),
-				Master:     master,
-				Others:     relOthers,
-				InodeCount: len(inodeMap),
-			})
+// groupByDevice splits files with identical content into one duplicate group
+// per device. Inode numbers are only unique per device and hardlinks can't
+// span filesystems, so files on different devices never share a group.
+func (s *Scanner) groupByDevice(size int64, paths []string, opts ScanOptions) []DuplicateGroup {
+	byDevice := make(map[uint64][]masterCandidate)
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			continue
+		}
 
-			progress.mu.Lock()
-			progress.GroupsFound = len(duplicateGroups)
-			progress.mu.Unlock()
+		dev, _, _, ok := fsutil.Identity(info)
+		if !ok {
+			continue
+		}
+
+		if candidate, ok := newCandidate(s.relPath(path), info); ok {
+			byDevice[dev] = append(byDevice[dev], candidate)
 		}
 	}
 
-	// Sort groups by size descending
-	sort.Slice(duplicateGroups, func(i, j int) bool {
-		return duplicateGroups[i].Size > duplicateGroups[j].Size
-	})
+	var devices []uint64
+	for dev := range byDevice {
+		devices = append(devices, dev)
+	}
+	sort.Slice(devices, func(i, j int) bool { return devices[i] < devices[j] })
 
-	progress.mu.Lock()
-	progress.Status = "completed"
-	progress.Processed = totalFiles
-	progress.GroupsFound = len(duplicateGroups)
-	progress.Results = duplicateGroups
-	progress.mu.Unlock()
+	var groups []DuplicateGroup
+	for _, dev := range devices {
+		if group, ok := buildGroup(size, dev, byDevice[dev], opts); ok {
+			group.Verify = opts.Verify
+			groups = append(groups, group)
+		}
+	}
 
-	s.db.CompleteScanJob(jobID, len(duplicateGroups))
-	log.Printf("Job %s: Completed. Found %d duplicate groups", jobID, len(duplicateGroups))
+	return groups
+}
+
+// buildGroup builds a duplicate group from the files of one device, keeping
+// the master selected by the scan's strategy
+func buildGroup(size int64, dev uint64, candidates []masterCandidate, opts ScanOptions) (DuplicateGroup, bool) {
+	// Only create a group if there are multiple inodes
+	inodes := make(map[uint64]bool)
+	for _, c := range candidates {
+		inodes[c.ino] = true
+	}
+	if len(inodes) < 2 {
+		return DuplicateGroup{}, false
+	}
+
+	master := candidates[pickMaster(candidates, opts.Master, opts.PreferPaths)]
+
+	// Collect the paths of the other inodes
+	var others []string
+	for _, c := range candidates {
+		if c.ino != master.ino {
+			others = append(others, c.path)
+		}
+	}
+	sort.Strings(others)
+
+	return DuplicateGroup{
+		Size:       size,
+		SizeHuman:  humanSize(size),
+		Dev:        dev,
+		Master:     master.path,
+		Others:     others,
+		InodeCount: len(inodes),
+	}, true
 }
 
 func humanSize(size int64) string {
@@ -0,0 +1,74 @@
+package scanner
+
+import (
+	"context"
+	"io"
+	"sync"
+	"time"
+)
+
+// rateLimiter caps the number of bytes read per second, shared by all workers
+type rateLimiter struct {
+	rate int64 // bytes per second
+
+	mu sync.Mutex
+	// next is the time at which the bytes granted so far are paid for
+	next time.Time
+}
+
+// newRateLimiter returns a limiter for the given rate, or nil when unlimited
+func newRateLimiter(bytesPerSecond int64) *rateLimiter {
+	if bytesPerSecond <= 0 {
+		return nil
+	}
+	return &rateLimiter{rate: bytesPerSecond}
+}
+
+// wait blocks until n more bytes may be read, or until ctx is done
+func (l *rateLimiter) wait(ctx context.Context, n int) error {
+	if l == nil || n <= 0 {
+		return nil
+	}
+
+	l.mu.Lock()
+	now := time.Now()
+	if l.next.Before(now) {
+		l.next = now
+	}
+	l.next = l.next.Add(time.Duration(int64(n) * int64(time.Second) / l.rate))
+	delay := l.next.Sub(now)
+	l.mu.Unlock()
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// throttledReader slows reads down to the limiter rate
+type throttledReader struct {
+	ctx     context.Context
+	r       io.Reader
+	limiter *rateLimiter
+}
+
+func (t *throttledReader) Read(p []byte) (int, error) {
+	n, err := t.r.Read(p)
+	if werr := t.limiter.wait(t.ctx, n); werr != nil {
+		return n, werr
+	}
+	return n, err
+}
+
+// throttle wraps r with the limiter, if any. Reads fail once ctx is done.
+func throttle(ctx context.Context, r io.Reader, limiter *rateLimiter) io.Reader {
+	if limiter == nil {
+		return r
+	}
+	return &throttledReader{ctx: ctx, r: r, limiter: limiter}
+}
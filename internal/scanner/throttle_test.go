@@ -0,0 +1,86 @@
+package scanner
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"testing"
+	"time"
+)
+
+// TestRateLimiter verifies that reads are spread over time at the limiter
+// rate, and stop once the context is done
+func TestRateLimiter(t *testing.T) {
+	if newRateLimiter(0) != nil || newRateLimiter(-1) != nil {
+		t.Error("Expected no limiter without a rate")
+	}
+
+	t.Run("unlimited", func(t *testing.T) {
+		var l *rateLimiter
+		if err := l.wait(context.Background(), 1<<30); err != nil {
+			t.Errorf("Expected a nil limiter not to wait, got %v", err)
+		}
+		r := bytes.NewReader(nil)
+		if throttle(context.Background(), r, nil) != io.Reader(r) {
+			t.Error("Expected the reader to be left as is")
+		}
+	})
+
+	t.Run("rate", func(t *testing.T) {
+		// 100 bytes at 1000 bytes per second take about 100ms, the first
+		// read included
+		l := newRateLimiter(1000)
+		start := time.Now()
+		n, err := io.Copy(io.Discard, throttle(context.Background(), io.LimitReader(zeros{}, 100), l))
+		if err != nil || n != 100 {
+			t.Fatalf("Expected 100 bytes, got %d, %v", n, err)
+		}
+		if elapsed := time.Since(start); elapsed < 90*time.Millisecond {
+			t.Errorf("Expected the read to be throttled, took %v", elapsed)
+		}
+	})
+
+	t.Run("shared", func(t *testing.T) {
+		// Two waits share the rate: the second one is paid after the first
+		l := newRateLimiter(1000)
+		start := time.Now()
+		done := make(chan error, 2)
+		for i := 0; i < 2; i++ {
+			go func() { done <- l.wait(context.Background(), 50) }()
+		}
+		for i := 0; i < 2; i++ {
+			if err := <-done; err != nil {
+				t.Fatalf("Failed to wait: %v", err)
+			}
+		}
+		if elapsed := time.Since(start); elapsed < 90*time.Millisecond {
+			t.Errorf("Expected the waits to add up, took %v", elapsed)
+		}
+	})
+
+	t.Run("canceled", func(t *testing.T) {
+		// A wait of an hour returns as soon as the context is done
+		l := newRateLimiter(1)
+		ctx, cancel := context.WithCancel(context.Background())
+		time.AfterFunc(10*time.Millisecond, cancel)
+		start := time.Now()
+		_, err := throttle(ctx, io.LimitReader(zeros{}, 3600), l).Read(make([]byte, 3600))
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("Expected the read to be canceled, got %v", err)
+		}
+		if elapsed := time.Since(start); elapsed > time.Second {
+			t.Errorf("Expected the cancelation to stop the wait, took %v", elapsed)
+		}
+	})
+}
+
+// zeros is an endless reader of zero bytes
+type zeros struct{}
+
+func (zeros) Read(p []byte) (int, error) {
+	for i := range p {
+		p[i] = 0
+	}
+	return len(p), nil
+}
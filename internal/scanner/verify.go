@@ -0,0 +1,237 @@
+package scanner
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+
+	"github.com/cespare/xxhash/v2"
+	"lukechampine.com/blake3"
+)
+
+// VerifyPolicy selects how file contents are compared, by the scanner to
+// group duplicates and before converting them to hardlinks
+type VerifyPolicy string
+
+const (
+	// VerifyQuick hashes small files fully and large files by their first and
+	// last 256 KB plus size. Fast, but misses changes in the middle of large files.
+	VerifyQuick VerifyPolicy = "quick"
+	// VerifySampled also hashes chunks spread across large files
+	VerifySampled VerifyPolicy = "sampled"
+	// VerifySHA256 hashes the full content with SHA-256
+	VerifySHA256 VerifyPolicy = "sha256"
+	// VerifyBLAKE3 hashes the full content with BLAKE3
+	VerifyBLAKE3 VerifyPolicy = "blake3"
+	// VerifyFull compares files byte by byte
+	VerifyFull VerifyPolicy = "full"
+)
+
+// SampleCount is the number of chunks hashed by the sampled policy
+const SampleCount = 16
+
+// ParseVerifyPolicy validates a policy name, empty meaning VerifyQuick
+func ParseVerifyPolicy(value string) (VerifyPolicy, error) {
+	switch policy := VerifyPolicy(value); policy {
+	case "":
+		return VerifyQuick, nil
+	case VerifyQuick, VerifySampled, VerifySHA256, VerifyBLAKE3, VerifyFull:
+		return policy, nil
+	default:
+		return "", fmt.Errorf("unknown verification policy %q", value)
+	}
+}
+
+// strength ranks the policies from the weakest check to the strongest
+var strength = map[VerifyPolicy]int{
+	VerifyQuick:   1,
+	VerifySampled: 2,
+	VerifySHA256:  3,
+	VerifyBLAKE3:  3,
+	VerifyFull:    4,
+}
+
+// Stronger returns the stronger of two policies, p when they are as strong.
+// An empty policy is the weakest.
+func (p VerifyPolicy) Stronger(other VerifyPolicy) VerifyPolicy {
+	if strength[other] > strength[p] {
+		return other
+	}
+	return p
+}
+
+// hashAlgo returns the name under which the policy's hashes are cached.
+// The full policy groups files by quick hash before comparing them.
+func (p VerifyPolicy) hashAlgo() string {
+	if p == VerifyFull || p == "" {
+		return string(VerifyQuick)
+	}
+	return string(p)
+}
+
+// computeHash hashes a file according to the policy, with reads throttled by
+// limiter (may be nil) until ctx is done
+func computeHash(ctx context.Context, path string, policy VerifyPolicy, limiter *rateLimiter) (string, error) {
+	switch policy {
+	case VerifySampled:
+		return computeSampledHash(ctx, path, limiter)
+	case VerifySHA256:
+		return computeFullHash(ctx, path, sha256.New(), limiter)
+	case VerifyBLAKE3:
+		return computeFullHash(ctx, path, blake3.New(32, nil), limiter)
+	default:
+		return computeFileHash(ctx, path, limiter)
+	}
+}
+
+// computeFullHash hashes the whole content of a file with h
+func computeFullHash(ctx context.Context, path string, h hash.Hash, limiter *rateLimiter) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to open file: %w", err)
+	}
+	defer file.Close()
+
+	if _, err := io.Copy(h, throttle(ctx, file, limiter)); err != nil {
+		return "", fmt.Errorf("failed to hash file: %w", err)
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// computeSampledHash hashes small files fully and SampleCount chunks evenly
+// spread across large files, including the first and last one
+func computeSampledHash(ctx context.Context, path string, limiter *rateLimiter) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to open file: %w", err)
+	}
+	defer file.Close()
+
+	stat, err := file.Stat()
+	if err != nil {
+		return "", fmt.Errorf("failed to stat file: %w", err)
+	}
+
+	size := stat.Size()
+	h := xxhash.New()
+
+	if size <= SmallFileThreshold {
+		if _, err := io.Copy(h, throttle(ctx, file, limiter)); err != nil {
+			return "", fmt.Errorf("failed to hash file: %w", err)
+		}
+		return fmt.Sprintf("%016x", h.Sum64()), nil
+	}
+
+	buf := make([]byte, PartialHashSize)
+	step := (size - PartialHashSize) / (SampleCount - 1)
+	for i := int64(0); i < SampleCount; i++ {
+		n, err := file.ReadAt(buf, i*step)
+		if err != nil && err != io.EOF {
+			return "", fmt.Errorf("failed to read sample %d: %w", i, err)
+		}
+		if err := limiter.wait(ctx, n); err != nil {
+			return "", err
+		}
+		h.Write(buf[:n])
+	}
+
+	h.Write([]byte(fmt.Sprintf("SIZE:%d", size)))
+	return fmt.Sprintf("%016x", h.Sum64()), nil
+}
+
+// VerifyFiles checks that two files have the same content according to the policy
+func VerifyFiles(path1, path2 string, policy VerifyPolicy) (bool, error) {
+	stat1, err := os.Stat(path1)
+	if err != nil {
+		return false, err
+	}
+
+	stat2, err := os.Stat(path2)
+	if err != nil {
+		return false, err
+	}
+
+	// Size must match
+	if stat1.Size() != stat2.Size() {
+		return false, nil
+	}
+
+	// If they're already the same inode, they're identical
+	if os.SameFile(stat1, stat2) {
+		return true, nil
+	}
+
+	switch policy {
+	case VerifyFull:
+		return compareFiles(context.Background(), path1, path2, nil)
+	case VerifyQuick, "":
+		// Small files are cheap enough to compare fully
+		if stat1.Size() <= SmallFileThreshold {
+			return compareFiles(context.Background(), path1, path2, nil)
+		}
+	}
+
+	hash1, err := computeHash(context.Background(), path1, policy, nil)
+	if err != nil {
+		return false, err
+	}
+
+	hash2, err := computeHash(context.Background(), path2, policy, nil)
+	if err != nil {
+		return false, err
+	}
+
+	return hash1 == hash2, nil
+}
+
+// splitIdentical partitions files sharing a hash into sets of byte-identical
+// files. Paths of the same inode are not compared again. It fails once ctx
+// is done, the partition being incomplete.
+func splitIdentical(ctx context.Context, paths []string, limiter *rateLimiter) ([][]string, error) {
+	type class struct {
+		info  os.FileInfo
+		paths []string
+	}
+
+	var classes []*class
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			continue
+		}
+
+		matched := false
+		for _, c := range classes {
+			same := os.SameFile(c.info, info)
+			if !same {
+				identical, err := compareFiles(ctx, c.paths[0], path, limiter)
+				if ctx.Err() != nil {
+					return nil, ctx.Err()
+				}
+				if err != nil {
+					continue
+				}
+				same = identical
+			}
+			if same {
+				c.paths = append(c.paths, path)
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			classes = append(classes, &class{info: info, paths: []string{path}})
+		}
+	}
+
+	result := make([][]string, 0, len(classes))
+	for _, c := range classes {
+		result = append(result, c.paths)
+	}
+	return result, nil
+}
@@ -0,0 +1,138 @@
+package scanner
+
+import (
+	"context"
+	"log"
+	"runtime"
+	"sort"
+	"sync"
+)
+
+// sizeBucket holds the candidate files sharing the same size
+type sizeBucket struct {
+	Size  int64    `json:"size"`
+	Paths []string `json:"paths"`
+}
+
+// hashTask is a single file to hash
+type hashTask struct {
+	bucket int
+	path   string
+}
+
+// hashResult is the outcome of hashing one file
+type hashResult struct {
+	bucket int
+	path   string
+	hash   string
+	cached bool
+	err    error
+}
+
+// candidateBuckets keeps the sizes shared by at least two files, largest first
+func candidateBuckets(sizeMap map[int64][]string) []sizeBucket {
+	var buckets []sizeBucket
+	for size, paths := range sizeMap {
+		if len(paths) < 2 {
+			continue
+		}
+		sort.Strings(paths)
+		buckets = append(buckets, sizeBucket{Size: size, Paths: paths})
+	}
+
+	sort.Slice(buckets, func(i, j int) bool { return buckets[i].Size > buckets[j].Size })
+	return buckets
+}
+
+// hashBuckets hashes every file of the buckets with a pool of workers.
+// onResult is called for each hashed file and onBucket once every file of a
+// bucket has been hashed, both from the calling goroutine. When ctx is done
+// no new file is started and the buckets left incomplete are not reported.
+func (s *Scanner) hashBuckets(ctx context.Context, buckets []sizeBucket, opts ScanOptions, cache *hashCache, limiter *rateLimiter,
+	onResult func(hashResult), onBucket func(index int, hashes map[string][]string)) {
+
+	workers := opts.Workers
+	if workers < 1 {
+		workers = 1
+	}
+
+	tasks := make(chan hashTask)
+	results := make(chan hashResult)
+
+	// Producer: feed files in bucket order
+	go func() {
+		defer close(tasks)
+		for i, b := range buckets {
+			for _, path := range b.Paths {
+				select {
+				case tasks <- hashTask{bucket: i, path: path}:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			if opts.LowPriority {
+				// I/O priority applies to the OS thread: keep this worker on its
+				// own thread, which is discarded when the goroutine exits
+				runtime.LockOSThread()
+				if err := setLowPriority(); err != nil {
+					log.Printf("Failed to lower scan worker priority: %v", err)
+				}
+			}
+
+			for task := range tasks {
+				if ctx.Err() != nil {
+					continue // drain remaining tasks
+				}
+				hash, cached, err := cache.hash(ctx, task.path, limiter)
+				if err != nil && ctx.Err() != nil {
+					continue // interrupted, its bucket stays incomplete
+				}
+				results <- hashResult{
+					bucket: task.bucket,
+					path:   task.path,
+					hash:   hash,
+					cached: cached,
+					err:    err,
+				}
+			}
+		}()
+	}
+
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	// Collector: group hashes per bucket as results come in
+	hashMaps := make(map[int]map[string][]string)
+	remaining := make([]int, len(buckets))
+	for i, b := range buckets {
+		remaining[i] = len(b.Paths)
+	}
+
+	for res := range results {
+		onResult(res)
+
+		if res.err == nil {
+			if hashMaps[res.bucket] == nil {
+				hashMaps[res.bucket] = make(map[string][]string)
+			}
+			hashMaps[res.bucket][res.hash] = append(hashMaps[res.bucket][res.hash], res.path)
+		}
+
+		remaining[res.bucket]--
+		if remaining[res.bucket] == 0 {
+			onBucket(res.bucket, hashMaps[res.bucket])
+			delete(hashMaps, res.bucket)
+		}
+	}
+}
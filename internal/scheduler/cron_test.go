@@ -0,0 +1,106 @@
+package scheduler
+
+import (
+	"testing"
+	"time"
+)
+
+// TestParseCron verifies cron expressions and their next run
+func TestParseCron(t *testing.T) {
+	from := time.Date(2024, time.March, 15, 10, 30, 0, 0, time.UTC) // a Friday
+
+	tests := []struct {
+		name string
+		expr string
+		next time.Time
+	}{
+		{"step", "*/15 * * * *", time.Date(2024, time.March, 15, 10, 45, 0, 0, time.UTC)},
+		{"strictly after", "30 10 * * *", time.Date(2024, time.March, 16, 10, 30, 0, 0, time.UTC)},
+		{"daily", "30 3 * * *", time.Date(2024, time.March, 16, 3, 30, 0, 0, time.UTC)},
+		{"nightly", "@nightly", time.Date(2024, time.March, 16, 3, 0, 0, 0, time.UTC)},
+		{"macro case", "@DAILY", time.Date(2024, time.March, 16, 0, 0, 0, 0, time.UTC)},
+		{"weekly", "@weekly", time.Date(2024, time.March, 17, 0, 0, 0, 0, time.UTC)},
+		{"weekdays", "0 12 * * 1-5", time.Date(2024, time.March, 15, 12, 0, 0, 0, time.UTC)},
+		{"7 is sunday", "0 0 * * 7", time.Date(2024, time.March, 17, 0, 0, 0, 0, time.UTC)},
+		{"day of month or sunday", "0 0 1 * 7", time.Date(2024, time.March, 17, 0, 0, 0, 0, time.UTC)},
+		{"day of month or monday", "0 0 16 * 1", time.Date(2024, time.March, 16, 0, 0, 0, 0, time.UTC)},
+		{"day of month and any day of week", "0 0 16 * *", time.Date(2024, time.March, 16, 0, 0, 0, 0, time.UTC)},
+		{"any day of month and monday", "0 0 * * 1", time.Date(2024, time.March, 18, 0, 0, 0, 0, time.UTC)},
+		{"day of month step and monday", "0 3 */2 * 1", time.Date(2024, time.March, 25, 3, 0, 0, 0, time.UTC)},
+		{"value step", "5/15 * * * *", time.Date(2024, time.March, 15, 10, 35, 0, 0, time.UTC)},
+		{"range step", "0 1-5/2 * * *", time.Date(2024, time.March, 16, 1, 0, 0, 0, time.UTC)},
+		{"list", "0 9,18 * * *", time.Date(2024, time.March, 15, 18, 0, 0, 0, time.UTC)},
+		{"last day of month", "0 0 31 * *", time.Date(2024, time.March, 31, 0, 0, 0, 0, time.UTC)},
+		{"skipped months", "0 0 31 4-6 *", time.Date(2024, time.May, 31, 0, 0, 0, 0, time.UTC)},
+		{"leap day", "0 0 29 2 *", time.Date(2028, time.February, 29, 0, 0, 0, 0, time.UTC)},
+		{"never", "0 0 30 2 *", time.Time{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cron, err := ParseCron(tt.expr)
+			if err != nil {
+				t.Fatalf("Failed to parse %q: %v", tt.expr, err)
+			}
+			if next := cron.Next(from); !next.Equal(tt.next) {
+				t.Errorf("Expected next run of %q %v, got %v", tt.expr, tt.next, next)
+			}
+		})
+	}
+
+	invalid := []string{
+		"",
+		"* * * *",
+		"* * * * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"*/0 * * * *",
+		"1/x * * * *",
+		"5-1 * * * *",
+		"1- * * * *",
+		"a * * * *",
+		"1,,2 * * * *",
+		"@often",
+	}
+	for _, expr := range invalid {
+		if _, err := ParseCron(expr); err == nil {
+			t.Errorf("Expected %q to be rejected", expr)
+		}
+	}
+}
+
+// TestParseField verifies the values allowed by a field
+func TestParseField(t *testing.T) {
+	tests := []struct {
+		field  string
+		min    int
+		max    int
+		values []int
+	}{
+		{"*", 0, 3, []int{0, 1, 2, 3}},
+		{"5/15", 0, 59, []int{5, 20, 35, 50}},
+		{"*/20", 0, 59, []int{0, 20, 40}},
+		{"10-20/5", 0, 59, []int{10, 15, 20}},
+		{"1,3-4,6", 0, 7, []int{1, 3, 4, 6}},
+		{"7", 0, 7, []int{7}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.field, func(t *testing.T) {
+			bits, err := parseField(tt.field, tt.min, tt.max)
+			if err != nil {
+				t.Fatalf("Failed to parse: %v", err)
+			}
+			var want uint64
+			for _, v := range tt.values {
+				want |= 1 << uint(v)
+			}
+			if bits != want {
+				t.Errorf("Expected %b, got %b", want, bits)
+			}
+		})
+	}
+}
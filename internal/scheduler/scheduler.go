@@ -0,0 +1,145 @@
+package scheduler
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/gosiva/hardlink-ui/internal/scanner"
+	"github.com/gosiva/hardlink-ui/internal/storage"
+)
+
+// tickInterval is how often due schedules are checked
+const tickInterval = time.Minute
+
+// Scheduler starts the duplicate scans of the schedules stored in the
+// database when they are due
+type Scheduler struct {
+	db      *storage.DB
+	scanner *scanner.Scanner
+}
+
+// NewScheduler creates a new scheduler instance
+func NewScheduler(db *storage.DB, scan *scanner.Scanner) *Scheduler {
+	return &Scheduler{
+		db:      db,
+		scanner: scan,
+	}
+}
+
+// Run checks the schedules every minute until ctx is done. A run missed
+// while the server was stopped is started on the first check.
+func (s *Scheduler) Run(ctx context.Context) {
+	s.RunDue()
+
+	ticker := time.NewTicker(tickInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			s.RunDue()
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// RunDue starts the scans of the enabled schedules whose next run has passed
+func (s *Scheduler) RunDue() {
+	schedules, err := s.db.ListScanSchedules()
+	if err != nil {
+		log.Printf("Scheduler: failed to list schedules: %v", err)
+		return
+	}
+
+	now := time.Now()
+	for i := range schedules {
+		schedule := &schedules[i]
+		if !schedule.Enabled {
+			continue
+		}
+
+		// Schedules created without a next run get one now
+		if !schedule.NextRunAt.Valid {
+			next, err := NextRun(schedule.Cron, now)
+			if err != nil {
+				log.Printf("Scheduler: schedule %d has an invalid cron expression: %v", schedule.ID, err)
+				continue
+			}
+			schedule.NextRunAt = next
+			if err := s.db.UpdateScanSchedule(schedule); err != nil {
+				log.Printf("Scheduler: failed to update schedule %d: %v", schedule.ID, err)
+			}
+			continue
+		}
+
+		if schedule.NextRunAt.Int64 > now.Unix() {
+			continue
+		}
+
+		if err := s.start(schedule, now); err != nil {
+			log.Printf("Scheduler: schedule %d (%s): %v", schedule.ID, schedule.Name, err)
+		}
+	}
+}
+
+// start launches the scan of a due schedule and records its next run
+func (s *Scheduler) start(schedule *storage.ScanSchedule, now time.Time) error {
+	next, err := NextRun(schedule.Cron, now)
+	if err != nil {
+		return err
+	}
+
+	// Don't stack scans when the previous one is still running or paused
+	if schedule.LastJobID.Valid {
+		job, err := s.db.GetScanJob(schedule.LastJobID.String)
+		if err != nil {
+			return err
+		}
+		if job != nil && (job.Status == "running" || job.Status == "paused") {
+			log.Printf("Scheduler: schedule %d skipped, job %s is %s", schedule.ID, job.JobID, job.Status)
+			return s.db.RecordScheduleRun(schedule.ID, job.JobID, schedule.LastRunAt.Int64, next)
+		}
+	}
+
+	var opts scanner.ScanOptions
+	if err := json.Unmarshal([]byte(schedule.Options), &opts); err != nil {
+		return fmt.Errorf("failed to decode scan options: %w", err)
+	}
+
+	jobID, err := scanner.NewJobID()
+	if err != nil {
+		return err
+	}
+
+	// Record the run first so a failing scan isn't retried every minute
+	if err := s.db.RecordScheduleRun(schedule.ID, jobID, now.Unix(), next); err != nil {
+		return err
+	}
+
+	if err := s.scanner.StartScanWithOptions(jobID, opts); err != nil {
+		return fmt.Errorf("failed to start scan: %w", err)
+	}
+
+	log.Printf("DUPSCAN START job=%s schedule=%d (%s)", jobID, schedule.ID, schedule.Name)
+	return nil
+}
+
+// NextRun returns the next run of a cron expression after t, as stored in the
+// database
+func NextRun(expr string, t time.Time) (sql.NullInt64, error) {
+	cron, err := ParseCron(expr)
+	if err != nil {
+		return sql.NullInt64{}, err
+	}
+
+	next := cron.Next(t)
+	if next.IsZero() {
+		return sql.NullInt64{}, nil
+	}
+	return sql.NullInt64{Int64: next.Unix(), Valid: true}, nil
+}
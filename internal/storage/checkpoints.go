@@ -0,0 +1,68 @@
+package storage
+
+import (
+	"database/sql"
+	"time"
+)
+
+// ScanCheckpoint is the saved state of a paused scan job
+type ScanCheckpoint struct {
+	JobID      string
+	Phase      string // "walk" or "hash"
+	SizeMap    string // JSON candidate files grouped by size
+	Position   int    // size groups fully hashed
+	TotalFiles int
+	Processed  int
+	Groups     string // JSON duplicate groups found so far
+}
+
+// PauseScanJob saves the checkpoint of a scan job and marks it as paused
+func (db *DB) PauseScanJob(cp ScanCheckpoint) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	now := time.Now().Unix()
+	if _, err := tx.Exec(`
+		INSERT INTO scan_checkpoints (job_id, phase, size_map, position, total_files, processed, groups, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(job_id) DO UPDATE SET
+			phase = excluded.phase,
+			size_map = excluded.size_map,
+			position = excluded.position,
+			total_files = excluded.total_files,
+			processed = excluded.processed,
+			groups = excluded.groups,
+			updated_at = excluded.updated_at
+	`, cp.JobID, cp.Phase, cp.SizeMap, cp.Position, cp.TotalFiles, cp.Processed, cp.Groups, now); err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(`
+		UPDATE scan_jobs SET status = 'paused', progress = ?, total_files = ? WHERE job_id = ?
+	`, cp.Processed, cp.TotalFiles, cp.JobID); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// GetScanCheckpoint retrieves the checkpoint of a paused scan job
+func (db *DB) GetScanCheckpoint(jobID string) (*ScanCheckpoint, error) {
+	cp := &ScanCheckpoint{}
+	err := db.QueryRow(`
+		SELECT job_id, phase, size_map, position, total_files, processed, groups
+		FROM scan_checkpoints WHERE job_id = ?
+	`, jobID).Scan(&cp.JobID, &cp.Phase, &cp.SizeMap, &cp.Position, &cp.TotalFiles, &cp.Processed, &cp.Groups)
+
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return cp, nil
+}
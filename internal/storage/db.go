@@ -67,26 +67,184 @@ func (db *DB) initSchema() error {
 	CREATE INDEX IF NOT EXISTS idx_sessions_username ON sessions(username);
 	CREATE INDEX IF NOT EXISTS idx_sessions_last_active ON sessions(last_active);
 
-	-- Inode index table for persistence
+	-- Inode index table for persistence (inode numbers are only unique per device),
+	-- with the file attributes searched by /api/search
 	CREATE TABLE IF NOT EXISTS inode_index (
+		id INTEGER PRIMARY KEY,
+		dev INTEGER NOT NULL,
 		inode INTEGER NOT NULL,
 		path TEXT NOT NULL,
+		name TEXT NOT NULL DEFAULT '', -- lowercased file name
+		size INTEGER NOT NULL DEFAULT 0,
+		mtime INTEGER NOT NULL DEFAULT 0,
+		nlink INTEGER NOT NULL DEFAULT 0,
 		last_seen INTEGER NOT NULL,
-		PRIMARY KEY (inode, path)
+		UNIQUE (dev, inode, path)
 	);
 
-	CREATE INDEX IF NOT EXISTS idx_inode ON inode_index(inode);
+	CREATE INDEX IF NOT EXISTS idx_inode ON inode_index(dev, inode);
+	CREATE INDEX IF NOT EXISTS idx_inode_path ON inode_index(path);
+	CREATE INDEX IF NOT EXISTS idx_inode_last_seen ON inode_index(last_seen);
+	CREATE INDEX IF NOT EXISTS idx_inode_size ON inode_index(size);
+	CREATE INDEX IF NOT EXISTS idx_inode_mtime ON inode_index(mtime);
+
+	-- Full-text index of the inode index paths, kept in sync by triggers
+	CREATE VIRTUAL TABLE IF NOT EXISTS path_search USING fts4(content="inode_index", path, tokenize=unicode61);
+
+	CREATE TRIGGER IF NOT EXISTS inode_index_search_insert AFTER INSERT ON inode_index BEGIN
+		INSERT INTO path_search(docid, path) VALUES (new.id, new.path);
+	END;
+	CREATE TRIGGER IF NOT EXISTS inode_index_search_delete BEFORE DELETE ON inode_index BEGIN
+		DELETE FROM path_search WHERE docid = old.id;
+	END;
+	CREATE TRIGGER IF NOT EXISTS inode_index_search_before_move BEFORE UPDATE OF path ON inode_index BEGIN
+		DELETE FROM path_search WHERE docid = old.id;
+	END;
+	CREATE TRIGGER IF NOT EXISTS inode_index_search_after_move AFTER UPDATE OF path ON inode_index BEGIN
+		INSERT INTO path_search(docid, path) VALUES (new.id, new.path);
+	END;
+
+	-- Application state (key/value)
+	CREATE TABLE IF NOT EXISTS app_state (
+		key TEXT PRIMARY KEY,
+		value TEXT NOT NULL,
+		updated_at INTEGER NOT NULL
+	);
 
 	-- Duplicate scan jobs
 	CREATE TABLE IF NOT EXISTS scan_jobs (
 		job_id TEXT PRIMARY KEY,
-		status TEXT NOT NULL, -- 'running', 'completed', 'failed'
+		status TEXT NOT NULL, -- 'running', 'paused', 'completed', 'failed', 'cancelled'
 		progress INTEGER NOT NULL DEFAULT 0,
 		total_files INTEGER NOT NULL DEFAULT 0,
 		groups_found INTEGER NOT NULL DEFAULT 0,
 		started_at INTEGER NOT NULL,
 		completed_at INTEGER,
-		error TEXT
+		error TEXT,
+		options TEXT NOT NULL DEFAULT '{}' -- JSON scan options
+	);
+
+	-- Recurring duplicate scans
+	CREATE TABLE IF NOT EXISTS scan_schedules (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		name TEXT NOT NULL,
+		cron TEXT NOT NULL,
+		options TEXT NOT NULL DEFAULT '{}', -- JSON scan options
+		enabled INTEGER NOT NULL DEFAULT 1,
+		next_run_at INTEGER,
+		last_run_at INTEGER,
+		last_job_id TEXT,
+		created_at INTEGER NOT NULL
+	);
+
+	-- Automatic duplicate conversion rules, evaluated in ID order
+	CREATE TABLE IF NOT EXISTS conversion_rules (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		name TEXT NOT NULL,
+		conditions TEXT NOT NULL DEFAULT '{}', -- JSON rule conditions and settings
+		enabled INTEGER NOT NULL DEFAULT 1,
+		auto_apply INTEGER NOT NULL DEFAULT 0,
+		created_at INTEGER NOT NULL
+	);
+
+	-- Conversion rule reports of scan jobs
+	CREATE TABLE IF NOT EXISTS conversion_reports (
+		job_id TEXT PRIMARY KEY,
+		report TEXT NOT NULL, -- JSON report
+		created_at INTEGER NOT NULL,
+		FOREIGN KEY (job_id) REFERENCES scan_jobs(job_id) ON DELETE CASCADE
+	);
+
+	-- Background jobs of bulk file operations
+	CREATE TABLE IF NOT EXISTS jobs (
+		job_id TEXT PRIMARY KEY,
+		kind TEXT NOT NULL, -- 'convert' or 'link-folder'
+		status TEXT NOT NULL, -- 'running', 'completed', 'failed', 'cancelled'
+		total INTEGER NOT NULL DEFAULT 0,
+		processed INTEGER NOT NULL DEFAULT 0,
+		failed INTEGER NOT NULL DEFAULT 0,
+		params TEXT NOT NULL DEFAULT '{}', -- JSON request
+		report TEXT NOT NULL DEFAULT '', -- JSON final report
+		error TEXT,
+		username TEXT NOT NULL DEFAULT '',
+		started_at INTEGER NOT NULL,
+		completed_at INTEGER
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_jobs_started ON jobs(started_at);
+
+	-- Journal of file operations, for undo
+	CREATE TABLE IF NOT EXISTS operations (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		kind TEXT NOT NULL, -- 'link', 'replace', 'unlink' or 'rename'
+		path TEXT NOT NULL,
+		target TEXT NOT NULL DEFAULT '', -- link source, master or rename destination
+		dev INTEGER NOT NULL DEFAULT 0,
+		inode INTEGER NOT NULL DEFAULT 0,
+		size INTEGER NOT NULL DEFAULT 0,
+		metadata TEXT NOT NULL DEFAULT '', -- JSON metadata of a replaced file
+		username TEXT NOT NULL DEFAULT '',
+		created_at INTEGER NOT NULL,
+		undone_at INTEGER
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_operations_path ON operations(path);
+
+	-- Deleted files and folders kept in the trash of their device
+	CREATE TABLE IF NOT EXISTS trash_items (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		path TEXT NOT NULL, -- original path
+		trash_path TEXT NOT NULL UNIQUE,
+		dev INTEGER NOT NULL DEFAULT 0,
+		inode INTEGER NOT NULL DEFAULT 0,
+		size INTEGER NOT NULL DEFAULT 0, -- total size of a folder
+		is_dir INTEGER NOT NULL DEFAULT 0,
+		username TEXT NOT NULL DEFAULT '',
+		deleted_at INTEGER NOT NULL
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_trash_items_inode ON trash_items(dev, inode);
+	CREATE INDEX IF NOT EXISTS idx_trash_items_deleted_at ON trash_items(deleted_at);
+
+	-- Inodes of the regular files of the trash items, folder contents included
+	CREATE TABLE IF NOT EXISTS trash_links (
+		item_id INTEGER NOT NULL,
+		dev INTEGER NOT NULL,
+		inode INTEGER NOT NULL,
+		FOREIGN KEY (item_id) REFERENCES trash_items(id) ON DELETE CASCADE
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_trash_links_inode ON trash_links(dev, inode);
+	CREATE INDEX IF NOT EXISTS idx_trash_links_item ON trash_links(item_id);
+
+	-- Conversions in progress, repaired at startup after a crash
+	CREATE TABLE IF NOT EXISTS replace_intents (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		path TEXT NOT NULL,
+		tmp_path TEXT NOT NULL UNIQUE,
+		master TEXT NOT NULL,
+		master_dev INTEGER NOT NULL,
+		master_inode INTEGER NOT NULL,
+		dev INTEGER NOT NULL, -- identity of the replaced file
+		inode INTEGER NOT NULL,
+		size INTEGER NOT NULL DEFAULT 0,
+		metadata TEXT NOT NULL DEFAULT '',
+		username TEXT NOT NULL DEFAULT '',
+		step TEXT NOT NULL, -- 'link' or 'rename'
+		created_at INTEGER NOT NULL
+	);
+
+	-- Checkpoints of paused scan jobs
+	CREATE TABLE IF NOT EXISTS scan_checkpoints (
+		job_id TEXT PRIMARY KEY,
+		phase TEXT NOT NULL, -- 'walk' or 'hash'
+		size_map TEXT NOT NULL DEFAULT '[]', -- JSON candidate files grouped by size
+		position INTEGER NOT NULL DEFAULT 0, -- size groups fully hashed
+		total_files INTEGER NOT NULL DEFAULT 0,
+		processed INTEGER NOT NULL DEFAULT 0,
+		groups TEXT NOT NULL DEFAULT '[]', -- JSON duplicate groups found so far
+		updated_at INTEGER NOT NULL,
+		FOREIGN KEY (job_id) REFERENCES scan_jobs(job_id) ON DELETE CASCADE
 	);
 
 	-- Duplicate groups results
@@ -94,7 +252,10 @@ func (db *DB) initSchema() error {
 		job_id TEXT NOT NULL,
 		group_id INTEGER NOT NULL,
 		size INTEGER NOT NULL,
+		dev INTEGER NOT NULL DEFAULT 0,
 		master_path TEXT NOT NULL,
+		inode_count INTEGER NOT NULL DEFAULT 0,
+		verify TEXT NOT NULL DEFAULT '', -- verification policy that confirmed the group
 		FOREIGN KEY (job_id) REFERENCES scan_jobs(job_id) ON DELETE CASCADE,
 		PRIMARY KEY (job_id, group_id)
 	);
@@ -110,6 +271,19 @@ func (db *DB) initSchema() error {
 		PRIMARY KEY (job_id, group_id, path)
 	);
 
+	-- File hash cache: a hash is reused while size, mtime and ctime are unchanged
+	CREATE TABLE IF NOT EXISTS file_hashes (
+		dev INTEGER NOT NULL,
+		inode INTEGER NOT NULL,
+		size INTEGER NOT NULL,
+		mtime INTEGER NOT NULL, -- nanoseconds
+		ctime INTEGER NOT NULL, -- nanoseconds
+		algo TEXT NOT NULL, -- hash algorithm, see scanner.VerifyPolicy
+		hash TEXT NOT NULL,
+		hashed_at INTEGER NOT NULL,
+		PRIMARY KEY (dev, inode, algo)
+	);
+
 	-- Failed login attempts (anti-brute force)
 	CREATE TABLE IF NOT EXISTS failed_logins (
 		key TEXT PRIMARY KEY, -- ip:username
@@ -125,8 +299,112 @@ func (db *DB) initSchema() error {
 	);
 	`
 
-	_, err := db.Exec(schema)
-	return err
+	// Caches whose primary key changed are dropped and rebuilt, nothing is lost:
+	// the inode index gained the dev column then the id searched by
+	// path_search, the hash cache the algo column
+	droppedIndex, err := db.dropLegacyTable("inode_index", "id")
+	if err != nil {
+		return err
+	}
+	if _, err := db.dropLegacyTable("file_hashes", "algo"); err != nil {
+		return err
+	}
+
+	if _, err := db.Exec(schema); err != nil {
+		return err
+	}
+
+	// The dropped index must not be reported complete until rebuilt
+	if droppedIndex {
+		if err := db.DeleteState(IndexCompletedStateKey); err != nil {
+			return fmt.Errorf("failed to reset inode index state: %w", err)
+		}
+	}
+
+	return db.migrate()
+}
+
+// migrate adds columns introduced after the initial schema to existing databases
+func (db *DB) migrate() error {
+	columns := []struct {
+		table      string
+		column     string
+		definition string
+	}{
+		{"duplicate_groups", "inode_count", "INTEGER NOT NULL DEFAULT 0"},
+		{"duplicate_groups", "dev", "INTEGER NOT NULL DEFAULT 0"},
+		{"scan_jobs", "options", "TEXT NOT NULL DEFAULT '{}'"},
+		{"duplicate_groups", "verify", "TEXT NOT NULL DEFAULT ''"},
+	}
+
+	for _, c := range columns {
+		exists, err := db.columnExists(c.table, c.column)
+		if err != nil {
+			return fmt.Errorf("failed to inspect %s: %w", c.table, err)
+		}
+		if exists {
+			continue
+		}
+
+		if _, err := db.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", c.table, c.column, c.definition)); err != nil {
+			return fmt.Errorf("failed to add column %s.%s: %w", c.table, c.column, err)
+		}
+		log.Printf("Database migrated: added column %s.%s", c.table, c.column)
+	}
+
+	return nil
+}
+
+// dropLegacyTable drops a cache table created before column existed, so the
+// schema recreates it with its new primary key. It reports whether the table
+// was dropped.
+func (db *DB) dropLegacyTable(table, column string) (bool, error) {
+	var count int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = ?`, table).Scan(&count); err != nil {
+		return false, err
+	}
+	if count == 0 {
+		return false, nil
+	}
+
+	hasColumn, err := db.columnExists(table, column)
+	if err != nil || hasColumn {
+		return false, err
+	}
+
+	if _, err := db.Exec(fmt.Sprintf("DROP TABLE %s", table)); err != nil {
+		return false, fmt.Errorf("failed to drop legacy %s: %w", table, err)
+	}
+	log.Printf("Database migrated: dropped legacy %s, it will be rebuilt", table)
+	return true, nil
+}
+
+// columnExists reports whether a table has the given column
+func (db *DB) columnExists(table, column string) (bool, error) {
+	rows, err := db.Query(fmt.Sprintf("PRAGMA table_info(%s)", table))
+	if err != nil {
+		return false, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			cid       int
+			name      string
+			colType   string
+			notNull   int
+			dfltValue sql.NullString
+			pk        int
+		)
+		if err := rows.Scan(&cid, &name, &colType, &notNull, &dfltValue, &pk); err != nil {
+			return false, err
+		}
+		if name == column {
+			return true, nil
+		}
+	}
+
+	return false, rows.Err()
 }
 
 // Close closes the database connection
@@ -0,0 +1,106 @@
+package storage
+
+import (
+	"database/sql"
+)
+
+// DuplicateGroupRecord represents a persisted group of duplicate files
+type DuplicateGroupRecord struct {
+	GroupID    int
+	Size       int64
+	Dev        uint64
+	Master     string
+	Others     []string
+	InodeCount int
+	Verify     string // verification policy that confirmed the group
+}
+
+// saveDuplicateGroups replaces the stored groups of a job within a transaction
+func saveDuplicateGroups(tx *sql.Tx, jobID string, groups []DuplicateGroupRecord) error {
+	if _, err := tx.Exec(`DELETE FROM duplicate_members WHERE job_id = ?`, jobID); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`DELETE FROM duplicate_groups WHERE job_id = ?`, jobID); err != nil {
+		return err
+	}
+
+	groupStmt, err := tx.Prepare(`
+		INSERT INTO duplicate_groups (job_id, group_id, size, dev, master_path, inode_count, verify)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+	`)
+	if err != nil {
+		return err
+	}
+	defer groupStmt.Close()
+
+	memberStmt, err := tx.Prepare(`
+		INSERT OR IGNORE INTO duplicate_members (job_id, group_id, path)
+		VALUES (?, ?, ?)
+	`)
+	if err != nil {
+		return err
+	}
+	defer memberStmt.Close()
+
+	for _, group := range groups {
+		if _, err := groupStmt.Exec(jobID, group.GroupID, group.Size, group.Dev, group.Master, group.InodeCount, group.Verify); err != nil {
+			return err
+		}
+		for _, path := range group.Others {
+			if _, err := memberStmt.Exec(jobID, group.GroupID, path); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// GetDuplicateGroups retrieves the stored duplicate groups of a scan job,
+// in the order they were saved
+func (db *DB) GetDuplicateGroups(jobID string) ([]DuplicateGroupRecord, error) {
+	rows, err := db.Query(`
+		SELECT group_id, size, dev, master_path, inode_count, verify
+		FROM duplicate_groups WHERE job_id = ? ORDER BY group_id
+	`, jobID)
+	if err != nil {
+		return nil, err
+	}
+
+	var groups []DuplicateGroupRecord
+	index := make(map[int]int)
+	for rows.Next() {
+		var group DuplicateGroupRecord
+		if err := rows.Scan(&group.GroupID, &group.Size, &group.Dev, &group.Master, &group.InodeCount, &group.Verify); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		index[group.GroupID] = len(groups)
+		groups = append(groups, group)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	members, err := db.Query(`
+		SELECT group_id, path FROM duplicate_members WHERE job_id = ? ORDER BY group_id, path
+	`, jobID)
+	if err != nil {
+		return nil, err
+	}
+	defer members.Close()
+
+	for members.Next() {
+		var groupID int
+		var path string
+		if err := members.Scan(&groupID, &path); err != nil {
+			return nil, err
+		}
+		if i, ok := index[groupID]; ok {
+			groups[i].Others = append(groups[i].Others, path)
+		}
+	}
+
+	return groups, members.Err()
+}
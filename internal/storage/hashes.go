@@ -0,0 +1,75 @@
+package storage
+
+import (
+	"database/sql"
+	"time"
+)
+
+// FileHashKey identifies a version of a file in the hash cache
+type FileHashKey struct {
+	Dev   uint64
+	Inode uint64
+	Size  int64
+	Mtime int64 // nanoseconds
+	Ctime int64 // nanoseconds
+	Algo  string
+}
+
+// FileHash is a cached hash for a version of a file
+type FileHash struct {
+	FileHashKey
+	Hash string
+}
+
+// GetFileHash returns the cached hash of a file if the file is unchanged
+// since it was hashed
+func (db *DB) GetFileHash(key FileHashKey) (string, bool, error) {
+	var hash string
+	err := db.QueryRow(`
+		SELECT hash FROM file_hashes
+		WHERE dev = ? AND inode = ? AND algo = ? AND size = ? AND mtime = ? AND ctime = ?
+	`, key.Dev, key.Inode, key.Algo, key.Size, key.Mtime, key.Ctime).Scan(&hash)
+
+	if err == sql.ErrNoRows {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, err
+	}
+
+	return hash, true, nil
+}
+
+// SaveFileHashes stores multiple file hashes in a transaction, replacing the
+// cached hash of previous versions of the same files
+func (db *DB) SaveFileHashes(entries []FileHash) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.Prepare(`
+		INSERT INTO file_hashes (dev, inode, algo, size, mtime, ctime, hash, hashed_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(dev, inode, algo) DO UPDATE SET
+			size = excluded.size,
+			mtime = excluded.mtime,
+			ctime = excluded.ctime,
+			hash = excluded.hash,
+			hashed_at = excluded.hashed_at
+	`)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	now := time.Now().Unix()
+	for _, e := range entries {
+		if _, err := stmt.Exec(e.Dev, e.Inode, e.Algo, e.Size, e.Mtime, e.Ctime, e.Hash, now); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
@@ -2,26 +2,43 @@ package storage
 
 import (
 	"database/sql"
+	"fmt"
+	"path/filepath"
+	"strings"
 	"time"
+	"unicode/utf8"
 )
 
-// AddInodePath adds or updates an inode-path mapping
-func (db *DB) AddInodePath(inode uint64, path string) error {
+// InodeEntry is a single inode-path mapping of the inode index, with the
+// attributes of the file
+type InodeEntry struct {
+	Dev   uint64
+	Inode uint64
+	Path  string
+	Size  int64
+	Mtime int64
+	Nlink uint64
+}
+
+// AddInodePath adds or updates an inode-path mapping. A new path takes the
+// attributes already indexed for its inode.
+func (db *DB) AddInodePath(dev, inode uint64, path string) error {
 	now := time.Now().Unix()
 	_, err := db.Exec(`
-		INSERT INTO inode_index (inode, path, last_seen)
-		VALUES (?, ?, ?)
-		ON CONFLICT(inode, path) DO UPDATE SET last_seen = ?
-	`, inode, path, now, now)
+		INSERT INTO inode_index (dev, inode, path, name, size, mtime, nlink, last_seen)
+		SELECT ?, ?, ?, ?, COALESCE(MAX(size), 0), COALESCE(MAX(mtime), 0), COALESCE(MAX(nlink), 0), ?
+		FROM inode_index WHERE dev = ? AND inode = ?
+		ON CONFLICT(dev, inode, path) DO UPDATE SET last_seen = excluded.last_seen
+	`, dev, inode, path, indexName(path), now, dev, inode)
 
 	return err
 }
 
 // GetInodePaths retrieves all paths for a given inode
-func (db *DB) GetInodePaths(inode uint64) ([]string, error) {
+func (db *DB) GetInodePaths(dev, inode uint64) ([]string, error) {
 	rows, err := db.Query(`
-		SELECT path FROM inode_index WHERE inode = ? ORDER BY path
-	`, inode)
+		SELECT path FROM inode_index WHERE dev = ? AND inode = ? ORDER BY path
+	`, dev, inode)
 	if err != nil {
 		return nil, err
 	}
@@ -40,21 +57,80 @@ func (db *DB) GetInodePaths(inode uint64) ([]string, error) {
 }
 
 // RemoveInodePath removes a specific inode-path mapping
-func (db *DB) RemoveInodePath(inode uint64, path string) error {
-	_, err := db.Exec(`DELETE FROM inode_index WHERE inode = ? AND path = ?`, inode, path)
+func (db *DB) RemoveInodePath(dev, inode uint64, path string) error {
+	_, err := db.Exec(`DELETE FROM inode_index WHERE dev = ? AND inode = ? AND path = ?`, dev, inode, path)
+	return err
+}
+
+// UpdateInodeAttrs sets the attributes indexed for every path of an inode
+func (db *DB) UpdateInodeAttrs(dev, inode uint64, size, mtime int64, nlink uint64) error {
+	_, err := db.Exec(`
+		UPDATE inode_index SET size = ?, mtime = ?, nlink = ? WHERE dev = ? AND inode = ?
+	`, size, mtime, nlink, dev, inode)
+	return err
+}
+
+// indexName is the name indexed for a path, lowercased for case insensitive
+// search: SQLite's lower() only folds ASCII
+func indexName(path string) string {
+	return strings.ToLower(filepath.Base(path))
+}
+
+// RemoveInodePaths removes the mappings of a file or directory and of
+// everything under it
+func (db *DB) RemoveInodePaths(path string) error {
+	// substr counts characters, not bytes
+	_, err := db.Exec(`
+		DELETE FROM inode_index WHERE path = ? OR substr(path, 1, ?) = ?
+	`, path, utf8.RuneCountInString(path)+1, path+"/")
 	return err
 }
 
+// MoveInodePaths updates the mappings of a moved file or directory, and of
+// everything under it, from oldPath to newPath. Stale mappings at newPath are
+// dropped first.
+func (db *DB) MoveInodePaths(oldPath, newPath string) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// substr counts characters, not bytes
+	oldLen, newLen := utf8.RuneCountInString(oldPath), utf8.RuneCountInString(newPath)
+
+	if _, err := tx.Exec(`
+		DELETE FROM inode_index WHERE path = ? OR substr(path, 1, ?) = ?
+	`, newPath, newLen+1, newPath+"/"); err != nil {
+		return fmt.Errorf("failed to drop stale paths: %w", err)
+	}
+
+	if _, err := tx.Exec(`
+		UPDATE inode_index SET path = ? || substr(path, ?), name = CASE WHEN path = ? THEN ? ELSE name END
+		WHERE path = ? OR substr(path, 1, ?) = ?
+	`, newPath, oldLen+1, oldPath, indexName(newPath), oldPath, oldLen+1, oldPath+"/"); err != nil {
+		return fmt.Errorf("failed to move paths: %w", err)
+	}
+
+	return tx.Commit()
+}
+
 // CleanupOldInodeEntries removes entries not seen for the given number of days
 func (db *DB) CleanupOldInodeEntries(daysOld int) error {
 	cutoff := time.Now().Unix() - int64(daysOld*86400)
-	_, err := db.Exec(`DELETE FROM inode_index WHERE last_seen < ?`, cutoff)
+	return db.PruneInodeEntries(cutoff)
+}
+
+// PruneInodeEntries removes entries last seen before the given unix time.
+// Called after a full index pass to drop paths that no longer exist.
+func (db *DB) PruneInodeEntries(before int64) error {
+	_, err := db.Exec(`DELETE FROM inode_index WHERE last_seen < ?`, before)
 	return err
 }
 
 // FindPathsByInode finds all paths with the same inode (used for details view)
-func (db *DB) FindPathsByInode(inode uint64) ([]string, error) {
-	return db.GetInodePaths(inode)
+func (db *DB) FindPathsByInode(dev, inode uint64) ([]string, error) {
+	return db.GetInodePaths(dev, inode)
 }
 
 // ClearInodeIndex clears the entire inode index (use before rebuild)
@@ -66,7 +142,7 @@ func (db *DB) ClearInodeIndex() error {
 // GetInodeCount returns the total number of unique inodes in the index
 func (db *DB) GetInodeCount() (int, error) {
 	var count int
-	err := db.QueryRow(`SELECT COUNT(DISTINCT inode) FROM inode_index`).Scan(&count)
+	err := db.QueryRow(`SELECT COUNT(*) FROM (SELECT DISTINCT dev, inode FROM inode_index)`).Scan(&count)
 	return count, err
 }
 
@@ -78,10 +154,7 @@ func (db *DB) GetPathCount() (int, error) {
 }
 
 // BatchAddInodePaths adds multiple inode-path mappings in a transaction
-func (db *DB) BatchAddInodePaths(entries []struct {
-	Inode uint64
-	Path  string
-}) error {
+func (db *DB) BatchAddInodePaths(entries []InodeEntry) error {
 	tx, err := db.Begin()
 	if err != nil {
 		return err
@@ -89,9 +162,11 @@ func (db *DB) BatchAddInodePaths(entries []struct {
 	defer tx.Rollback()
 
 	stmt, err := tx.Prepare(`
-		INSERT INTO inode_index (inode, path, last_seen)
-		VALUES (?, ?, ?)
-		ON CONFLICT(inode, path) DO UPDATE SET last_seen = ?
+		INSERT INTO inode_index (dev, inode, path, name, size, mtime, nlink, last_seen)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(dev, inode, path) DO UPDATE SET
+			name = excluded.name, size = excluded.size, mtime = excluded.mtime,
+			nlink = excluded.nlink, last_seen = excluded.last_seen
 	`)
 	if err != nil {
 		return err
@@ -100,7 +175,8 @@ func (db *DB) BatchAddInodePaths(entries []struct {
 
 	now := time.Now().Unix()
 	for _, entry := range entries {
-		if _, err := stmt.Exec(entry.Inode, entry.Path, now, now); err != nil {
+		if _, err := stmt.Exec(entry.Dev, entry.Inode, entry.Path, indexName(entry.Path),
+			entry.Size, entry.Mtime, entry.Nlink, now); err != nil {
 			return err
 		}
 	}
@@ -118,15 +194,19 @@ type ScanJob struct {
 	StartedAt   int64
 	CompletedAt sql.NullInt64
 	Error       sql.NullString
+	Options     string // JSON scan options
+	// ReclaimableBytes is the space freed by converting every group found,
+	// only filled by ListScanJobs
+	ReclaimableBytes int64
 }
 
-// CreateScanJob creates a new scan job
-func (db *DB) CreateScanJob(jobID string) error {
+// CreateScanJob creates a new scan job with its JSON options
+func (db *DB) CreateScanJob(jobID string, options string) error {
 	now := time.Now().Unix()
 	_, err := db.Exec(`
-		INSERT INTO scan_jobs (job_id, status, progress, total_files, groups_found, started_at)
-		VALUES (?, 'running', 0, 0, 0, ?)
-	`, jobID, now)
+		INSERT INTO scan_jobs (job_id, status, progress, total_files, groups_found, started_at, options)
+		VALUES (?, 'running', 0, 0, 0, ?, ?)
+	`, jobID, now, options)
 	return err
 }
 
@@ -138,15 +218,34 @@ func (db *DB) UpdateScanJobProgress(jobID string, progress, totalFiles int) erro
 	return err
 }
 
-// CompleteScanJob marks a scan job as completed
-func (db *DB) CompleteScanJob(jobID string, groupsFound int) error {
+// CompleteScanJob stores the duplicate groups of a scan job and marks it as
+// completed in a single transaction, so a job is never reported as completed
+// without its results
+func (db *DB) CompleteScanJob(jobID string, groups []DuplicateGroupRecord) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := saveDuplicateGroups(tx, jobID, groups); err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(`DELETE FROM scan_checkpoints WHERE job_id = ?`, jobID); err != nil {
+		return err
+	}
+
 	now := time.Now().Unix()
-	_, err := db.Exec(`
+	if _, err := tx.Exec(`
 		UPDATE scan_jobs
 		SET status = 'completed', groups_found = ?, completed_at = ?
 		WHERE job_id = ?
-	`, groupsFound, now, jobID)
-	return err
+	`, len(groups), now, jobID); err != nil {
+		return err
+	}
+
+	return tx.Commit()
 }
 
 // FailScanJob marks a scan job as failed
@@ -160,14 +259,58 @@ func (db *DB) FailScanJob(jobID string, errorMsg string) error {
 	return err
 }
 
+// CancelScanJob marks a scan job as cancelled and drops its checkpoint
+func (db *DB) CancelScanJob(jobID string) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM scan_checkpoints WHERE job_id = ?`, jobID); err != nil {
+		return err
+	}
+
+	now := time.Now().Unix()
+	if _, err := tx.Exec(`
+		UPDATE scan_jobs
+		SET status = 'cancelled', completed_at = ?
+		WHERE job_id = ?
+	`, now, jobID); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// ResumeScanJob marks a paused scan job as running again
+func (db *DB) ResumeScanJob(jobID string) error {
+	_, err := db.Exec(`UPDATE scan_jobs SET status = 'running' WHERE job_id = ?`, jobID)
+	return err
+}
+
+// FailInterruptedScanJobs marks jobs left running by a previous process as failed
+func (db *DB) FailInterruptedScanJobs() (int64, error) {
+	now := time.Now().Unix()
+	result, err := db.Exec(`
+		UPDATE scan_jobs
+		SET status = 'failed', error = 'interrupted by restart', completed_at = ?
+		WHERE status = 'running'
+	`, now)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
 // GetScanJob retrieves a scan job by ID
 func (db *DB) GetScanJob(jobID string) (*ScanJob, error) {
 	job := &ScanJob{}
 	err := db.QueryRow(`
-		SELECT job_id, status, progress, total_files, groups_found, started_at, completed_at, error
+		SELECT job_id, status, progress, total_files, groups_found, started_at, completed_at, error, options
 		FROM scan_jobs WHERE job_id = ?
 	`, jobID).Scan(&job.JobID, &job.Status, &job.Progress, &job.TotalFiles,
-		&job.GroupsFound, &job.StartedAt, &job.CompletedAt, &job.Error)
+		&job.GroupsFound, &job.StartedAt, &job.CompletedAt, &job.Error, &job.Options)
 
 	if err == sql.ErrNoRows {
 		return nil, nil
@@ -178,3 +321,131 @@ func (db *DB) GetScanJob(jobID string) (*ScanJob, error) {
 
 	return job, nil
 }
+
+// GetLatestCompletedScanJob returns the most recently completed scan job, or
+// nil if no scan has completed yet
+func (db *DB) GetLatestCompletedScanJob() (*ScanJob, error) {
+	var jobID string
+	err := db.QueryRow(`
+		SELECT job_id FROM scan_jobs
+		WHERE status = 'completed'
+		ORDER BY completed_at DESC, started_at DESC
+		LIMIT 1
+	`).Scan(&jobID)
+
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return db.GetScanJob(jobID)
+}
+
+// ListScanJobs returns scan jobs, most recent first, with the total number of jobs
+func (db *DB) ListScanJobs(limit, offset int) ([]ScanJob, int, error) {
+	var total int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM scan_jobs`).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	// Hardlinks of the same inode already share their data: each group frees
+	// its size once per inode beyond the first
+	rows, err := db.Query(`
+		SELECT j.job_id, j.status, j.progress, j.total_files, j.groups_found, j.started_at,
+			j.completed_at, j.error, j.options,
+			COALESCE((
+				SELECT SUM(g.size * MAX(g.inode_count - 1, 0))
+				FROM duplicate_groups g WHERE g.job_id = j.job_id
+			), 0)
+		FROM scan_jobs j
+		ORDER BY j.started_at DESC, j.job_id
+		LIMIT ? OFFSET ?
+	`, limit, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	var jobs []ScanJob
+	for rows.Next() {
+		var job ScanJob
+		if err := rows.Scan(&job.JobID, &job.Status, &job.Progress, &job.TotalFiles, &job.GroupsFound,
+			&job.StartedAt, &job.CompletedAt, &job.Error, &job.Options, &job.ReclaimableBytes); err != nil {
+			return nil, 0, err
+		}
+		jobs = append(jobs, job)
+	}
+
+	return jobs, total, rows.Err()
+}
+
+// DeleteScanJob deletes a scan job with its results and checkpoint
+func (db *DB) DeleteScanJob(jobID string) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := deleteScanJobs(tx, []string{jobID}); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// PruneScanJobs deletes the finished scan jobs started before the given time,
+// always keeping the keep most recent ones. It returns the deleted job IDs.
+func (db *DB) PruneScanJobs(before int64, keep int) ([]string, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.Query(`
+		SELECT job_id FROM scan_jobs
+		WHERE status IN ('completed', 'failed', 'cancelled') AND started_at < ?
+		AND job_id NOT IN (
+			SELECT job_id FROM scan_jobs ORDER BY started_at DESC, job_id LIMIT ?
+		)
+	`, before, keep)
+	if err != nil {
+		return nil, err
+	}
+
+	var jobIDs []string
+	for rows.Next() {
+		var jobID string
+		if err := rows.Scan(&jobID); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		jobIDs = append(jobIDs, jobID)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if err := deleteScanJobs(tx, jobIDs); err != nil {
+		return nil, err
+	}
+
+	return jobIDs, tx.Commit()
+}
+
+// deleteScanJobs deletes jobs and everything attached to them.
+// Foreign keys aren't enforced, so each table is cleaned explicitly.
+func deleteScanJobs(tx *sql.Tx, jobIDs []string) error {
+	for _, jobID := range jobIDs {
+		for _, table := range []string{"duplicate_members", "duplicate_groups", "scan_checkpoints", "conversion_reports", "scan_jobs"} {
+			if _, err := tx.Exec(fmt.Sprintf("DELETE FROM %s WHERE job_id = ?", table), jobID); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,108 @@
+package storage
+
+import (
+	"time"
+)
+
+// ReplaceIntent is a conversion of a duplicate in progress: a temporary
+// hardlink to the master renamed over the duplicate
+type ReplaceIntent struct {
+	ID          int64
+	Path        string // relative to the data root
+	TmpPath     string // relative to the data root
+	Master      string // relative to the data root
+	MasterDev   uint64
+	MasterInode uint64
+	Dev         uint64 // identity of the replaced file
+	Inode       uint64
+	Size        int64
+	Metadata    string // JSON metadata of the replaced file
+	Username    string
+	Step        string
+	CreatedAt   int64
+}
+
+// CreateReplaceIntent records a conversion about to start and returns its ID
+func (db *DB) CreateReplaceIntent(in *ReplaceIntent) (int64, error) {
+	result, err := db.Exec(`
+		INSERT INTO replace_intents (path, tmp_path, master, master_dev, master_inode, dev, inode, size, metadata, username, step, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, in.Path, in.TmpPath, in.Master, in.MasterDev, in.MasterInode, in.Dev, in.Inode, in.Size,
+		in.Metadata, in.Username, in.Step, time.Now().Unix())
+	if err != nil {
+		return 0, err
+	}
+	return result.LastInsertId()
+}
+
+// SetReplaceIntentStep records the next step of a conversion
+func (db *DB) SetReplaceIntentStep(id int64, step string) error {
+	_, err := db.Exec(`UPDATE replace_intents SET step = ? WHERE id = ?`, step, id)
+	return err
+}
+
+// DeleteReplaceIntent removes the intent of an abandoned conversion
+func (db *DB) DeleteReplaceIntent(id int64) error {
+	_, err := db.Exec(`DELETE FROM replace_intents WHERE id = ?`, id)
+	return err
+}
+
+// CommitReplaceIntent journals a completed conversion and removes its intent
+// in one transaction. It returns the ID of the journaled operation.
+func (db *DB) CommitReplaceIntent(id int64, op *Operation) (int64, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`
+		INSERT INTO operations (kind, path, target, dev, inode, size, metadata, username, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, op.Kind, op.Path, op.Target, op.Dev, op.Inode, op.Size, op.Metadata, op.Username, time.Now().Unix())
+	if err != nil {
+		return 0, err
+	}
+	opID, err := result.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+
+	if _, err := tx.Exec(`DELETE FROM replace_intents WHERE id = ?`, id); err != nil {
+		return 0, err
+	}
+
+	return opID, tx.Commit()
+}
+
+// ListReplaceIntents returns the conversions in progress, oldest first
+func (db *DB) ListReplaceIntents() ([]ReplaceIntent, error) {
+	rows, err := db.Query(`
+		SELECT id, path, tmp_path, master, master_dev, master_inode, dev, inode, size, metadata, username, step, created_at
+		FROM replace_intents ORDER BY id
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var intents []ReplaceIntent
+	for rows.Next() {
+		var in ReplaceIntent
+		if err := rows.Scan(&in.ID, &in.Path, &in.TmpPath, &in.Master, &in.MasterDev, &in.MasterInode,
+			&in.Dev, &in.Inode, &in.Size, &in.Metadata, &in.Username, &in.Step, &in.CreatedAt); err != nil {
+			return nil, err
+		}
+		intents = append(intents, in)
+	}
+
+	return intents, rows.Err()
+}
+
+// HasReplaceIntent reports whether a temporary hardlink belongs to a
+// conversion in progress
+func (db *DB) HasReplaceIntent(tmpPath string) (bool, error) {
+	var count int
+	err := db.QueryRow(`SELECT COUNT(*) FROM replace_intents WHERE tmp_path = ?`, tmpPath).Scan(&count)
+	return count > 0, err
+}
@@ -0,0 +1,111 @@
+package storage
+
+import (
+	"database/sql"
+	"time"
+)
+
+// Job is a background job of a bulk file operation
+type Job struct {
+	JobID       string
+	Kind        string
+	Status      string
+	Total       int
+	Processed   int
+	Failed      int
+	Params      string // JSON request
+	Report      string // JSON final report
+	Error       sql.NullString
+	Username    string
+	StartedAt   int64
+	CompletedAt sql.NullInt64
+}
+
+// CreateJob records a new running job
+func (db *DB) CreateJob(j *Job) error {
+	_, err := db.Exec(`
+		INSERT INTO jobs (job_id, kind, status, total, params, username, started_at)
+		VALUES (?, ?, 'running', ?, ?, ?, ?)
+	`, j.JobID, j.Kind, j.Total, j.Params, j.Username, time.Now().Unix())
+	return err
+}
+
+// UpdateJobProgress records the items processed by a running job
+func (db *DB) UpdateJobProgress(jobID string, total, processed, failed int) error {
+	_, err := db.Exec(`
+		UPDATE jobs SET total = ?, processed = ?, failed = ? WHERE job_id = ?
+	`, total, processed, failed, jobID)
+	return err
+}
+
+// FinishJob records the final status, counters and report of a job
+func (db *DB) FinishJob(j *Job) error {
+	_, err := db.Exec(`
+		UPDATE jobs
+		SET status = ?, total = ?, processed = ?, failed = ?, report = ?, error = ?, completed_at = ?
+		WHERE job_id = ?
+	`, j.Status, j.Total, j.Processed, j.Failed, j.Report, j.Error, time.Now().Unix(), j.JobID)
+	return err
+}
+
+// FailInterruptedJobs marks the jobs left running by a previous process as
+// failed and returns how many there were
+func (db *DB) FailInterruptedJobs() (int64, error) {
+	result, err := db.Exec(`
+		UPDATE jobs SET status = 'failed', error = 'interrupted by a restart', completed_at = ?
+		WHERE status = 'running'
+	`, time.Now().Unix())
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// GetJob retrieves a job by ID
+func (db *DB) GetJob(jobID string) (*Job, error) {
+	j := &Job{}
+	err := db.QueryRow(`
+		SELECT job_id, kind, status, total, processed, failed, params, report, error, username, started_at, completed_at
+		FROM jobs WHERE job_id = ?
+	`, jobID).Scan(&j.JobID, &j.Kind, &j.Status, &j.Total, &j.Processed, &j.Failed,
+		&j.Params, &j.Report, &j.Error, &j.Username, &j.StartedAt, &j.CompletedAt)
+
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return j, nil
+}
+
+// ListJobs returns a page of jobs, most recent first, without their params
+// and reports, and the total number of jobs
+func (db *DB) ListJobs(limit, offset int) ([]Job, int, error) {
+	var total int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM jobs`).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	rows, err := db.Query(`
+		SELECT job_id, kind, status, total, processed, failed, error, username, started_at, completed_at
+		FROM jobs ORDER BY started_at DESC, rowid DESC LIMIT ? OFFSET ?
+	`, limit, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	var jobs []Job
+	for rows.Next() {
+		var j Job
+		if err := rows.Scan(&j.JobID, &j.Kind, &j.Status, &j.Total, &j.Processed, &j.Failed,
+			&j.Error, &j.Username, &j.StartedAt, &j.CompletedAt); err != nil {
+			return nil, 0, err
+		}
+		jobs = append(jobs, j)
+	}
+
+	return jobs, total, rows.Err()
+}
@@ -0,0 +1,96 @@
+package storage
+
+import (
+	"database/sql"
+	"time"
+)
+
+// Operation is a journaled file operation
+type Operation struct {
+	ID       int64
+	Kind     string
+	Path     string // relative to the data root
+	Target   string // relative to the data root
+	Dev      uint64
+	Inode    uint64 // inode of the file before the operation
+	Size     int64
+	Metadata string // JSON metadata of a replaced file
+	Username string
+	// CreatedAt is set by RecordOperation
+	CreatedAt int64
+	UndoneAt  sql.NullInt64
+}
+
+// RecordOperation appends an operation to the journal and returns its ID
+func (db *DB) RecordOperation(op *Operation) (int64, error) {
+	result, err := db.Exec(`
+		INSERT INTO operations (kind, path, target, dev, inode, size, metadata, username, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, op.Kind, op.Path, op.Target, op.Dev, op.Inode, op.Size, op.Metadata, op.Username, time.Now().Unix())
+	if err != nil {
+		return 0, err
+	}
+	return result.LastInsertId()
+}
+
+// GetOperation retrieves an operation by ID
+func (db *DB) GetOperation(id int64) (*Operation, error) {
+	op := &Operation{}
+	err := db.QueryRow(`
+		SELECT id, kind, path, target, dev, inode, size, metadata, username, created_at, undone_at
+		FROM operations WHERE id = ?
+	`, id).Scan(&op.ID, &op.Kind, &op.Path, &op.Target, &op.Dev, &op.Inode, &op.Size,
+		&op.Metadata, &op.Username, &op.CreatedAt, &op.UndoneAt)
+
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return op, nil
+}
+
+// ListOperations returns a page of the journal, most recent first, and the
+// total number of operations
+func (db *DB) ListOperations(limit, offset int) ([]Operation, int, error) {
+	var total int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM operations`).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	rows, err := db.Query(`
+		SELECT id, kind, path, target, dev, inode, size, metadata, username, created_at, undone_at
+		FROM operations ORDER BY id DESC LIMIT ? OFFSET ?
+	`, limit, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	var ops []Operation
+	for rows.Next() {
+		var op Operation
+		if err := rows.Scan(&op.ID, &op.Kind, &op.Path, &op.Target, &op.Dev, &op.Inode, &op.Size,
+			&op.Metadata, &op.Username, &op.CreatedAt, &op.UndoneAt); err != nil {
+			return nil, 0, err
+		}
+		ops = append(ops, op)
+	}
+
+	return ops, total, rows.Err()
+}
+
+// MarkOperationUndone records that an operation was undone. It returns false
+// when the operation was already undone.
+func (db *DB) MarkOperationUndone(id int64) (bool, error) {
+	result, err := db.Exec(`
+		UPDATE operations SET undone_at = ? WHERE id = ? AND undone_at IS NULL
+	`, time.Now().Unix(), id)
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	return n > 0, err
+}
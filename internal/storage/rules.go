@@ -0,0 +1,109 @@
+package storage
+
+import (
+	"database/sql"
+	"time"
+)
+
+// ConversionRule is an automatic duplicate conversion rule
+type ConversionRule struct {
+	ID         int64
+	Name       string
+	Conditions string // JSON rule conditions and settings
+	Enabled    bool
+	AutoApply  bool
+	CreatedAt  int64
+}
+
+// CreateConversionRule stores a new rule and returns its ID
+func (db *DB) CreateConversionRule(r *ConversionRule) (int64, error) {
+	result, err := db.Exec(`
+		INSERT INTO conversion_rules (name, conditions, enabled, auto_apply, created_at)
+		VALUES (?, ?, ?, ?, ?)
+	`, r.Name, r.Conditions, r.Enabled, r.AutoApply, time.Now().Unix())
+	if err != nil {
+		return 0, err
+	}
+	return result.LastInsertId()
+}
+
+// UpdateConversionRule updates the settings of a rule
+func (db *DB) UpdateConversionRule(r *ConversionRule) error {
+	_, err := db.Exec(`
+		UPDATE conversion_rules
+		SET name = ?, conditions = ?, enabled = ?, auto_apply = ?
+		WHERE id = ?
+	`, r.Name, r.Conditions, r.Enabled, r.AutoApply, r.ID)
+	return err
+}
+
+// DeleteConversionRule deletes a rule
+func (db *DB) DeleteConversionRule(id int64) error {
+	_, err := db.Exec(`DELETE FROM conversion_rules WHERE id = ?`, id)
+	return err
+}
+
+// GetConversionRule retrieves a rule by ID
+func (db *DB) GetConversionRule(id int64) (*ConversionRule, error) {
+	r := &ConversionRule{}
+	err := db.QueryRow(`
+		SELECT id, name, conditions, enabled, auto_apply, created_at
+		FROM conversion_rules WHERE id = ?
+	`, id).Scan(&r.ID, &r.Name, &r.Conditions, &r.Enabled, &r.AutoApply, &r.CreatedAt)
+
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+// ListConversionRules returns all rules in evaluation order
+func (db *DB) ListConversionRules() ([]ConversionRule, error) {
+	rows, err := db.Query(`
+		SELECT id, name, conditions, enabled, auto_apply, created_at
+		FROM conversion_rules ORDER BY id
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var rules []ConversionRule
+	for rows.Next() {
+		var r ConversionRule
+		if err := rows.Scan(&r.ID, &r.Name, &r.Conditions, &r.Enabled, &r.AutoApply, &r.CreatedAt); err != nil {
+			return nil, err
+		}
+		rules = append(rules, r)
+	}
+
+	return rules, rows.Err()
+}
+
+// SaveConversionReport stores the rule report of a scan job, replacing any
+// previous one
+func (db *DB) SaveConversionReport(jobID, report string) error {
+	_, err := db.Exec(`
+		INSERT INTO conversion_reports (job_id, report, created_at)
+		VALUES (?, ?, ?)
+		ON CONFLICT(job_id) DO UPDATE SET
+			report = excluded.report,
+			created_at = excluded.created_at
+	`, jobID, report, time.Now().Unix())
+	return err
+}
+
+// GetConversionReport retrieves the rule report of a scan job, or an empty
+// string if there is none
+func (db *DB) GetConversionReport(jobID string) (string, error) {
+	var report string
+	err := db.QueryRow(`SELECT report FROM conversion_reports WHERE job_id = ?`, jobID).Scan(&report)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	return report, err
+}
@@ -0,0 +1,98 @@
+package storage
+
+import (
+	"database/sql"
+	"time"
+)
+
+// ScanSchedule is a recurring duplicate scan
+type ScanSchedule struct {
+	ID        int64
+	Name      string
+	Cron      string
+	Options   string // JSON scan options
+	Enabled   bool
+	NextRunAt sql.NullInt64
+	LastRunAt sql.NullInt64
+	LastJobID sql.NullString
+	CreatedAt int64
+}
+
+// CreateScanSchedule stores a new schedule and returns its ID
+func (db *DB) CreateScanSchedule(s *ScanSchedule) (int64, error) {
+	result, err := db.Exec(`
+		INSERT INTO scan_schedules (name, cron, options, enabled, next_run_at, created_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+	`, s.Name, s.Cron, s.Options, s.Enabled, s.NextRunAt, time.Now().Unix())
+	if err != nil {
+		return 0, err
+	}
+	return result.LastInsertId()
+}
+
+// UpdateScanSchedule updates the settings and next run of a schedule
+func (db *DB) UpdateScanSchedule(s *ScanSchedule) error {
+	_, err := db.Exec(`
+		UPDATE scan_schedules
+		SET name = ?, cron = ?, options = ?, enabled = ?, next_run_at = ?
+		WHERE id = ?
+	`, s.Name, s.Cron, s.Options, s.Enabled, s.NextRunAt, s.ID)
+	return err
+}
+
+// DeleteScanSchedule deletes a schedule, the jobs it started are kept
+func (db *DB) DeleteScanSchedule(id int64) error {
+	_, err := db.Exec(`DELETE FROM scan_schedules WHERE id = ?`, id)
+	return err
+}
+
+// GetScanSchedule retrieves a schedule by ID
+func (db *DB) GetScanSchedule(id int64) (*ScanSchedule, error) {
+	s := &ScanSchedule{}
+	err := db.QueryRow(`
+		SELECT id, name, cron, options, enabled, next_run_at, last_run_at, last_job_id, created_at
+		FROM scan_schedules WHERE id = ?
+	`, id).Scan(&s.ID, &s.Name, &s.Cron, &s.Options, &s.Enabled, &s.NextRunAt, &s.LastRunAt, &s.LastJobID, &s.CreatedAt)
+
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// ListScanSchedules returns all schedules
+func (db *DB) ListScanSchedules() ([]ScanSchedule, error) {
+	rows, err := db.Query(`
+		SELECT id, name, cron, options, enabled, next_run_at, last_run_at, last_job_id, created_at
+		FROM scan_schedules ORDER BY id
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var schedules []ScanSchedule
+	for rows.Next() {
+		var s ScanSchedule
+		if err := rows.Scan(&s.ID, &s.Name, &s.Cron, &s.Options, &s.Enabled, &s.NextRunAt, &s.LastRunAt, &s.LastJobID, &s.CreatedAt); err != nil {
+			return nil, err
+		}
+		schedules = append(schedules, s)
+	}
+
+	return schedules, rows.Err()
+}
+
+// RecordScheduleRun stores the job started by a schedule and its next run
+func (db *DB) RecordScheduleRun(id int64, jobID string, ranAt int64, nextRunAt sql.NullInt64) error {
+	_, err := db.Exec(`
+		UPDATE scan_schedules
+		SET last_run_at = ?, last_job_id = ?, next_run_at = ?
+		WHERE id = ?
+	`, ranAt, jobID, nextRunAt, id)
+	return err
+}
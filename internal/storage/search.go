@@ -0,0 +1,131 @@
+package storage
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// SearchQuery selects files of the inode index. Zero values disable a
+// criterion.
+type SearchQuery struct {
+	// Text is matched against the words of the path, each word of Text
+	// being a prefix
+	Text string
+	// Name is a case insensitive substring of the file name, or a glob when
+	// it contains *, ? or [
+	Name string
+	// Root restricts the search to the files under this absolute path
+	Root     string
+	MinSize  int64
+	MaxSize  int64
+	MinMtime int64
+	MaxMtime int64
+	MinLinks uint64
+	MaxLinks uint64
+	// SameInode restricts the search to the paths of the inode Dev/Inode
+	SameInode bool
+	Dev       uint64
+	Inode     uint64
+	Limit     int
+	Offset    int
+}
+
+// SearchFiles returns the indexed files matching q, ordered by path, with the
+// total number of matches
+func (db *DB) SearchFiles(q SearchQuery) ([]InodeEntry, int, error) {
+	var where []string
+	var args []interface{}
+
+	if text := ftsQuery(q.Text); text != "" {
+		where = append(where, "id IN (SELECT docid FROM path_search WHERE path_search MATCH ?)")
+		args = append(args, text)
+	}
+	if q.Name != "" {
+		if strings.ContainsAny(q.Name, "*?[") {
+			where = append(where, "name GLOB ?")
+		} else {
+			where = append(where, "instr(name, ?) > 0")
+		}
+		args = append(args, strings.ToLower(q.Name))
+	}
+	if q.Root != "" {
+		where = append(where, "substr(path, 1, ?) = ?")
+		prefix := strings.TrimSuffix(q.Root, "/") + "/"
+		// substr counts characters, not bytes
+		args = append(args, utf8.RuneCountInString(prefix), prefix)
+	}
+	if q.MinSize > 0 {
+		where = append(where, "size >= ?")
+		args = append(args, q.MinSize)
+	}
+	if q.MaxSize > 0 {
+		where = append(where, "size <= ?")
+		args = append(args, q.MaxSize)
+	}
+	if q.MinMtime > 0 {
+		where = append(where, "mtime >= ?")
+		args = append(args, q.MinMtime)
+	}
+	if q.MaxMtime > 0 {
+		where = append(where, "mtime <= ?")
+		args = append(args, q.MaxMtime)
+	}
+	if q.MinLinks > 0 {
+		where = append(where, "nlink >= ?")
+		args = append(args, q.MinLinks)
+	}
+	if q.MaxLinks > 0 {
+		where = append(where, "nlink <= ?")
+		args = append(args, q.MaxLinks)
+	}
+	if q.SameInode {
+		where = append(where, "dev = ? AND inode = ?")
+		args = append(args, q.Dev, q.Inode)
+	}
+
+	clause := ""
+	if len(where) > 0 {
+		clause = "WHERE " + strings.Join(where, " AND ")
+	}
+
+	var total int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM inode_index `+clause, args...).Scan(&total); err != nil {
+		return nil, 0, fmt.Errorf("failed to count results: %w", err)
+	}
+
+	rows, err := db.Query(`
+		SELECT dev, inode, path, size, mtime, nlink FROM inode_index
+		`+clause+`
+		ORDER BY path
+		LIMIT ? OFFSET ?
+	`, append(args, q.Limit, q.Offset)...)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to search: %w", err)
+	}
+	defer rows.Close()
+
+	var entries []InodeEntry
+	for rows.Next() {
+		var e InodeEntry
+		if err := rows.Scan(&e.Dev, &e.Inode, &e.Path, &e.Size, &e.Mtime, &e.Nlink); err != nil {
+			return nil, 0, err
+		}
+		entries = append(entries, e)
+	}
+
+	return entries, total, rows.Err()
+}
+
+// ftsQuery turns free text into a full-text query matching every word as a
+// prefix. Punctuation separates words, as in the unicode61 tokenizer.
+func ftsQuery(text string) string {
+	words := strings.FieldsFunc(text, func(c rune) bool {
+		return !unicode.IsLetter(c) && !unicode.IsDigit(c)
+	})
+	for i, word := range words {
+		words[i] = word + "*"
+	}
+	return strings.Join(words, " ")
+}
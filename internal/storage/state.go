@@ -0,0 +1,38 @@
+package storage
+
+import (
+	"database/sql"
+	"time"
+)
+
+// IndexCompletedStateKey records when the last full pass of the inode index
+// finished
+const IndexCompletedStateKey = "inode_index_completed_at"
+
+// SetState stores a value in the application state table
+func (db *DB) SetState(key, value string) error {
+	now := time.Now().Unix()
+	_, err := db.Exec(`
+		INSERT INTO app_state (key, value, updated_at)
+		VALUES (?, ?, ?)
+		ON CONFLICT(key) DO UPDATE SET value = ?, updated_at = ?
+	`, key, value, now, value, now)
+	return err
+}
+
+// GetState retrieves a value from the application state table.
+// Returns an empty string if the key does not exist.
+func (db *DB) GetState(key string) (string, error) {
+	var value string
+	err := db.QueryRow(`SELECT value FROM app_state WHERE key = ?`, key).Scan(&value)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	return value, err
+}
+
+// DeleteState removes a value from the application state table
+func (db *DB) DeleteState(key string) error {
+	_, err := db.Exec(`DELETE FROM app_state WHERE key = ?`, key)
+	return err
+}
@@ -0,0 +1,167 @@
+package storage
+
+import (
+	"database/sql"
+	"time"
+)
+
+// TrashItem is a file or folder moved to the trash
+type TrashItem struct {
+	ID        int64
+	Path      string // original path, relative to the data root
+	TrashPath string // relative to the data root
+	Dev       uint64
+	Inode     uint64
+	Size      int64 // total size of the files of a folder
+	IsDir     bool
+	Username  string
+	// DeletedAt is set by AddTrashItem
+	DeletedAt int64
+}
+
+// TrashLink is the inode of a regular file of a trash item
+type TrashLink struct {
+	Dev   uint64
+	Inode uint64
+}
+
+const trashItemColumns = `id, path, trash_path, dev, inode, size, is_dir, username, deleted_at`
+
+// scanTrashItem scans a row of trashItemColumns
+func scanTrashItem(row interface{ Scan(...interface{}) error }) (*TrashItem, error) {
+	item := &TrashItem{}
+	err := row.Scan(&item.ID, &item.Path, &item.TrashPath, &item.Dev, &item.Inode,
+		&item.Size, &item.IsDir, &item.Username, &item.DeletedAt)
+	return item, err
+}
+
+// AddTrashItem records an item moved to the trash with the inodes of its
+// regular files, and returns its ID
+func (db *DB) AddTrashItem(item *TrashItem, links []TrashLink) (int64, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	deletedAt := time.Now().Unix()
+	result, err := tx.Exec(`
+		INSERT INTO trash_items (path, trash_path, dev, inode, size, is_dir, username, deleted_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+	`, item.Path, item.TrashPath, item.Dev, item.Inode, item.Size, item.IsDir, item.Username, deletedAt)
+	if err != nil {
+		return 0, err
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+
+	stmt, err := tx.Prepare(`INSERT INTO trash_links (item_id, dev, inode) VALUES (?, ?, ?)`)
+	if err != nil {
+		return 0, err
+	}
+	defer stmt.Close()
+
+	for _, link := range links {
+		if _, err := stmt.Exec(id, link.Dev, link.Inode); err != nil {
+			return 0, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	item.ID, item.DeletedAt = id, deletedAt
+	return id, nil
+}
+
+// GetTrashItem retrieves a trash item by ID, nil if it doesn't exist
+func (db *DB) GetTrashItem(id int64) (*TrashItem, error) {
+	item, err := scanTrashItem(db.QueryRow(`SELECT `+trashItemColumns+` FROM trash_items WHERE id = ?`, id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return item, nil
+}
+
+// ListTrashItems returns a page of the trash, most recent first, with the
+// total number of items and their total size
+func (db *DB) ListTrashItems(limit, offset int) ([]TrashItem, int, int64, error) {
+	var total int
+	var size int64
+	if err := db.QueryRow(`SELECT COUNT(*), COALESCE(SUM(size), 0) FROM trash_items`).Scan(&total, &size); err != nil {
+		return nil, 0, 0, err
+	}
+
+	rows, err := db.Query(`
+		SELECT `+trashItemColumns+` FROM trash_items ORDER BY id DESC LIMIT ? OFFSET ?
+	`, limit, offset)
+	if err != nil {
+		return nil, 0, 0, err
+	}
+	defer rows.Close()
+
+	var items []TrashItem
+	for rows.Next() {
+		item, err := scanTrashItem(rows)
+		if err != nil {
+			return nil, 0, 0, err
+		}
+		items = append(items, *item)
+	}
+
+	return items, total, size, rows.Err()
+}
+
+// ListTrashItemsBefore returns the items trashed before the given unix time
+func (db *DB) ListTrashItemsBefore(before int64) ([]TrashItem, error) {
+	rows, err := db.Query(`
+		SELECT `+trashItemColumns+` FROM trash_items WHERE deleted_at < ? ORDER BY id
+	`, before)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var items []TrashItem
+	for rows.Next() {
+		item, err := scanTrashItem(rows)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, *item)
+	}
+
+	return items, rows.Err()
+}
+
+// DeleteTrashItem removes a trash item record and its links
+func (db *DB) DeleteTrashItem(id int64) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM trash_links WHERE item_id = ?`, id); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`DELETE FROM trash_items WHERE id = ?`, id); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// CountTrashedLinks returns the number of links of an inode in the trash,
+// the files of trashed folders included
+func (db *DB) CountTrashedLinks(dev, inode uint64) (int, error) {
+	var count int
+	err := db.QueryRow(`
+		SELECT COUNT(*) FROM trash_links WHERE dev = ? AND inode = ?
+	`, dev, inode).Scan(&count)
+	return count, err
+}
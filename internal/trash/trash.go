@@ -0,0 +1,338 @@
+package trash
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/gosiva/hardlink-ui/internal/fsutil"
+	"github.com/gosiva/hardlink-ui/internal/indexer"
+	"github.com/gosiva/hardlink-ui/internal/storage"
+)
+
+var (
+	// ErrNotFound is returned for an unknown or vanished trash item
+	ErrNotFound = errors.New("trash item not found")
+	// ErrPathTaken is returned when restoring over an existing path
+	ErrPathTaken = errors.New("original path is taken")
+)
+
+// purgeInterval is the time between two purges of the expired items
+const purgeInterval = time.Hour
+
+// device returns the device of a path, replaced by tests
+var device = fsutil.Device
+
+// Trash moves deleted files and folders to the trash directory at the top of
+// their device under the data root. A trashed link keeps its inode, so it can
+// be restored until the trash is emptied.
+type Trash struct {
+	db        *storage.DB
+	dataRoot  string
+	retention time.Duration
+}
+
+// New creates a new trash. Items older than retention are purged by Run, a
+// zero retention keeps them until the trash is emptied.
+func New(db *storage.DB, dataRoot string, retention time.Duration) *Trash {
+	return &Trash{
+		db:        db,
+		dataRoot:  dataRoot,
+		retention: retention,
+	}
+}
+
+// dirFor returns the trash directory of path, on the device dev: the
+// topmost directory of that device under the data root. A mount point has no
+// trash it can be moved to.
+func (t *Trash) dirFor(path string, dev uint64) (string, error) {
+	top := filepath.Dir(path)
+	if d, err := device(top); err != nil {
+		return "", fmt.Errorf("failed to stat %s: %w", t.rel(top), err)
+	} else if d != dev {
+		return "", fmt.Errorf("%s is a mount point", t.rel(path))
+	}
+
+	for top != t.dataRoot && strings.HasPrefix(top, t.dataRoot) {
+		parent := filepath.Dir(top)
+		if d, err := device(parent); err != nil || d != dev {
+			break
+		}
+		top = parent
+	}
+	return filepath.Join(top, fsutil.TrashDir()), nil
+}
+
+// Move moves a file or folder to the trash and records it
+func (t *Trash) Move(path, username string) (*storage.TrashItem, error) {
+	return t.add(path, username, false)
+}
+
+// Keep links a file into the trash and records it, leaving it in place for
+// the caller to replace. The item must be deleted if the replacement fails
+func (t *Trash) Keep(path, username string) (*storage.TrashItem, error) {
+	return t.add(path, username, true)
+}
+
+// add moves or, when keep is set, links a file or folder into the trash
+func (t *Trash) add(path, username string, keep bool) (*storage.TrashItem, error) {
+	info, err := os.Lstat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	dev, ino, _, ok := fsutil.Identity(info)
+	if !ok {
+		return nil, errors.New("failed to get file stats")
+	}
+
+	dir, err := t.dirFor(path, dev)
+	if err != nil {
+		return nil, err
+	}
+	if isSubPath(dir, path) {
+		return nil, fmt.Errorf("%s is already in the trash", t.rel(path))
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create the trash: %w", err)
+	}
+
+	// The deletion time keeps the names of the same file apart
+	trashPath := filepath.Join(dir, fmt.Sprintf("%d-%s", time.Now().UnixNano(), filepath.Base(path)))
+
+	item := &storage.TrashItem{
+		Path:      t.rel(path),
+		TrashPath: t.rel(trashPath),
+		Dev:       dev,
+		Inode:     ino,
+		Size:      info.Size(),
+		IsDir:     info.IsDir(),
+		Username:  username,
+	}
+	var links []storage.TrashLink
+	if info.IsDir() {
+		item.Size, links = folderLinks(path)
+	} else if info.Mode().IsRegular() {
+		links = []storage.TrashLink{{Dev: dev, Inode: ino}}
+	}
+
+	if keep {
+		if !info.Mode().IsRegular() {
+			return nil, fmt.Errorf("%s is not a regular file", t.rel(path))
+		}
+		if err := os.Link(path, trashPath); err != nil {
+			return nil, fmt.Errorf("failed to link into the trash: %w", err)
+		}
+	} else if err := os.Rename(path, trashPath); err != nil {
+		return nil, fmt.Errorf("failed to move to the trash: %w", err)
+	}
+	if _, err := t.db.AddTrashItem(item, links); err != nil {
+		// An untracked item could never be restored nor purged
+		if keep {
+			if rerr := os.Remove(trashPath); rerr != nil {
+				log.Printf("Failed to remove %s from the trash: %v", trashPath, rerr)
+			}
+		} else if rerr := os.Rename(trashPath, path); rerr != nil {
+			log.Printf("Failed to move %s back from the trash: %v", path, rerr)
+		}
+		return nil, fmt.Errorf("failed to record trash item: %w", err)
+	}
+	if !keep {
+		t.db.RemoveInodePaths(path)
+	}
+
+	log.Printf("TRASH %s -> %s by %s", path, trashPath, username)
+	return item, nil
+}
+
+// Restore moves a trashed item back to its original path and returns it
+func (t *Trash) Restore(id int64, username string) (*storage.TrashItem, error) {
+	item, err := t.db.GetTrashItem(id)
+	if err != nil {
+		return nil, err
+	}
+	if item == nil {
+		return nil, ErrNotFound
+	}
+
+	trashPath, path, err := t.paths(item)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := os.Lstat(trashPath); os.IsNotExist(err) {
+		t.db.DeleteTrashItem(item.ID)
+		return nil, fmt.Errorf("%w: %s no longer exists", ErrNotFound, item.TrashPath)
+	}
+	if _, err := os.Lstat(path); err == nil {
+		return nil, fmt.Errorf("%w: %s exists", ErrPathTaken, item.Path)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create parent directory: %w", err)
+	}
+	if err := os.Rename(trashPath, path); err != nil {
+		return nil, fmt.Errorf("failed to restore %s: %w", item.Path, err)
+	}
+	if err := t.db.DeleteTrashItem(item.ID); err != nil {
+		log.Printf("Failed to delete trash item %d: %v", item.ID, err)
+	}
+
+	// The files of a folder are indexed by the next pass
+	if !item.IsDir {
+		indexer.AddPath(t.db, item.Dev, item.Inode, path)
+	}
+
+	log.Printf("TRASH RESTORE %s by %s", path, username)
+	return item, nil
+}
+
+// Delete permanently removes a trashed item
+func (t *Trash) Delete(id int64, username string) (*storage.TrashItem, error) {
+	item, err := t.db.GetTrashItem(id)
+	if err != nil {
+		return nil, err
+	}
+	if item == nil {
+		return nil, ErrNotFound
+	}
+	if err := t.remove(item); err != nil {
+		return nil, err
+	}
+
+	log.Printf("TRASH DELETE %s by %s", item.TrashPath, username)
+	return item, nil
+}
+
+// Empty permanently removes the items trashed before the given time, or all
+// of them for a zero time, and returns their number and total size
+func (t *Trash) Empty(before time.Time, username string) (int, int64, error) {
+	limit := int64(math.MaxInt64)
+	if !before.IsZero() {
+		limit = before.Unix()
+	}
+	items, err := t.db.ListTrashItemsBefore(limit)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to list trash items: %w", err)
+	}
+
+	count := 0
+	var size int64
+	for i := range items {
+		if err := t.remove(&items[i]); err != nil {
+			log.Printf("Failed to empty %s: %v", items[i].TrashPath, err)
+			continue
+		}
+		count++
+		size += items[i].Size
+	}
+
+	if count > 0 {
+		log.Printf("TRASH EMPTY items=%d bytes=%d by %s", count, size, username)
+	}
+	return count, size, nil
+}
+
+// Run purges the items older than the retention until ctx is cancelled
+func (t *Trash) Run(ctx context.Context) {
+	if t.retention <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(purgeInterval)
+	defer ticker.Stop()
+
+	for {
+		if _, _, err := t.Empty(time.Now().Add(-t.retention), "retention"); err != nil {
+			log.Printf("Failed to purge the trash: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// remove deletes the files of a trash item and its record
+func (t *Trash) remove(item *storage.TrashItem) error {
+	trashPath, _, err := t.paths(item)
+	if err != nil {
+		return err
+	}
+	if err := os.RemoveAll(trashPath); err != nil {
+		return fmt.Errorf("failed to delete %s: %w", item.TrashPath, err)
+	}
+	return t.db.DeleteTrashItem(item.ID)
+}
+
+// paths returns the absolute trash and original paths of an item
+func (t *Trash) paths(item *storage.TrashItem) (string, string, error) {
+	trashPath, err := t.abs(item.TrashPath)
+	if err != nil {
+		return "", "", err
+	}
+	path, err := t.abs(item.Path)
+	if err != nil {
+		return "", "", err
+	}
+	// Only paths inside a trash directory are ever removed
+	if filepath.Base(filepath.Dir(trashPath)) != fsutil.TrashDir() {
+		return "", "", fmt.Errorf("%s is not in a trash directory", item.TrashPath)
+	}
+	return trashPath, path, nil
+}
+
+// rel returns path relative to the data root, with a leading slash
+func (t *Trash) rel(path string) string {
+	rel, err := filepath.Rel(t.dataRoot, path)
+	if err != nil {
+		return path
+	}
+	return "/" + filepath.ToSlash(rel)
+}
+
+// abs returns the absolute path of a recorded path
+func (t *Trash) abs(rel string) (string, error) {
+	path := filepath.Clean(filepath.Join(t.dataRoot, strings.TrimPrefix(rel, "/")))
+	if !strings.HasPrefix(path, t.dataRoot) {
+		return "", fmt.Errorf("path outside root: %s", rel)
+	}
+	return path, nil
+}
+
+// isSubPath reports whether path is dir or one of its descendants
+func isSubPath(dir, path string) bool {
+	rel, err := filepath.Rel(dir, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, "../")
+}
+
+// folderLinks returns the total size and the inodes of the regular files of
+// a folder
+func folderLinks(root string) (int64, []storage.TrashLink) {
+	var size int64
+	var links []storage.TrashLink
+	filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || !d.Type().IsRegular() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		size += info.Size()
+		if dev, ino, _, ok := fsutil.Identity(info); ok {
+			links = append(links, storage.TrashLink{Dev: dev, Inode: ino})
+		}
+		return nil
+	})
+	return size, links
+}
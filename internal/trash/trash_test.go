@@ -0,0 +1,279 @@
+package trash
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gosiva/hardlink-ui/internal/fsutil"
+	"github.com/gosiva/hardlink-ui/internal/storage"
+)
+
+// TestDirFor verifies that the trash of a path is at the top of its device
+// under the data root, and that mount points are refused
+func TestDirFor(t *testing.T) {
+	// /data and /data/media are on device 1, /data/disk2 is mounted from
+	// device 2
+	devices := map[string]uint64{
+		"/data":                 1,
+		"/data/media":           1,
+		"/data/media/films":     1,
+		"/data/disk2":           2,
+		"/data/disk2/downloads": 2,
+	}
+	defer func(orig func(string) (uint64, error)) { device = orig }(device)
+	device = func(path string) (uint64, error) {
+		if dev, ok := devices[path]; ok {
+			return dev, nil
+		}
+		return 0, errors.New("no such file")
+	}
+
+	tr := New(nil, "/data", 0)
+	tests := []struct {
+		path    string
+		dev     uint64
+		want    string
+		wantErr string
+	}{
+		{"/data/film.mkv", 1, "/data/.hardlink-ui-trash", ""},
+		{"/data/media/films/film.mkv", 1, "/data/.hardlink-ui-trash", ""},
+		{"/data/media/films", 1, "/data/.hardlink-ui-trash", ""},
+		{"/data/disk2/downloads/film.mkv", 2, "/data/disk2/.hardlink-ui-trash", ""},
+		{"/data/disk2/downloads", 2, "/data/disk2/.hardlink-ui-trash", ""},
+		{"/data/disk2", 2, "", "/disk2 is a mount point"},
+		{"/data/missing/film.mkv", 1, "", "failed to stat /missing"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			got, err := tr.dirFor(tt.path, tt.dev)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Expected error %q, got %q, %v", tt.wantErr, got, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if got != filepath.FromSlash(tt.want) {
+				t.Errorf("Expected %s, got %s", tt.want, got)
+			}
+		})
+	}
+
+	// A configured name replaces the default one, in walks too
+	defer fsutil.SetTrashDir(fsutil.DefaultTrashDir)
+	fsutil.SetTrashDir("#trash")
+	if got, err := tr.dirFor("/data/disk2/downloads/film.mkv", 2); err != nil || got != filepath.FromSlash("/data/disk2/#trash") {
+		t.Errorf("Expected the configured trash directory, got %s, %v", got, err)
+	}
+	if !fsutil.SkipDir("#trash") || fsutil.SkipDir(fsutil.DefaultTrashDir) {
+		t.Error("Expected walks to skip the configured trash directory only")
+	}
+}
+
+// newTestTrash creates a trash over an empty data root with its database
+func newTestTrash(t *testing.T) (*Trash, string) {
+	t.Helper()
+	tmpDir := t.TempDir()
+	dataRoot := filepath.Join(tmpDir, "data")
+	if err := os.MkdirAll(dataRoot, 0755); err != nil {
+		t.Fatalf("Failed to create data dir: %v", err)
+	}
+	db, err := storage.New(filepath.Join(tmpDir, "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return New(db, dataRoot, 0), dataRoot
+}
+
+// writeFile creates a file and its parent directories
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatalf("Failed to create dir: %v", err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write %s: %v", path, err)
+	}
+}
+
+// trashedLinks returns the number of trashed links to the inode of path
+func trashedLinks(t *testing.T, tr *Trash, path string) int {
+	t.Helper()
+	info, err := os.Lstat(path)
+	if err != nil {
+		t.Fatalf("Failed to stat %s: %v", path, err)
+	}
+	dev, ino, _, _ := fsutil.Identity(info)
+	n, err := tr.db.CountTrashedLinks(dev, ino)
+	if err != nil {
+		t.Fatalf("Failed to count trashed links: %v", err)
+	}
+	return n
+}
+
+// TestMoveAndRestore verifies that trashed files and folders keep their
+// inodes and are restored in place
+func TestMoveAndRestore(t *testing.T) {
+	tr, dataRoot := newTestTrash(t)
+	film := filepath.Join(dataRoot, "media", "film.mkv")
+	link := filepath.Join(dataRoot, "downloads", "film.mkv")
+	writeFile(t, film, "film")
+	if err := os.MkdirAll(filepath.Dir(link), 0755); err != nil {
+		t.Fatalf("Failed to create dir: %v", err)
+	}
+	if err := os.Link(film, link); err != nil {
+		t.Fatalf("Failed to link: %v", err)
+	}
+
+	t.Run("file", func(t *testing.T) {
+		item, err := tr.Move(link, "admin")
+		if err != nil {
+			t.Fatalf("Failed to trash: %v", err)
+		}
+		if item.Path != "/downloads/film.mkv" || item.IsDir || item.Size != 4 || item.Username != "admin" {
+			t.Errorf("Unexpected item %+v", item)
+		}
+		if _, err := os.Lstat(link); !os.IsNotExist(err) {
+			t.Error("Expected the link to be moved")
+		}
+		if !strings.HasPrefix(item.TrashPath, "/"+fsutil.TrashDir()+"/") {
+			t.Errorf("Expected the item in the trash of the data root, got %s", item.TrashPath)
+		}
+		if n := trashedLinks(t, tr, film); n != 1 {
+			t.Errorf("Expected 1 trashed link, got %d", n)
+		}
+
+		// A taken path isn't overwritten
+		writeFile(t, link, "other")
+		if _, err := tr.Restore(item.ID, "admin"); !errors.Is(err, ErrPathTaken) {
+			t.Fatalf("Expected ErrPathTaken, got %v", err)
+		}
+		os.Remove(link)
+
+		if _, err := tr.Restore(item.ID, "admin"); err != nil {
+			t.Fatalf("Failed to restore: %v", err)
+		}
+		a, _ := os.Lstat(film)
+		b, err := os.Lstat(link)
+		if err != nil || !os.SameFile(a, b) {
+			t.Error("Expected the restored link to share the inode")
+		}
+		if n := trashedLinks(t, tr, film); n != 0 {
+			t.Errorf("Expected no trashed link, got %d", n)
+		}
+		if _, err := tr.Restore(item.ID, "admin"); !errors.Is(err, ErrNotFound) {
+			t.Errorf("Expected ErrNotFound, got %v", err)
+		}
+	})
+
+	t.Run("folder", func(t *testing.T) {
+		show := filepath.Join(dataRoot, "downloads", "Show")
+		writeFile(t, filepath.Join(show, "e01.mkv"), "e01")
+		writeFile(t, filepath.Join(show, "Season 1", "e02.mkv"), "e02!")
+		if err := os.Link(film, filepath.Join(show, "film.mkv")); err != nil {
+			t.Fatalf("Failed to link: %v", err)
+		}
+
+		item, err := tr.Move(show, "admin")
+		if err != nil {
+			t.Fatalf("Failed to trash: %v", err)
+		}
+		if !item.IsDir || item.Size != 11 {
+			t.Errorf("Expected a folder of 11 bytes, got %+v", item)
+		}
+		if n := trashedLinks(t, tr, film); n != 1 {
+			t.Errorf("Expected the files of the folder to be trashed links, got %d", n)
+		}
+
+		if _, err := tr.Restore(item.ID, "admin"); err != nil {
+			t.Fatalf("Failed to restore: %v", err)
+		}
+		if _, err := os.Lstat(filepath.Join(show, "Season 1", "e02.mkv")); err != nil {
+			t.Errorf("Expected the folder to be restored: %v", err)
+		}
+	})
+
+	t.Run("vanished", func(t *testing.T) {
+		item, err := tr.Move(link, "admin")
+		if err != nil {
+			t.Fatalf("Failed to trash: %v", err)
+		}
+		os.Remove(filepath.Join(dataRoot, filepath.FromSlash(item.TrashPath)))
+		if _, err := tr.Restore(item.ID, "admin"); !errors.Is(err, ErrNotFound) {
+			t.Errorf("Expected ErrNotFound, got %v", err)
+		}
+		if item, _ := tr.db.GetTrashItem(item.ID); item != nil {
+			t.Error("Expected the vanished item to be forgotten")
+		}
+	})
+}
+
+// TestKeep verifies that a kept file stays in place with a link in the trash
+func TestKeep(t *testing.T) {
+	tr, dataRoot := newTestTrash(t)
+	path := filepath.Join(dataRoot, "seed", "film.mkv")
+	writeFile(t, path, "film")
+
+	item, err := tr.Keep(path, "admin")
+	if err != nil {
+		t.Fatalf("Failed to keep: %v", err)
+	}
+	info, err := os.Lstat(path)
+	if err != nil {
+		t.Fatal("Expected the file to be left in place")
+	}
+	if _, _, nlink, _ := fsutil.Identity(info); nlink != 2 {
+		t.Errorf("Expected a link in the trash, got %d links", nlink)
+	}
+	if n := trashedLinks(t, tr, path); n != 1 {
+		t.Errorf("Expected 1 trashed link, got %d", n)
+	}
+
+	// Deleting the item removes the trash link only
+	if _, err := tr.Delete(item.ID, "admin"); err != nil {
+		t.Fatalf("Failed to delete: %v", err)
+	}
+	info, _ = os.Lstat(path)
+	if _, _, nlink, _ := fsutil.Identity(info); nlink != 1 {
+		t.Errorf("Expected the trash link to be removed, got %d links", nlink)
+	}
+
+	if _, err := tr.Keep(filepath.Dir(path), "admin"); err == nil {
+		t.Error("Expected a folder to be refused")
+	}
+}
+
+// TestEmpty verifies that emptying removes the items trashed before a time
+func TestEmpty(t *testing.T) {
+	tr, dataRoot := newTestTrash(t)
+	for _, name := range []string{"a.mkv", "b.mkv"} {
+		path := filepath.Join(dataRoot, name)
+		writeFile(t, path, name)
+		if _, err := tr.Move(path, "admin"); err != nil {
+			t.Fatalf("Failed to trash: %v", err)
+		}
+	}
+
+	count, _, err := tr.Empty(time.Now().Add(-time.Hour), "admin")
+	if err != nil || count != 0 {
+		t.Errorf("Expected recent items to be kept, got %d, %v", count, err)
+	}
+	count, size, err := tr.Empty(time.Time{}, "admin")
+	if err != nil || count != 2 || size != 10 {
+		t.Errorf("Expected 2 items of 10 bytes to be removed, got %d, %d, %v", count, size, err)
+	}
+	entries, _ := os.ReadDir(filepath.Join(dataRoot, fsutil.TrashDir()))
+	if len(entries) != 0 {
+		t.Errorf("Expected an empty trash directory, got %d entries", len(entries))
+	}
+	if _, err := tr.Delete(1, "admin"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound, got %v", err)
+	}
+}
@@ -17,6 +17,8 @@ const explorerTableBody = document.querySelector("#fb-table tbody");
 const detailsEl = document.getElementById("details");
 const explorerDeleteToggle = document.getElementById("explorer-delete-toggle");
 const explorerDeleteStatus = document.getElementById("explorer-delete-status");
+const explorerRenameBtn = document.getElementById("explorer-rename");
+const explorerMoveBtn = document.getElementById("explorer-move");
 
 // Hardlink creator
 const hlSrcBreadcrumb = document.getElementById("hl-src-breadcrumb");
@@ -31,6 +33,10 @@ const hlSelectMode = document.getElementById("hl-select-mode");
 
 // Doublons
 const btnDupScan = document.getElementById("btn-dup-scan");
+const btnDupPause = document.getElementById("btn-dup-pause");
+const btnDupResume = document.getElementById("btn-dup-resume");
+const btnDupCancel = document.getElementById("btn-dup-cancel");
+const dupForceRehash = document.getElementById("dup-force-rehash");
 const btnDupConvert = document.getElementById("btn-dup-convert");
 const dupTableBody = document.querySelector("#dup-table tbody");
 const dupSummaryEl = document.getElementById("dup-summary");
@@ -55,6 +61,11 @@ let isLoadingFolder = false;
 let currentPath = "/";
 let currentSelection = null;
 let explorerDeleteMode = false;
+let explorerSort = { sort: "name", order: "asc" };
+let explorerCursor = "";
+
+// Number of entries loaded per page of a directory listing
+const LIST_PAGE_SIZE = 500;
 
 // Hardlink creator state
 let hlSrcPath = "/";
@@ -315,6 +326,86 @@ function hideLoadingOverlay() {
     });
 }
 
+// ----- BACKGROUND JOBS -----
+
+// runJob starts a bulk operation as a background job and follows its
+// progress in the loading overlay, which can cancel it. It resolves with the
+// report of the job, partial when the job was cancelled.
+async function runJob(url, body, message) {
+    const res = await fetch(url, {
+        method: "POST",
+        headers: { "Content-Type": "application/json" },
+        body: JSON.stringify({ ...body, async: true })
+    });
+    const start = await res.json();
+    if (!start.ok || !start.job_id) throw new Error(start.error || "Impossible de démarrer la tâche");
+
+    const jobId = start.job_id;
+    addLog("info", `Tâche ${jobId} démarrée`, "debug");
+
+    showLoadingOverlay(message);
+    const content = document.querySelector("#loading-overlay .modal-content > div");
+    const progressEl = document.createElement("p");
+    progressEl.style.margin = "0";
+    progressEl.style.color = "var(--fg-muted)";
+    progressEl.textContent = "En attente...";
+    const cancelBtn = document.createElement("button");
+    cancelBtn.className = "btn btn-secondary";
+    cancelBtn.textContent = "Annuler";
+    cancelBtn.addEventListener("click", async () => {
+        cancelBtn.disabled = true;
+        try {
+            await fetch(`/api/jobs/${jobId}`, { method: "DELETE" });
+            addLog("warning", `Annulation de la tâche ${jobId} demandée`, "minimal");
+        } catch (err) {
+            addLog("error", `Erreur annulation : ${err.message}`, "minimal");
+        }
+    });
+    content.append(progressEl, cancelBtn);
+
+    const showProgress = (job) => {
+        const total = job.total ? ` / ${job.total}` : "";
+        progressEl.textContent = `${job.processed}${total} éléments traités` +
+            (job.failed ? `, ${job.failed} échec(s)` : "");
+    };
+
+    const job = await new Promise((resolve, reject) => {
+        // Polling takes over when the stream is interrupted
+        const poll = async () => {
+            try {
+                const res = await fetch(`/api/jobs/${jobId}`);
+                const job = await res.json();
+                if (!res.ok) throw new Error(job.error || "Tâche introuvable");
+                showProgress(job);
+                if (job.status === "running") setTimeout(poll, 1000);
+                else resolve(job);
+            } catch (err) {
+                reject(err);
+            }
+        };
+
+        const source = new EventSource(`/api/jobs/${jobId}/progress`);
+        source.onmessage = (e) => {
+            const job = JSON.parse(e.data);
+            showProgress(job);
+            if (job.status !== "running") {
+                source.close();
+                resolve(job);
+            }
+        };
+        source.onerror = () => {
+            source.close();
+            addLog("info", `Flux de la tâche ${jobId} interrompu, suivi par requêtes`, "trace");
+            poll();
+        };
+    }).finally(hideLoadingOverlay);
+
+    addLog("info", `Tâche ${jobId} terminée : ${job.status}, ${job.processed} traités, ${job.failed} échecs`, "debug");
+    if (job.status === "failed") throw new Error(job.error || "La tâche a échoué");
+    if (job.status === "cancelled") addLog("warning", "Tâche annulée : résultat partiel", "minimal");
+    return { ...job.report, cancelled: job.status === "cancelled" };
+}
+
 // ----- MOBILE TOOLTIP SYSTEM -----
 
 let currentTooltip = null;
@@ -642,6 +733,40 @@ function updateBreadcrumb(path) {
     });
 }
 
+// Streams a page of a directory listing as NDJSON, calling onEntry for each
+// entry, and resolves to the cursor of the next page and the total
+async function streamDirectoryPage(path, options, onEntry) {
+    const params = new URLSearchParams({
+        path,
+        format: "ndjson",
+        limit: LIST_PAGE_SIZE,
+        sort: options.sort || "name",
+        order: options.order || "asc",
+    });
+    if (options.cursor) params.set("cursor", options.cursor);
+
+    const res = await fetch("/api/list?" + params.toString());
+    if (!res.ok) throw new Error("Erreur HTTP " + res.status);
+
+    const reader = res.body.getReader();
+    const decoder = new TextDecoder();
+    let buffer = "";
+    for (;;) {
+        const { done, value } = await reader.read();
+        buffer += decoder.decode(value || new Uint8Array(), { stream: !done });
+        const lines = buffer.split("\n");
+        buffer = lines.pop();
+        lines.filter(line => line.trim()).forEach(line => onEntry(JSON.parse(line)));
+        if (done) break;
+    }
+    if (buffer.trim()) onEntry(JSON.parse(buffer));
+
+    return {
+        cursor: res.headers.get("X-Next-Cursor") || "",
+        total: parseInt(res.headers.get("X-Total-Count") || "0", 10),
+    };
+}
+
 async function loadFolder(path) {
     if (!explorerTableBody) return;
     if (isLoadingFolder) return;
@@ -652,46 +777,24 @@ async function loadFolder(path) {
             <span class="spinner"></span> Chargement…
         </td></tr>
     `;
+    currentSelection = null;
     updateBreadcrumb(path);
+    updateExplorerSortHeaders();
     addLog("info", `Ouverture du dossier (explorateur) : ${path}`);
 
     try {
-        const res = await fetch("/api/list?path=" + encodeURIComponent(path));
-        if (!res.ok) throw new Error("Erreur HTTP " + res.status);
-        const data = await res.json();
-
-        explorerTableBody.innerHTML = "";
-
-        // Handle empty folders (ensure entries is an array)
-        const entries = data.entries || [];
-        entries.forEach(e => {
-            const tr = document.createElement("tr");
-            tr.classList.add("fb-row");
-            tr.dataset.name = e.name;
-            tr.dataset.path = e.path;
-            tr.dataset.isDir = e.is_dir ? "1" : "0";
-            tr.dataset.nlink = e.nlink;
-
-            const icon = e.is_dir ? "📁" : "📄";
-            const hlBadge = e.nlink > 1 ? `<span class="badge">${e.nlink}</span>` : "";
-            
-            // Add visual indicator for deletable hardlinks in delete mode
-            let deleteIndicator = "";
-            if (!e.is_dir && e.nlink > 1) {
-                tr.classList.add("deletable-hardlink");
+        let first = true;
+        const page = await streamDirectoryPage(path, explorerSort, e => {
+            if (first) {
+                explorerTableBody.innerHTML = "";
+                first = false;
             }
-
-            tr.innerHTML = `
-                <td>${icon}</td>
-                <td title="${escapeHtml(decodeName(e.name))}">${escapeHtml(decodeName(e.name))}</td>
-                <td>${hlBadge}</td>
-                <td>${escapeHtml(e.size_human || "")}</td>
-            `;
-
-            tr.addEventListener("click", () => onRowClickExplorer(e, tr));
-
-            explorerTableBody.appendChild(tr);
+            appendExplorerRow(e, e.name);
         });
+        if (first) explorerTableBody.innerHTML = "";
+
+        explorerCursor = page.cursor;
+        appendLoadMoreRow(path, page.total);
 
         addLog("success", `Dossier chargé (explorateur) : ${path}`);
         
@@ -705,38 +808,215 @@ async function loadFolder(path) {
     }
 }
 
+// Appends a row loading the next page of the folder, when there is one
+function appendLoadMoreRow(path, total) {
+    if (!explorerCursor) return;
+
+    const remaining = total - explorerTableBody.querySelectorAll("tr[data-path]").length;
+    const tr = document.createElement("tr");
+    tr.className = "fb-load-more";
+    tr.innerHTML = `<td colspan="4"><button class="btn">Afficher plus (${remaining} restants)</button></td>`;
+    tr.querySelector("button").onclick = () => loadMoreEntries(path, tr);
+    explorerTableBody.appendChild(tr);
+}
+
+async function loadMoreEntries(path, row) {
+    if (isLoadingFolder || path !== currentPath) return;
+    isLoadingFolder = true;
+    row.innerHTML = `<td colspan="4"><span class="spinner"></span> Chargement…</td>`;
+
+    try {
+        const page = await streamDirectoryPage(path, { ...explorerSort, cursor: explorerCursor }, e => {
+            explorerTableBody.insertBefore(appendExplorerRow(e, e.name), row);
+        });
+        row.remove();
+        explorerCursor = page.cursor;
+        appendLoadMoreRow(path, page.total);
+        setupTooltipsForTable(explorerTableBody);
+    } catch (err) {
+        row.innerHTML = `<td colspan="4">Erreur de chargement</td>`;
+        addLog("error", `Erreur chargement dossier : ${err.message}`);
+    } finally {
+        isLoadingFolder = false;
+    }
+}
+
+// Shows the sort order on the sortable explorer headers
+function updateExplorerSortHeaders() {
+    document.querySelectorAll("#fb-table th[data-sort]").forEach(th => {
+        const label = th.dataset.label || th.textContent;
+        th.dataset.label = label;
+        if (th.dataset.sort === explorerSort.sort) {
+            th.textContent = label + (explorerSort.order === "asc" ? " ▲" : " ▼");
+        } else {
+            th.textContent = label;
+        }
+    });
+}
+
+document.querySelectorAll("#fb-table th[data-sort]").forEach(th => {
+    th.style.cursor = "pointer";
+    th.onclick = () => {
+        if (explorerSort.sort === th.dataset.sort) {
+            explorerSort.order = explorerSort.order === "asc" ? "desc" : "asc";
+        } else {
+            explorerSort = { sort: th.dataset.sort, order: "asc" };
+        }
+        loadFolder(currentPath);
+    };
+});
+
+// Appends a file entry to the explorer table, labelled by its name or path,
+// and returns its row
+function appendExplorerRow(e, label) {
+    const tr = document.createElement("tr");
+    tr.classList.add("fb-row");
+    tr.dataset.name = e.name;
+    tr.dataset.path = e.path;
+    tr.dataset.isDir = e.is_dir ? "1" : "0";
+    tr.dataset.nlink = e.nlink;
+
+    const icon = e.is_dir ? "📁" : "📄";
+    const hlBadge = e.nlink > 1 ? `<span class="badge">${e.nlink}</span>` : "";
+
+    // Add visual indicator for deletable hardlinks in delete mode
+    if (!e.is_dir && e.nlink > 1) {
+        tr.classList.add("deletable-hardlink");
+    }
+
+    tr.innerHTML = `
+        <td>${icon}</td>
+        <td title="${escapeHtml(decodeName(e.path))}">${escapeHtml(decodeName(label))}</td>
+        <td>${hlBadge}</td>
+        <td>${escapeHtml(e.size_human || "")}</td>
+    `;
+
+    tr.addEventListener("click", () => onRowClickExplorer(e, tr));
+
+    explorerTableBody.appendChild(tr);
+    return tr;
+}
+
+// Searches the whole data root by file name through the search index
+async function searchEverywhere(query) {
+    if (!explorerTableBody) return;
+
+    explorerTableBody.innerHTML = `
+        <tr><td colspan="4">
+            <span class="spinner"></span> Recherche…
+        </td></tr>
+    `;
+
+    const params = new URLSearchParams({ name: query, limit: 200 });
+    const links = document.getElementById("search-links").value;
+    if (links) params.set("links", links);
+
+    try {
+        const res = await fetch("/api/search?" + params.toString());
+        const data = await res.json();
+        if (!data.ok) throw new Error(data.error || "Erreur HTTP " + res.status);
+
+        explorerTableBody.innerHTML = "";
+        data.results.forEach(e => appendExplorerRow(e, e.path));
+        if (!data.results.length) {
+            explorerTableBody.innerHTML = `<tr><td colspan="4">Aucun résultat</td></tr>`;
+        } else if (data.total > data.results.length) {
+            const tr = document.createElement("tr");
+            tr.innerHTML = `<td colspan="4" class="text-muted">… ${data.total - data.results.length} autres résultats, précise la recherche</td>`;
+            explorerTableBody.appendChild(tr);
+        }
+        setupTooltipsForTable(explorerTableBody);
+
+        addLog("info", `Recherche « ${query} » : ${data.total} résultat(s)`);
+        if (!data.indexed) {
+            addLog("warning", "L'index est en cours de construction, les résultats peuvent être incomplets");
+        }
+    } catch (err) {
+        explorerTableBody.innerHTML = `<tr><td colspan="4">Erreur de recherche</td></tr>`;
+        addLog("error", `Erreur recherche : ${err.message}`);
+    }
+}
+
+// Deletes a folder and its content once its report is confirmed: files whose
+// last link is in the folder are lost, the others stay in their other folders
+async function deleteExplorerFolder(entry, report) {
+    try {
+        if (!report) {
+            const res = await fetch("/api/delete-folder", {
+                method: "POST",
+                headers: { "Content-Type": "application/json" },
+                body: JSON.stringify({ path: entry.path })
+            });
+            const data = await res.json();
+            if (!res.ok) throw new Error(data.error || "Erreur inconnue");
+            report = data.report;
+        }
+    } catch (err) {
+        addLog("error", `Erreur analyse du dossier : ${err.message}`);
+        showModal("error", "Erreur de suppression", err.message);
+        return;
+    }
+
+    let message = `Dossier : ${decodeName(entry.name)}\n` +
+        `${report.files} fichier(s), ${report.dirs} dossier(s), ${formatBytes(report.bytes)}\n\n` +
+        `✅ ${report.linked_files} fichier(s) restent accessibles ailleurs (${formatBytes(report.linked_bytes)})\n`;
+    if (report.lost_files > 0) {
+        message += `❌ ${report.lost_files} fichier(s) seront définitivement perdus (${formatBytes(report.lost_bytes)}) :\n` +
+            report.lost.map(f => `- ${decodeName(f.path)}`).join("\n") +
+            (report.truncated ? "\n…" : "") + "\n";
+    } else {
+        message += "Aucune donnée ne sera perdue.\n";
+    }
+    message += "\nCette action est irréversible.";
+
+    showConfirmModal(
+        report.lost_files > 0 ? "⚠️ Supprimer ce dossier et des données ?" : "Supprimer ce dossier ?",
+        message,
+        async () => {
+            try {
+                const res = await fetch("/api/delete-folder", {
+                    method: "POST",
+                    headers: { "Content-Type": "application/json" },
+                    body: JSON.stringify({ path: entry.path, token: report.token })
+                });
+                const data = await res.json();
+                if (res.status === 409 && data.report) {
+                    // The folder changed: confirm the new report
+                    addLog("warning", `Le dossier a changé : ${entry.path}`);
+                    deleteExplorerFolder(entry, data.report);
+                    return;
+                }
+                if (!data.ok) throw new Error(data.error || "Erreur inconnue");
+
+                const failed = data.failed || [];
+                if (data.trash_id) {
+                    addLog("success", `Dossier déplacé dans la corbeille : ${entry.path} (${data.trashed_files} fichier(s), ${formatBytes(data.trashed_bytes)})`);
+                    showModal("success", "Suppression réussie", "Le dossier a été déplacé dans la corbeille (Paramètres), d'où il peut être restauré.");
+                } else {
+                    addLog(failed.length ? "warning" : "success",
+                        `Dossier supprimé : ${entry.path} (${data.deleted_files} fichier(s), ${formatBytes(data.freed_bytes)} libérés)`);
+                    if (failed.length) {
+                        showModal("warning", "Suppression partielle",
+                            failed.map(f => `${decodeName(f.path)} : ${f.error}`).join("\n"));
+                    } else {
+                        showModal("success", "Suppression réussie", `${formatBytes(data.freed_bytes)} libérés.`);
+                    }
+                }
+                loadFolder(currentPath);
+                loadTrash();
+            } catch (err) {
+                addLog("error", `Erreur suppression : ${err.message}`);
+                showModal("error", "Erreur de suppression", err.message);
+            }
+        }
+    );
+}
+
 function onRowClickExplorer(entry, rowEl) {
     // In delete mode, handle deletion for both empty directories and hardlinks
     if (explorerDeleteMode) {
         if (entry.is_dir) {
-            // Try to delete directory (backend will check if it's empty)
-            showConfirmModal(
-                "⚠️ Supprimer ce dossier ?",
-                `Dossier : ${entry.name}\n\nSeuls les dossiers vides peuvent être supprimés.\nCette action est irréversible.`,
-                async () => {
-                    try {
-                        const res = await fetch("/api/delete-hardlink", {
-                            method: "POST",
-                            headers: { "Content-Type": "application/json" },
-                            body: JSON.stringify({ path: entry.path })
-                        });
-                        const data = await res.json();
-                        
-                        if (!data.ok) {
-                            throw new Error(data.error || "Erreur inconnue");
-                        }
-                        
-                        addLog("success", `Dossier vide supprimé : ${entry.path}`);
-                        showModal("success", "Suppression réussie", `Le dossier vide a été supprimé.`);
-                        
-                        // Reload current folder
-                        loadFolder(currentPath);
-                    } catch (err) {
-                        addLog("error", `Erreur suppression : ${err.message}`);
-                        showModal("error", "Erreur de suppression", err.message);
-                    }
-                }
-            );
+            deleteExplorerFolder(entry);
             return;
         }
         
@@ -759,7 +1039,9 @@ function onRowClickExplorer(entry, rowEl) {
                         }
                         
                         addLog("success", `Hardlink supprimé : ${entry.path} (${data.remaining_links} liens restants)`);
-                        showModal("success", "Suppression réussie", `Le hardlink a été supprimé.\n${data.remaining_links} lien(s) restant(s) vers ce fichier.`);
+                        const trashed = data.trash_id ? "\nIl peut être restauré depuis la corbeille (Paramètres)." : "";
+                        showModal("success", "Suppression réussie", `Le hardlink a été supprimé.\n${data.remaining_links} lien(s) restant(s) vers ce fichier.${trashed}`);
+                        loadTrash();
                         
                         // Reload current folder
                         loadFolder(currentPath);
@@ -838,7 +1120,7 @@ async function loadDetails(entry) {
     }
 }
 
-// Search
+// Search: typing filters the current folder, Enter searches everywhere
 if (searchInput) {
     searchInput.addEventListener("input", e => {
         const q = e.target.value.toLowerCase();
@@ -847,6 +1129,15 @@ if (searchInput) {
             row.style.display = name.includes(q) ? "" : "none";
         });
     });
+    searchInput.addEventListener("keydown", e => {
+        if (e.key !== "Enter") return;
+        const q = searchInput.value.trim();
+        if (q) {
+            searchEverywhere(q);
+        } else {
+            loadFolder(currentPath);
+        }
+    });
 }
 
 // Delete mode toggle
@@ -872,6 +1163,71 @@ if (explorerDeleteToggle) {
     });
 }
 
+// Rename and move act on the selected file, or on the open folder
+function explorerTarget() {
+    const target = currentSelection || currentPath;
+    if (target === "/") {
+        showModal("warning", "Sélection requise", "Sélectionne un fichier ou ouvre un dossier.");
+        return null;
+    }
+    return target;
+}
+
+function parentPath(path) {
+    return path.substring(0, path.lastIndexOf("/")) || "/";
+}
+
+// Posts a rename or move, then shows the result in the explorer
+async function moveExplorerItem(url, body, target) {
+    try {
+        const res = await fetch(url, {
+            method: "POST",
+            headers: { "Content-Type": "application/json" },
+            body: JSON.stringify(body)
+        });
+        const data = await res.json();
+        if (!data.ok) throw new Error(data.error || "Erreur inconnue");
+
+        addLog("success", `${target} → ${data.path}`);
+        // The open folder follows its own move
+        loadFolder(target === currentPath ? data.path : currentPath);
+    } catch (err) {
+        addLog("error", `Erreur déplacement : ${err.message}`);
+        showModal("error", "Déplacement impossible", err.message);
+    }
+}
+
+if (explorerRenameBtn) {
+    explorerRenameBtn.addEventListener("click", () => {
+        const target = explorerTarget();
+        if (!target) return;
+
+        const oldName = decodeName(target.substring(target.lastIndexOf("/") + 1));
+        showPromptModal("Renommer", "Nouveau nom :", escapeHtml(oldName), name => {
+            if (!name || name === oldName) return;
+
+            const validation = validateFolderName(name);
+            if (!validation.valid) {
+                showModal("error", "Nom invalide", validation.error);
+                return;
+            }
+            moveExplorerItem("/api/rename", { path: target, name: validation.name }, target);
+        });
+    });
+}
+
+if (explorerMoveBtn) {
+    explorerMoveBtn.addEventListener("click", () => {
+        const target = explorerTarget();
+        if (!target) return;
+
+        showPromptModal("Déplacer", "Dossier de destination (sur le même disque) :", escapeHtml(parentPath(target)), destDir => {
+            if (!destDir) return;
+            moveExplorerItem("/api/move", { source: target, dest_dir: destDir }, target);
+        });
+    });
+}
+
 // ---------- HARDLINK CREATOR ----------
 
 function updateBreadcrumbGeneric(container, path, onClick) {
@@ -1195,6 +1551,10 @@ async function createHardlinks() {
 
     let totalCreated = 0;
     let errors = [];
+    const syncMode = document.getElementById("hl-sync-mode").value;
+    const overwrite = document.getElementById("hl-overwrite").checked;
+    const filters = hlFolderFilters();
+    const renaming = hlRenameOptions();
 
     addLog("info", `Début de création de hardlinks pour ${items.length} élément(s)...`, "debug");
 
@@ -1234,23 +1594,50 @@ async function createHardlinks() {
         if (item.isDir) {
             const src = item.path;
             const srcName = src.split("/").pop() || "";
-            const destRoot = (destination === "/" ? "" : destination) + "/" + srcName;
+            // Renamed files go directly into the destination
+            const destRoot = renaming.template
+                ? destination
+                : (destination === "/" ? "" : destination) + "/" + srcName;
 
             console.log("📁 Dossier:", src, "→", destRoot);
             addLog("info", `Traitement du dossier : ${src} → ${destRoot}`, "trace");
 
+            // Preview the plan before asking for confirmation
+            let planText = "";
+            try {
+                const res = await fetch("/api/create-hardlinks-folder", {
+                    method: "POST",
+                    headers: { "Content-Type": "application/json" },
+                    body: JSON.stringify({ source: src, dest_root: destRoot, mode: syncMode, overwrite, ...filters, ...renaming, dry_run: true })
+                });
+                const plan = await res.json();
+                if (plan.ok) {
+                    const actions = { link: 0, replace: 0, remove: 0 };
+                    (plan.diff || []).filter(item => item.status === "planned").forEach(item => actions[item.action]++);
+                    planText = `\n\nHardlinks à créer : ${actions.link} (${plan.bytes_saved_human})` +
+                        (actions.replace ? `\nFichiers différents à remplacer : ${actions.replace}` : "") +
+                        (actions.remove ? `\nFichiers disparus de la source à supprimer : ${actions.remove}` : "") +
+                        (plan.protected ? `\nDerniers liens conservés : ${plan.protected}` : "") +
+                        (plan.differing ? `\nFichiers différents conservés : ${plan.differing}` : "") +
+                        (plan.unchanged ? `\nDéjà présents : ${plan.unchanged}` : "") +
+                        (plan.filtered ? `\nFichiers filtrés : ${plan.filtered}` : "") +
+                        (plan.dirs_created ? `\nDossiers à créer : ${plan.dirs_created}` : "") +
+                        (plan.errors && plan.errors.length ? `\nÉchecs prévus : ${plan.errors.length}` : "");
+                    (plan.errors || []).forEach(err => addLog("warning", `Simulation ${srcName} : ${err}`, "debug"));
+                }
+            } catch (err) {
+                addLog("warning", `Simulation impossible pour ${src} : ${err.message}`, "debug");
+            }
+
             showConfirmModal(
                 "Créer des hardlinks",
-                `Créer des hardlinks pour tout le dossier :\n${src}\nvers :\n${destRoot} ?`,
+                `Créer des hardlinks pour tout le dossier :\n${src}\nvers :\n${destRoot} ?${planText}`,
                 async () => {
                     try {
                         addLog("info", `Envoi de la requête pour le dossier ${src}...`, "trace");
-                        const res = await fetch("/api/create-hardlinks-folder", {
-                            method: "POST",
-                            headers: { "Content-Type": "application/json" },
-                            body: JSON.stringify({ source: src, dest_root: destRoot })
-                        });
-                        const data = await res.json();
+                        const data = await runJob("/api/create-hardlinks-folder",
+                            { source: src, dest_root: destRoot, mode: syncMode, overwrite, ...filters, ...renaming },
+                            `Création des hardlinks de ${srcName}...`);
                         
                         console.log("✅ Réponse API:", data);
                         addLog("info", `Réponse reçue pour ${src}: ${JSON.stringify(data)}`, "trace");
@@ -1266,7 +1653,13 @@ async function createHardlinks() {
                             });
                         }
                         
-                        addLog("success", `Dossier traité : ${srcName}, ${data.created} hardlinks créés`, "debug");
+                        (data.diff || []).filter(item => item.status !== "failed").forEach(item => {
+                            const label = item.differs ? "différent, conservé" : `${item.action} ${item.status}`;
+                            addLog("info", `  ${item.dest} : ${label}`, "trace");
+                        });
+                        addLog("success", `Dossier traité : ${srcName}, ${data.created} hardlinks créés` +
+                            (data.replaced ? `, ${data.replaced} remplacés` : "") +
+                            (data.removed ? `, ${data.removed} supprimés` : ""), "debug");
                     } catch (err) {
                         const errorMsg = `Erreur sur ${srcName}: ${err.message}`;
                         errors.push(errorMsg);
@@ -1286,7 +1679,9 @@ async function createHardlinks() {
         } else {
             const src = item.path;
             const srcName = src.split("/").pop();
-            const dest = (destination === "/" ? "" : destination) + "/" + srcName;
+            const dest = renaming.template
+                ? destination
+                : (destination === "/" ? "" : destination) + "/" + srcName;
 
             console.log("📄 Fichier:", src, "→", dest);
             addLog("info", `Traitement du fichier : ${src} → ${dest}`, "trace");
@@ -1295,7 +1690,7 @@ async function createHardlinks() {
                 const res = await fetch("/api/create-hardlink", {
                     method: "POST",
                     headers: { "Content-Type": "application/json" },
-                    body: JSON.stringify({ source: src, dest })
+                    body: JSON.stringify({ source: src, dest, ...renaming })
                 });
                 const data = await res.json();
                 
@@ -1325,6 +1720,58 @@ async function createHardlinks() {
 if (hlBtnNewFolder) hlBtnNewFolder.addEventListener("click", createDestFolder);
 if (hlBtnCreate) hlBtnCreate.addEventListener("click", createHardlinks);
 
+// Returns the rename template of the hardlink tab, empty when unset
+function hlRenameOptions() {
+    const template = document.getElementById("hl-template").value.trim();
+    const pattern = document.getElementById("hl-pattern").value.trim();
+    return template ? { template, pattern } : {};
+}
+
+// Previews the names the rename template gives to the selected source
+async function previewRename() {
+    const renaming = hlRenameOptions();
+    if (!renaming.template) {
+        showModal("warning", "Aperçu du renommage", "Saisis d'abord un modèle de renommage.");
+        return;
+    }
+    if (!hlSrcSelectedPaths.length) {
+        showModal("warning", "Aperçu du renommage", "Sélectionne d'abord une source.");
+        return;
+    }
+
+    const item = hlSrcSelectedPaths[0];
+    try {
+        const res = await fetch("/api/rename-preview", {
+            method: "POST",
+            headers: { "Content-Type": "application/json" },
+            body: JSON.stringify({
+                source: item.path,
+                dest_root: hlDestSelectedPath || "/",
+                ...(item.isDir ? hlFolderFilters() : {}),
+                ...renaming
+            })
+        });
+        const data = await res.json();
+        if (!data.ok) throw new Error(data.error || "Erreur inconnue");
+
+        const lines = data.items.slice(0, 20).map(file =>
+            file.error ? `✗ ${file.source} : ${file.error}` : `${file.source}\n  → ${file.dest}`);
+        data.items.forEach(file => addLog(file.error ? "warning" : "info",
+            file.error ? `Renommage ${file.source} : ${file.error}` : `Renommage ${file.source} → ${file.dest}`, "trace"));
+        showModal(data.conflicts || data.unmatched ? "warning" : "info", "Aperçu du renommage",
+            `Fichiers : ${data.total}` +
+            (data.unmatched ? `\nNon reconnus (ignorés) : ${data.unmatched}` : "") +
+            (data.conflicts ? `\nNoms en conflit : ${data.conflicts}` : "") +
+            `\n\n${lines.join("\n")}` +
+            (data.total > lines.length ? `\n… et ${data.total - lines.length} autres` : ""));
+    } catch (err) {
+        addLog("error", `Aperçu du renommage impossible : ${err.message}`);
+    }
+}
+
+const hlBtnRenamePreview = document.getElementById("hl-btn-rename-preview");
+if (hlBtnRenamePreview) hlBtnRenamePreview.addEventListener("click", previewRename);
+
 // Switch Single / Multi
 if (hlSelectMode) {
     hlSelectMode.addEventListener("click", (e) => {
@@ -1364,6 +1811,7 @@ if (hlSelectMode) {
 // ---------- DOUBLONS ----------
 
 let dupItems = [];
+let dupJobId = null;
 
 function resetDupDashboard() {
     if (dupCardGroups) dupCardGroups.textContent = "–";
@@ -1399,39 +1847,19 @@ function updateDupDashboard(stats) {
 async function scanDuplicates() {
     if (!dupTableBody) return;
 
-    dupTableBody.innerHTML = `
-        <tr><td colspan="4">
-            <span class="spinner"></span> Scan en cours…
-        </td></tr>
-    `;
-    if (dupSummaryEl) {
-        dupSummaryEl.textContent = "Scan en cours…";
-    }
+    showDupScanRunning();
     resetDupDashboard();
-    
-    // Hide actions container while scanning
-    const actionsContainer = document.getElementById("dup-actions-container");
-    if (actionsContainer) actionsContainer.style.display = "none";
-
-    let eventSource = null;
-    let timeoutId = null;
-
-    const cleanup = () => {
-        if (eventSource) {
-            eventSource.close();
-            eventSource = null;
-        }
-        if (timeoutId) {
-            clearTimeout(timeoutId);
-            timeoutId = null;
-        }
-    };
 
     try {
         // Step 1: Start scan job
-        const startRes = await fetch("/api/duplicates/scan");
+        const startRes = await fetch("/api/duplicates/scan", {
+            method: "POST",
+            headers: { "Content-Type": "application/json" },
+            body: JSON.stringify(dupScanOptions())
+        });
         if (!startRes.ok) {
-            throw new Error("HTTP " + startRes.status);
+            const data = await startRes.json().catch(() => ({}));
+            throw new Error(data.error || "HTTP " + startRes.status);
         }
         const startData = await startRes.json();
         const jobId = startData.job_id;
@@ -1442,165 +1870,755 @@ async function scanDuplicates() {
 
         addLog("info", `Scan démarré, job ID: ${jobId}`, "debug");
 
-        // Step 2: Subscribe to progress via SSE
-        addLog("info", `Connexion SSE à /api/duplicates/progress?job_id=${jobId}`, "trace");
-        eventSource = new EventSource(`/api/duplicates/progress?job_id=${jobId}`);
-        
-        // Log EventSource state changes
-        eventSource.addEventListener('open', () => {
-            addLog("info", `EventSource connecté (readyState=${eventSource.readyState})`, "trace");
-        });
-        
-        eventSource.onmessage = (event) => {
-            try {
-                addLog("info", `SSE message reçu, taille=${event.data.length} bytes`, "trace");
-                const progress = JSON.parse(event.data);
-                
-                // Update UI with progress
-                if (progress.status === "running") {
-                    const percent = progress.total_files > 0 
-                        ? Math.round((progress.processed / progress.total_files) * 100) 
-                        : 0;
-                    dupTableBody.innerHTML = `
-                        <tr><td colspan="4">
-                            <span class="spinner"></span> Scan en cours… ${percent}% (${progress.processed}/${progress.total_files} fichiers analysés)
-                        </td></tr>
-                    `;
-                    if (dupSummaryEl) {
-                        dupSummaryEl.textContent = `Scan en cours… ${progress.groups_found} groupes trouvés`;
-                    }
-                    addLog("info", `Progrès: ${percent}% (${progress.processed}/${progress.total_files})`, "trace");
-                } else if (progress.status === "completed") {
-                    cleanup();
-                    
-                    // Display results
-                    dupItems = progress.results || [];
-                    displayScanResults();
-                    
-                    addLog("info", `Scan doublons terminé : ${dupItems.length} groupes trouvés.`);
-                } else if (progress.status === "failed") {
-                    cleanup();
-                    throw new Error(progress.error || "Scan failed");
-                }
-            } catch (parseErr) {
-                console.error("Failed to parse progress:", parseErr);
-                addLog("info", "Erreur de parsing des progrès du scan", "debug");
-            }
-        };
-
-        eventSource.onerror = (err) => {
-            console.error("SSE error:", err);
-            addLog("info", `EventSource erreur (readyState=${eventSource.readyState}, type=${err.type})`, "trace");
-            cleanup();
-            
-            addLog("info", "SSE non disponible, basculement sur le mode polling...", "trace");
-            
-            // Fallback to polling with exponential backoff
-            let pollAttempts = 0;
-            const maxPollAttempts = 60; // ~6-10 minutes total with exponential backoff
-            const basePollDelay = 2000; // Start with 2 seconds
-            const startTime = Date.now();
-            const globalTimeout = 10 * 60 * 1000; // 10 minutes
-            
-            addLog("info", "Démarrage du mode polling avec backoff exponentiel", "debug");
+        watchScan(jobId);
+    } catch (err) {
+        dupTableBody.innerHTML = `<tr><td colspan="4">Erreur lors du scan.</td></tr>`;
+        if (dupSummaryEl) {
+            dupSummaryEl.textContent = "Erreur lors du scan des doublons.";
+        }
+        resetDupDashboard();
+        addLog("error", `Erreur scan doublons : ${err.message}`);
+    }
+}
+
+// Splits a comma separated input into trimmed, non-empty values
+function splitList(value) {
+    return (value || "").split(",").map(v => v.trim()).filter(v => v);
+}
+
+// Parses a size such as "500", "100M" or "2G" into bytes, 0 when empty
+function parseSizeInput(value) {
+    const match = (value || "").trim().toUpperCase().match(/^(\d+)\s*([KMG]?)O?B?$/);
+    if (!match) return 0;
+    const units = { "": 1, K: 1024, M: 1024 ** 2, G: 1024 ** 3 };
+    return parseInt(match[1], 10) * units[match[2]];
+}
+
+// Builds the folder filters from the hardlink tab inputs
+function hlFolderFilters() {
+    const value = id => document.getElementById(id).value;
+    return {
+        include: splitList(value("hl-include")),
+        exclude: splitList(value("hl-exclude")),
+        extensions: splitList(value("hl-extensions")),
+        min_size: parseSizeInput(value("hl-min-size")),
+        skip_hidden: document.getElementById("hl-skip-hidden").checked,
+        create_dirs: document.getElementById("hl-create-dirs").checked
+    };
+}
+
+// Returns the selected verification policy, empty for the server default
+function dupVerifyPolicy() {
+    const el = document.getElementById("dup-verify");
+    return el ? el.value : "";
+}
+
+// Returns the selected metadata policy, empty for the server default
+function dupMetadataPolicy() {
+    const el = document.getElementById("dup-metadata");
+    return el ? el.value : "";
+}
+
+// Logs the metadata differences and skipped files of a conversion
+function logConversionFiles(files) {
+    (files || []).forEach(file => {
+        if (file.status === "skipped") {
+            addLog("warning", `Ignoré : ${file.path} (${file.error})`);
+        } else if (file.conflicts && file.conflicts.length) {
+            const fields = file.conflicts.map(c => `${c.field} ${c.file} → ${c.master}`).join(", ");
+            addLog("warning", `Métadonnées de ${file.path} différentes du maître : ${fields}`);
+        }
+        if (file.merged && file.merged.length) {
+            addLog("info", `Fusionné dans ${file.master} depuis ${file.path} : ${file.merged.join(", ")}`);
+        }
+    });
+}
+
+// Builds the scan options from the scan panel inputs
+function dupScanOptions() {
+    const value = id => {
+        const el = document.getElementById(id);
+        return el ? el.value : "";
+    };
+    return {
+        force_rehash: !!(dupForceRehash && dupForceRehash.checked),
+        verify: value("dup-verify"),
+        roots: splitList(value("dup-roots")),
+        exclude: splitList(value("dup-exclude")),
+        min_size: parseSizeInput(value("dup-min-size")),
+        max_size: parseSizeInput(value("dup-max-size")),
+        extensions: splitList(value("dup-extensions")),
+        master: value("dup-master"),
+        prefer_paths: splitList(value("dup-prefer"))
+    };
+}
+
+function showDupScanRunning() {
+    dupTableBody.innerHTML = `
+        <tr><td colspan="4">
+            <span class="spinner"></span> Scan en cours…
+        </td></tr>
+    `;
+    if (dupSummaryEl) {
+        dupSummaryEl.textContent = "Scan en cours…";
+    }
+
+    // Hide actions container while scanning
+    const actionsContainer = document.getElementById("dup-actions-container");
+    if (actionsContainer) actionsContainer.style.display = "none";
+}
+
+// Shows the pause / resume / cancel buttons matching the job status
+function setDupScanControls(status) {
+    if (btnDupPause) btnDupPause.style.display = status === "running" ? "" : "none";
+    if (btnDupResume) btnDupResume.style.display = status === "paused" ? "" : "none";
+    if (btnDupCancel) btnDupCancel.style.display = (status === "running" || status === "paused") ? "" : "none";
+    if (btnDupScan) btnDupScan.disabled = status === "running";
+    // Keep the history in sync with the job status
+    loadScanHistory();
+}
+
+// Follows a scan job until it completes, fails, is paused or cancelled
+function watchScan(jobId) {
+    dupJobId = jobId;
+    setDupScanControls("running");
+
+    let eventSource = null;
+    let timeoutId = null;
+
+    const cleanup = () => {
+        if (eventSource) {
+            eventSource.close();
+            eventSource = null;
+        }
+        if (timeoutId) {
+            clearTimeout(timeoutId);
+            timeoutId = null;
+        }
+    };
+
+    // Subscribe to progress via SSE
+    addLog("info", `Connexion SSE à /api/duplicates/progress?job_id=${jobId}`, "trace");
+    eventSource = new EventSource(`/api/duplicates/progress?job_id=${jobId}`);
+    
+    // Log EventSource state changes
+    eventSource.addEventListener('open', () => {
+        addLog("info", `EventSource connecté (readyState=${eventSource.readyState})`, "trace");
+    });
+    
+    eventSource.onmessage = (event) => {
+        try {
+            addLog("info", `SSE message reçu, taille=${event.data.length} bytes`, "trace");
+            const progress = JSON.parse(event.data);
             
-            const pollResults = () => {
-                pollAttempts++;
-                const elapsed = Date.now() - startTime;
-                
-                // Check global timeout
-                if (elapsed > globalTimeout) {
-                    if (timeoutId) {
-                        clearTimeout(timeoutId);
-                        timeoutId = null;
-                    }
-                    dupTableBody.innerHTML = `<tr><td colspan="4">Le scan a pris trop de temps. Réessaye.</td></tr>`;
-                    if (dupSummaryEl) {
-                        dupSummaryEl.textContent = "Timeout lors du scan.";
-                    }
-                    resetDupDashboard();
-                    addLog("error", `Timeout global atteint après ${Math.round(elapsed/1000)}s`, "debug");
-                    return;
+            // Update UI with progress
+            if (progress.status === "running") {
+                const percent = progress.total_files > 0 
+                    ? Math.round((progress.processed / progress.total_files) * 100) 
+                    : 0;
+                dupTableBody.innerHTML = `
+                    <tr><td colspan="4">
+                        <span class="spinner"></span> Scan en cours… ${percent}% (${progress.processed}/${progress.total_files} fichiers analysés)
+                    </td></tr>
+                `;
+                if (dupSummaryEl) {
+                    dupSummaryEl.textContent = `Scan en cours… ${progress.groups_found} groupes trouvés`;
                 }
+                addLog("info", `Progrès: ${percent}% (${progress.processed}/${progress.total_files})`, "trace");
+            } else if (progress.status === "completed") {
+                cleanup();
+                setDupScanControls(progress.status);
                 
-                addLog("info", `Polling tentative ${pollAttempts}/${maxPollAttempts}`, "trace");
+                // Display results
+                dupItems = progress.results || [];
+                displayScanResults();
                 
-                fetch(`/api/duplicates/results?job_id=${jobId}`)
-                    .then(res => {
-                        addLog("info", `Polling réponse HTTP ${res.status}`, "trace");
-                        if (res.ok) {
-                            return res.json();
-                        } else if (res.status === 400) {
-                            // Job not completed yet, retry with backoff
-                            throw new Error("Job not completed");
-                        } else {
-                            throw new Error(`HTTP ${res.status}`);
-                        }
-                    })
-                    .then(data => {
-                        // Success - job completed
-                        dupItems = data.items || [];
-                        displayScanResults();
-                        addLog("info", `Scan doublons terminé : ${dupItems.length} groupes trouvés.`);
-                        addLog("info", `Polling réussi après ${pollAttempts} tentatives`, "debug");
-                    })
-                    .catch(pollErr => {
-                        if (pollErr.message === "Job not completed" && pollAttempts < maxPollAttempts) {
-                            // Calculate exponential backoff delay (max 10 seconds)
-                            const delay = Math.min(basePollDelay * Math.pow(1.5, pollAttempts - 1), 10000);
-                            
-                            addLog("info", `Job non terminé, nouvelle tentative dans ${Math.round(delay/1000)}s (backoff)`, "trace");
-                            
-                            // Update UI to show polling progress
-                            dupTableBody.innerHTML = `
-                                <tr><td colspan="4">
-                                    <span class="spinner"></span> Scan en cours… (tentative ${pollAttempts}/${maxPollAttempts})
-                                </td></tr>
-                            `;
-                            
-                            // Schedule next poll
-                            timeoutId = setTimeout(pollResults, delay);
-                        } else {
-                            // Max attempts reached or other error - clear timeout
-                            if (timeoutId) {
-                                clearTimeout(timeoutId);
-                                timeoutId = null;
-                            }
-                            dupTableBody.innerHTML = `<tr><td colspan="4">Erreur de connexion. Actualise la page et réessaye.</td></tr>`;
-                            if (dupSummaryEl) {
-                                dupSummaryEl.textContent = "Erreur lors du scan des doublons.";
-                            }
-                            resetDupDashboard();
-                            addLog("error", `Erreur lors du scan (polling) : ${pollErr.message}`, "debug");
-                        }
-                    });
-            };
-            
-            // Start polling immediately
-            pollResults();
-        };
-
-        // Timeout after 10 minutes
-        timeoutId = setTimeout(() => {
-            if (eventSource && eventSource.readyState !== EventSource.CLOSED) {
+                addLog("info", `Scan doublons terminé : ${dupItems.length} groupes trouvés.`);
+                if (progress.cache_hits) {
+                    addLog("info", `${progress.cache_hits} hash(s) réutilisé(s) depuis le cache`, "debug");
+                }
+            } else if (progress.status === "paused") {
+                cleanup();
+                setDupScanControls(progress.status);
+                dupTableBody.innerHTML = `
+                    <tr><td colspan="4">
+                        ⏸️ Scan en pause (${progress.processed}/${progress.total_files} fichiers analysés)
+                    </td></tr>
+                `;
+                if (dupSummaryEl) {
+                    dupSummaryEl.textContent = `Scan en pause, ${progress.groups_found} groupes trouvés pour l'instant.`;
+                }
+                addLog("info", `Scan doublons en pause (job ${jobId})`);
+            } else if (progress.status === "cancelled") {
                 cleanup();
+                setDupScanControls(progress.status);
+                dupTableBody.innerHTML = `<tr><td colspan="4">Scan annulé.</td></tr>`;
+                if (dupSummaryEl) {
+                    dupSummaryEl.textContent = "Scan annulé.";
+                }
+                addLog("info", `Scan doublons annulé (job ${jobId})`);
+            } else if (progress.status === "failed") {
+                cleanup();
+                setDupScanControls(progress.status);
+                throw new Error(progress.error || "Scan failed");
+            }
+        } catch (parseErr) {
+            console.error("Failed to parse progress:", parseErr);
+            addLog("info", "Erreur de parsing des progrès du scan", "debug");
+        }
+    };
+
+    eventSource.onerror = (err) => {
+        console.error("SSE error:", err);
+        addLog("info", `EventSource erreur (readyState=${eventSource.readyState}, type=${err.type})`, "trace");
+        cleanup();
+        
+        addLog("info", "SSE non disponible, basculement sur le mode polling...", "trace");
+        
+        // Fallback to polling with exponential backoff
+        let pollAttempts = 0;
+        const maxPollAttempts = 60; // ~6-10 minutes total with exponential backoff
+        const basePollDelay = 2000; // Start with 2 seconds
+        const startTime = Date.now();
+        const globalTimeout = 10 * 60 * 1000; // 10 minutes
+        
+        addLog("info", "Démarrage du mode polling avec backoff exponentiel", "debug");
+        
+        const pollResults = () => {
+            pollAttempts++;
+            const elapsed = Date.now() - startTime;
+            
+            // Check global timeout
+            if (elapsed > globalTimeout) {
+                if (timeoutId) {
+                    clearTimeout(timeoutId);
+                    timeoutId = null;
+                }
                 dupTableBody.innerHTML = `<tr><td colspan="4">Le scan a pris trop de temps. Réessaye.</td></tr>`;
                 if (dupSummaryEl) {
                     dupSummaryEl.textContent = "Timeout lors du scan.";
                 }
                 resetDupDashboard();
-                addLog("error", "Timeout lors du scan des doublons");
+                addLog("error", `Timeout global atteint après ${Math.round(elapsed/1000)}s`, "debug");
+                return;
             }
-        }, 10 * 60 * 1000);
+            
+            addLog("info", `Polling tentative ${pollAttempts}/${maxPollAttempts}`, "trace");
+            
+            fetch(`/api/duplicates/results?job_id=${jobId}`)
+                .then(res => {
+                    addLog("info", `Polling réponse HTTP ${res.status}`, "trace");
+                    if (res.ok) {
+                        return res.json();
+                    } else if (res.status === 400) {
+                        // Job not completed yet, retry with backoff
+                        throw new Error("Job not completed");
+                    } else {
+                        throw new Error(`HTTP ${res.status}`);
+                    }
+                })
+                .then(data => {
+                    // Success - job completed
+                    dupItems = data.items || [];
+                    displayScanResults();
+                    addLog("info", `Scan doublons terminé : ${dupItems.length} groupes trouvés.`);
+                    addLog("info", `Polling réussi après ${pollAttempts} tentatives`, "debug");
+                })
+                .catch(pollErr => {
+                    if (pollErr.message === "Job not completed" && pollAttempts < maxPollAttempts) {
+                        // Calculate exponential backoff delay (max 10 seconds)
+                        const delay = Math.min(basePollDelay * Math.pow(1.5, pollAttempts - 1), 10000);
+                        
+                        addLog("info", `Job non terminé, nouvelle tentative dans ${Math.round(delay/1000)}s (backoff)`, "trace");
+                        
+                        // Update UI to show polling progress
+                        dupTableBody.innerHTML = `
+                            <tr><td colspan="4">
+                                <span class="spinner"></span> Scan en cours… (tentative ${pollAttempts}/${maxPollAttempts})
+                            </td></tr>
+                        `;
+                        
+                        // Schedule next poll
+                        timeoutId = setTimeout(pollResults, delay);
+                    } else {
+                        // Max attempts reached or other error - clear timeout
+                        if (timeoutId) {
+                            clearTimeout(timeoutId);
+                            timeoutId = null;
+                        }
+                        dupTableBody.innerHTML = `<tr><td colspan="4">Erreur de connexion. Actualise la page et réessaye.</td></tr>`;
+                        if (dupSummaryEl) {
+                            dupSummaryEl.textContent = "Erreur lors du scan des doublons.";
+                        }
+                        resetDupDashboard();
+                        addLog("error", `Erreur lors du scan (polling) : ${pollErr.message}`, "debug");
+                    }
+                });
+        };
+        
+        // Start polling immediately
+        pollResults();
+    };
 
+    // Timeout after 10 minutes
+    timeoutId = setTimeout(() => {
+        if (eventSource && eventSource.readyState !== EventSource.CLOSED) {
+            cleanup();
+            dupTableBody.innerHTML = `<tr><td colspan="4">Le scan a pris trop de temps. Réessaye.</td></tr>`;
+            if (dupSummaryEl) {
+                dupSummaryEl.textContent = "Timeout lors du scan.";
+            }
+            resetDupDashboard();
+            addLog("error", "Timeout lors du scan des doublons");
+        }
+    }, 10 * 60 * 1000);
+}
+
+// ---------- HISTORIQUE DES SCANS ----------
+
+const DUP_HISTORY_PAGE_SIZE = 10;
+let dupHistoryOffset = 0;
+
+const DUP_STATUS_LABELS = {
+    running: "⏳ En cours",
+    paused: "⏸️ En pause",
+    completed: "✅ Terminé",
+    failed: "❌ Échec",
+    cancelled: "✖ Annulé"
+};
+
+function formatDuration(seconds) {
+    if (seconds < 60) return `${seconds} s`;
+    const minutes = Math.floor(seconds / 60);
+    if (minutes < 60) return `${minutes} min ${seconds % 60} s`;
+    return `${Math.floor(minutes / 60)} h ${minutes % 60} min`;
+}
+
+async function loadScanHistory(offset = dupHistoryOffset) {
+    const tbody = document.querySelector("#dup-history-table tbody");
+    if (!tbody) return;
+
+    try {
+        const res = await fetch(`/api/duplicates/jobs?limit=${DUP_HISTORY_PAGE_SIZE}&offset=${offset}`);
+        if (!res.ok) throw new Error("HTTP " + res.status);
+        const data = await res.json();
+        dupHistoryOffset = offset;
+
+        const jobs = data.items || [];
+        if (!jobs.length) {
+            tbody.innerHTML = `<tr><td colspan="7">Aucun scan dans l'historique.</td></tr>`;
+        } else {
+            tbody.innerHTML = "";
+            jobs.forEach(job => {
+                const tr = document.createElement("tr");
+                const date = new Date(job.started_at * 1000).toLocaleString();
+                let actions = "";
+                if (job.status === "completed") {
+                    actions += `<button class="btn-secondary small" data-action="view">👁️ Voir</button> `;
+                } else if (job.status === "paused") {
+                    actions += `<button class="btn-secondary small" data-action="resume">▶️ Reprendre</button> `;
+                }
+                if (job.status !== "running") {
+                    actions += `<button class="btn-secondary small" data-action="delete">🗑️</button>`;
+                }
+                tr.innerHTML = `
+                    <td>${escapeHtml(date)}</td>
+                    <td title="${escapeHtml(job.error || "")}">${DUP_STATUS_LABELS[job.status] || escapeHtml(job.status)}</td>
+                    <td>${job.total_files}</td>
+                    <td>${job.groups_found}</td>
+                    <td>${escapeHtml(job.reclaimable_human)}</td>
+                    <td>${formatDuration(job.duration_seconds)}</td>
+                    <td>${actions}</td>
+                `;
+                tr.querySelectorAll("button[data-action]").forEach(btn => {
+                    btn.addEventListener("click", () => onHistoryAction(btn.dataset.action, job.job_id));
+                });
+                tbody.appendChild(tr);
+            });
+        }
+
+        const page = Math.floor(offset / DUP_HISTORY_PAGE_SIZE) + 1;
+        const pages = Math.max(1, Math.ceil(data.total / DUP_HISTORY_PAGE_SIZE));
+        const pageEl = document.getElementById("dup-history-page");
+        if (pageEl) pageEl.textContent = `Page ${page} / ${pages} (${data.total} scans)`;
+        const prev = document.getElementById("btn-dup-history-prev");
+        const next = document.getElementById("btn-dup-history-next");
+        if (prev) prev.disabled = offset === 0;
+        if (next) next.disabled = offset + DUP_HISTORY_PAGE_SIZE >= data.total;
     } catch (err) {
-        cleanup();
-        dupTableBody.innerHTML = `<tr><td colspan="4">Erreur lors du scan.</td></tr>`;
+        tbody.innerHTML = `<tr><td colspan="7">Erreur lors du chargement de l'historique.</td></tr>`;
+        addLog("error", `Erreur historique des scans : ${err.message}`);
+    }
+}
+
+async function onHistoryAction(action, jobId) {
+    try {
+        if (action === "view") {
+            const res = await fetch(`/api/duplicates/results?job_id=${jobId}`);
+            if (!res.ok) throw new Error("HTTP " + res.status);
+            const data = await res.json();
+            dupItems = data.items || [];
+            displayScanResults();
+            addLog("info", `Résultats du scan ${jobId} chargés`, "debug");
+        } else if (action === "resume") {
+            dupJobId = jobId;
+            await resumeScan();
+        } else if (action === "delete") {
+            if (!confirm("Supprimer ce scan et ses résultats de l'historique ?")) return;
+            const res = await fetch(`/api/duplicates/jobs/${jobId}`, { method: "DELETE" });
+            if (!res.ok) {
+                const data = await res.json().catch(() => ({}));
+                throw new Error(data.error || "HTTP " + res.status);
+            }
+            addLog("info", `Scan ${jobId} supprimé de l'historique`);
+            loadScanHistory();
+        }
+    } catch (err) {
+        addLog("error", `Erreur historique des scans : ${err.message}`);
+    }
+}
+
+async function pruneScanHistory() {
+    if (!confirm("Supprimer les scans terminés de plus de 30 jours ?")) return;
+    try {
+        const res = await fetch("/api/duplicates/jobs/prune", {
+            method: "POST",
+            headers: { "Content-Type": "application/json" },
+            body: JSON.stringify({ older_than_days: 30 })
+        });
+        if (!res.ok) throw new Error("HTTP " + res.status);
+        const data = await res.json();
+        addLog("info", `${data.deleted} scan(s) supprimé(s) de l'historique`);
+        loadScanHistory(0);
+    } catch (err) {
+        addLog("error", `Erreur purge de l'historique : ${err.message}`);
+    }
+}
+
+function formatTimestamp(ts) {
+    return ts ? new Date(ts * 1000).toLocaleString() : "—";
+}
+
+async function loadSchedules() {
+    const tbody = document.querySelector("#dup-schedules-table tbody");
+    if (!tbody) return;
+
+    try {
+        const res = await fetch("/api/schedules");
+        if (!res.ok) throw new Error("HTTP " + res.status);
+        const data = await res.json();
+
+        const schedules = data.items || [];
+        if (!schedules.length) {
+            tbody.innerHTML = `<tr><td colspan="6">Aucun scan planifié.</td></tr>`;
+            return;
+        }
+
+        tbody.innerHTML = "";
+        schedules.forEach(schedule => {
+            const tr = document.createElement("tr");
+            tr.innerHTML = `
+                <td>${escapeHtml(schedule.name)}</td>
+                <td><code>${escapeHtml(schedule.cron)}</code></td>
+                <td>${escapeHtml(schedule.enabled ? formatTimestamp(schedule.next_run_at) : "—")}</td>
+                <td>${escapeHtml(formatTimestamp(schedule.last_run_at))}</td>
+                <td><input type="checkbox" data-action="toggle" ${schedule.enabled ? "checked" : ""}></td>
+                <td><button class="btn-secondary small" data-action="delete">🗑️</button></td>
+            `;
+            tr.querySelector("[data-action=toggle]").addEventListener("change", e => {
+                updateSchedule(schedule.id, { enabled: e.target.checked });
+            });
+            tr.querySelector("[data-action=delete]").addEventListener("click", () => deleteSchedule(schedule));
+            tbody.appendChild(tr);
+        });
+    } catch (err) {
+        tbody.innerHTML = `<tr><td colspan="6">Erreur lors du chargement des planifications.</td></tr>`;
+        addLog("error", `Erreur scans planifiés : ${err.message}`);
+    }
+}
+
+async function createSchedule() {
+    const nameInput = document.getElementById("dup-schedule-name");
+    const cronInput = document.getElementById("dup-schedule-cron");
+    const name = nameInput ? nameInput.value.trim() : "";
+    const cron = cronInput ? cronInput.value.trim() : "";
+    if (!name || !cron) {
+        addLog("warning", "Indiquez un nom et une expression cron pour planifier un scan");
+        return;
+    }
+
+    try {
+        const res = await fetch("/api/schedules", {
+            method: "POST",
+            headers: { "Content-Type": "application/json" },
+            body: JSON.stringify({ name, cron, options: dupScanOptions() })
+        });
+        const data = await res.json().catch(() => ({}));
+        if (!res.ok) throw new Error(data.error || "HTTP " + res.status);
+        addLog("info", `Scan planifié « ${data.name} » créé (prochain : ${formatTimestamp(data.next_run_at)})`);
+        if (nameInput) nameInput.value = "";
+        if (cronInput) cronInput.value = "";
+        loadSchedules();
+    } catch (err) {
+        addLog("error", `Erreur planification : ${err.message}`);
+    }
+}
+
+async function updateSchedule(id, changes) {
+    try {
+        const res = await fetch(`/api/schedules/${id}`, {
+            method: "PUT",
+            headers: { "Content-Type": "application/json" },
+            body: JSON.stringify(changes)
+        });
+        const data = await res.json().catch(() => ({}));
+        if (!res.ok) throw new Error(data.error || "HTTP " + res.status);
+    } catch (err) {
+        addLog("error", `Erreur planification : ${err.message}`);
+    }
+    loadSchedules();
+}
+
+async function deleteSchedule(schedule) {
+    if (!confirm(`Supprimer le scan planifié « ${schedule.name} » ?`)) return;
+    try {
+        const res = await fetch(`/api/schedules/${schedule.id}`, { method: "DELETE" });
+        if (!res.ok) throw new Error("HTTP " + res.status);
+        addLog("info", `Scan planifié « ${schedule.name} » supprimé`);
+        loadSchedules();
+    } catch (err) {
+        addLog("error", `Erreur planification : ${err.message}`);
+    }
+}
+
+const RULE_MASTER_LABELS = {
+    "": "Choisi par le scan",
+    "oldest-mtime": "Plus ancien",
+    "newest-mtime": "Plus récent",
+    "most-links": "Le plus de hardlinks",
+    "shortest-path": "Chemin le plus court"
+};
+
+function formatBytes(bytes) {
+    const units = ["o", "Ko", "Mo", "Go", "To"];
+    let value = bytes;
+    let unit = 0;
+    while (value >= 1024 && unit < units.length - 1) {
+        value /= 1024;
+        unit++;
+    }
+    return unit === 0 ? `${value} ${units[0]}` : `${value.toFixed(1)} ${units[unit]}`;
+}
+
+function describeRule(rule) {
+    const parts = [];
+    if (rule.roots && rule.roots.length) parts.push(rule.roots.join(", "));
+    if (rule.min_size) parts.push(`≥ ${formatBytes(rule.min_size)}`);
+    if (rule.max_size) parts.push(`≤ ${formatBytes(rule.max_size)}`);
+    if (rule.extensions && rule.extensions.length) parts.push(rule.extensions.join(", "));
+    if (rule.exclude && rule.exclude.length) parts.push(`sauf ${rule.exclude.join(", ")}`);
+    return parts.length ? parts.join(" · ") : "Tous les doublons";
+}
+
+async function loadRules() {
+    const tbody = document.querySelector("#dup-rules-table tbody");
+    if (!tbody) return;
+
+    try {
+        const res = await fetch("/api/duplicates/rules");
+        if (!res.ok) throw new Error("HTTP " + res.status);
+        const data = await res.json();
+
+        const rules = data.items || [];
+        if (!rules.length) {
+            tbody.innerHTML = `<tr><td colspan="6">Aucune règle.</td></tr>`;
+            return;
+        }
+
+        tbody.innerHTML = "";
+        rules.forEach(rule => {
+            const tr = document.createElement("tr");
+            tr.innerHTML = `
+                <td>${escapeHtml(rule.name)}</td>
+                <td>${escapeHtml(describeRule(rule))}</td>
+                <td>${escapeHtml(RULE_MASTER_LABELS[rule.master || ""] || rule.master)}</td>
+                <td><input type="checkbox" data-field="enabled" ${rule.enabled ? "checked" : ""}></td>
+                <td><input type="checkbox" data-field="auto_apply" ${rule.auto_apply ? "checked" : ""}></td>
+                <td><button class="btn-secondary small" data-action="delete">🗑️</button></td>
+            `;
+            tr.querySelectorAll("input[data-field]").forEach(input => {
+                input.addEventListener("change", () => updateRule(rule.id, { [input.dataset.field]: input.checked }));
+            });
+            tr.querySelector("[data-action=delete]").addEventListener("click", () => deleteRule(rule));
+            tbody.appendChild(tr);
+        });
+    } catch (err) {
+        tbody.innerHTML = `<tr><td colspan="6">Erreur lors du chargement des règles.</td></tr>`;
+        addLog("error", `Erreur règles de conversion : ${err.message}`);
+    }
+}
+
+async function createRule() {
+    const value = id => {
+        const el = document.getElementById(id);
+        return el ? el.value.trim() : "";
+    };
+    const name = value("dup-rule-name");
+    if (!name) {
+        addLog("warning", "Indiquez un nom pour la règle");
+        return;
+    }
+    const autoEl = document.getElementById("dup-rule-auto");
+    const rule = {
+        name,
+        roots: splitList(value("dup-rule-roots")),
+        min_size: parseSizeInput(value("dup-rule-min-size")),
+        extensions: splitList(value("dup-rule-extensions")),
+        master: value("dup-rule-master"),
+        auto_apply: !!(autoEl && autoEl.checked)
+    };
+    if (rule.auto_apply && !confirm("Les doublons correspondant à cette règle seront convertis sans confirmation après chaque scan. Continuer ?")) return;
+
+    try {
+        const res = await fetch("/api/duplicates/rules", {
+            method: "POST",
+            headers: { "Content-Type": "application/json" },
+            body: JSON.stringify(rule)
+        });
+        const data = await res.json().catch(() => ({}));
+        if (!res.ok) throw new Error(data.error || "HTTP " + res.status);
+        addLog("info", `Règle « ${data.name} » créée`);
+        ["dup-rule-name", "dup-rule-roots", "dup-rule-min-size", "dup-rule-extensions"].forEach(id => {
+            const el = document.getElementById(id);
+            if (el) el.value = "";
+        });
+        if (autoEl) autoEl.checked = false;
+        loadRules();
+    } catch (err) {
+        addLog("error", `Erreur règles de conversion : ${err.message}`);
+    }
+}
+
+async function updateRule(id, changes) {
+    try {
+        const res = await fetch(`/api/duplicates/rules/${id}`, {
+            method: "PUT",
+            headers: { "Content-Type": "application/json" },
+            body: JSON.stringify(changes)
+        });
+        const data = await res.json().catch(() => ({}));
+        if (!res.ok) throw new Error(data.error || "HTTP " + res.status);
+    } catch (err) {
+        addLog("error", `Erreur règles de conversion : ${err.message}`);
+    }
+    loadRules();
+}
+
+async function deleteRule(rule) {
+    if (!confirm(`Supprimer la règle « ${rule.name} » ?`)) return;
+    try {
+        const res = await fetch(`/api/duplicates/rules/${rule.id}`, { method: "DELETE" });
+        if (!res.ok) throw new Error("HTTP " + res.status);
+        addLog("info", `Règle « ${rule.name} » supprimée`);
+        loadRules();
+    } catch (err) {
+        addLog("error", `Erreur règles de conversion : ${err.message}`);
+    }
+}
+
+async function evaluateRules(apply) {
+    if (apply && !confirm("Convertir en hardlinks tous les doublons du dernier scan correspondant aux règles actives ?")) return;
+    const reportEl = document.getElementById("dup-rules-report");
+
+    try {
+        const res = await fetch("/api/duplicates/rules/evaluate", {
+            method: "POST",
+            headers: { "Content-Type": "application/json" },
+            body: JSON.stringify({ apply })
+        });
+        const data = await res.json().catch(() => ({}));
+        if (!res.ok) throw new Error(data.error || "HTTP " + res.status);
+
+        let summary = `${data.groups} groupe(s) correspondant aux règles, ${formatBytes(data.reclaimable)} récupérables`;
+        if (!data.dry_run) {
+            summary += ` — ${data.converted} fichier(s) converti(s), ${formatBytes(data.bytes_saved)} libérés`;
+        }
+        if (reportEl) {
+            reportEl.innerHTML = escapeHtml(summary) + "<br>" + (data.matches || []).slice(0, 20).map(m =>
+                `${escapeHtml(m.rule)} : ${escapeHtml(m.master)} ← ${m.others.map(escapeHtml).join(", ")}` +
+                (m.errors && m.errors.length ? ` ⚠️ ${escapeHtml(m.errors.join("; "))}` : "")
+            ).join("<br>");
+        }
+        addLog("info", summary);
+    } catch (err) {
+        addLog("error", `Erreur règles de conversion : ${err.message}`);
+    }
+}
+
+// loadLatestResults shows the results of the last completed scan, typically a
+// scheduled one that ran overnight
+async function loadLatestResults() {
+    try {
+        const res = await fetch("/api/duplicates/latest");
+        if (res.status === 404) return;
+        if (!res.ok) throw new Error("HTTP " + res.status);
+        const data = await res.json();
+        if (dupJobId || dupItems.length) return;
+        dupItems = data.items || [];
+        displayScanResults();
+        addLog("info", `Derniers résultats chargés (scan du ${formatTimestamp(data.completed_at)})`, "debug");
+    } catch (err) {
+        addLog("error", `Erreur chargement des derniers résultats : ${err.message}`);
+    }
+}
+
+async function pauseScan() {
+    if (!dupJobId) return;
+    try {
+        const res = await fetch(`/api/duplicates/scan/${dupJobId}/pause`, { method: "POST" });
+        if (!res.ok) {
+            const data = await res.json().catch(() => ({}));
+            throw new Error(data.error || "HTTP " + res.status);
+        }
+        addLog("info", "Mise en pause du scan demandée…", "debug");
+    } catch (err) {
+        addLog("error", `Erreur pause scan : ${err.message}`);
+    }
+}
+
+async function resumeScan() {
+    if (!dupJobId) return;
+    try {
+        const res = await fetch(`/api/duplicates/scan/${dupJobId}/resume`, { method: "POST" });
+        if (!res.ok) {
+            const data = await res.json().catch(() => ({}));
+            throw new Error(data.error || "HTTP " + res.status);
+        }
+        addLog("info", `Reprise du scan (job ${dupJobId})`);
+        showDupScanRunning();
+        watchScan(dupJobId);
+    } catch (err) {
+        addLog("error", `Erreur reprise scan : ${err.message}`);
+    }
+}
+
+async function cancelScan() {
+    if (!dupJobId) return;
+    if (!confirm("Annuler le scan en cours ? La progression sera perdue.")) return;
+    try {
+        const res = await fetch(`/api/duplicates/scan/${dupJobId}`, { method: "DELETE" });
+        if (!res.ok) {
+            const data = await res.json().catch(() => ({}));
+            throw new Error(data.error || "HTTP " + res.status);
+        }
+        // A paused job has no progress stream to report the cancellation
+        setDupScanControls("cancelled");
+        dupTableBody.innerHTML = `<tr><td colspan="4">Scan annulé.</td></tr>`;
         if (dupSummaryEl) {
-            dupSummaryEl.textContent = "Erreur lors du scan des doublons.";
+            dupSummaryEl.textContent = "Scan annulé.";
         }
-        resetDupDashboard();
-        addLog("error", `Erreur scan doublons : ${err.message}`);
+    } catch (err) {
+        addLog("error", `Erreur annulation scan : ${err.message}`);
     }
 }
 
@@ -1650,13 +2668,19 @@ function displayScanResults() {
     dupTableBody.innerHTML = "";
     dupItems.forEach((item, idx) => {
         const othersHtml = item.others.map(p => `- ${escapeHtml(p)}`).join("<br>");
+        // The master can be overridden per group with any of its files
+        const masterOptions = [item.master, ...item.others]
+            .map(p => `<option value="${escapeHtml(p)}">${escapeHtml(p)}</option>`)
+            .join("");
         const tr = document.createElement("tr");
         tr.innerHTML = `
             <td class="col-select">
                 <input type="checkbox" class="dup-checkbox" data-index="${idx}">
             </td>
             <td>${escapeHtml(item.size_human)}</td>
-            <td title="${escapeHtml(item.master)}">${escapeHtml(item.master)}</td>
+            <td title="${escapeHtml(item.master)}">
+                <select class="dup-master-select" data-index="${idx}" style="max-width:100%;">${masterOptions}</select>
+            </td>
             <td title="${escapeHtml(item.others.join(' | '))}">${othersHtml}</td>
         `;
         dupTableBody.appendChild(tr);
@@ -1669,10 +2693,12 @@ function displayScanResults() {
     setupTooltipsForTable(dupTableBody);
 }
 
-async function convertDuplicates() {
+// Returns the indexes of the checked duplicate groups, or null after telling
+// the user why there is nothing to convert
+function checkedDupIndexes() {
     if (!dupItems.length) {
         showModal("warning", "Scan requis", "Pas de doublons chargés. Lance d'abord un scan.");
-        return;
+        return null;
     }
     const checked = Array.from(document.querySelectorAll(".dup-checkbox"))
         .filter(cb => cb.checked)
@@ -1681,16 +2707,63 @@ async function convertDuplicates() {
 
     if (!checked.length) {
         showModal("warning", "Sélection requise", "Sélectionne au moins un groupe de doublons.");
-        return;
+        return null;
     }
+    return checked;
+}
 
-    const groups = checked.map(i => {
+// Builds the conversion groups of the checked results with their selected master
+function dupConvertGroups(checked) {
+    return checked.map(i => {
         const item = dupItems[i];
+        const select = document.querySelector(`.dup-master-select[data-index="${i}"]`);
+        const master = select ? select.value : item.master;
         return {
-            master: item.master,
-            others: item.others
+            master,
+            others: [item.master, ...item.others].filter(p => p !== master),
+            // The server verifies with the scan's policy when it is stronger
+            verify: item.verify
         };
     });
+}
+
+// Runs every check of the conversion without touching the files
+async function simulateConversion() {
+    const checked = checkedDupIndexes();
+    if (!checked) return;
+    const groups = dupConvertGroups(checked);
+
+    showLoadingOverlay("Simulation en cours...");
+    try {
+        const res = await fetch("/api/duplicates/convert", {
+            method: "POST",
+            headers: { "Content-Type": "application/json" },
+            body: JSON.stringify({ groups, verify: dupVerifyPolicy(), metadata: dupMetadataPolicy(), dry_run: true })
+        });
+        const data = await res.json();
+        hideLoadingOverlay();
+        if (!data.ok) throw new Error(data.error || "Erreur simulation");
+
+        logConversionFiles(data.files);
+        const failed = (data.files || []).filter(f => f.status === "failed");
+        failed.forEach(f => addLog("error", `Échec prévu : ${f.error}`));
+
+        let msg = `Fichiers convertibles : ${data.planned}\nEspace économisable : ${formatBytes(data.bytes_saved)}`;
+        if (data.skipped) msg += `\nFichiers ignorés (métadonnées différentes) : ${data.skipped}`;
+        if (failed.length) msg += `\nÉchecs prévus : ${failed.length} (voir le journal)`;
+        addLog("info", `🧪 Simulation : ${data.planned} fichiers convertibles, ${failed.length} échecs`, "minimal");
+        showModal(failed.length ? "warning" : "success", "Simulation de la conversion", msg + "\n\nAucun fichier n'a été modifié.");
+    } catch (err) {
+        hideLoadingOverlay();
+        addLog("error", `Erreur simulation : ${err.message}`, "minimal");
+        showModal("error", "Erreur de simulation", err.message);
+    }
+}
+
+async function convertDuplicates() {
+    const checked = checkedDupIndexes();
+    if (!checked) return;
+    const groups = dupConvertGroups(checked);
 
     // Calculate potential space saved
     let potentialBytesSaved = 0;
@@ -1707,26 +2780,21 @@ async function convertDuplicates() {
         "⚠️ Attention : Opération irréversible !",
         `Convertir ${groups.length} groupes de doublons en hardlinks ?\nEspace potentiellement économisable : ${savedText}`,
         async () => {
-            // Show loading overlay during conversion
-            showLoadingOverlay("Conversion en cours...");
             addLog("info", "🔄 Début de la conversion des doublons...", "minimal");
             
             try {
-                const res = await fetch("/api/duplicates/convert", {
-                    method: "POST",
-                    headers: { "Content-Type": "application/json" },
-                    body: JSON.stringify({ groups })
-                });
-                const data = await res.json();
-                
-                // Hide loading overlay before showing result
-                hideLoadingOverlay();
+                // The job shows its progress in the loading overlay
+                const data = await runJob("/api/duplicates/convert",
+                    { groups, verify: dupVerifyPolicy(), metadata: dupMetadataPolicy() },
+                    "Conversion en cours...");
                 
                 // FIX BUG #4: Check if conversion actually succeeded
                 if (!data.ok) {
                     throw new Error(data.error || "Erreur conversion doublons");
                 }
 
+                logConversionFiles(data.files);
+
                 // Check if no hardlinks were created despite "ok: true"
                 if (data.created === 0) {
                     let errorMsg = "Aucun hardlink n'a été créé.";
@@ -1748,6 +2816,9 @@ async function convertDuplicates() {
                 const savedDisplay = savedGb >= 1 ? `${savedGb.toFixed(2)} Go` : `${savedMb.toFixed(1)} Mo`;
 
                 let successMsg = `Hardlinks créés : ${data.created}\nEspace économisé : ${savedDisplay}`;
+                if (data.skipped) {
+                    successMsg += `\nFichiers ignorés (métadonnées différentes) : ${data.skipped}`;
+                }
                 
                 // Show warnings if some failed
                 if (data.errors && data.errors.length > 0) {
@@ -1771,7 +2842,35 @@ async function convertDuplicates() {
 }
 
 if (btnDupScan) btnDupScan.addEventListener("click", scanDuplicates);
+if (btnDupPause) btnDupPause.addEventListener("click", pauseScan);
+if (btnDupResume) btnDupResume.addEventListener("click", resumeScan);
+if (btnDupCancel) btnDupCancel.addEventListener("click", cancelScan);
+
+const btnDupHistoryRefresh = document.getElementById("btn-dup-history-refresh");
+const btnDupHistoryPrune = document.getElementById("btn-dup-history-prune");
+const btnDupHistoryPrev = document.getElementById("btn-dup-history-prev");
+const btnDupHistoryNext = document.getElementById("btn-dup-history-next");
+if (btnDupHistoryRefresh) btnDupHistoryRefresh.addEventListener("click", () => loadScanHistory());
+if (btnDupHistoryPrune) btnDupHistoryPrune.addEventListener("click", pruneScanHistory);
+if (btnDupHistoryPrev) btnDupHistoryPrev.addEventListener("click", () => loadScanHistory(Math.max(0, dupHistoryOffset - DUP_HISTORY_PAGE_SIZE)));
+if (btnDupHistoryNext) btnDupHistoryNext.addEventListener("click", () => loadScanHistory(dupHistoryOffset + DUP_HISTORY_PAGE_SIZE));
+loadScanHistory(0);
+
+const btnDupScheduleAdd = document.getElementById("btn-dup-schedule-add");
+if (btnDupScheduleAdd) btnDupScheduleAdd.addEventListener("click", createSchedule);
+loadSchedules();
+loadLatestResults();
+
+const btnDupRuleAdd = document.getElementById("btn-dup-rule-add");
+const btnDupRulesSimulate = document.getElementById("btn-dup-rules-simulate");
+const btnDupRulesApply = document.getElementById("btn-dup-rules-apply");
+if (btnDupRuleAdd) btnDupRuleAdd.addEventListener("click", createRule);
+if (btnDupRulesSimulate) btnDupRulesSimulate.addEventListener("click", () => evaluateRules(false));
+if (btnDupRulesApply) btnDupRulesApply.addEventListener("click", () => evaluateRules(true));
+loadRules();
 if (btnDupConvert) btnDupConvert.addEventListener("click", convertDuplicates);
+const btnDupSimulate = document.getElementById("btn-dup-simulate");
+if (btnDupSimulate) btnDupSimulate.addEventListener("click", simulateConversion);
 
 // Select all / Deselect all for duplicates
 const btnDupSelectAll = document.getElementById("btn-dup-select-all");
@@ -1894,4 +2993,178 @@ document.addEventListener("DOMContentLoaded", () => {
         if (hlDestSelectedEl) hlDestSelectedEl.textContent = "(racine " + ROOT_LABEL + ")";
     }
     resetDupDashboard();
-});
\ No newline at end of file
+});
+
+// ===============================
+// OPERATIONS JOURNAL
+// ===============================
+
+const OPERATION_LABELS = {
+    link: "🔗 Hardlink créé",
+    replace: "♻️ Doublon converti",
+    unlink: "🗑️ Hardlink supprimé",
+    rename: "✏️ Renommage"
+};
+
+async function loadOperations() {
+    const tbody = document.querySelector("#operations-table tbody");
+    if (!tbody) return;
+
+    try {
+        const res = await fetch("/api/operations?limit=50");
+        if (!res.ok) throw new Error("HTTP " + res.status);
+        const data = await res.json();
+
+        const ops = data.items || [];
+        if (!ops.length) {
+            tbody.innerHTML = `<tr><td colspan="6">Aucune opération.</td></tr>`;
+            return;
+        }
+
+        tbody.innerHTML = "";
+        ops.forEach(op => {
+            const tr = document.createElement("tr");
+            const action = op.undone_at
+                ? `<span class="text-muted">Annulée</span>`
+                : `<button class="btn-secondary small" data-action="undo">↩️ Annuler</button>`;
+            tr.innerHTML = `
+                <td>${escapeHtml(formatTimestamp(op.created_at))}</td>
+                <td>${OPERATION_LABELS[op.kind] || escapeHtml(op.kind)}</td>
+                <td>${escapeHtml(op.path)}</td>
+                <td>${escapeHtml(op.target || "")}</td>
+                <td>${escapeHtml(op.username || "")}</td>
+                <td>${action}</td>
+            `;
+            const btn = tr.querySelector("button[data-action=undo]");
+            if (btn) btn.addEventListener("click", () => undoOperation(op));
+            tbody.appendChild(tr);
+        });
+    } catch (err) {
+        tbody.innerHTML = `<tr><td colspan="6">Erreur lors du chargement du journal.</td></tr>`;
+        addLog("error", `Erreur journal des opérations : ${err.message}`);
+    }
+}
+
+async function undoOperation(op) {
+    if (!confirm(`Annuler l'opération « ${OPERATION_LABELS[op.kind] || op.kind} » sur ${op.path} ?`)) return;
+
+    try {
+        const res = await fetch(`/api/operations/${op.id}/undo`, { method: "POST" });
+        const data = await res.json().catch(() => ({}));
+        if (!res.ok) throw new Error(data.error || "HTTP " + res.status);
+        addLog("info", `Opération annulée : ${op.path}`, "minimal");
+        loadOperations();
+    } catch (err) {
+        addLog("error", `Impossible d'annuler l'opération : ${err.message}`, "minimal");
+    }
+}
+
+const btnOperationsRefresh = document.getElementById("btn-operations-refresh");
+if (btnOperationsRefresh) btnOperationsRefresh.addEventListener("click", loadOperations);
+loadOperations();
+
+// ===============================
+// TRASH
+// ===============================
+
+async function loadTrash() {
+    const tbody = document.querySelector("#trash-table tbody");
+    const summary = document.getElementById("trash-summary");
+    if (!tbody) return;
+
+    try {
+        const res = await fetch("/api/trash?limit=100");
+        if (!res.ok) throw new Error("HTTP " + res.status);
+        const data = await res.json();
+
+        if (summary) {
+            let text = `${data.total} élément(s), ${formatBytes(data.size)}`;
+            if (!data.enabled) text += " · désactivée";
+            else if (data.retention_days) text += ` · vidée après ${data.retention_days} jours`;
+            summary.textContent = text;
+        }
+
+        const items = data.items || [];
+        if (!items.length) {
+            tbody.innerHTML = `<tr><td colspan="5">La corbeille est vide.</td></tr>`;
+            return;
+        }
+
+        tbody.innerHTML = "";
+        items.forEach(item => {
+            const tr = document.createElement("tr");
+            tr.innerHTML = `
+                <td>${escapeHtml(formatTimestamp(item.deleted_at))}</td>
+                <td title="${escapeHtml(item.trash_path)}">${item.is_dir ? "📁" : "📄"} ${escapeHtml(item.path)}</td>
+                <td>${escapeHtml(formatBytes(item.size))}</td>
+                <td>${escapeHtml(item.username || "")}</td>
+                <td>
+                    <button class="btn-secondary small" data-action="restore">↩️ Restaurer</button>
+                    <button class="btn-danger small" data-action="delete">✖</button>
+                </td>
+            `;
+            tr.querySelector("button[data-action=restore]").addEventListener("click", () => restoreTrashItem(item));
+            tr.querySelector("button[data-action=delete]").addEventListener("click", () => deleteTrashItem(item));
+            tbody.appendChild(tr);
+        });
+    } catch (err) {
+        tbody.innerHTML = `<tr><td colspan="5">Erreur lors du chargement de la corbeille.</td></tr>`;
+        addLog("error", `Erreur corbeille : ${err.message}`);
+    }
+}
+
+async function restoreTrashItem(item) {
+    try {
+        const res = await fetch(`/api/trash/${item.id}/restore`, { method: "POST" });
+        const data = await res.json().catch(() => ({}));
+        if (!res.ok) throw new Error(data.error || "HTTP " + res.status);
+        addLog("success", `Restauré : ${item.path}`, "minimal");
+        loadTrash();
+        loadFolder(currentPath);
+    } catch (err) {
+        addLog("error", `Impossible de restaurer : ${err.message}`, "minimal");
+        showModal("error", "Restauration impossible", err.message);
+    }
+}
+
+function deleteTrashItem(item) {
+    showConfirmModal(
+        "⚠️ Supprimer définitivement ?",
+        `${item.path}\n${formatBytes(item.size)}\n\nCette action est irréversible.`,
+        async () => {
+            try {
+                const res = await fetch(`/api/trash/${item.id}`, { method: "DELETE" });
+                const data = await res.json().catch(() => ({}));
+                if (!res.ok) throw new Error(data.error || "HTTP " + res.status);
+                addLog("success", `Supprimé définitivement : ${item.path}`, "minimal");
+                loadTrash();
+            } catch (err) {
+                addLog("error", `Erreur suppression : ${err.message}`, "minimal");
+            }
+        }
+    );
+}
+
+function emptyTrash() {
+    showConfirmModal(
+        "⚠️ Vider la corbeille ?",
+        "Tous les éléments de la corbeille seront supprimés définitivement.\n\nCette action est irréversible.",
+        async () => {
+            try {
+                const res = await fetch("/api/trash/empty", { method: "POST" });
+                const data = await res.json().catch(() => ({}));
+                if (!res.ok) throw new Error(data.error || "HTTP " + res.status);
+                addLog("success", `Corbeille vidée : ${data.deleted} élément(s), ${formatBytes(data.freed_bytes)} libérés`, "minimal");
+                loadTrash();
+            } catch (err) {
+                addLog("error", `Erreur vidage corbeille : ${err.message}`, "minimal");
+            }
+        }
+    );
+}
+
+const btnTrashRefresh = document.getElementById("btn-trash-refresh");
+if (btnTrashRefresh) btnTrashRefresh.addEventListener("click", loadTrash);
+const btnTrashEmpty = document.getElementById("btn-trash-empty");
+if (btnTrashEmpty) btnTrashEmpty.addEventListener("click", emptyTrash);
+loadTrash();